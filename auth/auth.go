@@ -114,21 +114,7 @@ var ErrInvalidToken = httperror.Error{Message: "invalid token"}
 func DeepSourceTokenMiddleware(runnerID string, verifier *jwtutil.Verifier) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			authrorization := c.Request().Header.Get("Authorization")
-			parts := strings.SplitN(authrorization, " ", 2)
-			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
-				slog.Error("not a valid bearer token", slog.Any("header", authrorization))
-				return c.JSON(http.StatusUnauthorized, ErrInvalidToken)
-			}
-
-			claims, err := verifier.Verify(parts[1])
-			if err != nil {
-				slog.Error("failed to verify token", slog.Any("err", err))
-				return c.JSON(http.StatusUnauthorized, ErrInvalidToken)
-			}
-
-			if claims["runner_id"] != runnerID {
-				slog.Error("runner id mismatch", slog.Any("runner-id", claims["runner-id"]))
+			if err := VerifyDeepSourceToken(runnerID, verifier, c.Request().Header.Get("Authorization")); err != nil {
 				return c.JSON(http.StatusUnauthorized, ErrInvalidToken)
 			}
 
@@ -137,3 +123,30 @@ func DeepSourceTokenMiddleware(runnerID string, verifier *jwtutil.Verifier) echo
 		}
 	}
 }
+
+var (
+	errInvalidBearer  = errors.New("not a valid bearer token")
+	errRunnerMismatch = errors.New("runner id mismatch")
+)
+
+// VerifyDeepSourceToken verifies that the bearer token of an Authorization
+// header is a DeepSource token for the runner.
+func VerifyDeepSourceToken(runnerID string, verifier *jwtutil.Verifier, authorization string) error {
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		slog.Error("not a valid bearer token", slog.Any("header", authorization))
+		return errInvalidBearer
+	}
+
+	claims, err := verifier.Verify(parts[1])
+	if err != nil {
+		slog.Error("failed to verify token", slog.Any("err", err))
+		return err
+	}
+
+	if claims["runner_id"] != runnerID {
+		slog.Error("runner id mismatch", slog.Any("runner-id", claims["runner-id"]))
+		return errRunnerMismatch
+	}
+	return nil
+}
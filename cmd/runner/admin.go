@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/deepsourcecorp/runner/config"
+	runnermiddleware "github.com/deepsourcecorp/runner/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminMiddleware returns the middleware guarding the operator endpoints.
+func AdminMiddleware(c *config.Config) echo.MiddlewareFunc {
+	token := ""
+	if c.Admin != nil {
+		token = c.Admin.Token
+	}
+	return runnermiddleware.AdminTokenMiddleware(token)
+}
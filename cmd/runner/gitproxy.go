@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/gitproxy"
+	"github.com/deepsourcecorp/runner/orchestrator"
+)
+
+// GetGitProxy returns the git smart-HTTP proxy, or nil when it is disabled.
+func GetGitProxy(_ context.Context, c *config.Config, provider gitproxy.Provider, client *http.Client) (*gitproxy.Facade, error) {
+	if c.GitProxy == nil || !c.GitProxy.Enabled {
+		return nil, nil
+	}
+
+	opts := &gitproxy.Opts{
+		RunnerID:   c.Runner.ID,
+		ServiceURL: c.GitProxy.ServiceURL,
+		Signer:     jwtutil.NewSigner(c.Runner.PrivateKey),
+		Verifier:   jwtutil.NewVerifier(&c.Runner.PrivateKey.PublicKey),
+		Provider:   provider,
+	}
+	return gitproxy.New(opts, client)
+}
+
+// CloneProvider returns the provider generating remote URLs for fetch-only
+// jobs.  It is nil when the git proxy is disabled, so that the orchestrator
+// falls back to the VCS provider.
+func CloneProvider(gitProxy *gitproxy.Facade) orchestrator.Provider {
+	if gitProxy == nil {
+		return nil
+	}
+	return gitProxy.RemoteURLProvider
+}
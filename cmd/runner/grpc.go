@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/grpcapi"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+)
+
+// grpcStopGrace is how long calls in flight, like Watch streams, may take
+// to finish once the runner is stopped.
+const grpcStopGrace = 30 * time.Second
+
+// GetGRPC returns the gRPC server of the task API, or nil when it is
+// disabled.
+func GetGRPC(_ context.Context, c *config.Config, orchestrator grpcapi.Orchestrator) (*grpc.Server, error) {
+	if c.GRPC == nil || !c.GRPC.Enabled {
+		return nil, nil
+	}
+	return grpcapi.New(&grpcapi.Opts{
+		Orchestrator:  orchestrator,
+		Authenticator: grpcapi.DeepSourceAuthenticator(c.Runner.ID, jwtutil.NewVerifier(c.DeepSource.PublicKey)),
+		WatchInterval: c.GRPC.WatchInterval,
+		Certificate:   c.GRPC.Certificate,
+	})
+}
+
+// stopGRPCOnSignal stops the gRPC server on SIGTERM or SIGINT, letting the
+// calls in flight finish for up to the grace period, and then shuts down the
+// HTTP server so that the runner exits.
+func stopGRPCOnSignal(server *grpc.Server, e *echo.Echo, grace time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	<-signals
+	signal.Stop(signals)
+
+	slog.Info("stopping grpc server")
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(grace):
+		slog.Warn("grpc calls still in flight, stopping")
+		server.Stop()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		slog.Error("failed to shut down server", slog.Any("err", err))
+	}
+}
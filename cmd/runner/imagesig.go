@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"net/http"
+
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/imagesig"
+	"github.com/deepsourcecorp/runner/orchestrator"
+)
+
+// GetImageVerifier returns the job image signature verifier.  It is nil when
+// verification is disabled, so that jobs are scheduled without it.
+func GetImageVerifier(_ context.Context, c *config.Config, client *http.Client) (orchestrator.ImageVerifier, error) {
+	if c.ImageVerification == nil || !c.ImageVerification.Enabled {
+		return nil, nil
+	}
+
+	keys := make([]crypto.PublicKey, 0, len(c.ImageVerification.PublicKeys))
+	for _, b := range c.ImageVerification.PublicKeys {
+		key, err := imagesig.ParsePublicKey(b)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return imagesig.New(&imagesig.Opts{
+		PublicKeys: keys,
+		Username:   c.ImageVerification.Username,
+		Password:   c.ImageVerification.Password,
+	}, client)
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 
 	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/notify"
 	"github.com/getsentry/sentry-go"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/exp/slog"
@@ -77,11 +79,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	notifier, err := GetNotifier(ctx, c, http.DefaultClient)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("failed to initialize notifications", slog.Any("err", err))
+		os.Exit(1)
+	}
+
 	syncer := GetSyncer(ctx, c, http.DefaultClient)
 	err = syncer.Sync()
 	if err != nil {
 		sentry.CaptureException(err)
 		slog.Error("failed to sync upstream", slog.Any("err", err))
+		notifier.Notify(&notify.Notification{
+			Type:    notify.TypeSyncFailed,
+			Title:   "Failed to sync the runner with DeepSource",
+			Message: err.Error(),
+		})
+		notifier.Wait()
 		os.Exit(1)
 	}
 
@@ -100,21 +115,67 @@ func main() {
 	}
 	auth.AddRoutes(r)
 
-	provider, err := GetProvider(ctx, c, http.DefaultClient)
+	provider, err := GetProvider(ctx, c, http.DefaultClient, notifier)
 	if err != nil {
 		sentry.CaptureException(err)
 		slog.Error("failed to initialize provider", slog.Any("err", err))
 		os.Exit(1)
 	}
-	provider.AddRoutes(r)
+	provider.AddRoutes(r, []echo.MiddlewareFunc{auth.TokenMiddleware})
+
+	gitProxy, err := GetGitProxy(ctx, c, provider.Adapter, http.DefaultClient)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("failed to initialize git proxy", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if gitProxy != nil {
+		gitProxy.AddRoutes(r)
+	}
+
+	mirrorCache := GetMirror(ctx, c, provider.Adapter)
+	if mirrorCache != nil {
+		provider.PushNotifier.Subscribe(mirrorCache)
+		go mirrorCache.Start(ctx)
+	}
 
-	orchestrator, err := GetOrchestrator(ctx, c, provider.Adapter, Driver)
+	imageVerifier, err := GetImageVerifier(ctx, c, http.DefaultClient)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("failed to initialize image verifier", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	usage, err := GetUsage(ctx, c, Driver)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("failed to initialize usage accounting", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if usage != nil {
+		usage.AddRoutes(r, []echo.MiddlewareFunc{AdminMiddleware(c)})
+		go usage.Meter.Start(ctx)
+	}
+
+	rightsizing, err := GetRightsizing(ctx, c, Driver)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("failed to initialize rightsizing", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if rightsizing != nil {
+		rightsizing.AddRoutes(r, []echo.MiddlewareFunc{AdminMiddleware(c)})
+		go rightsizing.Recommender.Start(ctx)
+	}
+
+	orchestrator, err := GetOrchestrator(ctx, c, provider.Adapter, CloneProvider(gitProxy), mirrorCache, imageVerifier, usageMeter(usage), limitRecommender(rightsizing), usageSampler(rightsizing), notifier, Driver)
 	if err != nil {
 		sentry.CaptureException(err)
 		slog.Error("failed to initialize orchestrator", slog.Any("err", err))
 		os.Exit(1)
 	}
 	orchestrator.AddRoutes(r, []echo.MiddlewareFunc{auth.TokenMiddleware})
+	orchestrator.AddAdminRoutes(r, []echo.MiddlewareFunc{AdminMiddleware(c)})
 
 	artifacts, err := GetArtifacts(ctx, c)
 	if err != nil {
@@ -124,9 +185,61 @@ func main() {
 	}
 	artifacts.AddRoutes(r, []echo.MiddlewareFunc{auth.SessionMiddleware})
 
+	relay, err := GetRelay(ctx, c, http.DefaultClient)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("failed to initialize results relay", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if relay != nil {
+		relay.AddRoutes(r, []echo.MiddlewareFunc{AdminMiddleware(c)})
+		go relay.Deliverer.Start(ctx)
+	}
+
 	go orchestrator.Cleaner.Start(ctx)
+	go orchestrator.WatchEvents(ctx)
+	go orchestrator.ResumeClonePhases(ctx)
+	go orchestrator.WatchFailures(ctx)
+	go orchestrator.WatchPolicy(ctx)
+	if taskQueue := GetTaskQueue(ctx, c, orchestrator); taskQueue != nil {
+		go taskQueue.Start(ctx)
+	}
+	go handleMaintenanceSignal(ctx, orchestrator.Maintenance)
+
+	grpcServer, err := GetGRPC(ctx, c, orchestrator)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("failed to initialize grpc server", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if grpcServer != nil {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", c.GRPC.Port))
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.Error("failed to listen for grpc", slog.Any("err", err))
+			os.Exit(1)
+		}
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				sentry.CaptureException(err)
+				slog.Error("failed to serve grpc", slog.Any("err", err))
+				os.Exit(1)
+			}
+		}()
+		go stopGRPCOnSignal(grpcServer, s.Echo, grpcStopGrace)
+	}
 
 	r.Setup()
+	tunnel, err := GetTunnel(ctx, c, s.Echo, http.DefaultClient)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("failed to initialize tunnel", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if tunnel != nil {
+		go tunnel.Start(ctx)
+	}
+
 	err = s.Start()
 	if err != nil {
 		sentry.CaptureException(err)
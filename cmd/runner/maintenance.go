@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/deepsourcecorp/runner/orchestrator"
+)
+
+// handleMaintenanceSignal toggles maintenance mode on SIGUSR1, and starts a
+// drain on SIGUSR2.
+func handleMaintenanceSignal(ctx context.Context, m *orchestrator.Maintenance) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			if sig == syscall.SIGUSR2 {
+				m.Drain("signal")
+				continue
+			}
+			m.Toggle("signal")
+		}
+	}
+}
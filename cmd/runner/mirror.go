@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/mirror"
+	"github.com/deepsourcecorp/runner/orchestrator"
+)
+
+// GetMirror returns the repository mirror cache, or nil when it is disabled.
+func GetMirror(_ context.Context, c *config.Config, provider mirror.Provider) *mirror.Cache {
+	if c.Mirror == nil || !c.Mirror.Enabled {
+		return nil
+	}
+	return mirror.New(&mirror.Opts{
+		Root:    c.Mirror.Path,
+		MaxSize: c.Mirror.MaxSize,
+		MaxAge:  c.Mirror.MaxAge,
+	}, provider)
+}
+
+func mirrorOpts(c *config.Config, cache *mirror.Cache) *orchestrator.MirrorOpts {
+	if cache == nil {
+		return nil
+	}
+	return &orchestrator.MirrorOpts{
+		Cache:     cache,
+		ClaimName: c.Mirror.ClaimName,
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/internal/signer"
+	"github.com/deepsourcecorp/runner/notify"
+	"github.com/deepsourcecorp/runner/orchestrator"
+)
+
+// GetNotifier returns the notifier of runner problems, or nil when no sinks
+// are configured.
+func GetNotifier(_ context.Context, c *config.Config, client *http.Client) (*notify.Notifier, error) {
+	if c.Notifications == nil || len(c.Notifications.Sinks) == 0 {
+		return nil, nil
+	}
+	routes := make([]*notify.Route, 0, len(c.Notifications.Sinks))
+	for _, s := range c.Notifications.Sinks {
+		sig, err := signer.NewSHA256Signer([]byte(s.Secret))
+		if err != nil {
+			return nil, fmt.Errorf("error initializing notification sink %s: %w", s.Name, err)
+		}
+		var sink notify.Sink
+		switch s.Type {
+		case config.NotificationSinkWebhook:
+			sink = notify.NewWebhookSink(s.URL, sig, client)
+		case config.NotificationSinkSlack:
+			sink = notify.NewSlackSink(s.URL, sig, client)
+		case config.NotificationSinkEmail:
+			sink = notify.NewEmailSink(s.SMTP.Addr, s.SMTP.From, s.SMTP.To, sig)
+		}
+		routes = append(routes, &notify.Route{
+			Name:   s.Name,
+			Sink:   sink,
+			Filter: &notify.Filter{Types: s.Types, Fields: s.Fields},
+			Limit:  s.RateLimit,
+			Period: s.RatePeriod,
+		})
+	}
+	return notify.New(c.Runner.ID, routes...)
+}
+
+// failureAlertOpts returns the thresholds of job failure notifications.
+func failureAlertOpts(c *config.Config) *orchestrator.FailureAlertOpts {
+	if c.Notifications == nil {
+		return nil
+	}
+	return &orchestrator.FailureAlertOpts{
+		Threshold: c.Notifications.FailureThreshold,
+		Window:    c.Notifications.FailureWindow,
+	}
+}
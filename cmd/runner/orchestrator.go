@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,13 +9,19 @@ import (
 
 	"github.com/deepsourcecorp/runner/auth/jwtutil"
 	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/mirror"
+	"github.com/deepsourcecorp/runner/notify"
 	"github.com/deepsourcecorp/runner/orchestrator"
 )
 
 var CleanerInterval = 30 * time.Minute
 
-func GetOrchestrator(_ context.Context, c *config.Config, provider orchestrator.Provider, driverType string) (*orchestrator.Facade, error) {
-	driver, err := createDriver(driverType)
+func GetOrchestrator(_ context.Context, c *config.Config, provider orchestrator.Provider, cloneProvider orchestrator.Provider, mirrorCache *mirror.Cache, imageVerifier orchestrator.ImageVerifier, usageMeter orchestrator.UsageMeter, limits orchestrator.LimitRecommender, sampler orchestrator.UsageSampler, notifier *notify.Notifier, driverType string) (*orchestrator.Facade, error) {
+	security := securityProfile(c)
+	driver, err := createDriver(driverType, &orchestrator.K8sDriverOpts{
+		NetworkPolicy: networkPolicyOpts(c),
+		Security:      security,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error initializing orchestrator: %w", err)
 	}
@@ -30,6 +37,26 @@ func GetOrchestrator(_ context.Context, c *config.Config, provider orchestrator.
 		NodeSelector:     c.Kubernetes.NodeSelector,
 		ImageURL:         c.Kubernetes.ImageRegistry.RegistryUrl,
 		ImagePullSecrets: []string{c.Kubernetes.ImageRegistry.PullSecretName},
+		ImagePolicy:      imagePolicy(c),
+	}
+
+	submodules, _ := provider.(orchestrator.SubmoduleResolver)
+	branches, _ := provider.(orchestrator.BranchResolver)
+	pushes, _ := cloneProvider.(orchestrator.PushProvider)
+
+	keys, err := signingKeys(c)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing orchestrator: %w", err)
+	}
+
+	policy, err := orgPolicy(c)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing orchestrator: %w", err)
+	}
+
+	quota, err := createQuota(driverType, c, notifier)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing orchestrator: %w", err)
 	}
 
 	taskOpts := &orchestrator.TaskOpts{
@@ -37,9 +64,29 @@ func GetOrchestrator(_ context.Context, c *config.Config, provider orchestrator.
 		SnippetStorageType:   c.ObjectStorage.Provider,
 		SnippetStorageBucket: c.ObjectStorage.Bucket,
 		SentryDSN:            c.Sentry.DSN,
+		RelayHost:            relayHost(c),
+		Mirror:               mirrorOpts(c, mirrorCache),
+		CloneStrategies:      cloneStrategies(c),
+		Submodules:           submodules,
+		Branches:             branches,
+		Pushes:               pushes,
+		SigningKeys:          keys,
+		PushPolicy:           pushPolicy(c),
+		OrgPolicy:            policy,
+		CredentialProfiles:   credentialProfiles(c),
+		ImageVerifier:        imageVerifier,
+		Quota:                quota,
+		Dispatcher:           dispatcher(c),
+		Usage:                usageMeter,
+		Rightsizing:          limits,
+		Notifier:             notifier,
 		KubernetesOpts:       kubernetesOpts,
 	}
 
+	if err := orchestrator.ValidateSecurityProfile(security, taskOpts); err != nil {
+		return nil, fmt.Errorf("error initializing orchestrator: %w", err)
+	}
+
 	cleanerOpts := &orchestrator.CleanerOpts{
 		Namespace: c.Kubernetes.Namespace,
 		Interval:  &CleanerInterval,
@@ -50,22 +97,250 @@ func GetOrchestrator(_ context.Context, c *config.Config, provider orchestrator.
 	}
 
 	opts := &orchestrator.Opts{
-		TaskOpts:    taskOpts,
-		CleanerOpts: cleanerOpts,
-		Driver:      driver,
-		Provider:    provider,
-		Signer:      signer,
-		Runner:      runner,
+		TaskOpts:      taskOpts,
+		CleanerOpts:   cleanerOpts,
+		Driver:        driver,
+		Provider:      provider,
+		CloneProvider: cloneProvider,
+		Signer:        signer,
+		Runner:        runner,
+		UsageSampler:  sampler,
+	}
+	if c.Maintenance != nil {
+		opts.Maintenance = c.Maintenance.Enabled
+		opts.MaintenanceOpts = &orchestrator.MaintenanceOpts{
+			Namespace:     c.Kubernetes.Namespace,
+			RetryAfter:    c.Maintenance.RetryAfter,
+			DrainInterval: c.Maintenance.DrainInterval,
+		}
+	}
+
+	if c.Events != nil {
+		opts.EventBufferSize = c.Events.BufferSize
+	}
+	opts.FailureAlerts = failureAlertOpts(c)
+	if c.OrgPolicy != nil {
+		opts.PolicyReloadInterval = c.OrgPolicy.ReloadInterval
 	}
 
 	return orchestrator.New(opts)
 }
 
-func createDriver(driver string) (orchestrator.Driver, error) {
+// cloneStrategies returns the clone strategies of the apps that configure
+// one.
+func cloneStrategies(c *config.Config) map[string]*orchestrator.AppCloneStrategy {
+	strategies := make(map[string]*orchestrator.AppCloneStrategy)
+	for _, app := range c.Apps {
+		if app.CloneStrategy == nil {
+			continue
+		}
+		analyzers := make(map[string]*orchestrator.CloneStrategy)
+		for shortcode, s := range app.CloneStrategy.Analyzers {
+			analyzers[shortcode] = cloneStrategy(s)
+		}
+		strategies[app.ID] = &orchestrator.AppCloneStrategy{
+			CloneStrategy: *cloneStrategy(app.CloneStrategy),
+			Analyzers:     analyzers,
+		}
+	}
+	return strategies
+}
+
+func cloneStrategy(s *config.CloneStrategy) *orchestrator.CloneStrategy {
+	if s == nil {
+		return &orchestrator.CloneStrategy{}
+	}
+	return &orchestrator.CloneStrategy{
+		Depth:               s.Depth,
+		Filter:              s.Filter,
+		Sparse:              s.Sparse,
+		ExcludeTestPatterns: s.ExcludeTestPatterns,
+		SparsePaths:         s.SparsePaths,
+	}
+}
+
+func credentialProfiles(c *config.Config) []*orchestrator.CredentialProfile {
+	var profiles []*orchestrator.CredentialProfile
+	for _, p := range c.CredentialProfiles {
+		profiles = append(profiles, &orchestrator.CredentialProfile{
+			Name:         p.Name,
+			Type:         p.Type,
+			SecretName:   p.SecretName,
+			Key:          p.Key,
+			Apps:         p.Apps,
+			Repositories: p.Repositories,
+			Analyzers:    p.Analyzers,
+		})
+	}
+	return profiles
+}
+
+// signingKeys returns the commit signing keys of the apps.  An app can only
+// have one.
+func signingKeys(c *config.Config) ([]*orchestrator.SigningKey, error) {
+	var keys []*orchestrator.SigningKey
+	seen := make(map[string]bool)
+	for _, s := range c.CommitSigning {
+		if seen[s.App] {
+			return nil, fmt.Errorf("app %s has more than one commit signing key", s.App)
+		}
+		seen[s.App] = true
+		keys = append(keys, &orchestrator.SigningKey{
+			AppID:      s.App,
+			Format:     s.Format,
+			SecretName: s.SecretName,
+			Key:        s.Key,
+			PublicKey:  s.PublicKey,
+			Name:       s.Name,
+			Email:      s.Email,
+		})
+	}
+	return keys, nil
+}
+
+// pushPolicy returns the policy the pushes of transformer and patcher jobs
+// are checked against, if one is configured.
+func pushPolicy(c *config.Config) *orchestrator.PushPolicy {
+	if c.PushPolicy == nil {
+		return nil
+	}
+	return &orchestrator.PushPolicy{
+		ProtectedBranches:    c.PushPolicy.ProtectedBranches,
+		ProtectDefaultBranch: c.PushPolicy.ProtectDefaultBranch,
+		RequirePullRequest:   c.PushPolicy.RequirePullRequest,
+		MaxFiles:             c.PushPolicy.MaxFiles,
+		MaxPatches:           c.PushPolicy.MaxPatches,
+	}
+}
+
+// orgPolicy returns the organization policy file incoming runs are checked
+// against, if one is configured.
+func orgPolicy(c *config.Config) (*orchestrator.PolicyFile, error) {
+	if c.OrgPolicy == nil {
+		return nil, nil
+	}
+	return orchestrator.NewPolicyFile(c.OrgPolicy.Path, parseOrgPolicy)
+}
+
+func parseOrgPolicy(data []byte) (*orchestrator.OrgPolicy, error) {
+	rules, err := config.LoadOrgPolicyRules(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &orchestrator.OrgPolicy{
+		Analyzers:            policyList(rules.Analyzers),
+		Transformers:         policyList(rules.Transformers),
+		MaxChecks:            rules.MaxChecks,
+		ExcludedRepositories: rules.ExcludedRepositories,
+		RequiredVersions:     rules.RequiredVersions,
+	}, nil
+}
+
+func policyList(l *config.OrgPolicyList) orchestrator.PolicyList {
+	if l == nil {
+		return orchestrator.PolicyList{}
+	}
+	return orchestrator.PolicyList{Allow: l.Allow, Deny: l.Deny}
+}
+
+// imagePolicy returns the image policy applied to job images, if one is
+// configured.
+func imagePolicy(c *config.Config) *orchestrator.ImagePolicy {
+	if c.ImagePolicy == nil {
+		return nil
+	}
+	return &orchestrator.ImagePolicy{
+		Catalog:          c.ImagePolicy.Catalog,
+		RequireDigest:    c.ImagePolicy.RequireDigest,
+		RegistryRewrites: c.ImagePolicy.RegistryRewrites,
+		Allow:            imageRules(c.ImagePolicy.Allow),
+		Deny:             imageRules(c.ImagePolicy.Deny),
+		CoatVersion:      c.ImagePolicy.CoatVersion,
+		AnalyzerVersions: c.ImagePolicy.AnalyzerVersions,
+	}
+}
+
+func imageRules(rules []*config.ImageRule) []*orchestrator.ImageRule {
+	var r []*orchestrator.ImageRule
+	for _, rule := range rules {
+		r = append(r, &orchestrator.ImageRule{
+			Analyzer: rule.Analyzer,
+			Versions: rule.Versions,
+		})
+	}
+	return r
+}
+
+// networkPolicyOpts returns the NetworkPolicies created with jobs, or nil
+// when they are disabled.
+func networkPolicyOpts(c *config.Config) *orchestrator.NetworkPolicyOpts {
+	if c.NetworkPolicy == nil || !c.NetworkPolicy.Enabled {
+		return nil
+	}
+	egress := make(map[string][]*orchestrator.EgressPeer)
+	for container, peers := range c.NetworkPolicy.Egress {
+		for _, p := range peers {
+			egress[container] = append(egress[container], &orchestrator.EgressPeer{
+				CIDR: p.CIDR,
+				Host: p.Host,
+				Port: p.Port,
+			})
+		}
+	}
+	return &orchestrator.NetworkPolicyOpts{
+		Egress:   egress,
+		AllowDNS: c.NetworkPolicy.AllowDNS,
+	}
+}
+
+// securityProfile returns the security profile of job pods, or nil for the
+// baseline profile.
+func securityProfile(c *config.Config) *orchestrator.SecurityProfile {
+	if c.Kubernetes == nil || c.Kubernetes.SecurityProfile == nil {
+		return nil
+	}
+	return &orchestrator.SecurityProfile{
+		Name:             c.Kubernetes.SecurityProfile.Name,
+		RuntimeClassName: c.Kubernetes.SecurityProfile.RuntimeClassName,
+	}
+}
+
+func createDriver(driver string, opts *orchestrator.K8sDriverOpts) (orchestrator.Driver, error) {
 	switch driver {
 	case orchestrator.DriverPrinter:
-		return orchestrator.NewK8sPrinterDriver(), nil
+		return orchestrator.NewK8sPrinterDriver(opts), nil
 	default:
-		return orchestrator.NewK8sDriver("")
+		return orchestrator.NewK8sDriver("", opts)
+	}
+}
+
+// dispatcher returns the fair-share dispatcher of tasks, or nil when tasks
+// are dispatched first-come first-served.
+func dispatcher(c *config.Config) *orchestrator.Dispatcher {
+	if c.FairShare == nil || !c.FairShare.Enabled {
+		return nil
+	}
+	return orchestrator.NewDispatcher(&orchestrator.DispatcherOpts{
+		MaxInFlight:        c.FairShare.MaxInFlight,
+		AppWeights:         c.FairShare.AppWeights,
+		RepositoryWeights:  c.FairShare.RepositoryWeights,
+		DefaultBranchBoost: c.FairShare.DefaultBranchBoost,
+		InteractiveBoost:   c.FairShare.InteractiveBoost,
+		QueueTimeout:       c.FairShare.QueueTimeout,
+	})
+}
+
+// createQuota returns the quota jobs are admitted against, or nil when quota
+// admission is disabled.  The printer driver has no cluster to read quotas
+// from.
+func createQuota(driver string, c *config.Config, notifier *notify.Notifier) (*orchestrator.Quota, error) {
+	if c.Quota == nil || !c.Quota.Enabled || driver == orchestrator.DriverPrinter {
+		return nil, nil
 	}
+	return orchestrator.NewK8sQuota("", &orchestrator.QuotaOpts{
+		Namespace:   c.Kubernetes.Namespace,
+		RetryAfter:  c.Quota.RetryAfter,
+		Reservation: c.Quota.Reservation,
+		Notifier:    notifier,
+	})
 }
@@ -6,13 +6,14 @@ import (
 	"net/http"
 
 	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/notify"
 	"github.com/deepsourcecorp/runner/provider"
 	"github.com/deepsourcecorp/runner/provider/github"
 	"github.com/deepsourcecorp/runner/provider/model"
 )
 
-func GetProvider(_ context.Context, c *config.Config, client *http.Client) (*provider.Facade, error) {
-	githubApps := createGithubApps(c)
+func GetProvider(_ context.Context, c *config.Config, client *http.Client, notifier *notify.Notifier) (*provider.Facade, error) {
+	githubApps := createGithubApps(c, notifier)
 	providerApps := createProviderApps(c)
 
 	runner := &model.Runner{
@@ -26,7 +27,8 @@ func GetProvider(_ context.Context, c *config.Config, client *http.Client) (*pro
 
 	appFactory := github.NewAppFactory(githubApps)
 
-	webhookService := github.NewWebhookService(appFactory, runner, deepsource, client)
+	pushNotifier := model.NewPushNotifier()
+	webhookService := github.NewWebhookService(appFactory, runner, deepsource, client, pushNotifier)
 	apiService := github.NewAPIService(appFactory, client)
 
 	githubProvider, err := github.NewHandler(webhookService, apiService, appFactory, runner, deepsource, client)
@@ -34,10 +36,10 @@ func GetProvider(_ context.Context, c *config.Config, client *http.Client) (*pro
 		return nil, fmt.Errorf("error initializing provider: %w", err)
 	}
 
-	return provider.NewFacade(providerApps, githubProvider), nil
+	return provider.NewFacade(providerApps, githubProvider, pushNotifier), nil
 }
 
-func createGithubApps(c *config.Config) map[string]*github.App {
+func createGithubApps(c *config.Config, notifier *notify.Notifier) map[string]*github.App {
 	apps := make(map[string]*github.App)
 	for _, v := range c.Apps {
 		switch {
@@ -50,6 +52,7 @@ func createGithubApps(c *config.Config) map[string]*github.App {
 				APIHost:       v.Github.APIHost,
 				AppSlug:       v.Github.Slug,
 				PrivateKey:    v.Github.PrivateKey,
+				Notifier:      notifier,
 			}
 		}
 	}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/relay"
+	relaystore "github.com/deepsourcecorp/runner/relay/rqlite"
+	"github.com/deepsourcecorp/runner/rqlite"
+)
+
+// GetRelay returns the results relay, or nil when the relay is disabled.
+func GetRelay(_ context.Context, c *config.Config, client *http.Client) (*relay.Facade, error) {
+	if c.Relay == nil || !c.Relay.Enabled {
+		return nil, nil
+	}
+
+	db, err := rqlite.Connect(c.RQLite.Host, c.RQLite.Port)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing relay: %w", err)
+	}
+
+	opts := &relay.Opts{
+		RunnerID: c.Runner.ID,
+		Verifier: jwtutil.NewVerifier(&c.Runner.PrivateKey.PublicKey),
+		Signer:   jwtutil.NewSigner(c.Runner.PrivateKey),
+		Store:    relaystore.New(db),
+		DelivererOpts: &relay.DelivererOpts{
+			RunnerID:       c.Runner.ID,
+			DeepSourceHost: c.DeepSource.Host,
+			MaxAttempts:    c.Relay.MaxAttempts,
+		},
+	}
+
+	return relay.New(opts, client)
+}
+
+// relayHost returns the URL jobs should publish results to when the relay is
+// enabled.
+func relayHost(c *config.Config) string {
+	if c.Relay == nil || !c.Relay.Enabled {
+		return ""
+	}
+	return c.Relay.ServiceURL.JoinPath(relay.PathPrefix).String()
+}
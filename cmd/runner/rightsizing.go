@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"github.com/deepsourcecorp/runner/rightsizing"
+	rightsizingstore "github.com/deepsourcecorp/runner/rightsizing/rqlite"
+	"github.com/deepsourcecorp/runner/rqlite"
+)
+
+// GetRightsizing returns the right-sizing of analyzer limits, or nil when it
+// is disabled.
+func GetRightsizing(_ context.Context, c *config.Config, driverType string) (*rightsizing.Facade, error) {
+	if c.Rightsizing == nil || !c.Rightsizing.Enabled {
+		return nil, nil
+	}
+
+	db, err := rqlite.Connect(c.RQLite.Host, c.RQLite.Port)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing rightsizing: %w", err)
+	}
+
+	opts := &rightsizing.Opts{
+		Store: rightsizingstore.New(db),
+		RecommenderOpts: &rightsizing.RecommenderOpts{
+			SampleInterval: c.Rightsizing.SampleInterval,
+			Window:         c.Rightsizing.Window,
+			Percentile:     c.Rightsizing.Percentile,
+			Headroom:       c.Rightsizing.Headroom,
+			MinSamples:     c.Rightsizing.MinSamples,
+			Apply:          c.Rightsizing.Apply,
+		},
+	}
+	if b := c.Rightsizing.Bounds; b != nil {
+		opts.Bounds = &rightsizing.Bounds{
+			MinCPUMillis: b.MinCPU,
+			MaxCPUMillis: b.MaxCPU,
+			MinMemoryMiB: b.MinMemory,
+			MaxMemoryMiB: b.MaxMemory,
+		}
+	}
+	// The printer driver runs no pods to sample.
+	if driverType != orchestrator.DriverPrinter {
+		metrics, err := orchestrator.NewK8sPodMetrics("", c.Kubernetes.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing rightsizing: %w", err)
+		}
+		opts.Metrics = metrics
+	}
+
+	return rightsizing.New(opts)
+}
+
+// limitRecommender returns the recommender of analyzer limits when the
+// recommendations are applied to jobs, and nil otherwise.
+func limitRecommender(r *rightsizing.Facade) orchestrator.LimitRecommender {
+	if r == nil || !r.Recommender.Applied() {
+		return nil
+	}
+	return r.Recommender
+}
+
+// usageSampler returns the recommender to sample the usage of completed
+// analysis pods, and nil when right-sizing is disabled.
+func usageSampler(r *rightsizing.Facade) orchestrator.UsageSampler {
+	if r == nil {
+		return nil
+	}
+	return r.Recommender
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -61,7 +62,7 @@ func NewServer(c *runnerconfig.Config) *Server {
 
 func (s *Server) Start() error {
 	err := s.Echo.Start(fmt.Sprintf(":%d", RunnerPort))
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		slog.Error("failed to start server", slog.Any("err", err))
 		return err
 	}
@@ -23,6 +23,7 @@ func GetSyncer(_ context.Context, c *config.Config, client *http.Client) *sync.S
 		ClientID:      c.Runner.ClientID,
 		ClientSecret:  c.Runner.ClientSecret,
 		WebhookSecret: c.Runner.WebhookSecret,
+		Outbound:      c.Tunnel != nil && c.Tunnel.Enabled,
 	}
 
 	apps := make([]sync.App, 0, len(c.Apps))
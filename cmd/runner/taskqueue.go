@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/taskqueue"
+)
+
+// GetTaskQueue returns the consumer of the AMQP task queue, or nil when AMQP
+// intake is disabled.
+func GetTaskQueue(_ context.Context, c *config.Config, scheduler taskqueue.Scheduler) *taskqueue.Consumer {
+	if c.AMQP == nil || !c.AMQP.Enabled {
+		return nil
+	}
+	return taskqueue.NewConsumer(taskqueue.DialURL(c.AMQP.URL), scheduler, &taskqueue.Opts{
+		Queue:           c.AMQP.Queue,
+		DeadLetterQueue: c.AMQP.DeadLetterQueue,
+		Prefetch:        c.AMQP.Prefetch,
+		MaxRetries:      c.AMQP.MaxRetries,
+		RetryDelay:      c.AMQP.RetryDelay,
+		Authenticator:   taskqueue.DeepSourceAuthenticator(c.Runner.ID, jwtutil.NewVerifier(c.DeepSource.PublicKey)),
+	})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/tunnel"
+)
+
+// GetTunnel returns the tunnel serving DeepSource's requests with the
+// handler, or nil when outbound-only connectivity is disabled.
+func GetTunnel(_ context.Context, c *config.Config, handler http.Handler, client *http.Client) (*tunnel.Tunnel, error) {
+	if c.Tunnel == nil || !c.Tunnel.Enabled {
+		return nil, nil
+	}
+	return tunnel.New(handler, jwtutil.NewSigner(c.Runner.PrivateKey), client, &tunnel.Opts{
+		RunnerID:       c.Runner.ID,
+		DeepSourceHost: c.DeepSource.Host,
+		Concurrency:    c.Tunnel.Concurrency,
+		PollTimeout:    c.Tunnel.PollTimeout,
+		ReconnectDelay: c.Tunnel.ReconnectDelay,
+	})
+}
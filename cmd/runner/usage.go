@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deepsourcecorp/runner/config"
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"github.com/deepsourcecorp/runner/rqlite"
+	"github.com/deepsourcecorp/runner/usage"
+	usagestore "github.com/deepsourcecorp/runner/usage/rqlite"
+)
+
+// GetUsage returns usage accounting, or nil when it is disabled.
+func GetUsage(_ context.Context, c *config.Config, driverType string) (*usage.Facade, error) {
+	if c.Usage == nil || !c.Usage.Enabled {
+		return nil, nil
+	}
+
+	db, err := rqlite.Connect(c.RQLite.Host, c.RQLite.Port)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing usage accounting: %w", err)
+	}
+
+	opts := &usage.Opts{
+		Store: usagestore.New(db),
+		MeterOpts: &usage.MeterOpts{
+			Limits:    usageLimits(c.Usage.Limits),
+			AppLimits: make(map[string]*usage.Limits),
+		},
+	}
+	for appID, l := range c.Usage.Apps {
+		opts.AppLimits[appID] = usageLimits(l)
+	}
+	// The printer driver runs no jobs to read the times of.
+	if driverType != orchestrator.DriverPrinter {
+		status, err := orchestrator.NewK8sJobStatus("", c.Kubernetes.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing usage accounting: %w", err)
+		}
+		opts.JobStatus = status
+	}
+
+	return usage.New(opts)
+}
+
+func usageLimits(l *config.UsageLimits) *usage.Limits {
+	if l == nil || l.DailyMinutes == 0 && l.MonthlyMinutes == 0 {
+		return nil
+	}
+	return &usage.Limits{
+		DailyMinutes:   l.DailyMinutes,
+		MonthlyMinutes: l.MonthlyMinutes,
+	}
+}
+
+// usageMeter returns the meter of usage accounting, or nil when it is
+// disabled.
+func usageMeter(u *usage.Facade) orchestrator.UsageMeter {
+	if u == nil {
+		return nil
+	}
+	return u.Meter
+}
@@ -0,0 +1,7 @@
+package config
+
+// Admin configures access to the operator facing endpoints under /admin.
+// The endpoints are disabled when no token is set.
+type Admin struct {
+	Token string `yaml:"token"`
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidAMQP = errors.New("config: invalid amqp")
+
+// AMQP configures the intake of tasks from an AMQP queue, alongside the task
+// endpoints.
+type AMQP struct {
+	Enabled         bool
+	URL             string
+	Queue           string
+	DeadLetterQueue string
+	Prefetch        int
+	MaxRetries      int
+	RetryDelay      time.Duration
+}
+
+func (a *AMQP) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled         bool   `yaml:"enabled"`
+		URL             string `yaml:"url"`
+		Queue           string `yaml:"queue"`
+		DeadLetterQueue string `yaml:"deadLetterQueue"`
+		Prefetch        int    `yaml:"prefetch"`
+		MaxRetries      int    `yaml:"maxRetries"`
+		RetryDelayStr   string `yaml:"retryDelay"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.Enabled && (v.URL == "" || v.Queue == "") {
+		return fmt.Errorf("%w: url and queue are required", ErrInvalidAMQP)
+	}
+	if v.RetryDelayStr != "" {
+		d, err := time.ParseDuration(v.RetryDelayStr)
+		if err != nil {
+			return err
+		}
+		a.RetryDelay = d
+	}
+	a.Enabled = v.Enabled
+	a.URL = v.URL
+	a.Queue = v.Queue
+	a.DeadLetterQueue = v.DeadLetterQueue
+	a.Prefetch = v.Prefetch
+	a.MaxRetries = v.MaxRetries
+	return nil
+}
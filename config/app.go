@@ -5,8 +5,9 @@ const (
 )
 
 type App struct {
-	ID       string  `yaml:"id"`
-	Name     string  `yaml:"name"`
-	Provider string  `yaml:"provider"`
-	Github   *Github `yaml:"github"`
+	ID            string         `yaml:"id"`
+	Name          string         `yaml:"name"`
+	Provider      string         `yaml:"provider"`
+	Github        *Github        `yaml:"github"`
+	CloneStrategy *CloneStrategy `yaml:"cloneStrategy"`
 }
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"errors"
+	"regexp"
+)
+
+var (
+	ErrInvalidCloneDepth  = errors.New("config: clone depth must not be negative")
+	ErrInvalidCloneFilter = errors.New("config: clone filter must be one of blob:none, blob:limit=<n>, tree:0")
+
+	cloneFilterRegexp = regexp.MustCompile(`^(blob:none|blob:limit=\d+[kmg]?|tree:0)$`)
+)
+
+// CloneStrategy controls how jobs clone the repository of an app.  The zero
+// value is a full clone.  Analyzers lists per-analyzer strategies, keyed by
+// analyzer shortcode, that replace the app's strategy for that analyzer.
+type CloneStrategy struct {
+	Depth               int
+	Filter              string
+	Sparse              bool
+	SparsePaths         []string
+	ExcludeTestPatterns bool
+	Analyzers           map[string]*CloneStrategy
+}
+
+func (s *CloneStrategy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Depth               int                       `yaml:"depth"`
+		Filter              string                    `yaml:"filter"`
+		Sparse              bool                      `yaml:"sparse"`
+		SparsePaths         []string                  `yaml:"sparsePaths"`
+		ExcludeTestPatterns bool                      `yaml:"excludeTestPatterns"`
+		Analyzers           map[string]*CloneStrategy `yaml:"analyzers"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.Depth < 0 {
+		return ErrInvalidCloneDepth
+	}
+	if v.Filter != "" && !cloneFilterRegexp.MatchString(v.Filter) {
+		return ErrInvalidCloneFilter
+	}
+	s.Depth = v.Depth
+	s.Filter = v.Filter
+	s.Sparse = v.Sparse
+	s.SparsePaths = v.SparsePaths
+	s.ExcludeTestPatterns = v.ExcludeTestPatterns
+	s.Analyzers = v.Analyzers
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCloneStrategy_UnmarshalYAML(t *testing.T) {
+	t.Run("app and analyzer strategies", func(t *testing.T) {
+		input := `
+depth: 1
+filter: blob:none
+sparse: true
+excludeTestPatterns: true
+analyzers:
+  secrets:
+    depth: 50
+  python:
+    sparsePaths: ["/services/api/"]`
+		var strategy CloneStrategy
+		err := yaml.Unmarshal([]byte(input), &strategy)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strategy.Depth)
+		assert.Equal(t, "blob:none", strategy.Filter)
+		assert.True(t, strategy.Sparse)
+		assert.True(t, strategy.ExcludeTestPatterns)
+		assert.Equal(t, 50, strategy.Analyzers["secrets"].Depth)
+		assert.Equal(t, []string{"/services/api/"}, strategy.Analyzers["python"].SparsePaths)
+	})
+
+	t.Run("invalid depth", func(t *testing.T) {
+		var strategy CloneStrategy
+		err := yaml.Unmarshal([]byte(`depth: -1`), &strategy)
+		assert.ErrorIs(t, err, ErrInvalidCloneDepth)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		var strategy CloneStrategy
+		err := yaml.Unmarshal([]byte(`filter: "blob:none --upload-pack=sh"`), &strategy)
+		assert.ErrorIs(t, err, ErrInvalidCloneFilter)
+	})
+
+	t.Run("nested analyzer", func(t *testing.T) {
+		var strategy CloneStrategy
+		err := yaml.Unmarshal([]byte("analyzers:\n  go:\n    filter: everything"), &strategy)
+		assert.ErrorIs(t, err, ErrInvalidCloneFilter)
+	})
+}
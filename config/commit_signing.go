@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrInvalidCommitSigning = errors.New("config: invalid commit signing key")
+
+// CommitSigning is the identity the commits pushed by patcher and
+// transformer jobs of an app are signed with.  The private key is the Key of
+// the Secret, and PublicKey is shown to register the key with the VCS.  Only
+// SSH keys are supported, as git signs with them without a keyring.
+type CommitSigning struct {
+	App        string
+	Format     string
+	SecretName string
+	Key        string
+	PublicKey  string
+
+	// Name and Email are the committer of signed commits, as the VCS
+	// matches the signature against the committer.
+	Name  string
+	Email string
+}
+
+func (s *CommitSigning) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		App        string `yaml:"app"`
+		Format     string `yaml:"format"`
+		SecretName string `yaml:"secretName"`
+		Key        string `yaml:"key"`
+		PublicKey  string `yaml:"publicKey"`
+		Name       string `yaml:"name"`
+		Email      string `yaml:"email"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.App == "" {
+		return fmt.Errorf("%w: app is required", ErrInvalidCommitSigning)
+	}
+	if v.SecretName == "" {
+		return fmt.Errorf("%w: %s: secretName is required", ErrInvalidCommitSigning, v.App)
+	}
+	v.PublicKey = strings.TrimSpace(v.PublicKey)
+	if v.Format != orchestrator.SigningFormatSSH {
+		return fmt.Errorf("%w: %s: unsupported format %q", ErrInvalidCommitSigning, v.App, v.Format)
+	}
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(v.PublicKey)); err != nil {
+		return fmt.Errorf("%w: %s: publicKey: %v", ErrInvalidCommitSigning, v.App, err)
+	}
+	if v.Name == "" || v.Email == "" {
+		return fmt.Errorf("%w: %s: name and email are required", ErrInvalidCommitSigning, v.App)
+	}
+	s.App = v.App
+	s.Format = v.Format
+	s.SecretName = v.SecretName
+	s.Key = v.Key
+	s.PublicKey = v.PublicKey
+	s.Name = v.Name
+	s.Email = v.Email
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const testSSHPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl runner@acme.com"
+
+func TestCommitSigning_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+app: gh-app
+format: ssh
+secretName: commit-signing
+key: id_ed25519
+publicKey: ` + testSSHPublicKey + `
+name: Acme Bot
+email: bot@acme.com`
+		var signing CommitSigning
+		err := yaml.Unmarshal([]byte(input), &signing)
+		require.NoError(t, err)
+		assert.Equal(t, CommitSigning{
+			App:        "gh-app",
+			Format:     orchestrator.SigningFormatSSH,
+			SecretName: "commit-signing",
+			Key:        "id_ed25519",
+			PublicKey:  testSSHPublicKey,
+			Name:       "Acme Bot",
+			Email:      "bot@acme.com",
+		}, signing)
+	})
+
+	invalid := map[string]string{
+		"missing app":       "format: ssh\nsecretName: s\npublicKey: " + testSSHPublicKey + "\nname: n\nemail: e",
+		"missing secret":    "app: a\nformat: ssh\npublicKey: " + testSSHPublicKey + "\nname: n\nemail: e",
+		"unknown format":    "app: a\nformat: x509\nsecretName: s\npublicKey: " + testSSHPublicKey + "\nname: n\nemail: e",
+		"invalid ssh key":   "app: a\nformat: ssh\nsecretName: s\npublicKey: ssh-ed25519 AAAA\nname: n\nemail: e",
+		"gpg":               "app: a\nformat: gpg\nsecretName: s\npublicKey: |\n  -----BEGIN PGP PUBLIC KEY BLOCK-----\nname: n\nemail: e",
+		"missing committer": "app: a\nformat: ssh\nsecretName: s\npublicKey: " + testSSHPublicKey,
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var signing CommitSigning
+			err := yaml.Unmarshal([]byte(input), &signing)
+			assert.ErrorIs(t, err, ErrInvalidCommitSigning)
+		})
+	}
+}
@@ -16,6 +16,28 @@ type Config struct {
 	SAML          *SAML          `yaml:"saml"`
 	ObjectStorage *ObjectStorage `yaml:"objectStorage"`
 	Sentry        *Sentry        `yaml:"sentry"`
+	Relay         *Relay         `yaml:"relay"`
+	Admin         *Admin         `yaml:"admin"`
+	GitProxy      *GitProxy      `yaml:"gitProxy"`
+	Mirror        *Mirror        `yaml:"mirror"`
+	NetworkPolicy *NetworkPolicy `yaml:"networkPolicy"`
+	Quota         *Quota         `yaml:"quota"`
+	FairShare     *FairShare     `yaml:"fairShare"`
+	Usage         *Usage         `yaml:"usage"`
+	Maintenance   *Maintenance   `yaml:"maintenance"`
+	Rightsizing   *Rightsizing   `yaml:"rightsizing"`
+	AMQP          *AMQP          `yaml:"amqp"`
+	GRPC          *GRPC          `yaml:"grpc"`
+	Tunnel        *Tunnel        `yaml:"tunnel"`
+	Events        *Events        `yaml:"events"`
+	Notifications *Notifications `yaml:"notifications"`
+	PushPolicy    *PushPolicy    `yaml:"pushPolicy"`
+	OrgPolicy     *OrgPolicy     `yaml:"orgPolicy"`
+
+	CredentialProfiles []*CredentialProfile `yaml:"credentialProfiles"`
+	CommitSigning      []*CommitSigning     `yaml:"commitSigning"`
+	ImagePolicy        *ImagePolicy         `yaml:"imagePolicy"`
+	ImageVerification  *ImageVerification   `yaml:"imageVerification"`
 }
 
 func LoadConfig(r io.Reader) (*Config, error) {
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var (
+	ErrInvalidCredentialProfile = errors.New("config: invalid credential profile")
+
+	credentialProfileTypes = map[string]bool{
+		orchestrator.CredentialProfileNetrc: true,
+		orchestrator.CredentialProfileNpmrc: true,
+		orchestrator.CredentialProfilePip:   true,
+		orchestrator.CredentialProfileMaven: true,
+		orchestrator.CredentialProfileEnv:   true,
+	}
+)
+
+// CredentialProfile injects credentials from a Kubernetes Secret into the
+// analyzer container of matching analysis jobs.  File profiles mount the Key
+// of the Secret at the well-known location for Type, env profiles expose
+// every key of the Secret as an environment variable.  Type is one of the
+// orchestrator's CredentialProfile types.
+//
+// A profile applies to a job when each of its non-empty selectors (Apps,
+// Repositories and Analyzers) matches.  At least one selector is required.
+type CredentialProfile struct {
+	Name         string
+	Type         string
+	SecretName   string
+	Key          string
+	Apps         []string
+	Repositories []string
+	Analyzers    []string
+}
+
+func (p *CredentialProfile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Name         string   `yaml:"name"`
+		Type         string   `yaml:"type"`
+		SecretName   string   `yaml:"secretName"`
+		Key          string   `yaml:"key"`
+		Apps         []string `yaml:"apps"`
+		Repositories []string `yaml:"repositories"`
+		Analyzers    []string `yaml:"analyzers"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if errs := validation.IsDNS1123Label(v.Name); len(errs) > 0 {
+		return fmt.Errorf("%w: name %q: %v", ErrInvalidCredentialProfile, v.Name, errs)
+	}
+	if !credentialProfileTypes[v.Type] {
+		return fmt.Errorf("%w: %s: unknown type %q", ErrInvalidCredentialProfile, v.Name, v.Type)
+	}
+	if v.SecretName == "" {
+		return fmt.Errorf("%w: %s: secretName is required", ErrInvalidCredentialProfile, v.Name)
+	}
+	if len(v.Apps) == 0 && len(v.Repositories) == 0 && len(v.Analyzers) == 0 {
+		return fmt.Errorf("%w: %s: at least one of apps, repositories or analyzers is required", ErrInvalidCredentialProfile, v.Name)
+	}
+	p.Name = v.Name
+	p.Type = v.Type
+	p.SecretName = v.SecretName
+	p.Key = v.Key
+	p.Apps = v.Apps
+	p.Repositories = v.Repositories
+	p.Analyzers = v.Analyzers
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCredentialProfile_UnmarshalYAML(t *testing.T) {
+	t.Run("valid profile", func(t *testing.T) {
+		input := `
+name: npm-registry
+type: npmrc
+secretName: npm-registry
+key: .npmrc
+repositories: ["acme/web"]
+analyzers: ["javascript"]`
+		var profile CredentialProfile
+		err := yaml.Unmarshal([]byte(input), &profile)
+		require.NoError(t, err)
+		assert.Equal(t, CredentialProfile{
+			Name:         "npm-registry",
+			Type:         orchestrator.CredentialProfileNpmrc,
+			SecretName:   "npm-registry",
+			Key:          ".npmrc",
+			Repositories: []string{"acme/web"},
+			Analyzers:    []string{"javascript"},
+		}, profile)
+	})
+
+	invalid := map[string]string{
+		"invalid name":     "name: NPM\ntype: npmrc\nsecretName: npm\napps: [app]",
+		"unknown type":     "name: npm\ntype: yarnrc\nsecretName: npm\napps: [app]",
+		"missing secret":   "name: npm\ntype: npmrc\napps: [app]",
+		"missing selector": "name: npm\ntype: npmrc\nsecretName: npm",
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var profile CredentialProfile
+			err := yaml.Unmarshal([]byte(input), &profile)
+			assert.ErrorIs(t, err, ErrInvalidCredentialProfile)
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package config
+
+// Events configures the stream of job lifecycle events.  BufferSize is the
+// number of events kept for clients resuming the stream.
+type Events struct {
+	BufferSize int `yaml:"bufferSize"`
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidFairShare = errors.New("config: invalid fair share")
+
+// FairShare configures the weighted round-robin dispatching of tasks across
+// apps and repositories.  Weights and boosts multiply.
+type FairShare struct {
+	Enabled            bool
+	MaxInFlight        int
+	AppWeights         map[string]int
+	RepositoryWeights  map[string]int
+	DefaultBranchBoost int
+	InteractiveBoost   int
+	QueueTimeout       time.Duration
+}
+
+func (f *FairShare) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled            bool           `yaml:"enabled"`
+		MaxInFlight        int            `yaml:"maxInFlight"`
+		AppWeights         map[string]int `yaml:"appWeights"`
+		RepositoryWeights  map[string]int `yaml:"repositoryWeights"`
+		DefaultBranchBoost int            `yaml:"defaultBranchBoost"`
+		InteractiveBoost   int            `yaml:"interactiveBoost"`
+		QueueTimeoutStr    string         `yaml:"queueTimeout"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.MaxInFlight < 0 || v.DefaultBranchBoost < 0 || v.InteractiveBoost < 0 {
+		return fmt.Errorf("%w: maxInFlight and boosts must not be negative", ErrInvalidFairShare)
+	}
+	for _, weights := range []map[string]int{v.AppWeights, v.RepositoryWeights} {
+		for name, w := range weights {
+			if w < 1 {
+				return fmt.Errorf("%w: weight of %s must be positive", ErrInvalidFairShare, name)
+			}
+		}
+	}
+	if v.QueueTimeoutStr != "" {
+		d, err := time.ParseDuration(v.QueueTimeoutStr)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("%w: invalid queueTimeout %q", ErrInvalidFairShare, v.QueueTimeoutStr)
+		}
+		f.QueueTimeout = d
+	}
+	if v.MaxInFlight == 0 {
+		v.MaxInFlight = 4
+	}
+	f.Enabled = v.Enabled
+	f.MaxInFlight = v.MaxInFlight
+	f.AppWeights = v.AppWeights
+	f.RepositoryWeights = v.RepositoryWeights
+	f.DefaultBranchBoost = v.DefaultBranchBoost
+	f.InteractiveBoost = v.InteractiveBoost
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestFairShare_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+maxInFlight: 8
+appWeights:
+  app-id: 3
+repositoryWeights:
+  acme/monorepo: 2
+defaultBranchBoost: 3
+interactiveBoost: 5
+queueTimeout: 15s`
+		var fairShare FairShare
+		err := yaml.Unmarshal([]byte(input), &fairShare)
+		require.NoError(t, err)
+		assert.Equal(t, FairShare{
+			Enabled:            true,
+			MaxInFlight:        8,
+			AppWeights:         map[string]int{"app-id": 3},
+			RepositoryWeights:  map[string]int{"acme/monorepo": 2},
+			DefaultBranchBoost: 3,
+			InteractiveBoost:   5,
+			QueueTimeout:       15 * time.Second,
+		}, fairShare)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		var fairShare FairShare
+		require.NoError(t, yaml.Unmarshal([]byte("enabled: true"), &fairShare))
+		assert.Equal(t, 4, fairShare.MaxInFlight)
+	})
+
+	invalid := map[string]string{
+		"zero weight":    "appWeights:\n  app-id: 0",
+		"negative boost": "interactiveBoost: -1",
+		"queue timeout":  "queueTimeout: soon",
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var fairShare FairShare
+			err := yaml.Unmarshal([]byte(input), &fairShare)
+			assert.ErrorIs(t, err, ErrInvalidFairShare)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"net/url"
+)
+
+// GitProxy configures the git smart-HTTP proxy.  When enabled, analysis and
+// autofix jobs clone through the runner at ServiceURL instead of the VCS.
+type GitProxy struct {
+	Enabled    bool
+	ServiceURL url.URL
+}
+
+func (g *GitProxy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled       bool   `yaml:"enabled"`
+		ServiceURLStr string `yaml:"serviceUrl"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	serviceURL, err := url.Parse(v.ServiceURLStr)
+	if err != nil {
+		return err
+	}
+	g.Enabled = v.Enabled
+	g.ServiceURL = *serviceURL
+	return nil
+}
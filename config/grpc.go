@@ -0,0 +1,56 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidGRPC = errors.New("config: invalid grpc")
+
+// GRPC configures the gRPC task API, served next to the HTTP server.  The
+// API is served over TLS when Certificate is set.
+type GRPC struct {
+	Enabled       bool
+	Port          int
+	WatchInterval time.Duration
+	Certificate   *tls.Certificate
+}
+
+func (g *GRPC) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled          bool   `yaml:"enabled"`
+		Port             int    `yaml:"port"`
+		WatchIntervalStr string `yaml:"watchInterval"`
+		TLS              *struct {
+			Certificate string `yaml:"certificate"`
+			Key         string `yaml:"key"`
+		} `yaml:"tls"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.Enabled && (v.Port <= 0 || v.Port > 65535) {
+		return fmt.Errorf("%w: invalid port %d", ErrInvalidGRPC, v.Port)
+	}
+	if v.WatchIntervalStr != "" {
+		d, err := time.ParseDuration(v.WatchIntervalStr)
+		if err != nil {
+			return err
+		}
+		g.WatchInterval = d
+	}
+	if v.TLS != nil {
+		cert, err := tls.X509KeyPair([]byte(strings.TrimSpace(v.TLS.Certificate)), []byte(strings.TrimSpace(v.TLS.Key)))
+		if err != nil {
+			return fmt.Errorf("%w: tls: %v", ErrInvalidGRPC, err)
+		}
+		g.Certificate = &cert
+	}
+	g.Enabled = v.Enabled
+	g.Port = v.Port
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGRPC_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+port: 9090
+watchInterval: 5s`
+		var g GRPC
+		err := yaml.Unmarshal([]byte(input), &g)
+		require.NoError(t, err)
+		assert.Equal(t, GRPC{Enabled: true, Port: 9090, WatchInterval: 5 * time.Second}, g)
+	})
+
+	t.Run("invalid tls", func(t *testing.T) {
+		var g GRPC
+		err := yaml.Unmarshal([]byte("tls:\n  certificate: foo\n  key: bar"), &g)
+		assert.ErrorIs(t, err, ErrInvalidGRPC)
+	})
+
+	t.Run("missing port", func(t *testing.T) {
+		var g GRPC
+		err := yaml.Unmarshal([]byte("enabled: true"), &g)
+		assert.ErrorIs(t, err, ErrInvalidGRPC)
+	})
+}
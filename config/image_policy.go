@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	ErrInvalidImagePolicy = errors.New("config: invalid image policy")
+
+	digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// ImagePolicy controls the images job pods run.  Catalog pins image tags to
+// digests, RegistryRewrites replaces registry hosts (for a pull-through
+// mirror), Allow and Deny restrict the analyzers and versions that may run,
+// and CoatVersion and AnalyzerVersions override the versions DeepSource
+// requests.
+type ImagePolicy struct {
+	// Catalog maps "<image>:<tag>" to the digest the tag is pinned to.  It is
+	// loaded from the file at CatalogPath.
+	Catalog     map[string]string
+	CatalogPath string
+
+	// RequireDigest rejects images that are not pinned in the catalog.
+	RequireDigest bool
+
+	RegistryRewrites map[string]string
+
+	Allow []*ImageRule
+	Deny  []*ImageRule
+
+	CoatVersion      string
+	AnalyzerVersions map[string]string
+}
+
+// ImageRule matches analyzers by shortcode, and their versions.  Both accept
+// shell patterns, and empty Versions matches every version.
+type ImageRule struct {
+	Analyzer string   `yaml:"analyzer"`
+	Versions []string `yaml:"versions"`
+}
+
+// imageCatalog is the format of the catalog file.
+type imageCatalog struct {
+	Images map[string]string `yaml:"images"`
+}
+
+func (p *ImagePolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Catalog          string            `yaml:"catalog"`
+		RequireDigest    bool              `yaml:"requireDigest"`
+		RegistryRewrites map[string]string `yaml:"registryRewrites"`
+		Allow            []*ImageRule      `yaml:"allow"`
+		Deny             []*ImageRule      `yaml:"deny"`
+		CoatVersion      string            `yaml:"coatVersion"`
+		AnalyzerVersions map[string]string `yaml:"analyzerVersions"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	for _, rule := range append(append([]*ImageRule{}, v.Allow...), v.Deny...) {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+	if v.RequireDigest && v.Catalog == "" {
+		return fmt.Errorf("%w: requireDigest needs a catalog", ErrInvalidImagePolicy)
+	}
+	if v.Catalog != "" {
+		catalog, err := loadImageCatalog(v.Catalog)
+		if err != nil {
+			return err
+		}
+		p.Catalog = catalog
+	}
+	p.CatalogPath = v.Catalog
+	p.RequireDigest = v.RequireDigest
+	p.RegistryRewrites = v.RegistryRewrites
+	p.Allow = v.Allow
+	p.Deny = v.Deny
+	p.CoatVersion = v.CoatVersion
+	p.AnalyzerVersions = v.AnalyzerVersions
+	return nil
+}
+
+func (r *ImageRule) validate() error {
+	if r == nil || r.Analyzer == "" {
+		return fmt.Errorf("%w: rule without analyzer", ErrInvalidImagePolicy)
+	}
+	for _, pattern := range append([]string{r.Analyzer}, r.Versions...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: pattern %q: %v", ErrInvalidImagePolicy, pattern, err)
+		}
+	}
+	return nil
+}
+
+func loadImageCatalog(name string) (map[string]string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to read image catalog: %w", err)
+	}
+	var catalog imageCatalog
+	if err := yaml.Unmarshal(b, &catalog); err != nil {
+		return nil, fmt.Errorf("config: failed to parse image catalog: %w", err)
+	}
+	for image, digest := range catalog.Images {
+		if !digestPattern.MatchString(digest) {
+			return nil, fmt.Errorf("%w: catalog entry %q: invalid digest %q", ErrInvalidImagePolicy, image, digest)
+		}
+	}
+	return catalog.Images, nil
+}
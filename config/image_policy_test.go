@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const testDigest = "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+func TestImagePolicy_UnmarshalYAML(t *testing.T) {
+	dir := t.TempDir()
+	catalog := filepath.Join(dir, "images.yaml")
+	require.NoError(t, os.WriteFile(catalog, []byte("images:\n  coat:v3.1.0: "+testDigest+"\n"), 0o600))
+	invalidCatalog := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalidCatalog, []byte("images:\n  coat:v3.1.0: latest\n"), 0o600))
+
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+catalog: ` + catalog + `
+requireDigest: true
+registryRewrites:
+  registry.deepsource.io: mirror.acme.internal
+allow:
+  - analyzer: python
+    versions: ["v1.*"]
+deny:
+  - analyzer: "*"
+    versions: ["*-beta*"]
+coatVersion: v3.1.0
+analyzerVersions:
+  python: v1.2.0`
+		var policy ImagePolicy
+		err := yaml.Unmarshal([]byte(input), &policy)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"coat:v3.1.0": testDigest}, policy.Catalog)
+		assert.True(t, policy.RequireDigest)
+		assert.Equal(t, "mirror.acme.internal", policy.RegistryRewrites["registry.deepsource.io"])
+		assert.Equal(t, []*ImageRule{{Analyzer: "python", Versions: []string{"v1.*"}}}, policy.Allow)
+		assert.Equal(t, []*ImageRule{{Analyzer: "*", Versions: []string{"*-beta*"}}}, policy.Deny)
+		assert.Equal(t, "v3.1.0", policy.CoatVersion)
+		assert.Equal(t, "v1.2.0", policy.AnalyzerVersions["python"])
+	})
+
+	invalid := map[string]string{
+		"invalid digest":         "catalog: " + invalidCatalog,
+		"digest without catalog": "requireDigest: true",
+		"rule without analyzer":  "allow:\n  - versions: [v1]",
+		"invalid pattern":        "deny:\n  - analyzer: \"[\"",
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var policy ImagePolicy
+			err := yaml.Unmarshal([]byte(input), &policy)
+			assert.ErrorIs(t, err, ErrInvalidImagePolicy)
+		})
+	}
+
+	t.Run("missing catalog", func(t *testing.T) {
+		var policy ImagePolicy
+		err := yaml.Unmarshal([]byte("catalog: "+filepath.Join(dir, "missing.yaml")), &policy)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrInvalidImageVerification = errors.New("config: invalid image verification")
+
+// ImageVerification configures signature verification of job images.
+// PublicKeys holds the PEM encoded keys read from the files at
+// PublicKeyPaths.
+type ImageVerification struct {
+	Enabled        bool
+	PublicKeyPaths []string
+	PublicKeys     [][]byte
+
+	// Username and Password authenticate with the registry to read
+	// signatures.  Empty for anonymous access.
+	Username string
+	Password string
+}
+
+func (v *ImageVerification) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled    bool     `yaml:"enabled"`
+		PublicKeys []string `yaml:"publicKeys"`
+		Username   string   `yaml:"username"`
+		Password   string   `yaml:"password"`
+	}
+	var t T
+	if err := unmarshal(&t); err != nil {
+		return err
+	}
+	if t.Enabled && len(t.PublicKeys) == 0 {
+		return fmt.Errorf("%w: at least one public key is required", ErrInvalidImageVerification)
+	}
+	keys := make([][]byte, 0, len(t.PublicKeys))
+	for _, path := range t.PublicKeys {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("config: failed to read image verification key: %w", err)
+		}
+		keys = append(keys, b)
+	}
+	v.Enabled = t.Enabled
+	v.PublicKeyPaths = t.PublicKeys
+	v.PublicKeys = keys
+	v.Username = t.Username
+	v.Password = t.Password
+	return nil
+}
@@ -7,16 +7,18 @@ import (
 )
 
 type Kubernetes struct {
-	Namespace     string            `yaml:"namespace"`
-	NodeSelector  map[string]string `yaml:"nodeSelector"`
-	ImageRegistry *ImageRegistry    `yaml:"imageRegistry"`
+	Namespace       string            `yaml:"namespace"`
+	NodeSelector    map[string]string `yaml:"nodeSelector"`
+	ImageRegistry   *ImageRegistry    `yaml:"imageRegistry"`
+	SecurityProfile *SecurityProfile  `yaml:"securityProfile"`
 }
 
 func (k *Kubernetes) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type T struct {
-		Namespace     string            `yaml:"namespace"`
-		NodeSelector  map[string]string `yaml:"nodeSelector"`
-		ImageRegistry *ImageRegistry    `yaml:"imageRegistry"`
+		Namespace       string            `yaml:"namespace"`
+		NodeSelector    map[string]string `yaml:"nodeSelector"`
+		ImageRegistry   *ImageRegistry    `yaml:"imageRegistry"`
+		SecurityProfile *SecurityProfile  `yaml:"securityProfile"`
 	}
 	var v T
 	if err := unmarshal(&v); err != nil {
@@ -48,6 +50,7 @@ func (k *Kubernetes) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	k.Namespace = v.Namespace
 	k.NodeSelector = v.NodeSelector
 	k.ImageRegistry = v.ImageRegistry
+	k.SecurityProfile = v.SecurityProfile
 	return nil
 }
 
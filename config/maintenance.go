@@ -0,0 +1,41 @@
+package config
+
+import (
+	"time"
+)
+
+// Maintenance configures maintenance mode, which pauses new tasks.  When
+// Enabled is set, the runner starts in maintenance mode.
+type Maintenance struct {
+	Enabled       bool
+	RetryAfter    time.Duration
+	DrainInterval time.Duration
+}
+
+func (m *Maintenance) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled          bool   `yaml:"enabled"`
+		RetryAfterStr    string `yaml:"retryAfter"`
+		DrainIntervalStr string `yaml:"drainInterval"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.RetryAfterStr != "" {
+		d, err := time.ParseDuration(v.RetryAfterStr)
+		if err != nil {
+			return err
+		}
+		m.RetryAfter = d
+	}
+	if v.DrainIntervalStr != "" {
+		d, err := time.ParseDuration(v.DrainIntervalStr)
+		if err != nil {
+			return err
+		}
+		m.DrainInterval = d
+	}
+	m.Enabled = v.Enabled
+	return nil
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMaintenance_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+retryAfter: 5m
+drainInterval: 30s`
+		var maintenance Maintenance
+		err := yaml.Unmarshal([]byte(input), &maintenance)
+		require.NoError(t, err)
+		assert.True(t, maintenance.Enabled)
+		assert.Equal(t, 5*time.Minute, maintenance.RetryAfter)
+		assert.Equal(t, 30*time.Second, maintenance.DrainInterval)
+	})
+
+	t.Run("invalid duration", func(t *testing.T) {
+		var maintenance Maintenance
+		err := yaml.Unmarshal([]byte(`drainInterval: often`), &maintenance)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Mirror configures the shared repository mirror cache.  The runner keeps
+// bare mirrors on the volume mounted at Path, and job pods mount the
+// ClaimName persistent volume claim read-only to clone with them.
+type Mirror struct {
+	Enabled   bool
+	Path      string
+	ClaimName string
+	MaxSize   int64
+	MaxAge    time.Duration
+}
+
+func (m *Mirror) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled    bool   `yaml:"enabled"`
+		Path       string `yaml:"path"`
+		ClaimName  string `yaml:"claimName"`
+		MaxSizeStr string `yaml:"maxSize"`
+		MaxAgeStr  string `yaml:"maxAge"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.MaxSizeStr != "" {
+		q, err := resource.ParseQuantity(v.MaxSizeStr)
+		if err != nil {
+			return err
+		}
+		m.MaxSize = q.Value()
+	}
+	if v.MaxAgeStr != "" {
+		d, err := time.ParseDuration(v.MaxAgeStr)
+		if err != nil {
+			return err
+		}
+		m.MaxAge = d
+	}
+	m.Enabled = v.Enabled
+	m.Path = v.Path
+	m.ClaimName = v.ClaimName
+	return nil
+}
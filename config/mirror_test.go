@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMirror_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+path: /mirror
+claimName: runner-mirror
+maxSize: 200Gi
+maxAge: 72h`
+		var mirror Mirror
+		err := yaml.Unmarshal([]byte(input), &mirror)
+		require.NoError(t, err)
+		assert.True(t, mirror.Enabled)
+		assert.Equal(t, "/mirror", mirror.Path)
+		assert.Equal(t, "runner-mirror", mirror.ClaimName)
+		assert.Equal(t, int64(200<<30), mirror.MaxSize)
+		assert.Equal(t, 72*time.Hour, mirror.MaxAge)
+	})
+
+	t.Run("invalid size", func(t *testing.T) {
+		var mirror Mirror
+		err := yaml.Unmarshal([]byte(`maxSize: lots`), &mirror)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+var ErrInvalidNetworkPolicy = errors.New("config: invalid network policy")
+
+// NetworkPolicy configures the NetworkPolicies created with every job.
+// Egress maps container names, coat and marvin, to the destinations they
+// may connect to.
+type NetworkPolicy struct {
+	Enabled  bool
+	AllowDNS bool
+	Egress   map[string][]*EgressPeer
+}
+
+// EgressPeer is a CIDR or a host, resolved when the job is created, and an
+// optional port.
+type EgressPeer struct {
+	CIDR string `yaml:"cidr"`
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+}
+
+func (n *NetworkPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled  bool                     `yaml:"enabled"`
+		AllowDNS *bool                    `yaml:"allowDNS"`
+		Egress   map[string][]*EgressPeer `yaml:"egress"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	for container, peers := range v.Egress {
+		for _, peer := range peers {
+			if err := peer.validate(); err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrInvalidNetworkPolicy, container, err)
+			}
+		}
+	}
+	n.Enabled = v.Enabled
+	n.AllowDNS = v.AllowDNS == nil || *v.AllowDNS
+	n.Egress = v.Egress
+	return nil
+}
+
+func (p *EgressPeer) validate() error {
+	if p == nil || (p.CIDR == "") == (p.Host == "") {
+		return errors.New("exactly one of cidr and host is required")
+	}
+	if p.CIDR != "" {
+		if _, _, err := net.ParseCIDR(p.CIDR); err != nil {
+			return err
+		}
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return fmt.Errorf("invalid port %d", p.Port)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestNetworkPolicy_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+egress:
+  coat:
+    - host: github.com
+      port: 443
+  marvin:
+    - cidr: 10.0.0.0/8`
+		var policy NetworkPolicy
+		err := yaml.Unmarshal([]byte(input), &policy)
+		require.NoError(t, err)
+		assert.True(t, policy.Enabled)
+		assert.True(t, policy.AllowDNS)
+		assert.Equal(t, []*EgressPeer{{Host: "github.com", Port: 443}}, policy.Egress["coat"])
+		assert.Equal(t, []*EgressPeer{{CIDR: "10.0.0.0/8"}}, policy.Egress["marvin"])
+	})
+
+	t.Run("dns disabled", func(t *testing.T) {
+		var policy NetworkPolicy
+		require.NoError(t, yaml.Unmarshal([]byte("allowDNS: false"), &policy))
+		assert.False(t, policy.AllowDNS)
+	})
+
+	invalid := map[string]string{
+		"cidr and host": "egress:\n  coat:\n    - cidr: 10.0.0.0/8\n      host: github.com",
+		"empty peer":    "egress:\n  coat:\n    - port: 443",
+		"invalid cidr":  "egress:\n  coat:\n    - cidr: 10.0.0.0",
+		"invalid port":  "egress:\n  coat:\n    - host: github.com\n      port: 70000",
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var policy NetworkPolicy
+			err := yaml.Unmarshal([]byte(input), &policy)
+			assert.ErrorIs(t, err, ErrInvalidNetworkPolicy)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidNotificationSink = errors.New("config: invalid notification sink")
+
+// Types of notification sinks.
+const (
+	NotificationSinkWebhook = "webhook"
+	NotificationSinkSlack   = "slack"
+	NotificationSinkEmail   = "email"
+)
+
+// Notifications configures the notifications of runner problems, and the
+// sinks they are sent to.
+type Notifications struct {
+	// FailureThreshold failures of the jobs of an analyzer within
+	// FailureWindow are notified.
+	FailureThreshold int
+	FailureWindow    time.Duration
+
+	Sinks []*NotificationSink
+}
+
+func (n *Notifications) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		FailureThreshold int                 `yaml:"failureThreshold"`
+		FailureWindowStr string              `yaml:"failureWindow"`
+		Sinks            []*NotificationSink `yaml:"sinks"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.FailureWindowStr != "" {
+		d, err := time.ParseDuration(v.FailureWindowStr)
+		if err != nil {
+			return err
+		}
+		n.FailureWindow = d
+	}
+	n.FailureThreshold = v.FailureThreshold
+	n.Sinks = v.Sinks
+	return nil
+}
+
+// NotificationSink is a webhook, Slack-compatible webhook or SMTP relay that
+// receives the notifications matching Types and Fields.  Payloads are signed
+// with Secret.  At most RateLimit notifications are sent per RatePeriod.
+type NotificationSink struct {
+	Name   string
+	Type   string
+	URL    string
+	Secret string
+	SMTP   *SMTP
+
+	Types  []string
+	Fields map[string]string
+
+	RateLimit  int
+	RatePeriod time.Duration
+}
+
+// SMTP is the relay emails are sent through.
+type SMTP struct {
+	Addr string   `yaml:"addr"`
+	From string   `yaml:"from"`
+	To   []string `yaml:"to"`
+}
+
+func (s *NotificationSink) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Name          string            `yaml:"name"`
+		Type          string            `yaml:"type"`
+		URL           string            `yaml:"url"`
+		Secret        string            `yaml:"secret"`
+		SMTP          *SMTP             `yaml:"smtp"`
+		Types         []string          `yaml:"types"`
+		Fields        map[string]string `yaml:"fields"`
+		RateLimit     int               `yaml:"rateLimit"`
+		RatePeriodStr string            `yaml:"ratePeriod"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.Name == "" || v.Secret == "" {
+		return fmt.Errorf("%w: name and secret are required", ErrInvalidNotificationSink)
+	}
+	switch v.Type {
+	case NotificationSinkWebhook, NotificationSinkSlack:
+		if v.URL == "" {
+			return fmt.Errorf("%w: %s: url is required", ErrInvalidNotificationSink, v.Name)
+		}
+	case NotificationSinkEmail:
+		if v.SMTP == nil || v.SMTP.Addr == "" || v.SMTP.From == "" || len(v.SMTP.To) == 0 {
+			return fmt.Errorf("%w: %s: smtp addr, from and to are required", ErrInvalidNotificationSink, v.Name)
+		}
+	default:
+		return fmt.Errorf("%w: %s: unknown type %q", ErrInvalidNotificationSink, v.Name, v.Type)
+	}
+	if v.RateLimit < 0 {
+		return fmt.Errorf("%w: %s: rateLimit must not be negative", ErrInvalidNotificationSink, v.Name)
+	}
+	s.RatePeriod = time.Hour
+	if v.RatePeriodStr != "" {
+		d, err := time.ParseDuration(v.RatePeriodStr)
+		if err != nil {
+			return err
+		}
+		s.RatePeriod = d
+	}
+	s.Name = v.Name
+	s.Type = v.Type
+	s.URL = v.URL
+	s.Secret = v.Secret
+	s.SMTP = v.SMTP
+	s.Types = v.Types
+	s.Fields = v.Fields
+	s.RateLimit = v.RateLimit
+	return nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestNotifications_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+failureThreshold: 5
+failureWindow: 1h
+sinks:
+  - name: ops
+    type: slack
+    url: https://hooks.slack.com/services/T0/B0/X
+    secret: slack-secret
+    types: [job_failures, quota_exhausted]
+    fields:
+      app_id: gh
+    rateLimit: 10
+    ratePeriod: 30m
+  - name: oncall
+    type: email
+    secret: email-secret
+    smtp:
+      addr: localhost:25
+      from: runner@example.com
+      to: [oncall@example.com]`
+		var n Notifications
+		err := yaml.Unmarshal([]byte(input), &n)
+		require.NoError(t, err)
+		assert.Equal(t, Notifications{
+			FailureThreshold: 5,
+			FailureWindow:    time.Hour,
+			Sinks: []*NotificationSink{
+				{
+					Name:       "ops",
+					Type:       NotificationSinkSlack,
+					URL:        "https://hooks.slack.com/services/T0/B0/X",
+					Secret:     "slack-secret",
+					Types:      []string{"job_failures", "quota_exhausted"},
+					Fields:     map[string]string{"app_id": "gh"},
+					RateLimit:  10,
+					RatePeriod: 30 * time.Minute,
+				},
+				{
+					Name:       "oncall",
+					Type:       NotificationSinkEmail,
+					Secret:     "email-secret",
+					SMTP:       &SMTP{Addr: "localhost:25", From: "runner@example.com", To: []string{"oncall@example.com"}},
+					RatePeriod: time.Hour,
+				},
+			},
+		}, n)
+	})
+
+	t.Run("invalid sinks", func(t *testing.T) {
+		for _, input := range []string{
+			"sinks: [{name: ops, type: webhook, url: https://example.com}]",
+			"sinks: [{name: ops, type: webhook, secret: s}]",
+			"sinks: [{name: ops, type: email, secret: s, smtp: {addr: localhost:25}}]",
+			"sinks: [{name: ops, type: pager, secret: s}]",
+		} {
+			var n Notifications
+			err := yaml.Unmarshal([]byte(input), &n)
+			assert.ErrorIs(t, err, ErrInvalidNotificationSink, input)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var ErrInvalidOrgPolicy = errors.New("config: invalid org policy")
+
+// OrgPolicy configures the organization policy incoming runs are checked
+// against.  The rules are read from the file at Path, which is reloaded when
+// it changes, as checked every ReloadInterval.
+type OrgPolicy struct {
+	Path           string
+	ReloadInterval time.Duration
+}
+
+func (p *OrgPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Path              string `yaml:"path"`
+		ReloadIntervalStr string `yaml:"reloadInterval"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.Path == "" {
+		return fmt.Errorf("%w: path is required", ErrInvalidOrgPolicy)
+	}
+	p.ReloadInterval = 30 * time.Second
+	if v.ReloadIntervalStr != "" {
+		d, err := time.ParseDuration(v.ReloadIntervalStr)
+		if err != nil {
+			return err
+		}
+		p.ReloadInterval = d
+	}
+	p.Path = v.Path
+	return nil
+}
+
+// OrgPolicyRules are the rules of the organization policy file.
+//
+// Analyzers, autofixers and transformers are matched by shortcode.  Those
+// in Deny are never run, and when Allow is set, only those in it are.
+// RequiredVersions maps analyzers to the versions they must run at, as
+// path.Match patterns.  ExcludedRepositories are owner/name patterns of the
+// repositories never analyzed or autofixed.  MaxChecks limits the checks of
+// an analysis run; zero is unlimited.
+type OrgPolicyRules struct {
+	Analyzers            *OrgPolicyList    `yaml:"analyzers"`
+	Transformers         *OrgPolicyList    `yaml:"transformers"`
+	MaxChecks            int               `yaml:"maxChecks"`
+	ExcludedRepositories []string          `yaml:"excludedRepositories"`
+	RequiredVersions     map[string]string `yaml:"requiredVersions"`
+}
+
+// OrgPolicyList allows and denies items by name.
+type OrgPolicyList struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// LoadOrgPolicyRules reads and validates the rules of an organization
+// policy file.
+func LoadOrgPolicyRules(r io.Reader) (*OrgPolicyRules, error) {
+	rules := new(OrgPolicyRules)
+	if err := yaml.NewDecoder(r).Decode(rules); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOrgPolicy, err)
+	}
+	if rules.MaxChecks < 0 {
+		return nil, fmt.Errorf("%w: maxChecks must not be negative", ErrInvalidOrgPolicy)
+	}
+	for _, pattern := range rules.ExcludedRepositories {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: excluded repository %q: %v", ErrInvalidOrgPolicy, pattern, err)
+		}
+	}
+	for analyzer, pattern := range rules.RequiredVersions {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: required version of %s %q: %v", ErrInvalidOrgPolicy, analyzer, pattern, err)
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestOrgPolicy_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		var policy OrgPolicy
+		err := yaml.Unmarshal([]byte("path: /etc/runner/policy.yaml\nreloadInterval: 1m"), &policy)
+		require.NoError(t, err)
+		assert.Equal(t, OrgPolicy{Path: "/etc/runner/policy.yaml", ReloadInterval: time.Minute}, policy)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		var policy OrgPolicy
+		err := yaml.Unmarshal([]byte("path: /etc/runner/policy.yaml"), &policy)
+		require.NoError(t, err)
+		assert.Equal(t, 30*time.Second, policy.ReloadInterval)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var policy OrgPolicy
+		err := yaml.Unmarshal([]byte("reloadInterval: 1m"), &policy)
+		assert.ErrorIs(t, err, ErrInvalidOrgPolicy)
+	})
+}
+
+func TestLoadOrgPolicyRules(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		rules, err := LoadOrgPolicyRules(strings.NewReader(`
+analyzers:
+  allow: [python, go]
+  deny: [secrets]
+transformers:
+  deny: [autopep8]
+maxChecks: 5
+excludedRepositories: ["acme/legacy-*"]
+requiredVersions:
+  python: "v2.*"`))
+		require.NoError(t, err)
+		assert.Equal(t, &OrgPolicyRules{
+			Analyzers:            &OrgPolicyList{Allow: []string{"python", "go"}, Deny: []string{"secrets"}},
+			Transformers:         &OrgPolicyList{Deny: []string{"autopep8"}},
+			MaxChecks:            5,
+			ExcludedRepositories: []string{"acme/legacy-*"},
+			RequiredVersions:     map[string]string{"python": "v2.*"},
+		}, rules)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		rules, err := LoadOrgPolicyRules(strings.NewReader(""))
+		require.NoError(t, err)
+		assert.Equal(t, &OrgPolicyRules{}, rules)
+	})
+
+	invalid := map[string]string{
+		"malformed":          "analyzers: [",
+		"negative maxChecks": "maxChecks: -1",
+		"invalid repository": `excludedRepositories: ["acme/["]`,
+		"invalid version":    `requiredVersions: {python: "v["}`,
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadOrgPolicyRules(strings.NewReader(input))
+			assert.ErrorIs(t, err, ErrInvalidOrgPolicy)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+var ErrInvalidPushPolicy = errors.New("config: invalid push policy")
+
+// PushPolicy restricts the pushes of transformer and patcher jobs.
+// ProtectedBranches are globs of branches never pushed to,
+// ProtectDefaultBranch forbids pushing to the default branch of each
+// repository, and RequirePullRequest forbids pushing to the branch the
+// changes are based on.  MaxFiles and MaxPatches limit the size of pushes,
+// and reject transformer runs, whose size is unknown; zero is unlimited.
+type PushPolicy struct {
+	ProtectedBranches    []string
+	ProtectDefaultBranch bool
+	RequirePullRequest   bool
+	MaxFiles             int
+	MaxPatches           int
+}
+
+func (p *PushPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		ProtectedBranches    []string `yaml:"protectedBranches"`
+		ProtectDefaultBranch bool     `yaml:"protectDefaultBranch"`
+		RequirePullRequest   bool     `yaml:"requirePullRequest"`
+		MaxFiles             int      `yaml:"maxFiles"`
+		MaxPatches           int      `yaml:"maxPatches"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	for _, pattern := range v.ProtectedBranches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: protected branch %q: %v", ErrInvalidPushPolicy, pattern, err)
+		}
+	}
+	if v.MaxFiles < 0 || v.MaxPatches < 0 {
+		return fmt.Errorf("%w: maxFiles and maxPatches must not be negative", ErrInvalidPushPolicy)
+	}
+	p.ProtectedBranches = v.ProtectedBranches
+	p.ProtectDefaultBranch = v.ProtectDefaultBranch
+	p.RequirePullRequest = v.RequirePullRequest
+	p.MaxFiles = v.MaxFiles
+	p.MaxPatches = v.MaxPatches
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPushPolicy_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+protectedBranches: ["main", "release/*"]
+protectDefaultBranch: true
+requirePullRequest: true
+maxFiles: 50
+maxPatches: 200`
+		var policy PushPolicy
+		err := yaml.Unmarshal([]byte(input), &policy)
+		require.NoError(t, err)
+		assert.Equal(t, PushPolicy{
+			ProtectedBranches:    []string{"main", "release/*"},
+			ProtectDefaultBranch: true,
+			RequirePullRequest:   true,
+			MaxFiles:             50,
+			MaxPatches:           200,
+		}, policy)
+	})
+
+	invalid := map[string]string{
+		"invalid glob":       `protectedBranches: ["release/["]`,
+		"negative max files": "maxFiles: -1",
+		"negative patches":   "maxPatches: -1",
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var policy PushPolicy
+			err := yaml.Unmarshal([]byte(input), &policy)
+			assert.ErrorIs(t, err, ErrInvalidPushPolicy)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"time"
+)
+
+// Quota configures the admission of jobs against the ResourceQuotas and
+// LimitRanges of the Kubernetes namespace.
+type Quota struct {
+	Enabled     bool
+	RetryAfter  time.Duration
+	Reservation time.Duration
+}
+
+func (q *Quota) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled        bool   `yaml:"enabled"`
+		RetryAfterStr  string `yaml:"retryAfter"`
+		ReservationStr string `yaml:"reservation"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.RetryAfterStr != "" {
+		d, err := time.ParseDuration(v.RetryAfterStr)
+		if err != nil {
+			return err
+		}
+		q.RetryAfter = d
+	}
+	if v.ReservationStr != "" {
+		d, err := time.ParseDuration(v.ReservationStr)
+		if err != nil {
+			return err
+		}
+		q.Reservation = d
+	}
+	q.Enabled = v.Enabled
+	return nil
+}
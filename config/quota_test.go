@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestQuota_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+retryAfter: 45s
+reservation: 2m`
+		var quota Quota
+		err := yaml.Unmarshal([]byte(input), &quota)
+		require.NoError(t, err)
+		assert.True(t, quota.Enabled)
+		assert.Equal(t, 45*time.Second, quota.RetryAfter)
+		assert.Equal(t, 2*time.Minute, quota.Reservation)
+	})
+
+	t.Run("invalid duration", func(t *testing.T) {
+		var quota Quota
+		err := yaml.Unmarshal([]byte(`retryAfter: soon`), &quota)
+		assert.Error(t, err)
+	})
+}
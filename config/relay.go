@@ -0,0 +1,41 @@
+package config
+
+import (
+	"net/url"
+)
+
+const (
+	DefaultRelayMaxAttempts = 10
+)
+
+// Relay configures the in-runner results relay.  When enabled, jobs post
+// their results to the runner at ServiceURL instead of DeepSource, and the
+// runner forwards them upstream.
+type Relay struct {
+	Enabled     bool
+	ServiceURL  url.URL
+	MaxAttempts int
+}
+
+func (r *Relay) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled       bool   `yaml:"enabled"`
+		ServiceURLStr string `yaml:"serviceUrl"`
+		MaxAttempts   int    `yaml:"maxAttempts"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	serviceURL, err := url.Parse(v.ServiceURLStr)
+	if err != nil {
+		return err
+	}
+	if v.MaxAttempts <= 0 {
+		v.MaxAttempts = DefaultRelayMaxAttempts
+	}
+	r.Enabled = v.Enabled
+	r.ServiceURL = *serviceURL
+	r.MaxAttempts = v.MaxAttempts
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestRelay_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+serviceUrl: "http://runner.runner.svc.cluster.local:8080"
+maxAttempts: 3`
+		var relay Relay
+		err := yaml.Unmarshal([]byte(input), &relay)
+		require.NoError(t, err)
+		assert.True(t, relay.Enabled)
+		assert.Equal(t, "http://runner.runner.svc.cluster.local:8080", relay.ServiceURL.String())
+		assert.Equal(t, 3, relay.MaxAttempts)
+	})
+
+	t.Run("default max attempts", func(t *testing.T) {
+		input := `
+enabled: true
+serviceUrl: "http://runner:8080"`
+		var relay Relay
+		err := yaml.Unmarshal([]byte(input), &relay)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultRelayMaxAttempts, relay.MaxAttempts)
+	})
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidRightsizing = errors.New("config: invalid rightsizing")
+
+// Rightsizing configures the sampling of the usage of analysis pods and the
+// analyzer limits recommended from it.  With Apply set, analysis jobs run
+// with the recommended limits, clamped to Bounds, which are then required.
+// Headroom is nil when not set.
+type Rightsizing struct {
+	Enabled        bool
+	SampleInterval time.Duration
+	Window         time.Duration
+	Percentile     int
+	Headroom       *float64
+	MinSamples     int
+	Apply          bool
+	Bounds         *RightsizingBounds
+}
+
+// RightsizingBounds are in millicores and MiB, like analyzer limits.  Zero
+// is unbounded.
+type RightsizingBounds struct {
+	MinCPU    int64 `yaml:"minCPU"`
+	MaxCPU    int64 `yaml:"maxCPU"`
+	MinMemory int64 `yaml:"minMemory"`
+	MaxMemory int64 `yaml:"maxMemory"`
+}
+
+func (r *Rightsizing) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled           bool               `yaml:"enabled"`
+		SampleIntervalStr string             `yaml:"sampleInterval"`
+		WindowStr         string             `yaml:"window"`
+		Percentile        int                `yaml:"percentile"`
+		Headroom          *float64           `yaml:"headroom"`
+		MinSamples        int                `yaml:"minSamples"`
+		Apply             bool               `yaml:"apply"`
+		Bounds            *RightsizingBounds `yaml:"bounds"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.SampleIntervalStr != "" {
+		d, err := time.ParseDuration(v.SampleIntervalStr)
+		if err != nil {
+			return err
+		}
+		r.SampleInterval = d
+	}
+	if v.WindowStr != "" {
+		d, err := time.ParseDuration(v.WindowStr)
+		if err != nil {
+			return err
+		}
+		r.Window = d
+	}
+	switch v.Percentile {
+	case 0, 50, 90, 95, 99, 100:
+	default:
+		return fmt.Errorf("%w: percentile must be one of 50, 90, 95, 99 or 100", ErrInvalidRightsizing)
+	}
+	if v.Headroom != nil && *v.Headroom < 0 {
+		return fmt.Errorf("%w: headroom must not be negative", ErrInvalidRightsizing)
+	}
+	if b := v.Bounds; b != nil {
+		if b.MinCPU < 0 || b.MaxCPU < 0 || b.MinMemory < 0 || b.MaxMemory < 0 {
+			return fmt.Errorf("%w: bounds must not be negative", ErrInvalidRightsizing)
+		}
+		if b.MaxCPU > 0 && b.MinCPU > b.MaxCPU || b.MaxMemory > 0 && b.MinMemory > b.MaxMemory {
+			return fmt.Errorf("%w: minimum bounds must not exceed maximum bounds", ErrInvalidRightsizing)
+		}
+	}
+	if v.Apply && v.Bounds == nil {
+		return fmt.Errorf("%w: apply requires bounds", ErrInvalidRightsizing)
+	}
+	r.Enabled = v.Enabled
+	r.Percentile = v.Percentile
+	r.Headroom = v.Headroom
+	r.MinSamples = v.MinSamples
+	r.Apply = v.Apply
+	r.Bounds = v.Bounds
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestRightsizing_UnmarshalYAML(t *testing.T) {
+	headroom := 0.3
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+sampleInterval: 15s
+window: 168h
+percentile: 99
+headroom: 0.3
+minSamples: 50
+apply: true
+bounds:
+  minCPU: 250
+  maxCPU: 4000
+  minMemory: 256
+  maxMemory: 8192`
+		var rightsizing Rightsizing
+		err := yaml.Unmarshal([]byte(input), &rightsizing)
+		require.NoError(t, err)
+		assert.Equal(t, Rightsizing{
+			Enabled:        true,
+			SampleInterval: 15 * time.Second,
+			Window:         168 * time.Hour,
+			Percentile:     99,
+			Headroom:       &headroom,
+			MinSamples:     50,
+			Apply:          true,
+			Bounds:         &RightsizingBounds{MinCPU: 250, MaxCPU: 4000, MinMemory: 256, MaxMemory: 8192},
+		}, rightsizing)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, input := range []string{
+			`percentile: 75`,
+			`headroom: -0.1`,
+			"bounds:\n  minCPU: 2000\n  maxCPU: 1000",
+			`apply: true`,
+		} {
+			var rightsizing Rightsizing
+			err := yaml.Unmarshal([]byte(input), &rightsizing)
+			assert.ErrorIs(t, err, ErrInvalidRightsizing, input)
+		}
+	})
+
+	t.Run("zero headroom", func(t *testing.T) {
+		var rightsizing Rightsizing
+		err := yaml.Unmarshal([]byte(`headroom: 0`), &rightsizing)
+		require.NoError(t, err)
+		require.NotNil(t, rightsizing.Headroom)
+		assert.Zero(t, *rightsizing.Headroom)
+
+		rightsizing = Rightsizing{}
+		require.NoError(t, yaml.Unmarshal([]byte(`enabled: true`), &rightsizing))
+		assert.Nil(t, rightsizing.Headroom)
+	})
+
+	t.Run("invalid duration", func(t *testing.T) {
+		var rightsizing Rightsizing
+		err := yaml.Unmarshal([]byte(`window: month`), &rightsizing)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	SecurityProfileBaseline   = "baseline"
+	SecurityProfileRestricted = "restricted"
+	SecurityProfileSandboxed  = "sandboxed"
+)
+
+var ErrInvalidSecurityProfile = errors.New("config: invalid security profile")
+
+// SecurityProfile selects the security settings of job pods.  Sandboxed
+// pods run with RuntimeClassName, gvisor by default.
+type SecurityProfile struct {
+	Name             string
+	RuntimeClassName string
+}
+
+func (p *SecurityProfile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Name             string `yaml:"name"`
+		RuntimeClassName string `yaml:"runtimeClassName"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	switch v.Name {
+	case "":
+		v.Name = SecurityProfileBaseline
+	case SecurityProfileBaseline, SecurityProfileRestricted, SecurityProfileSandboxed:
+	default:
+		return fmt.Errorf("%w: unknown profile %q", ErrInvalidSecurityProfile, v.Name)
+	}
+	if v.RuntimeClassName != "" && v.Name != SecurityProfileSandboxed {
+		return fmt.Errorf("%w: runtimeClassName requires the sandboxed profile", ErrInvalidSecurityProfile)
+	}
+	p.Name = v.Name
+	p.RuntimeClassName = v.RuntimeClassName
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSecurityProfile_UnmarshalYAML(t *testing.T) {
+	t.Run("sandboxed", func(t *testing.T) {
+		var profile SecurityProfile
+		err := yaml.Unmarshal([]byte("name: sandboxed\nruntimeClassName: kata"), &profile)
+		require.NoError(t, err)
+		assert.Equal(t, SecurityProfile{Name: SecurityProfileSandboxed, RuntimeClassName: "kata"}, profile)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		var profile SecurityProfile
+		require.NoError(t, yaml.Unmarshal([]byte("{}"), &profile))
+		assert.Equal(t, SecurityProfileBaseline, profile.Name)
+	})
+
+	invalid := map[string]string{
+		"unknown profile": "name: privileged",
+		"runtime class":   "name: restricted\nruntimeClassName: gvisor",
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var profile SecurityProfile
+			err := yaml.Unmarshal([]byte(input), &profile)
+			assert.ErrorIs(t, err, ErrInvalidSecurityProfile)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import "time"
+
+// Tunnel configures outbound-only connectivity: the runner polls DeepSource
+// for requests instead of DeepSource connecting to the runner.
+type Tunnel struct {
+	Enabled        bool
+	Concurrency    int
+	PollTimeout    time.Duration
+	ReconnectDelay time.Duration
+}
+
+func (t *Tunnel) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled           bool   `yaml:"enabled"`
+		Concurrency       int    `yaml:"concurrency"`
+		PollTimeoutStr    string `yaml:"pollTimeout"`
+		ReconnectDelayStr string `yaml:"reconnectDelay"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.PollTimeoutStr != "" {
+		d, err := time.ParseDuration(v.PollTimeoutStr)
+		if err != nil {
+			return err
+		}
+		t.PollTimeout = d
+	}
+	if v.ReconnectDelayStr != "" {
+		d, err := time.ParseDuration(v.ReconnectDelayStr)
+		if err != nil {
+			return err
+		}
+		t.ReconnectDelay = d
+	}
+	t.Enabled = v.Enabled
+	t.Concurrency = v.Concurrency
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestTunnel_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+concurrency: 8
+pollTimeout: 20s
+reconnectDelay: 1s`
+		var tunnel Tunnel
+		err := yaml.Unmarshal([]byte(input), &tunnel)
+		require.NoError(t, err)
+		assert.Equal(t, Tunnel{
+			Enabled:        true,
+			Concurrency:    8,
+			PollTimeout:    20 * time.Second,
+			ReconnectDelay: time.Second,
+		}, tunnel)
+	})
+
+	t.Run("invalid duration", func(t *testing.T) {
+		var tunnel Tunnel
+		err := yaml.Unmarshal([]byte("enabled: true\npollTimeout: soon"), &tunnel)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidUsage = errors.New("config: invalid usage")
+
+// Usage configures the accounting of job usage per app and repository, and
+// the job-minute quotas of apps.  Apps without an entry in Apps get the
+// default limits.  Zero limits are unlimited.
+type Usage struct {
+	Enabled bool
+	Limits  *UsageLimits
+	Apps    map[string]*UsageLimits
+}
+
+type UsageLimits struct {
+	DailyMinutes   int64 `yaml:"dailyMinutes"`
+	MonthlyMinutes int64 `yaml:"monthlyMinutes"`
+}
+
+func (u *Usage) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type T struct {
+		Enabled        bool                    `yaml:"enabled"`
+		DailyMinutes   int64                   `yaml:"dailyMinutes"`
+		MonthlyMinutes int64                   `yaml:"monthlyMinutes"`
+		Apps           map[string]*UsageLimits `yaml:"apps"`
+	}
+	var v T
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	limits := &UsageLimits{DailyMinutes: v.DailyMinutes, MonthlyMinutes: v.MonthlyMinutes}
+	if err := limits.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUsage, err)
+	}
+	for appID, l := range v.Apps {
+		if l == nil {
+			l = &UsageLimits{}
+			v.Apps[appID] = l
+		}
+		if err := l.validate(); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidUsage, appID, err)
+		}
+	}
+	u.Enabled = v.Enabled
+	u.Limits = limits
+	u.Apps = v.Apps
+	return nil
+}
+
+func (l *UsageLimits) validate() error {
+	if l.DailyMinutes < 0 || l.MonthlyMinutes < 0 {
+		return errors.New("limits must not be negative")
+	}
+	return nil
+}
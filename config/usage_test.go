@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestUsage_UnmarshalYAML(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		input := `
+enabled: true
+monthlyMinutes: 10000
+apps:
+  app-id:
+    dailyMinutes: 600
+  unlimited:`
+		var u Usage
+		err := yaml.Unmarshal([]byte(input), &u)
+		require.NoError(t, err)
+		assert.True(t, u.Enabled)
+		assert.Equal(t, &UsageLimits{MonthlyMinutes: 10000}, u.Limits)
+		assert.Equal(t, map[string]*UsageLimits{
+			"app-id":    {DailyMinutes: 600},
+			"unlimited": {},
+		}, u.Apps)
+	})
+
+	t.Run("negative limit", func(t *testing.T) {
+		var u Usage
+		err := yaml.Unmarshal([]byte("apps:\n  app-id:\n    dailyMinutes: -1"), &u)
+		assert.ErrorIs(t, err, ErrInvalidUsage)
+	})
+}
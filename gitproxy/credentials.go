@@ -0,0 +1,141 @@
+package gitproxy
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+)
+
+const (
+	ScopeGitRead  = "git:read"
+	ScopeGitWrite = "git:write"
+
+	// DefaultCredentialExpiry is how long a credential issued to a job can
+	// be used to fetch the repository.
+	DefaultCredentialExpiry = 30 * time.Minute
+
+	claimAppID          = "app_id"
+	claimInstallationID = "installation_id"
+	claimRemoteURL      = "remote_url"
+	claimRepository     = "repository"
+	claimRefs           = "refs"
+)
+
+var ErrInvalidCredential = errors.New("gitproxy: invalid credential")
+
+type Signer interface {
+	GenerateToken(issuer string, scope []string, claims map[string]interface{}, expiry time.Duration) (string, error)
+}
+
+// Grant is what a credential allows: fetching a single repository through the
+// installation it belongs to, and pushing to Refs.
+type Grant struct {
+	AppID          string
+	InstallationID string
+	RemoteURL      string
+	Repository     string
+
+	// Refs are the full names of the refs that can be pushed to.  Empty
+	// for fetch-only credentials.
+	Refs []string
+}
+
+// CanPush reports whether the grant allows updating ref.
+func (g *Grant) CanPush(ref string) bool {
+	for _, r := range g.Refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// Credentials issues and verifies the short-lived credentials handed to jobs
+// in place of a VCS token.
+type Credentials struct {
+	runnerID string
+	signer   Signer
+	verifier *jwtutil.Verifier
+	expiry   time.Duration
+}
+
+func NewCredentials(runnerID string, signer Signer, verifier *jwtutil.Verifier, expiry time.Duration) *Credentials {
+	if expiry <= 0 {
+		expiry = DefaultCredentialExpiry
+	}
+	return &Credentials{
+		runnerID: runnerID,
+		signer:   signer,
+		verifier: verifier,
+		expiry:   expiry,
+	}
+}
+
+func (c *Credentials) Issue(g *Grant) (string, error) {
+	scope := []string{ScopeGitRead}
+	claims := map[string]interface{}{
+		claimAppID:          g.AppID,
+		claimInstallationID: g.InstallationID,
+		claimRemoteURL:      g.RemoteURL,
+		claimRepository:     g.Repository,
+	}
+	if len(g.Refs) > 0 {
+		scope = append(scope, ScopeGitWrite)
+		claims[claimRefs] = g.Refs
+	}
+	return c.signer.GenerateToken(c.runnerID, scope, claims, c.expiry)
+}
+
+func (c *Credentials) Verify(token string) (*Grant, error) {
+	claims, err := c.verifier.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims["iss"] != c.runnerID {
+		return nil, ErrInvalidCredential
+	}
+	scp, _ := claims["scp"].(string)
+	push := scp == ScopeGitRead+" "+ScopeGitWrite
+	if scp != ScopeGitRead && !push {
+		return nil, ErrInvalidCredential
+	}
+
+	g := &Grant{}
+	for k, v := range map[string]*string{
+		claimAppID:          &g.AppID,
+		claimInstallationID: &g.InstallationID,
+		claimRemoteURL:      &g.RemoteURL,
+		claimRepository:     &g.Repository,
+	} {
+		s, ok := claims[k].(string)
+		if !ok {
+			return nil, ErrInvalidCredential
+		}
+		*v = s
+	}
+	if g.RemoteURL == "" || g.Repository == "" {
+		return nil, ErrInvalidCredential
+	}
+	if push {
+		refs, _ := claims[claimRefs].([]interface{})
+		for _, r := range refs {
+			ref, ok := r.(string)
+			if !ok {
+				return nil, ErrInvalidCredential
+			}
+			g.Refs = append(g.Refs, ref)
+		}
+		if len(g.Refs) == 0 {
+			return nil, ErrInvalidCredential
+		}
+	}
+	return g, nil
+}
+
+// repositoryKey identifies a repository by host and path, which is also how
+// it is addressed below PathPrefix.
+func repositoryKey(host, path string) string {
+	return strings.Trim(host+"/"+strings.TrimPrefix(path, "/"), "/")
+}
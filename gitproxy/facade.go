@@ -0,0 +1,55 @@
+package gitproxy
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/labstack/echo/v4"
+)
+
+// PathPrefix is the prefix under which repositories are served, as
+// PathPrefix/<host>/<path>.
+const PathPrefix = "/git"
+
+var ErrMissingOpts = errors.New("missing required options")
+
+type Router interface {
+	AddRoute(method string, path string, handlerFunc echo.HandlerFunc, middleware ...echo.MiddlewareFunc)
+}
+
+type Opts struct {
+	RunnerID         string
+	ServiceURL       url.URL
+	Signer           Signer
+	Verifier         *jwtutil.Verifier
+	Provider         Provider
+	CredentialExpiry time.Duration
+}
+
+// Facade wires up the git smart-HTTP proxy.  Jobs clone, and push, through
+// the runner with a short-lived credential, and the runner injects the
+// installation token upstream.
+type Facade struct {
+	Handler           *Handler
+	RemoteURLProvider *RemoteURLProvider
+}
+
+func New(opts *Opts, client *http.Client) (*Facade, error) {
+	if opts == nil || opts.Signer == nil || opts.Verifier == nil || opts.Provider == nil {
+		return nil, ErrMissingOpts
+	}
+	credentials := NewCredentials(opts.RunnerID, opts.Signer, opts.Verifier, opts.CredentialExpiry)
+	return &Facade{
+		Handler:           NewHandler(credentials, opts.Provider, client),
+		RemoteURLProvider: NewRemoteURLProvider(opts.ServiceURL, credentials),
+	}, nil
+}
+
+func (f *Facade) AddRoutes(r Router) Router {
+	r.AddRoute(http.MethodGet, PathPrefix+"/*", f.Handler.HandleInfoRefs)
+	r.AddRoute(http.MethodPost, PathPrefix+"/*", f.Handler.HandleRPC)
+	return r
+}
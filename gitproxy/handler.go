@@ -0,0 +1,193 @@
+package gitproxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	serviceUploadPack  = "git-upload-pack"
+	serviceReceivePack = "git-receive-pack"
+
+	suffixInfoRefs    = "/info/refs"
+	suffixUploadPack  = "/" + serviceUploadPack
+	suffixReceivePack = "/" + serviceReceivePack
+)
+
+var (
+	errUnsupportedService = errors.New("only git-upload-pack and git-receive-pack are supported")
+	errRepositoryMismatch = errors.New("credential is not valid for this repository")
+	errPushNotAllowed     = errors.New("credential does not allow pushing")
+)
+
+// Headers that are passed through between the job and the upstream VCS.
+var (
+	requestHeaders  = []string{"Accept", "Accept-Encoding", "Content-Type", "Content-Encoding", "Git-Protocol", "User-Agent"}
+	responseHeaders = []string{"Content-Type", "Content-Encoding", "Cache-Control", "Expires", "Pragma"}
+)
+
+type Provider interface {
+	AuthenticatedRemoteURL(appID, installationID string, srcURL string) (string, error)
+}
+
+// Handler serves the git smart-HTTP protocol for a single repository.
+// Requests are authenticated with a runner-issued credential, and forwarded
+// to the VCS with the installation token injected.  Pushes are only allowed
+// to the refs of the credential.
+type Handler struct {
+	credentials *Credentials
+	provider    Provider
+	client      *http.Client
+}
+
+func NewHandler(credentials *Credentials, provider Provider, client *http.Client) *Handler {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Handler{
+		credentials: credentials,
+		provider:    provider,
+		client:      client,
+	}
+}
+
+// HandleInfoRefs handles the reference discovery request,
+// GET <repo>/info/refs?service=git-upload-pack, or git-receive-pack.
+func (h *Handler) HandleInfoRefs(c echo.Context) error {
+	repo, ok := strings.CutSuffix(h.repoPath(c), suffixInfoRefs)
+	if !ok {
+		return echo.ErrNotFound
+	}
+	service := c.QueryParam("service")
+	if service != serviceUploadPack && service != serviceReceivePack {
+		return httperror.New(http.StatusForbidden, "service not allowed", errUnsupportedService)
+	}
+	grant, err := h.authorize(c, repo, service)
+	if err != nil {
+		return err
+	}
+	return h.proxy(c, grant, repo, suffixInfoRefs, url.Values{"service": []string{service}})
+}
+
+// HandleRPC handles the pack negotiation request,
+// POST <repo>/git-upload-pack, and the push request,
+// POST <repo>/git-receive-pack.
+func (h *Handler) HandleRPC(c echo.Context) error {
+	path := h.repoPath(c)
+	if repo, ok := strings.CutSuffix(path, suffixUploadPack); ok {
+		grant, err := h.authorize(c, repo, serviceUploadPack)
+		if err != nil {
+			return err
+		}
+		return h.proxy(c, grant, repo, suffixUploadPack, nil)
+	}
+	if repo, ok := strings.CutSuffix(path, suffixReceivePack); ok {
+		grant, err := h.authorize(c, repo, serviceReceivePack)
+		if err != nil {
+			return err
+		}
+		if err := checkPush(c.Request(), grant); err != nil {
+			slog.Warn("gitproxy: rejected push", slog.String("repository", repo), slog.Any("err", err))
+			return httperror.New(http.StatusForbidden, "push not allowed", err)
+		}
+		return h.proxy(c, grant, repo, suffixReceivePack, nil)
+	}
+	return echo.ErrNotFound
+}
+
+func (*Handler) repoPath(c echo.Context) string {
+	return strings.TrimPrefix(c.Request().URL.Path, PathPrefix+"/")
+}
+
+// authorize returns the grant of the request's credential, if it allows the
+// service on repo.
+func (h *Handler) authorize(c echo.Context, repo string, service string) (*Grant, error) {
+	grant, err := h.authenticate(c.Request())
+	if err != nil {
+		slog.Error("gitproxy: rejected request", slog.String("repository", repo), slog.Any("err", err))
+		c.Response().Header().Set("WWW-Authenticate", `Basic realm="runner"`)
+		return nil, httperror.ErrUnauthorized(err)
+	}
+	if grant.Repository != repo {
+		return nil, httperror.New(http.StatusForbidden, "forbidden", errRepositoryMismatch)
+	}
+	if service == serviceReceivePack && len(grant.Refs) == 0 {
+		return nil, httperror.New(http.StatusForbidden, "service not allowed", errPushNotAllowed)
+	}
+	return grant, nil
+}
+
+func (h *Handler) proxy(c echo.Context, grant *Grant, repo string, suffix string, query url.Values) error {
+	upstream, err := h.upstreamRequest(c.Request(), grant, suffix, query)
+	if err != nil {
+		return httperror.ErrUnknown(err)
+	}
+
+	res, err := h.client.Do(upstream)
+	if err != nil {
+		return httperror.ErrUpstreamFailed(err)
+	}
+	defer res.Body.Close()
+
+	for _, k := range responseHeaders {
+		if v := res.Header.Get(k); v != "" {
+			c.Response().Header().Set(k, v)
+		}
+	}
+	c.Response().WriteHeader(res.StatusCode)
+	if _, err := io.Copy(c.Response(), res.Body); err != nil {
+		slog.Error("gitproxy: failed to stream response", slog.String("repository", repo), slog.Any("err", err))
+	}
+	return nil
+}
+
+func (h *Handler) authenticate(r *http.Request) (*Grant, error) {
+	if _, password, ok := r.BasicAuth(); ok {
+		return h.credentials.Verify(password)
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return h.credentials.Verify(token)
+	}
+	return nil, ErrInvalidCredential
+}
+
+// upstreamRequest builds the request to the VCS, authenticated with the
+// installation token of the grant.
+func (h *Handler) upstreamRequest(r *http.Request, grant *Grant, suffix string, query url.Values) (*http.Request, error) {
+	authenticated, err := h.provider.AuthenticatedRemoteURL(grant.AppID, grant.InstallationID, grant.RemoteURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate upstream url: %w", err)
+	}
+	u, err := url.Parse(authenticated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse upstream url: %w", err)
+	}
+	user := u.User
+	u.User = nil
+	u = u.JoinPath(suffix)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, u.String(), r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upstream request: %w", err)
+	}
+	req.ContentLength = r.ContentLength
+	for _, k := range requestHeaders {
+		if v := r.Header.Get(k); v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+	if user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+	return req, nil
+}
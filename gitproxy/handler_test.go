@@ -0,0 +1,210 @@
+package gitproxy
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRouter struct {
+	e *echo.Echo
+}
+
+func (r *testRouter) AddRoute(method string, path string, handlerFunc echo.HandlerFunc, middleware ...echo.MiddlewareFunc) {
+	r.e.Add(method, path, handlerFunc, middleware...)
+}
+
+type fakeProvider struct {
+	token string
+}
+
+func (p *fakeProvider) AuthenticatedRemoteURL(_, _ string, srcURL string) (string, error) {
+	u, err := url.Parse(srcURL)
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword("x-access-token", p.token)
+	return u.String(), nil
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_TERMINAL_PROMPT=0",
+	)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// newUpstream serves a bare repository over smart-HTTP with git http-backend,
+// and only accepts requests authenticated with the installation token.
+func newUpstream(t *testing.T, token string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	work := t.TempDir()
+	git(t, work, "init", "-q", "-b", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "README.md"), []byte("hello"), 0o600))
+	git(t, work, "add", "README.md")
+	git(t, work, "commit", "-q", "-m", "initial")
+	git(t, root, "clone", "-q", "--bare", work, "repo.git")
+	git(t, filepath.Join(root, "repo.git"), "config", "http.receivepack", "true")
+
+	backend := &cgi.Handler{
+		Path: filepath.Join(git(t, root, "--exec-path"), "git-http-backend"),
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "x-access-token" || password != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func newProxy(t *testing.T, provider Provider) (*Facade, string) {
+	t.Helper()
+	e := echo.New()
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		var httpErr *httperror.Error
+		if errors.As(err, &httpErr) {
+			_ = c.JSON(httpErr.Code, httpErr)
+			return
+		}
+		e.DefaultHTTPErrorHandler(err, c)
+	}
+	// Routes are added before the server starts, so that it never serves
+	// requests concurrently with them.
+	server := httptest.NewUnstartedServer(e)
+	t.Cleanup(server.Close)
+	serviceURL, _ := url.Parse("http://" + server.Listener.Addr().String())
+
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	f, err := New(&Opts{
+		RunnerID:   "runner-id",
+		ServiceURL: *serviceURL,
+		Signer:     jwtutil.NewSigner(privateKey),
+		Verifier:   jwtutil.NewVerifier(&privateKey.PublicKey),
+		Provider:   provider,
+	}, nil)
+	require.NoError(t, err)
+	f.AddRoutes(&testRouter{e: e})
+	server.Start()
+	return f, server.URL
+}
+
+func TestProxy_Clone(t *testing.T) {
+	upstream := newUpstream(t, "installation-token")
+	f, _ := newProxy(t, &fakeProvider{token: "installation-token"})
+
+	cloneURL, err := f.RemoteURLProvider.AuthenticatedRemoteURL("app-id", "installation-id", upstream+"/repo.git")
+	require.NoError(t, err)
+	assert.NotContains(t, cloneURL, "installation-token")
+
+	dest := t.TempDir()
+	git(t, dest, "clone", "-q", cloneURL, "repo")
+	content, err := os.ReadFile(filepath.Join(dest, "repo", "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+}
+
+func TestProxy_Rejects(t *testing.T) {
+	upstream := newUpstream(t, "installation-token")
+	f, proxyURL := newProxy(t, &fakeProvider{token: "installation-token"})
+
+	cloneURL, err := f.RemoteURLProvider.AuthenticatedRemoteURL("app-id", "installation-id", upstream+"/repo.git")
+	require.NoError(t, err)
+	u, _ := url.Parse(cloneURL)
+	token, _ := u.User.Password()
+	repo := strings.TrimPrefix(u.Path, PathPrefix+"/")
+
+	do := func(method, path, token string) int {
+		req, _ := http.NewRequest(method, proxyURL+path, http.NoBody)
+		if token != "" {
+			req.SetBasicAuth(credentialUser, token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	t.Run("missing credential", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, PathPrefix+"/"+repo+"/info/refs?service=git-upload-pack", ""))
+	})
+
+	t.Run("invalid credential", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, PathPrefix+"/"+repo+"/info/refs?service=git-upload-pack", "invalid"))
+	})
+
+	t.Run("other repository", func(t *testing.T) {
+		other := strings.Replace(repo, "repo.git", "other.git", 1)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, PathPrefix+"/"+other+"/info/refs?service=git-upload-pack", token))
+	})
+
+	t.Run("push", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, PathPrefix+"/"+repo+"/info/refs?service=git-receive-pack", token))
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, PathPrefix+"/"+repo+"/git-receive-pack", token))
+	})
+}
+
+func TestProxy_Push(t *testing.T) {
+	upstream := newUpstream(t, "installation-token")
+	f, _ := newProxy(t, &fakeProvider{token: "installation-token"})
+
+	pushURL, err := f.RemoteURLProvider.PushRemoteURL("app-id", "installation-id", upstream+"/repo.git", "deepsource/fix")
+	require.NoError(t, err)
+	assert.NotContains(t, pushURL, "installation-token")
+
+	dest := t.TempDir()
+	git(t, dest, "clone", "-q", pushURL, "repo")
+	work := filepath.Join(dest, "repo")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "README.md"), []byte("fixed"), 0o600))
+	git(t, work, "commit", "-q", "-am", "fix")
+
+	git(t, work, "push", "-q", "origin", "HEAD:deepsource/fix")
+	assert.Contains(t, git(t, work, "ls-remote", "origin"), "refs/heads/deepsource/fix")
+
+	push := func(args ...string) error {
+		cmd := exec.Command("git", append([]string{"push", "-q", "origin"}, args...)...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		return cmd.Run()
+	}
+	assert.Error(t, push("HEAD:main"), "refs outside the credential are rejected")
+	assert.Error(t, push("HEAD:deepsource/fix", "HEAD:main"), "pushes are rejected as a whole")
+	assert.Error(t, push(":deepsource/fix"), "refs cannot be deleted")
+	assert.Error(t, push("HEAD:refs/tags/v1"))
+
+	heads := git(t, work, "ls-remote", "origin")
+	assert.NotContains(t, heads, "refs/tags/v1")
+	assert.Contains(t, heads, "refs/heads/deepsource/fix")
+	main := git(t, work, "rev-parse", "HEAD~1")
+	assert.Contains(t, heads, main+"\trefs/heads/main")
+}
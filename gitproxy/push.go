@@ -0,0 +1,102 @@
+package gitproxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxCommandsSize bounds the ref update commands read from a push before
+// it is forwarded.
+const maxCommandsSize = 1 << 20
+
+var (
+	errInvalidCommands = errors.New("invalid receive-pack commands")
+	errRefNotAllowed   = errors.New("credential does not allow pushing to ref")
+)
+
+// refUpdate is a ref update command of a push.
+type refUpdate struct {
+	Old string
+	New string
+	Ref string
+}
+
+// deletes reports whether the update deletes the ref.
+func (u *refUpdate) deletes() bool {
+	return strings.Trim(u.New, "0") == ""
+}
+
+// checkPush reads the ref update commands of the receive-pack request, and
+// returns an error unless the grant allows every update.  The request body is
+// replaced, so that it is forwarded unchanged, though no longer compressed.
+func checkPush(r *http.Request, grant *Grant) error {
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidCommands, err)
+		}
+		body = gz
+		r.Header.Del("Content-Encoding")
+		r.ContentLength = -1
+	}
+	updates, raw, err := readCommands(body)
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		if !grant.CanPush(u.Ref) || u.deletes() {
+			return fmt.Errorf("%w: %s", errRefNotAllowed, u.Ref)
+		}
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(raw), body), r.Body}
+	return nil
+}
+
+// readCommands reads the pkt-lines of a receive-pack request up to the flush
+// packet ending the commands, and returns the ref updates with the bytes read.
+func readCommands(r io.Reader) ([]refUpdate, []byte, error) {
+	var raw bytes.Buffer
+	var updates []refUpdate
+	for raw.Len() < maxCommandsSize {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", errInvalidCommands, err)
+		}
+		raw.Write(size[:])
+		n, err := strconv.ParseUint(string(size[:]), 16, 16)
+		if err != nil || n != 0 && n < 4 {
+			return nil, nil, fmt.Errorf("%w: invalid pkt-line length %q", errInvalidCommands, size)
+		}
+		if n == 0 {
+			return updates, raw.Bytes(), nil
+		}
+		line := make([]byte, n-4)
+		if _, err := io.ReadFull(r, line); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", errInvalidCommands, err)
+		}
+		raw.Write(line)
+
+		// The first command carries the capabilities after a NUL.  Shallow
+		// lines list the shallow commits of the pushing repository.
+		command, _, _ := strings.Cut(strings.TrimSuffix(string(line), "\n"), "\x00")
+		if strings.HasPrefix(command, "shallow ") {
+			continue
+		}
+		fields := strings.Fields(command)
+		if len(fields) != 3 {
+			return nil, nil, fmt.Errorf("%w: unsupported command %q", errInvalidCommands, command)
+		}
+		updates = append(updates, refUpdate{Old: fields[0], New: fields[1], Ref: fields[2]})
+	}
+	return nil, nil, fmt.Errorf("%w: commands exceed %d bytes", errInvalidCommands, maxCommandsSize)
+}
@@ -0,0 +1,87 @@
+package gitproxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testOld = "1111111111111111111111111111111111111111"
+	testNew = "2222222222222222222222222222222222222222"
+	testNil = "0000000000000000000000000000000000000000"
+)
+
+func pktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
+
+func TestReadCommands(t *testing.T) {
+	commands := pktLine("shallow "+testOld+"\n") +
+		pktLine(testOld+" "+testNew+" refs/heads/fix\x00report-status side-band-64k\n") +
+		pktLine(testNil+" "+testNew+" refs/heads/new\n") + "0000"
+	updates, raw, err := readCommands(strings.NewReader(commands + "PACK..."))
+	require.NoError(t, err)
+	assert.Equal(t, []refUpdate{
+		{Old: testOld, New: testNew, Ref: "refs/heads/fix"},
+		{Old: testNil, New: testNew, Ref: "refs/heads/new"},
+	}, updates)
+	assert.Equal(t, commands, string(raw))
+
+	invalid := map[string]string{
+		"truncated":      pktLine(testOld + " " + testNew + " refs/heads/fix\n"),
+		"invalid length": "zzzz",
+		"push cert":      pktLine("push-cert\x00report-status\n") + "0000",
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := readCommands(strings.NewReader(input))
+			assert.ErrorIs(t, err, errInvalidCommands)
+		})
+	}
+}
+
+func TestCheckPush(t *testing.T) {
+	grant := &Grant{Refs: []string{"refs/heads/fix"}}
+	request := func(commands string, compress bool) *http.Request {
+		body := commands + "PACK..."
+		r := httptest.NewRequest(http.MethodPost, "/git/repo/git-receive-pack", strings.NewReader(body))
+		if compress {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			_, _ = gz.Write([]byte(body))
+			_ = gz.Close()
+			r = httptest.NewRequest(http.MethodPost, "/git/repo/git-receive-pack", &buf)
+			r.Header.Set("Content-Encoding", "gzip")
+		}
+		return r
+	}
+	update := pktLine(testOld+" "+testNew+" refs/heads/fix\x00report-status\n") + "0000"
+
+	for _, compress := range []bool{false, true} {
+		r := request(update, compress)
+		require.NoError(t, checkPush(r, grant))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, update+"PACK...", string(body), "the request is forwarded whole")
+		assert.Empty(t, r.Header.Get("Content-Encoding"))
+	}
+
+	rejected := map[string]string{
+		"other ref": pktLine(testOld+" "+testNew+" refs/heads/main\x00report-status\n") + "0000",
+		"deletion":  pktLine(testOld+" "+testNil+" refs/heads/fix\x00report-status\n") + "0000",
+	}
+	for name, commands := range rejected {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, checkPush(request(commands, false), grant), errRefNotAllowed)
+		})
+	}
+}
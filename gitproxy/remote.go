@@ -0,0 +1,59 @@
+package gitproxy
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const credentialUser = "x-runner-token"
+
+// RemoteURLProvider hands out remote URLs that point at the runner's git
+// proxy instead of the VCS.  It is a drop-in replacement for the provider
+// used by the orchestrator, so jobs never see an installation token.
+type RemoteURLProvider struct {
+	serviceURL  url.URL
+	credentials *Credentials
+}
+
+func NewRemoteURLProvider(serviceURL url.URL, credentials *Credentials) *RemoteURLProvider {
+	return &RemoteURLProvider{
+		serviceURL:  serviceURL,
+		credentials: credentials,
+	}
+}
+
+// AuthenticatedRemoteURL returns the proxy URL for srcURL, with a credential
+// that only allows fetching that repository.
+func (p *RemoteURLProvider) AuthenticatedRemoteURL(appID, installationID, srcURL string) (string, error) {
+	return p.remoteURL(appID, installationID, srcURL, nil)
+}
+
+// PushRemoteURL returns the proxy URL for srcURL, with a credential that
+// allows fetching that repository and pushing to branch only.
+func (p *RemoteURLProvider) PushRemoteURL(appID, installationID, srcURL, branch string) (string, error) {
+	return p.remoteURL(appID, installationID, srcURL, []string{"refs/heads/" + branch})
+}
+
+func (p *RemoteURLProvider) remoteURL(appID, installationID, srcURL string, refs []string) (string, error) {
+	src, err := url.Parse(srcURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse url: %w", err)
+	}
+	src.User = nil
+
+	repository := repositoryKey(src.Host, src.Path)
+	token, err := p.credentials.Issue(&Grant{
+		AppID:          appID,
+		InstallationID: installationID,
+		RemoteURL:      src.String(),
+		Repository:     repository,
+		Refs:           refs,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to issue git proxy credential: %w", err)
+	}
+
+	u := p.serviceURL.JoinPath(PathPrefix, repository)
+	u.User = url.UserPassword(credentialUser, token)
+	return u.String(), nil
+}
@@ -0,0 +1,54 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/deepsourcecorp/runner/auth"
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator verifies the authorization metadata of a call.
+type Authenticator func(authorization string) error
+
+// DeepSourceAuthenticator verifies DeepSource tokens for the runner, like
+// auth.DeepSourceTokenMiddleware.
+func DeepSourceAuthenticator(runnerID string, verifier *jwtutil.Verifier) Authenticator {
+	return func(authorization string) error {
+		return auth.VerifyDeepSourceToken(runnerID, verifier, authorization)
+	}
+}
+
+func UnaryAuthInterceptor(authenticate Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorize(ctx, authenticate); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamAuthInterceptor(authenticate Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(stream.Context(), authenticate); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func authorize(ctx context.Context, authenticate Authenticator) error {
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+	if err := authenticate(authorization); err != nil {
+		return status.Error(codes.Unauthenticated, auth.ErrInvalidToken.Message)
+	}
+	return nil
+}
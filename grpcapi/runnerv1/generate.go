@@ -0,0 +1,5 @@
+// Package runnerv1 is the code generated from tasks.proto, the runner.v1
+// task API.
+package runnerv1
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative tasks.proto
@@ -0,0 +1,185 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/deepsourcecorp/runner/grpcapi/runnerv1"
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"github.com/deepsourcecorp/runner/usage"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const DefaultWatchInterval = 2 * time.Second
+
+// Orchestrator runs tasks and manages their jobs, like orchestrator.Facade.
+type Orchestrator interface {
+	Submit(ctx context.Context, msg *orchestrator.TaskMessage) ([]string, error)
+	CancelJobs(ctx context.Context, names []string) error
+	JobStates(ctx context.Context, names []string) ([]*orchestrator.JobState, error)
+}
+
+type Opts struct {
+	Orchestrator Orchestrator
+
+	// Authenticator verifies the authorization metadata of every call.
+	Authenticator Authenticator
+
+	// WatchInterval is how often watched jobs are polled.
+	WatchInterval time.Duration
+
+	// Certificate, when set, serves the API over TLS.
+	Certificate *tls.Certificate
+}
+
+// Server serves the runner.v1.Tasks service of tasks.proto over the
+// orchestrator.
+type Server struct {
+	runnerv1.UnimplementedTasksServer
+
+	orchestrator  Orchestrator
+	watchInterval time.Duration
+}
+
+var ErrMissingOpts = errors.New("grpcapi: missing opts")
+
+// New returns a gRPC server serving the task API.
+func New(opts *Opts) (*grpc.Server, error) {
+	if opts == nil || opts.Orchestrator == nil || opts.Authenticator == nil {
+		return nil, ErrMissingOpts
+	}
+	serverOpts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(UnaryAuthInterceptor(opts.Authenticator)),
+		grpc.StreamInterceptor(StreamAuthInterceptor(opts.Authenticator)),
+	}
+	if opts.Certificate != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{*opts.Certificate},
+			MinVersion:   tls.VersionTLS12,
+		})))
+	}
+	s := grpc.NewServer(serverOpts...)
+	runnerv1.RegisterTasksServer(s, NewServer(opts.Orchestrator, opts.WatchInterval))
+	return s, nil
+}
+
+func NewServer(orchestrator Orchestrator, watchInterval time.Duration) *Server {
+	if watchInterval <= 0 {
+		watchInterval = DefaultWatchInterval
+	}
+	return &Server{orchestrator: orchestrator, watchInterval: watchInterval}
+}
+
+func (s *Server) Submit(ctx context.Context, req *runnerv1.SubmitRequest) (*runnerv1.SubmitResponse, error) {
+	jobs, err := s.orchestrator.Submit(orchestrator.WithQueueTimeout(ctx), &orchestrator.TaskMessage{
+		Name:           req.Task,
+		AppID:          req.AppId,
+		InstallationID: req.InstallationId,
+		Body:           req.Run,
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &runnerv1.SubmitResponse{Jobs: jobs}, nil
+}
+
+func (s *Server) Cancel(ctx context.Context, req *runnerv1.CancelRequest) (*runnerv1.CancelResponse, error) {
+	if len(req.Jobs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no jobs")
+	}
+	if err := s.orchestrator.CancelJobs(ctx, req.Jobs); err != nil {
+		return nil, statusError(err)
+	}
+	return &runnerv1.CancelResponse{}, nil
+}
+
+func (s *Server) Status(ctx context.Context, req *runnerv1.StatusRequest) (*runnerv1.StatusResponse, error) {
+	if len(req.Jobs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no jobs")
+	}
+	states, err := s.orchestrator.JobStates(ctx, req.Jobs)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	res := &runnerv1.StatusResponse{Jobs: make([]*runnerv1.JobState, len(states))}
+	for i, state := range states {
+		res.Jobs[i] = jobState(state)
+	}
+	return res, nil
+}
+
+// Watch sends an event for the current phase of every job, and one for every
+// change of phase after.  The stream ends once every job is done.
+func (s *Server) Watch(req *runnerv1.WatchRequest, stream runnerv1.Tasks_WatchServer) error {
+	if len(req.Jobs) == 0 {
+		return status.Error(codes.InvalidArgument, "no jobs")
+	}
+	ctx := stream.Context()
+	phases := make(map[string]string, len(req.Jobs))
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+	for {
+		states, err := s.orchestrator.JobStates(ctx, req.Jobs)
+		if err != nil {
+			return statusError(err)
+		}
+		done := true
+		for _, state := range states {
+			if phases[state.Name] != state.Phase {
+				phases[state.Name] = state.Phase
+				if err := stream.Send(&runnerv1.JobEvent{Job: jobState(state), Time: timestamppb.Now()}); err != nil {
+					return err
+				}
+			}
+			done = done && state.Done()
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// jobState returns the message of the state of a job.
+func jobState(state *orchestrator.JobState) *runnerv1.JobState {
+	msg := &runnerv1.JobState{Name: state.Name, Phase: state.Phase, Message: state.Message}
+	if state.StartedAt != nil {
+		msg.StartedAt = timestamppb.New(*state.StartedAt)
+	}
+	if state.FinishedAt != nil {
+		msg.FinishedAt = timestamppb.New(*state.FinishedAt)
+	}
+	return msg
+}
+
+// statusError maps errors of the task layer to the gRPC codes matching the
+// HTTP statuses of the task endpoints.
+func statusError(err error) error {
+	var quotaErr *orchestrator.QuotaExceededError
+	switch {
+	case errors.Is(err, orchestrator.ErrInvalidTask):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, orchestrator.ErrJobNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, orchestrator.ErrJobStateUnsupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, orchestrator.ErrMaintenance), errors.As(err, &quotaErr):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, usage.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		slog.Error("grpcapi: task failed", slog.Any("err", err))
+		return status.Error(codes.Internal, "internal error")
+	}
+}
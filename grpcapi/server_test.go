@@ -0,0 +1,286 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/grpcapi/runnerv1"
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"github.com/deepsourcecorp/runner/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testOrchestrator struct {
+	mu        sync.Mutex
+	submitted []*orchestrator.TaskMessage
+	cancelled []string
+	polls     int
+	err       error
+
+	// phases are the phases of job "job-1" on successive polls.
+	phases []string
+}
+
+func (o *testOrchestrator) Submit(_ context.Context, msg *orchestrator.TaskMessage) ([]string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return nil, o.err
+	}
+	o.submitted = append(o.submitted, msg)
+	return []string{"job-1"}, nil
+}
+
+func (o *testOrchestrator) CancelJobs(_ context.Context, names []string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return o.err
+	}
+	o.cancelled = append(o.cancelled, names...)
+	return nil
+}
+
+func (o *testOrchestrator) JobStates(_ context.Context, names []string) ([]*orchestrator.JobState, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return nil, o.err
+	}
+	phase := o.phases[len(o.phases)-1]
+	if o.polls < len(o.phases) {
+		phase = o.phases[o.polls]
+	}
+	o.polls++
+	states := make([]*orchestrator.JobState, 0, len(names))
+	for _, name := range names {
+		if name == "job-1" {
+			states = append(states, &orchestrator.JobState{Name: name, Phase: phase})
+		} else {
+			states = append(states, &orchestrator.JobState{Name: name, Phase: orchestrator.JobPhaseNotFound})
+		}
+	}
+	return states, nil
+}
+
+func newTestClient(t *testing.T, o Orchestrator) (runnerv1.TasksClient, *jwtutil.Signer) {
+	t.Helper()
+	return newTestClientWith(t, o, nil, insecure.NewCredentials())
+}
+
+// newTestClientWith serves the orchestrator with the certificate, and
+// returns a client connecting with the credentials.
+func newTestClientWith(t *testing.T, o Orchestrator, cert *tls.Certificate, creds credentials.TransportCredentials) (runnerv1.TasksClient, *jwtutil.Signer) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	server, err := New(&Opts{
+		Orchestrator:  o,
+		Authenticator: DeepSourceAuthenticator("runner-id", jwtutil.NewVerifier(&key.PublicKey)),
+		WatchInterval: time.Millisecond,
+		Certificate:   cert,
+	})
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis) //nolint:errcheck
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(creds),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return runnerv1.NewTasksClient(conn), jwtutil.NewSigner(key)
+}
+
+func authorized(t *testing.T, signer *jwtutil.Signer, runnerID string) context.Context {
+	t.Helper()
+	token, err := signer.GenerateToken("deepsource", nil, map[string]interface{}{"runner_id": runnerID}, time.Minute)
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestServer_Auth(t *testing.T) {
+	o := &testOrchestrator{phases: []string{orchestrator.JobPhaseRunning}}
+	client, signer := newTestClient(t, o)
+
+	t.Run("missing token", func(t *testing.T) {
+		_, err := client.Status(context.Background(), &runnerv1.StatusRequest{Jobs: []string{"job-1"}})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("other runner", func(t *testing.T) {
+		_, err := client.Status(authorized(t, signer, "other-runner"), &runnerv1.StatusRequest{Jobs: []string{"job-1"}})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		w, err := client.Watch(context.Background(), &runnerv1.WatchRequest{Jobs: []string{"job-1"}})
+		require.NoError(t, err)
+		_, err = w.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		res, err := client.Status(authorized(t, signer, "runner-id"), &runnerv1.StatusRequest{Jobs: []string{"job-1"}})
+		require.NoError(t, err)
+		assert.Equal(t, orchestrator.JobPhaseRunning, res.Jobs[0].Phase)
+	})
+}
+
+// testCertificate returns a self-signed certificate for "bufnet", and a
+// pool trusting it.
+func testCertificate(t *testing.T) (*tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "bufnet"},
+		DNSNames:     []string{"bufnet"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func TestServer_TLS(t *testing.T) {
+	cert, pool := testCertificate(t)
+	o := &testOrchestrator{phases: []string{orchestrator.JobPhaseRunning}}
+
+	client, signer := newTestClientWith(t, o, cert, credentials.NewTLS(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}))
+	res, err := client.Status(authorized(t, signer, "runner-id"), &runnerv1.StatusRequest{Jobs: []string{"job-1"}})
+	require.NoError(t, err)
+	assert.Equal(t, orchestrator.JobPhaseRunning, res.Jobs[0].Phase)
+
+	client, signer = newTestClientWith(t, o, cert, insecure.NewCredentials())
+	_, err = client.Status(authorized(t, signer, "runner-id"), &runnerv1.StatusRequest{Jobs: []string{"job-1"}})
+	assert.Equal(t, codes.Unavailable, status.Code(err), "plaintext clients are refused")
+}
+
+func TestServer_Submit(t *testing.T) {
+	o := &testOrchestrator{}
+	client, signer := newTestClient(t, o)
+	ctx := authorized(t, signer, "runner-id")
+
+	res, err := client.Submit(ctx, &runnerv1.SubmitRequest{
+		Task:           orchestrator.TaskNameAnalysis,
+		AppId:          "app-id",
+		InstallationId: "42",
+		Run:            []byte(`{"run_id":"run-id"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-1"}, res.Jobs)
+	assert.Equal(t, &orchestrator.TaskMessage{
+		Name:           orchestrator.TaskNameAnalysis,
+		AppID:          "app-id",
+		InstallationID: "42",
+		Body:           json.RawMessage(`{"run_id":"run-id"}`),
+	}, o.submitted[0])
+}
+
+func TestServer_Errors(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{orchestrator.ErrInvalidTask, codes.InvalidArgument},
+		{orchestrator.ErrJobNotFound, codes.NotFound},
+		{orchestrator.ErrJobStateUnsupported, codes.Unimplemented},
+		{orchestrator.ErrMaintenance, codes.Unavailable},
+		{&orchestrator.QuotaExceededError{RetryAfter: time.Second}, codes.Unavailable},
+		{usage.ErrQuotaExceeded, codes.ResourceExhausted},
+		{errors.New("boom"), codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			client, signer := newTestClient(t, &testOrchestrator{err: tt.err})
+			_, err := client.Submit(authorized(t, signer, "runner-id"), &runnerv1.SubmitRequest{Task: orchestrator.TaskNameAnalysis})
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestServer_Cancel(t *testing.T) {
+	o := &testOrchestrator{}
+	client, signer := newTestClient(t, o)
+	ctx := authorized(t, signer, "runner-id")
+
+	_, err := client.Cancel(ctx, &runnerv1.CancelRequest{Jobs: []string{"job-1"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-1"}, o.cancelled)
+
+	_, err = client.Cancel(ctx, &runnerv1.CancelRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Watch(t *testing.T) {
+	o := &testOrchestrator{phases: []string{
+		orchestrator.JobPhasePending,
+		orchestrator.JobPhasePending,
+		orchestrator.JobPhaseRunning,
+		orchestrator.JobPhaseRunning,
+		orchestrator.JobPhaseSucceeded,
+	}}
+	client, signer := newTestClient(t, o)
+
+	w, err := client.Watch(authorized(t, signer, "runner-id"), &runnerv1.WatchRequest{Jobs: []string{"job-1", "job-2"}})
+	require.NoError(t, err)
+
+	var events []string
+	for {
+		e, err := w.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		events = append(events, e.Job.Name+":"+e.Job.Phase)
+	}
+	assert.Equal(t, []string{
+		"job-1:" + orchestrator.JobPhasePending,
+		"job-2:" + orchestrator.JobPhaseNotFound,
+		"job-1:" + orchestrator.JobPhaseRunning,
+		"job-1:" + orchestrator.JobPhaseSucceeded,
+	}, events)
+}
+
+func TestJobState(t *testing.T) {
+	started := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	msg := jobState(&orchestrator.JobState{Name: "job-1", Phase: orchestrator.JobPhaseFailed, StartedAt: &started, Message: "OOMKilled"})
+	assert.Equal(t, "job-1", msg.Name)
+	assert.Equal(t, orchestrator.JobPhaseFailed, msg.Phase)
+	assert.Equal(t, started, msg.StartedAt.AsTime())
+	assert.Nil(t, msg.FinishedAt)
+	assert.Equal(t, "OOMKilled", msg.Message)
+}
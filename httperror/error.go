@@ -48,4 +48,8 @@ var (
 	ErrUpstreamFailed = func(err error) *Error {
 		return New(http.StatusBadGateway, "failed to proxy request", err)
 	}
+
+	ErrUnavailable = func(err error) *Error {
+		return New(http.StatusServiceUnavailable, "runner is at capacity, retry later", err)
+	}
 )
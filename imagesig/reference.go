@@ -0,0 +1,64 @@
+package imagesig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidReference = errors.New("imagesig: invalid image reference")
+
+// Reference is a parsed image reference.  Exactly one of Tag and Digest is
+// set.
+type Reference struct {
+	// Scheme is the scheme the registry is reached with.  References without
+	// one use https.
+	Scheme     string
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+
+	// prefix is the scheme prefix of the parsed reference, if any.
+	prefix string
+}
+
+// ParseReference parses references of the form
+// [scheme://]registry/repository(:tag|@digest).
+func ParseReference(ref string) (*Reference, error) {
+	r := &Reference{Scheme: "https"}
+	if scheme, rest, ok := strings.Cut(ref, "://"); ok {
+		r.Scheme = scheme
+		r.prefix = scheme + "://"
+		ref = rest
+	}
+
+	registry, name, ok := strings.Cut(ref, "/")
+	if !ok || registry == "" || name == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidReference, ref)
+	}
+	r.Registry = registry
+
+	if repository, digest, ok := strings.Cut(name, "@"); ok {
+		r.Repository, r.Digest = repository, digest
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		r.Repository, r.Tag = name[:i], name[i+1:]
+	} else {
+		r.Repository, r.Tag = name, "latest"
+	}
+	if r.Repository == "" || (r.Tag == "" && r.Digest == "") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidReference, ref)
+	}
+	return r, nil
+}
+
+// Pinned returns the reference of the image by digest, in the format it was
+// parsed from.
+func (r *Reference) Pinned(digest string) string {
+	return r.prefix + r.Registry + "/" + r.Repository + "@" + digest
+}
+
+// signatureTag is the tag cosign stores the signature of digest under.
+func signatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
@@ -0,0 +1,200 @@
+package imagesig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	headerContentDigest = "Docker-Content-Digest"
+
+	maxManifestSize = 4 << 20
+	maxBlobSize     = 1 << 20
+)
+
+var (
+	errNotFound       = errors.New("not found")
+	errDigestMismatch = errors.New("content does not match digest")
+
+	manifestAccept = strings.Join([]string{mediaTypeOCIManifest, mediaTypeOCIIndex, mediaTypeDockerManifest, mediaTypeDockerList}, ", ")
+)
+
+// manifest is the subset of an OCI image manifest signatures are read from.
+type manifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// registry is a minimal client of the OCI distribution API.  It
+// authenticates with basic credentials, or with a bearer token when the
+// registry asks for one.
+type registry struct {
+	client   *http.Client
+	username string
+	password string
+}
+
+// resolve returns the digest of the manifest ref points to.
+func (r *registry) resolve(ctx context.Context, ref *Reference) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	res, err := r.do(ctx, http.MethodHead, ref, "manifests/"+ref.Tag, manifestAccept)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	digest := res.Header.Get(headerContentDigest)
+	if digest == "" {
+		return "", fmt.Errorf("registry did not return the digest of %s:%s", ref.Repository, ref.Tag)
+	}
+	return digest, nil
+}
+
+// manifest fetches the manifest tagged tag.
+func (r *registry) manifest(ctx context.Context, ref *Reference, tag string) (*manifest, error) {
+	res, err := r.do(ctx, http.MethodGet, ref, "manifests/"+tag, mediaTypeOCIManifest+", "+mediaTypeDockerManifest)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var m manifest
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxManifestSize)).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// blob fetches the blob with digest, and checks its content against it.
+func (r *registry) blob(ctx context.Context, ref *Reference, digest string) ([]byte, error) {
+	res, err := r.do(ctx, http.MethodGet, ref, "blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxBlobSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	if digest != "sha256:"+hex.EncodeToString(sum[:]) {
+		return nil, fmt.Errorf("blob %s: %w", digest, errDigestMismatch)
+	}
+	return b, nil
+}
+
+func (r *registry) do(ctx context.Context, method string, ref *Reference, suffix string, accept string) (*http.Response, error) {
+	target := fmt.Sprintf("%s://%s/v2/%s/%s", ref.Scheme, ref.Registry, ref.Repository, suffix)
+	res, err := r.request(ctx, method, target, accept, r.basicAuth())
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusUnauthorized {
+		challenge := res.Header.Get("WWW-Authenticate")
+		res.Body.Close()
+		token, err := r.token(ctx, challenge)
+		if err != nil {
+			return nil, err
+		}
+		if res, err = r.request(ctx, method, target, accept, "Bearer "+token); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		res.Body.Close()
+		return nil, fmt.Errorf("%s: %w", suffix, errNotFound)
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		res.Body.Close()
+		return nil, fmt.Errorf("%s: unexpected status code: %d", suffix, res.StatusCode)
+	}
+	return res, nil
+}
+
+func (r *registry) request(ctx context.Context, method, target, accept, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach registry: %w", err)
+	}
+	return res, nil
+}
+
+func (r *registry) basicAuth() string {
+	if r.username == "" {
+		return ""
+	}
+	req := &http.Request{Header: make(http.Header)}
+	req.SetBasicAuth(r.username, r.password)
+	return req.Header.Get("Authorization")
+}
+
+// token fetches a bearer token for the challenge of the registry,
+// `Bearer realm="...",service="...",scope="..."`.
+func (r *registry) token(ctx context.Context, challenge string) (string, error) {
+	params, ok := strings.CutPrefix(challenge, "Bearer ")
+	if !ok {
+		return "", fmt.Errorf("unsupported registry challenge: %q", challenge)
+	}
+	realm, query := "", url.Values{}
+	for _, param := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+		v = strings.Trim(v, `"`)
+		if k == "realm" {
+			realm = v
+			continue
+		}
+		query.Set(k, v)
+	}
+	if realm == "" {
+		return "", fmt.Errorf("registry challenge without realm: %q", challenge)
+	}
+
+	res, err := r.request(ctx, http.MethodGet, realm+"?"+query.Encode(), "", r.basicAuth())
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch registry token: unexpected status code: %d", res.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
@@ -0,0 +1,202 @@
+package imagesig
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	mediaTypeSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	annotationSignature    = "dev.cosignproject.cosign/signature"
+)
+
+var (
+	ErrNoPublicKeys = errors.New("imagesig: no public keys")
+	ErrNotVerified  = errors.New("imagesig: image signature not verified")
+)
+
+// simpleSigning is the payload cosign signs, binding the signature to the
+// digest of the image manifest.
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+type Opts struct {
+	PublicKeys []crypto.PublicKey
+
+	// Username and Password authenticate with the registry.  Empty for
+	// anonymous access.
+	Username string
+	Password string
+}
+
+// Verifier checks cosign signatures of images before jobs run them.  An
+// image is verified when its registry holds a signature, under the
+// sha256-<digest>.sig tag, made with one of the public keys over a payload
+// naming the digest of the image.
+//
+// Verified digests are cached, since the content of a digest never
+// changes.  Failures are not, so that images signed later are picked up.
+type Verifier struct {
+	keys     []crypto.PublicKey
+	registry *registry
+
+	mu       sync.RWMutex
+	verified map[string]bool
+}
+
+func New(opts *Opts, client *http.Client) (*Verifier, error) {
+	if opts == nil || len(opts.PublicKeys) == 0 {
+		return nil, ErrNoPublicKeys
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Verifier{
+		keys: opts.PublicKeys,
+		registry: &registry{
+			client:   client,
+			username: opts.Username,
+			password: opts.Password,
+		},
+		verified: make(map[string]bool),
+	}, nil
+}
+
+// Verify verifies the signature of the image ref points to, and returns the
+// reference of the image pinned to the verified digest.  Tags are resolved
+// so that the job runs exactly the image that was verified.
+func (v *Verifier) Verify(ctx context.Context, ref string) (string, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	digest, err := v.registry.resolve(ctx, r)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: failed to resolve digest: %v", ErrNotVerified, ref, err)
+	}
+	pinned := r.Pinned(digest)
+
+	key := r.Registry + "/" + r.Repository + "@" + digest
+	v.mu.RLock()
+	ok := v.verified[key]
+	v.mu.RUnlock()
+	if ok {
+		return pinned, nil
+	}
+
+	if err := v.verify(ctx, r, digest); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrNotVerified, pinned, err)
+	}
+	slog.Info("verified image signature", slog.String("image", pinned))
+
+	v.mu.Lock()
+	v.verified[key] = true
+	v.mu.Unlock()
+	return pinned, nil
+}
+
+// verify succeeds if any of the signatures of digest is valid.
+func (v *Verifier) verify(ctx context.Context, r *Reference, digest string) error {
+	m, err := v.registry.manifest(ctx, r, signatureTag(digest))
+	if err != nil {
+		return fmt.Errorf("failed to fetch signatures: %w", err)
+	}
+	var errs []error
+	for _, layer := range m.Layers {
+		if layer.MediaType != mediaTypeSimpleSigning {
+			continue
+		}
+		if err := v.verifyLayer(ctx, r, digest, layer); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return nil
+	}
+	if len(errs) == 0 {
+		return errors.New("no signatures")
+	}
+	return errors.Join(errs...)
+}
+
+func (v *Verifier) verifyLayer(ctx context.Context, r *Reference, digest string, layer descriptor) error {
+	sig, err := base64.StdEncoding.DecodeString(layer.Annotations[annotationSignature])
+	if err != nil || len(sig) == 0 {
+		return errors.New("invalid signature annotation")
+	}
+	payload, err := v.registry.blob(ctx, r, layer.Digest)
+	if err != nil {
+		return err
+	}
+	if !v.verifySignature(payload, sig) {
+		return errors.New("signature does not match any public key")
+	}
+
+	var s simpleSigning
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return fmt.Errorf("failed to decode signed payload: %w", err)
+	}
+	if s.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for %s", s.Critical.Image.DockerManifestDigest)
+	}
+	return nil
+}
+
+func (v *Verifier) verifySignature(payload, sig []byte) bool {
+	hash := sha256.Sum256(payload)
+	for _, key := range v.keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, hash[:], sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], sig) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParsePublicKey parses a PEM encoded PKIX public key, as written by
+// `cosign generate-key-pair`.
+func ParsePublicKey(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("imagesig: invalid public key pem")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("imagesig: failed to parse public key: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("imagesig: unsupported public key type %T", key)
+	}
+}
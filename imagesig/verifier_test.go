@@ -0,0 +1,188 @@
+package imagesig
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// testRegistry is a stand-in for an OCI registry serving a single
+// repository from memory.
+type testRegistry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+	token     string
+	requests  atomic.Int32
+}
+
+func newTestRegistry() *testRegistry {
+	return &testRegistry{manifests: make(map[string][]byte), blobs: make(map[string][]byte)}
+}
+
+// push stores manifest under tag and its digest, and returns the digest.
+func (r *testRegistry) push(tag string, manifest []byte) string {
+	digest := digestOf(manifest)
+	r.manifests[tag] = manifest
+	r.manifests[digest] = manifest
+	return digest
+}
+
+// sign stores a cosign signature of digest, made with key over a payload
+// claiming signedDigest.
+func (r *testRegistry) sign(t *testing.T, key *ecdsa.PrivateKey, digest, signedDigest string) {
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"analyzers/marvin-python"},"image":{"docker-manifest-digest":"` + signedDigest + `"},"type":"cosign container image signature"},"optional":null}`)
+	hash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+
+	payloadDigest := digestOf(payload)
+	r.blobs[payloadDigest] = payload
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     mediaTypeOCIManifest,
+		"layers": []descriptor{{
+			MediaType:   mediaTypeSimpleSigning,
+			Digest:      payloadDigest,
+			Size:        int64(len(payload)),
+			Annotations: map[string]string{annotationSignature: base64.StdEncoding.EncodeToString(sig)},
+		}},
+	})
+	require.NoError(t, err)
+	r.push(signatureTag(digest), manifest)
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.requests.Add(1)
+	if req.URL.Path == "/token" {
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": r.token})
+		return
+	}
+	if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="http://`+req.Host+`/token",service="registry",scope="repository:analyzers/marvin-python:pull"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/analyzers/marvin-python/")
+	var content []byte
+	switch {
+	case strings.HasPrefix(path, "manifests/"):
+		content = r.manifests[strings.TrimPrefix(path, "manifests/")]
+		w.Header().Set("Content-Type", mediaTypeOCIManifest)
+	case strings.HasPrefix(path, "blobs/"):
+		content = r.blobs[strings.TrimPrefix(path, "blobs/")]
+	}
+	if content == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set(headerContentDigest, digestOf(content))
+	if req.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(content)
+}
+
+func publicKey(t *testing.T, key *ecdsa.PrivateKey) crypto.PublicKey {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pub, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	return pub
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	registry := newTestRegistry()
+	registry.token = "registry-token"
+	signed := registry.push("v1", []byte(`{"schemaVersion":2,"layers":[]}`))
+	registry.sign(t, key, signed, signed)
+	unsigned := registry.push("v2", []byte(`{"schemaVersion":2,"layers":[],"v":2}`))
+	wrongKey := registry.push("v3", []byte(`{"schemaVersion":2,"layers":[],"v":3}`))
+	registry.sign(t, otherKey, wrongKey, wrongKey)
+	replayed := registry.push("v4", []byte(`{"schemaVersion":2,"layers":[],"v":4}`))
+	registry.sign(t, key, replayed, signed)
+
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	base := server.URL + "/analyzers/marvin-python"
+
+	verifier, err := New(&Opts{PublicKeys: []crypto.PublicKey{publicKey(t, key)}}, server.Client())
+	require.NoError(t, err)
+
+	t.Run("signed tag is pinned", func(t *testing.T) {
+		ref, err := verifier.Verify(context.Background(), base+":v1")
+		require.NoError(t, err)
+		assert.Equal(t, base+"@"+signed, ref)
+	})
+
+	t.Run("verified digest is cached", func(t *testing.T) {
+		before := registry.requests.Load()
+		ref, err := verifier.Verify(context.Background(), base+"@"+signed)
+		require.NoError(t, err)
+		assert.Equal(t, base+"@"+signed, ref)
+		assert.Equal(t, before, registry.requests.Load())
+	})
+
+	rejected := map[string]string{
+		"unsigned":         base + "@" + unsigned,
+		"wrong key":        base + ":v3",
+		"replayed payload": base + ":v4",
+		"unknown tag":      base + ":v5",
+	}
+	for name, ref := range rejected {
+		t.Run(name, func(t *testing.T) {
+			_, err := verifier.Verify(context.Background(), ref)
+			assert.ErrorIs(t, err, ErrNotVerified)
+		})
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	tests := map[string]*Reference{
+		"registry.example.com/analyzers/marvin-python:v1": {
+			Scheme: "https", Registry: "registry.example.com", Repository: "analyzers/marvin-python", Tag: "v1",
+		},
+		"http://localhost:5000/coat": {
+			Scheme: "http", Registry: "localhost:5000", Repository: "coat", Tag: "latest", prefix: "http://",
+		},
+		"registry.example.com/coat@sha256:abc": {
+			Scheme: "https", Registry: "registry.example.com", Repository: "coat", Digest: "sha256:abc",
+		},
+	}
+	for ref, want := range tests {
+		t.Run(ref, func(t *testing.T) {
+			got, err := ParseReference(ref)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	_, err := ParseReference("coat")
+	assert.ErrorIs(t, err, ErrInvalidReference)
+}
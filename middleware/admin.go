@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminTokenMiddleware guards operator facing endpoints with a static bearer
+// token.  All requests are rejected when the token is empty.
+func AdminTokenMiddleware(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if token == "" {
+				return c.NoContent(http.StatusNotFound)
+			}
+			parts := strings.SplitN(c.Request().Header.Get("Authorization"), " ", 2)
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+			if subtle.ConstantTimeCompare([]byte(parts[1]), []byte(token)) != 1 {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+			return next(c)
+		}
+	}
+}
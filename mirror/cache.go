@@ -0,0 +1,402 @@
+package mirror
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deepsourcecorp/runner/provider/model"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	DefaultMaxAge        = 7 * 24 * time.Hour
+	DefaultMinIdle       = 6 * time.Hour
+	DefaultEvictInterval = time.Hour
+
+	updateTimeout = time.Hour
+
+	lastUsedFile = "runner-last-used"
+	suffixMirror = ".git"
+	suffixTmp    = ".tmp"
+	suffixLock   = ".lock"
+)
+
+var (
+	ErrInvalidRemoteURL    = errors.New("mirror: invalid remote url")
+	ErrInvalidInstallation = errors.New("mirror: invalid app or installation id")
+)
+
+type Provider interface {
+	AuthenticatedRemoteURL(appID, installationID string, srcURL string) (string, error)
+}
+
+type Opts struct {
+	// Root is the directory the cache volume is mounted at in the runner.
+	Root string
+
+	// MaxSize is the total size, in bytes, the mirrors may occupy.  Zero
+	// disables size based eviction.
+	MaxSize int64
+
+	// MaxAge is how long a mirror is kept after it was last used by a job.
+	MaxAge time.Duration
+
+	// MinIdle protects recently used mirrors from eviction, since running
+	// jobs borrow objects from them.
+	MinIdle time.Duration
+
+	EvictInterval time.Duration
+}
+
+// Cache maintains bare mirrors of repositories on a shared volume.  Jobs
+// mount the volume read-only and clone with the mirror as a reference, so
+// only the objects missing from the mirror are fetched from the VCS.
+//
+// Mirrors are created the first time a job asks for a repository, and are
+// kept up to date on push webhooks.  Updates of a mirror are serialized with
+// an in-process lock and a file lock, so that multiple runner replicas can
+// share the volume.
+type Cache struct {
+	opts     *Opts
+	provider Provider
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func New(opts *Opts, provider Provider) *Cache {
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultMaxAge
+	}
+	if opts.MinIdle <= 0 {
+		opts.MinIdle = DefaultMinIdle
+	}
+	if opts.EvictInterval <= 0 {
+		opts.EvictInterval = DefaultEvictInterval
+	}
+	return &Cache{
+		opts:     opts,
+		provider: provider,
+		locks:    make(map[string]*sync.Mutex),
+	}
+}
+
+// Key returns the path of the mirror of remoteURL relative to the cache
+// root, for example app-id/installation-id/github.com/owner/repo.git.
+// Mirrors are kept per installation, since jobs mount the mirror of their
+// own repository only, and a repository may be reachable from several
+// installations.
+func Key(appID, installationID, remoteURL string) (string, error) {
+	for _, id := range []string{appID, installationID} {
+		if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) ||
+			strings.HasSuffix(id, suffixMirror) || strings.HasSuffix(id, suffixTmp) {
+			return "", ErrInvalidInstallation
+		}
+	}
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Host == "" || strings.Trim(u.Host, ".") == "" {
+		return "", ErrInvalidRemoteURL
+	}
+	p := strings.TrimPrefix(path.Clean("/"+u.Path), "/")
+	p = strings.TrimSuffix(p, suffixMirror)
+	if p == "" {
+		return "", ErrInvalidRemoteURL
+	}
+	return path.Join(appID, installationID, strings.ToLower(path.Join(u.Host, p)+suffixMirror)), nil
+}
+
+// Reference returns the key of the mirror of remoteURL if it exists, and
+// records that it was used.  When the repository is not mirrored yet, the
+// mirror is created in the background for subsequent jobs.
+func (c *Cache) Reference(appID, installationID, remoteURL string) (string, bool) {
+	key, err := Key(appID, installationID, remoteURL)
+	if err != nil {
+		return "", false
+	}
+	dir := c.dir(key)
+	if _, err := os.Stat(dir); err != nil {
+		go c.update(appID, installationID, remoteURL)
+		return "", false
+	}
+	if err := touch(dir); err != nil {
+		slog.Warn("mirror: failed to record usage", slog.String("mirror", key), slog.Any("err", err))
+	}
+	return key, true
+}
+
+// OnPush updates the mirror of the pushed repository.  Repositories that are
+// not mirrored yet are skipped, mirrors are only created when used.
+func (c *Cache) OnPush(event *model.PushEvent) {
+	key, err := Key(event.AppID, event.InstallationID, event.RemoteURL)
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(c.dir(key)); err != nil {
+		return
+	}
+	c.update(event.AppID, event.InstallationID, event.RemoteURL)
+}
+
+func (c *Cache) update(appID, installationID, remoteURL string) {
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+	if err := c.Update(ctx, appID, installationID, remoteURL); err != nil {
+		slog.Error("mirror: failed to update mirror", slog.String("remote_url", remoteURL), slog.Any("err", err))
+	}
+}
+
+// Update creates the mirror of remoteURL, or fetches the latest changes into
+// it if it already exists.
+func (c *Cache) Update(ctx context.Context, appID, installationID, remoteURL string) error {
+	key, err := Key(appID, installationID, remoteURL)
+	if err != nil {
+		return err
+	}
+	unlock, err := c.lock(key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	authenticated, err := c.provider.AuthenticatedRemoteURL(appID, installationID, remoteURL)
+	if err != nil {
+		return fmt.Errorf("mirror: failed to generate remote url: %w", err)
+	}
+	remote, config, err := credentialConfig(authenticated)
+	if err != nil {
+		return err
+	}
+
+	dir := c.dir(key)
+	if _, err := os.Stat(dir); err == nil {
+		return git(ctx, append(config, "-C", dir, "fetch", "--prune", "--quiet", "origin")...)
+	}
+
+	// Clone into a temporary directory and move it in place once complete,
+	// so that jobs never see a partial mirror.
+	tmp := dir + suffixTmp
+	if err := os.RemoveAll(tmp); err != nil {
+		return fmt.Errorf("mirror: failed to clean up previous clone: %w", err)
+	}
+	if err := git(ctx, append(config, "clone", "--mirror", "--quiet", remote, tmp)...); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+	if err := touch(tmp); err != nil {
+		return fmt.Errorf("mirror: failed to record usage: %w", err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return fmt.Errorf("mirror: failed to move mirror in place: %w", err)
+	}
+	slog.Info("mirror: created mirror", slog.String("mirror", key))
+	return nil
+}
+
+// Start evicts mirrors periodically until the context is cancelled.
+func (c *Cache) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.opts.EvictInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.Evict(); err != nil {
+			slog.Error("mirror: failed to evict mirrors", slog.Any("err", err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("shutting down mirror cache")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type entry struct {
+	key      string
+	size     int64
+	lastUsed time.Time
+}
+
+// Evict removes the mirrors that were not used for MaxAge, and then the
+// least recently used mirrors until the cache fits in MaxSize.  Mirrors used
+// within MinIdle are never removed.
+func (c *Cache) Evict() error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		idle := now.Sub(e.lastUsed)
+		if idle < c.opts.MinIdle {
+			break
+		}
+		expired := idle > c.opts.MaxAge
+		oversize := c.opts.MaxSize > 0 && total > c.opts.MaxSize
+		if !expired && !oversize {
+			continue
+		}
+		if err := c.remove(e.key); err != nil {
+			slog.Error("mirror: failed to remove mirror", slog.String("mirror", e.key), slog.Any("err", err))
+			continue
+		}
+		slog.Info("mirror: evicted mirror", slog.String("mirror", e.key), slog.Int64("size", e.size), slog.Duration("idle", idle))
+		total -= e.size
+	}
+	return nil
+}
+
+func (c *Cache) remove(key string) error {
+	unlock, err := c.lock(key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return os.RemoveAll(c.dir(key))
+}
+
+// entries lists the mirrors in the cache with their size and last usage.
+func (c *Cache) entries() ([]*entry, error) {
+	var entries []*entry
+	err := filepath.WalkDir(c.opts.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasSuffix(p, suffixTmp) {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() || !strings.HasSuffix(p, suffixMirror) {
+			return nil
+		}
+		key, err := filepath.Rel(c.opts.Root, p)
+		if err != nil {
+			return err
+		}
+		size, err := dirSize(p)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &entry{
+			key:      filepath.ToSlash(key),
+			size:     size,
+			lastUsed: lastUsed(p),
+		})
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mirror: failed to list mirrors: %w", err)
+	}
+	return entries, nil
+}
+
+func (c *Cache) dir(key string) string {
+	return filepath.Join(c.opts.Root, filepath.FromSlash(key))
+}
+
+// lock acquires the lock of a mirror, within this process and across runner
+// replicas sharing the volume.
+func (c *Cache) lock(key string) (func(), error) {
+	c.mu.Lock()
+	l, ok := c.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[key] = l
+	}
+	c.mu.Unlock()
+
+	l.Lock()
+	unlockFile, err := lockFile(c.dir(key) + suffixLock)
+	if err != nil {
+		l.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile()
+		l.Unlock()
+	}, nil
+}
+
+// credentialConfig splits the credentials out of an authenticated remote URL
+// and returns them as git configuration for a single command, so that they
+// are never persisted in the mirror's config on the shared volume.
+func credentialConfig(authenticated string) (string, []string, error) {
+	u, err := url.Parse(authenticated)
+	if err != nil {
+		return "", nil, fmt.Errorf("mirror: failed to parse remote url: %w", err)
+	}
+	if u.User == nil {
+		return u.String(), nil, nil
+	}
+	password, _ := u.User.Password()
+	credentials := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+	u.User = nil
+	return u.String(), []string{"-c", "http.extraHeader=Authorization: Basic " + credentials}, nil
+}
+
+func git(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("mirror: git failed: %w, output=%s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func touch(dir string) error {
+	p := filepath.Join(dir, lastUsedFile)
+	now := time.Now()
+	if err := os.Chtimes(p, now, now); err == nil {
+		return nil
+	}
+	return os.WriteFile(p, nil, 0o644)
+}
+
+func lastUsed(dir string) time.Time {
+	info, err := os.Stat(filepath.Join(dir, lastUsedFile))
+	if err != nil {
+		info, err = os.Stat(dir)
+		if err != nil {
+			return time.Time{}
+		}
+	}
+	return info.ModTime()
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,175 @@
+// Package notify sends notifications of runner problems to customer-owned
+// sinks: JSON webhooks, Slack-compatible webhooks and email.
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Types of notifications.
+const (
+	TypeJobFailures          = "job_failures"
+	TypeQuotaExhausted       = "quota_exhausted"
+	TypeSyncFailed           = "sync_failed"
+	TypeWebhookForwardFailed = "webhook_forward_failed"
+	TypeGitHubTokenFailed    = "github_token_failed"
+)
+
+// Types are the known types of notifications.
+var Types = []string{
+	TypeJobFailures,
+	TypeQuotaExhausted,
+	TypeSyncFailed,
+	TypeWebhookForwardFailed,
+	TypeGitHubTokenFailed,
+}
+
+// HeaderSignature carries the HMAC signature of the payload, as
+// "sha256=<hex>", in webhook requests and emails.
+const HeaderSignature = "X-Runner-Signature"
+
+const sendTimeout = 30 * time.Second
+
+var ErrMissingOpts = errors.New("notify: missing opts")
+
+// Notification describes a problem of the runner.
+type Notification struct {
+	Type     string            `json:"type"`
+	RunnerID string            `json:"runner_id"`
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Time     time.Time         `json:"time"`
+}
+
+// Sink delivers notifications.
+type Sink interface {
+	Send(ctx context.Context, n *Notification) error
+}
+
+// Filter selects notifications.  Empty filters match every notification.
+type Filter struct {
+	// Types are the types of notifications sent.
+	Types []string
+
+	// Fields must all be equal in the notification, as {"app_id": "gh"}.
+	Fields map[string]string
+}
+
+func (f *Filter) Match(n *Notification) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			found = found || t == n.Type
+		}
+		if !found {
+			return false
+		}
+	}
+	for k, v := range f.Fields {
+		if n.Fields[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Route is a sink with the notifications it receives.
+type Route struct {
+	Name   string
+	Sink   Sink
+	Filter *Filter
+
+	// Limit is the number of notifications sent per Period; the rest are
+	// dropped.  Zero sends every notification.
+	Limit  int
+	Period time.Duration
+
+	mu   sync.Mutex
+	sent []time.Time
+}
+
+// allow reports whether the rate limit of the route admits a notification
+// now, and counts it if so.
+func (r *Route) allow(now time.Time) bool {
+	if r.Limit <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recent := r.sent[:0]
+	for _, t := range r.sent {
+		if now.Sub(t) < r.Period {
+			recent = append(recent, t)
+		}
+	}
+	r.sent = recent
+	if len(r.sent) >= r.Limit {
+		return false
+	}
+	r.sent = append(r.sent, now)
+	return true
+}
+
+// Notifier sends notifications to the routes they match.  A nil *Notifier
+// drops notifications.
+type Notifier struct {
+	runnerID string
+	routes   []*Route
+	now      func() time.Time
+	wg       sync.WaitGroup
+}
+
+func New(runnerID string, routes ...*Route) (*Notifier, error) {
+	for _, r := range routes {
+		if r.Sink == nil || r.Limit > 0 && r.Period <= 0 {
+			return nil, ErrMissingOpts
+		}
+	}
+	return &Notifier{runnerID: runnerID, routes: routes, now: time.Now}, nil
+}
+
+// Notify sends the notification in the background.  Failures are logged.
+func (n *Notifier) Notify(notification *Notification) {
+	if n == nil {
+		return
+	}
+	notification.RunnerID = n.runnerID
+	if notification.Time.IsZero() {
+		notification.Time = n.now()
+	}
+	for _, r := range n.routes {
+		if !r.Filter.Match(notification) {
+			continue
+		}
+		if !r.allow(notification.Time) {
+			slog.Warn("notification rate limited", slog.String("sink", r.Name), slog.String("type", notification.Type))
+			continue
+		}
+		n.wg.Add(1)
+		go func(r *Route) {
+			defer n.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := r.Sink.Send(ctx, notification); err != nil {
+				slog.Error("failed to send notification", slog.String("sink", r.Name), slog.String("type", notification.Type), slog.Any("err", err))
+			}
+		}(r)
+	}
+}
+
+// Wait waits for the notifications being sent.
+func (n *Notifier) Wait() {
+	if n == nil {
+		return
+	}
+	n.wg.Wait()
+}
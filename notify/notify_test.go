@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSink struct {
+	mu   sync.Mutex
+	sent []*Notification
+}
+
+func (s *testSink) Send(_ context.Context, n *Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+func (s *testSink) types() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	types := make([]string, 0, len(s.sent))
+	for _, n := range s.sent {
+		types = append(types, n.Type)
+	}
+	return types
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	all, quota, app := &testSink{}, &testSink{}, &testSink{}
+	notifier, err := New("runner-id",
+		&Route{Name: "all", Sink: all},
+		&Route{Name: "quota", Sink: quota, Filter: &Filter{Types: []string{TypeQuotaExhausted}}, Limit: 2, Period: time.Hour},
+		&Route{Name: "app", Sink: app, Filter: &Filter{Fields: map[string]string{"app_id": "gh"}}},
+	)
+	require.NoError(t, err)
+	now := time.Date(2023, 11, 1, 10, 0, 0, 0, time.UTC)
+	notifier.now = func() time.Time { return now }
+
+	notifier.Notify(&Notification{Type: TypeSyncFailed})
+	for i := 0; i < 3; i++ {
+		notifier.Notify(&Notification{Type: TypeQuotaExhausted, Fields: map[string]string{"app_id": "gh"}})
+	}
+	notifier.Wait()
+
+	assert.ElementsMatch(t, []string{TypeSyncFailed, TypeQuotaExhausted, TypeQuotaExhausted, TypeQuotaExhausted}, all.types(), "sinks are sent to concurrently")
+	assert.Equal(t, []string{TypeQuotaExhausted, TypeQuotaExhausted}, quota.types(), "the third is rate limited")
+	assert.Equal(t, []string{TypeQuotaExhausted, TypeQuotaExhausted, TypeQuotaExhausted}, app.types())
+	assert.Equal(t, "runner-id", all.sent[0].RunnerID)
+	assert.Equal(t, now, all.sent[0].Time)
+
+	// The rate limit window slides.
+	now = now.Add(time.Hour)
+	notifier.Notify(&Notification{Type: TypeQuotaExhausted})
+	notifier.Wait()
+	assert.Len(t, quota.types(), 3)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("runner-id", &Route{Name: "missing sink"})
+	assert.ErrorIs(t, err, ErrMissingOpts)
+	_, err = New("runner-id", &Route{Name: "missing period", Sink: &testSink{}, Limit: 1})
+	assert.ErrorIs(t, err, ErrMissingOpts)
+
+	var notifier *Notifier
+	notifier.Notify(&Notification{Type: TypeSyncFailed})
+	notifier.Wait()
+}
@@ -0,0 +1,232 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+
+	"github.com/deepsourcecorp/runner/internal/signer"
+)
+
+// WebhookSink posts notifications as JSON.
+type WebhookSink struct {
+	url    string
+	signer signer.Signer
+	client *http.Client
+}
+
+func NewWebhookSink(url string, signer signer.Signer, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookSink{url: url, signer: signer, client: client}
+}
+
+func (s *WebhookSink) Send(ctx context.Context, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, s.signer, body)
+}
+
+// SlackSink posts notifications to a Slack-compatible incoming webhook.
+type SlackSink struct {
+	url    string
+	signer signer.Signer
+	client *http.Client
+}
+
+func NewSlackSink(url string, signer signer.Signer, client *http.Client) *SlackSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &SlackSink{url: url, signer: signer, client: client}
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Text   string       `json:"text"`
+	Fields []slackField `json:"fields,omitempty"`
+	Footer string       `json:"footer"`
+	TS     int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func (s *SlackSink) Send(ctx context.Context, n *Notification) error {
+	msg := &slackMessage{
+		Text: fmt.Sprintf("*%s*", n.Title),
+		Attachments: []slackAttachment{{
+			Color:  "danger",
+			Text:   n.Message,
+			Footer: "runner " + n.RunnerID + " · " + n.Type,
+			TS:     n.Time.Unix(),
+		}},
+	}
+	for _, k := range sortedKeys(n.Fields) {
+		msg.Attachments[0].Fields = append(msg.Attachments[0].Fields, slackField{Title: k, Value: n.Fields[k], Short: true})
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, s.signer, body)
+}
+
+func post(ctx context.Context, client *http.Client, url string, signer signer.Signer, body []byte) error {
+	signature, err := signer.Sign(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSignature, signature)
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("notify: unexpected status code: %d, body=%s", res.StatusCode, body)
+	}
+	return nil
+}
+
+// EmailSink mails notifications through an SMTP relay.  The relay is trusted
+// and used without authentication, as a local relay is.
+type EmailSink struct {
+	addr   string
+	from   string
+	to     []string
+	signer signer.Signer
+
+	// sendMail is sendMail, replaced in tests.
+	sendMail func(ctx context.Context, addr string, from string, to []string, msg []byte) error
+}
+
+func NewEmailSink(addr, from string, to []string, signer signer.Signer) *EmailSink {
+	return &EmailSink{addr: addr, from: from, to: to, signer: signer, sendMail: sendMail}
+}
+
+func (s *EmailSink) Send(ctx context.Context, n *Notification) error {
+	var body strings.Builder
+	body.WriteString(n.Message + "\r\n\r\n")
+	for _, k := range sortedKeys(n.Fields) {
+		fmt.Fprintf(&body, "%s: %s\r\n", k, n.Fields[k])
+	}
+	fmt.Fprintf(&body, "\r\nrunner: %s\r\ntype: %s\r\ntime: %s\r\n", n.RunnerID, n.Type, n.Time.UTC().Format("2006-01-02T15:04:05Z"))
+	signature, err := s.signer.Sign([]byte(body.String()))
+	if err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: [runner] %s\r\n", headerValue(n.Title))
+	fmt.Fprintf(&msg, "%s: %s\r\n", HeaderSignature, signature)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(body.String())
+	return s.sendMail(ctx, s.addr, s.from, s.to, []byte(msg.String()))
+}
+
+// headerValue folds the line breaks of a header value, so that it cannot add
+// headers of its own.
+func headerValue(v string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(v)
+}
+
+// sendMail is smtp.SendMail, bounded by the context: the connection is closed
+// when the context is done, and times out at its deadline, or after
+// sendTimeout without one.
+func sendMail(ctx context.Context, addr string, from string, to []string, msg []byte) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
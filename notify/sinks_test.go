@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepsourcecorp/runner/internal/signer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNotification() *Notification {
+	return &Notification{
+		Type:     TypeJobFailures,
+		RunnerID: "runner-id",
+		Title:    "Jobs of analyzer python are failing",
+		Message:  "3 jobs failed in 30m0s",
+		Fields:   map[string]string{"analyzer": "python", "app_id": "gh"},
+		Time:     time.Date(2023, 11, 1, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func receiver(t *testing.T, s signer.Signer, got *[]byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if s.Verify(body, r.Header.Get(HeaderSignature)) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		*got = body
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebhookSink_Send(t *testing.T) {
+	s, err := signer.NewSHA256Signer([]byte("secret"))
+	require.NoError(t, err)
+	var body []byte
+	server := receiver(t, s, &body)
+
+	require.NoError(t, NewWebhookSink(server.URL, s, nil).Send(context.Background(), testNotification()))
+	got := new(Notification)
+	require.NoError(t, json.Unmarshal(body, got))
+	assert.Equal(t, testNotification(), got)
+
+	other, err := signer.NewSHA256Signer([]byte("other"))
+	require.NoError(t, err)
+	err = NewWebhookSink(server.URL, other, nil).Send(context.Background(), testNotification())
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestSlackSink_Send(t *testing.T) {
+	s, err := signer.NewSHA256Signer([]byte("secret"))
+	require.NoError(t, err)
+	var body []byte
+	server := receiver(t, s, &body)
+
+	require.NoError(t, NewSlackSink(server.URL, s, nil).Send(context.Background(), testNotification()))
+	assert.JSONEq(t, `{
+		"text": "*Jobs of analyzer python are failing*",
+		"attachments": [{
+			"color": "danger",
+			"text": "3 jobs failed in 30m0s",
+			"fields": [
+				{"title": "analyzer", "value": "python", "short": true},
+				{"title": "app_id", "value": "gh", "short": true}
+			],
+			"footer": "runner runner-id · job_failures",
+			"ts": 1698832800
+		}]
+	}`, string(body))
+}
+
+func TestEmailSink_Send(t *testing.T) {
+	s, err := signer.NewSHA256Signer([]byte("secret"))
+	require.NoError(t, err)
+	sink := NewEmailSink("localhost:25", "runner@example.com", []string{"ops@example.com", "oncall@example.com"}, s)
+	var addr, from string
+	var to []string
+	var msg []byte
+	sink.sendMail = func(_ context.Context, a string, f string, t []string, m []byte) error {
+		addr, from, to, msg = a, f, t, m
+		return nil
+	}
+
+	require.NoError(t, sink.Send(context.Background(), testNotification()))
+	assert.Equal(t, "localhost:25", addr)
+	assert.Equal(t, "runner@example.com", from)
+	assert.Equal(t, []string{"ops@example.com", "oncall@example.com"}, to)
+
+	header, body, ok := strings.Cut(string(msg), "\r\n\r\n")
+	require.True(t, ok)
+	assert.Contains(t, header, "To: ops@example.com, oncall@example.com\r\n")
+	assert.Contains(t, header, "Subject: [runner] Jobs of analyzer python are failing\r\n")
+	assert.Equal(t, "3 jobs failed in 30m0s\r\n\r\nanalyzer: python\r\napp_id: gh\r\n\r\nrunner: runner-id\r\ntype: job_failures\r\ntime: 2023-11-01T10:00:00Z\r\n", body)
+
+	signature, err := s.Sign([]byte(body))
+	require.NoError(t, err)
+	assert.Contains(t, header, HeaderSignature+": "+signature+"\r\n")
+
+	n := testNotification()
+	n.Title = "python\r\nBcc: attacker@example.com\rX: y\nZ: z"
+	require.NoError(t, sink.Send(context.Background(), n))
+	header, _, _ = strings.Cut(string(msg), "\r\n\r\n")
+	assert.Contains(t, header, "Subject: [runner] python Bcc: attacker@example.com X: y Z: z\r\n")
+	assert.NotContains(t, header, "\r\nBcc:")
+}
+
+func TestEmailSink_SendTimeout(t *testing.T) {
+	// The relay accepts connections, but never greets.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	s, err := signer.NewSHA256Signer([]byte("secret"))
+	require.NoError(t, err)
+	sink := NewEmailSink(l.Addr().String(), "runner@example.com", []string{"ops@example.com"}, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, sink.Send(ctx, testNotification()))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,108 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/deepsourcecorp/runner/notify"
+)
+
+const (
+	DefaultFailureThreshold = 3
+	DefaultFailureWindow    = 30 * time.Minute
+)
+
+// FailureAlertOpts configures the notifications of repeated job failures of
+// an analyzer.
+type FailureAlertOpts struct {
+	// Threshold is the number of failures within Window that is notified.
+	Threshold int
+	Window    time.Duration
+}
+
+// failureAlerts counts the failed jobs of analyzers.
+type failureAlerts struct {
+	opts     *FailureAlertOpts
+	notifier *notify.Notifier
+	failures map[string][]time.Time
+}
+
+func newFailureAlerts(notifier *notify.Notifier, opts *FailureAlertOpts) *failureAlerts {
+	if opts == nil {
+		opts = &FailureAlertOpts{}
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultFailureThreshold
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultFailureWindow
+	}
+	return &failureAlerts{opts: opts, notifier: notifier, failures: make(map[string][]time.Time)}
+}
+
+// observe counts a failed job, and notifies once the analyzer reaches the
+// threshold.  The count starts over after a notification.
+func (a *failureAlerts) observe(e *Event) {
+	if e.Type != EventJobFailed || e.Analyzer == "" {
+		return
+	}
+	recent := a.failures[e.Analyzer][:0]
+	for _, t := range a.failures[e.Analyzer] {
+		if e.Time.Sub(t) < a.opts.Window {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, e.Time)
+	if len(recent) < a.opts.Threshold {
+		a.failures[e.Analyzer] = recent
+		return
+	}
+	delete(a.failures, e.Analyzer)
+	a.notifier.Notify(&notify.Notification{
+		Type:    notify.TypeJobFailures,
+		Title:   fmt.Sprintf("Jobs of analyzer %s are failing", e.Analyzer),
+		Message: fmt.Sprintf("%d jobs failed within %s. Last failure: %s: %s", len(recent), a.opts.Window, e.Job, e.Message),
+		Fields: map[string]string{
+			"analyzer": e.Analyzer,
+			"app_id":   e.AppID,
+			"failures": strconv.Itoa(len(recent)),
+			"job":      e.Job,
+		},
+	})
+}
+
+// WatchFailures notifies repeated job failures of analyzers until the
+// context is cancelled.  It does nothing without a notifier.
+func (f *Facade) WatchFailures(ctx context.Context) {
+	if f.notifier == nil {
+		return
+	}
+	alerts := newFailureAlerts(f.notifier, f.failureAlertOpts)
+	var lastID uint64
+	for {
+		replay, events, cancel := f.Events.Subscribe(lastID, &EventFilter{})
+		for _, e := range replay {
+			lastID = e.ID
+			alerts.observe(e)
+		}
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				cancel()
+				return
+			case e, ok := <-events:
+				if !ok {
+					// Dropped for falling behind: resume from the
+					// buffer.
+					break stream
+				}
+				lastID = e.ID
+				alerts.observe(e)
+			}
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,75 @@
+package orchestrator
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepsourcecorp/runner/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notificationSink struct {
+	mu   sync.Mutex
+	sent []*notify.Notification
+}
+
+func (s *notificationSink) Send(_ context.Context, n *notify.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+func (s *notificationSink) notifications() []*notify.Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*notify.Notification{}, s.sent...)
+}
+
+func TestFailureAlerts(t *testing.T) {
+	sink := &notificationSink{}
+	notifier, err := notify.New("runner-id", &notify.Route{Name: "test", Sink: sink})
+	require.NoError(t, err)
+	alerts := newFailureAlerts(notifier, &FailureAlertOpts{Threshold: 2, Window: time.Hour})
+
+	start := time.Date(2023, 11, 1, 10, 0, 0, 0, time.UTC)
+	failed := func(job, analyzer string, after time.Duration) *Event {
+		return &Event{Type: EventJobFailed, Job: job, Analyzer: analyzer, AppID: "gh", Time: start.Add(after), Message: "BackoffLimitExceeded"}
+	}
+	alerts.observe(failed("python-1", "python", 0))
+	alerts.observe(failed("go-1", "go", 0))
+	alerts.observe(&Event{Type: EventJobFinished, Job: "python-2", Analyzer: "python", Time: start})
+	alerts.observe(failed("python-3", "python", 2*time.Hour)) // The first failure is out of the window.
+	alerts.observe(failed("python-4", "python", 2*time.Hour+time.Minute))
+	alerts.observe(failed("python-5", "python", 2*time.Hour+2*time.Minute))
+	notifier.Wait()
+
+	sent := sink.notifications()
+	require.Len(t, sent, 1)
+	assert.Equal(t, notify.TypeJobFailures, sent[0].Type)
+	assert.Equal(t, map[string]string{"analyzer": "python", "app_id": "gh", "failures": "2", "job": "python-4"}, sent[0].Fields)
+}
+
+func TestFacade_WatchFailures(t *testing.T) {
+	sink := &notificationSink{}
+	notifier, err := notify.New("runner-id", &notify.Route{Name: "test", Sink: sink})
+	require.NoError(t, err)
+	events := NewEvents(0)
+	f := &Facade{Events: events, notifier: notifier, failureAlertOpts: &FailureAlertOpts{Threshold: 2}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.WatchFailures(ctx)
+
+	for _, job := range []string{"python-1", "python-2"} {
+		events.JobCreated(context.Background(), job, "python")
+		events.JobChanged(&JobState{Name: job, Phase: JobPhaseFailed})
+	}
+	require.Eventually(t, func() bool {
+		notifier.Wait()
+		return len(sink.notifications()) == 1
+	}, 5*time.Second, time.Millisecond)
+}
@@ -2,11 +2,16 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"sync"
 	"time"
 
 	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/deepsourcecorp/runner/usage"
+	"github.com/google/uuid"
 	"golang.org/x/exp/slog"
 )
 
@@ -20,6 +25,7 @@ type AnalysisTask struct {
 	provider Provider
 	signer   Signer
 	opts     *TaskOpts
+	client   *http.Client
 }
 
 func NewAnalysisTask(runner *Runner, opts *TaskOpts, driver Driver, provider Provider, signer Signer) *AnalysisTask {
@@ -29,6 +35,7 @@ func NewAnalysisTask(runner *Runner, opts *TaskOpts, driver Driver, provider Pro
 		signer:   signer, // used for generating the auth token.
 		provider: provider,
 		runner:   runner,
+		client:   http.DefaultClient,
 	}
 }
 
@@ -59,13 +66,66 @@ type AnalysisRunRequest struct {
 //
 // Run is safe for concurrent use.
 func (t *AnalysisTask) Run(ctx context.Context, req *AnalysisRunRequest) error {
-	remoteURL, err := t.provider.AuthenticatedRemoteURL(req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL)
+	repository := repositoryName(req.Run.VCSMeta.RemoteURL)
+	slot, err := t.opts.Dispatcher.Acquire(ctx, &DispatchRequest{
+		AppID:      req.AppID,
+		Repository: repository,
+		Class:      dispatchClass(req.Run.VCSMeta.IsForDefaultAnalysisBranch),
+	})
 	if err != nil {
 		return err
 	}
-	req.Run.VCSMeta.RemoteURL = remoteURL
-	var wg sync.WaitGroup // initialize waitgroup
+	defer slot.Release()
+
+	policy := t.opts.OrgPolicy.Policy()
+	if err := policy.CheckAnalysis(req.Run); err != nil {
+		slog.Warn("analysis run denied by org policy", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+		return t.rejectRun(ctx, req.Run, policyDeniedStatus(err))
+	}
+
+	// Checks of denied analyzers are concluded without a job.  A run whose
+	// checks are all denied needs no job set up.
+	var (
+		checks   []artifact.Check
+		rejected []*rejectedCheck
+	)
 	for _, check := range req.Run.Checks {
+		denied := policy.CheckAnalyzer(check.AnalyzerMeta.Shortcode, check.AnalyzerMeta.Version)
+		if denied == nil {
+			checks = append(checks, check)
+			continue
+		}
+		slog.Warn("analysis check denied by org policy", slog.String("check_seq", check.CheckSeq), slog.Any("err", denied))
+		token, err := t.signer.GenerateToken(t.runner.ID, []string{ScopeAnalysis}, nil, 30*time.Minute)
+		if err != nil {
+			return err
+		}
+		rejected = append(rejected, &rejectedCheck{checkSeq: check.CheckSeq, token: token, status: policyDeniedStatus(denied)})
+	}
+	if len(checks) == 0 {
+		return t.reportAll(ctx, req.Run.RunID, rejected)
+	}
+
+	if err := t.opts.CheckUsage(ctx, req.AppID); err != nil {
+		return err
+	}
+
+	srcURL := req.Run.VCSMeta.RemoteURL
+	mirrorReference := t.opts.MirrorReference(req.AppID, req.InstallationID, srcURL)
+	var submoduleCredentials string
+	if req.Run.VCSMeta.CloneSubmodules {
+		submoduleCredentials = t.opts.SubmoduleCredentials(req.AppID, req.InstallationID, srcURL, req.Run.VCSMeta.CheckoutOID)
+	}
+	remoteURL, err := t.provider.AuthenticatedRemoteURL(req.AppID, req.InstallationID, srcURL)
+	if err != nil {
+		return err
+	}
+	req.Run.VCSMeta.RemoteURL = remoteURL
+	var (
+		jobs     []JobCreator
+		admitted []*rejectedCheck
+	)
+	for _, check := range checks {
 		slog.Info("creating analysis job for check", check.CheckSeq)
 
 		token, err := t.signer.GenerateToken(t.runner.ID, []string{ScopeAnalysis}, nil, 30*time.Minute)
@@ -74,16 +134,31 @@ func (t *AnalysisTask) Run(ctx context.Context, req *AnalysisRunRequest) error {
 			return err
 		}
 
+		images, err := t.opts.ResolveJobImages(ctx, analyzerImage(&check.AnalyzerMeta))
+		if err != nil {
+			slog.Error("analysis job image rejected", slog.String("check_seq", check.CheckSeq), slog.Any("err", err))
+			rejected = append(rejected, &rejectedCheck{checkSeq: check.CheckSeq, token: token, status: imageRejectedStatus(err)})
+			continue
+		}
+
+		t.opts.RightSize(&check)
+
 		log.Printf("creating analysis job for check %s", check.CheckSeq)
 		job, err := NewAnalysisDriverJob(
 			req.Run,
 			check,
 			&AnalysisOpts{
-				PublisherURL:         t.opts.RemoteHost + analysisPublishPath,
+				PublisherURL:         t.opts.PublisherURL(analysisPublishPath),
 				PublisherToken:       token,
 				SnippetStorageType:   t.opts.SnippetStorageType,
 				SnippetStorageBucket: t.opts.SnippetStorageBucket,
 				SentryDSN:            t.opts.SentryDSN,
+				MirrorReference:      mirrorReference,
+				MirrorClaimName:      t.opts.MirrorClaimName(),
+				SubmoduleCredentials: submoduleCredentials,
+				CloneStrategy:        t.opts.CloneStrategy(req.AppID, check.AnalyzerMeta.Shortcode),
+				CredentialProfiles:   t.opts.MatchingCredentialProfiles(req.AppID, srcURL, check.AnalyzerMeta.Shortcode),
+				Images:               images,
 				KubernetesOpts:       t.opts.KubernetesOpts,
 			},
 		)
@@ -91,15 +166,108 @@ func (t *AnalysisTask) Run(ctx context.Context, req *AnalysisRunRequest) error {
 			slog.Error("failed to create analysis job for check sequence= %d, err= %v", check.CheckSeq, err)
 			return err
 		}
+		jobs = append(jobs, job)
+		admitted = append(admitted, &rejectedCheck{checkSeq: check.CheckSeq, token: token})
+	}
+
+	// Rejected checks are reported before admission, which may return the
+	// run to be retried.
+	t.reportAllRejected(ctx, req.Run.RunID, rejected)
 
+	// The checks of a run are admitted together, so that a run is either
+	// started or retried as a whole.
+	if err := t.opts.Quota.Admit(ctx, jobs...); err != nil {
+		if !errors.Is(err, ErrJobTooLarge) {
+			return err
+		}
+		slog.Error("analysis jobs too large", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+		for _, r := range admitted {
+			r.status = jobTooLargeStatus(err)
+		}
+		t.reportAllRejected(ctx, req.Run.RunID, admitted)
+		return nil
+	}
+
+	var wg sync.WaitGroup // initialize waitgroup
+	for _, job := range jobs {
 		wg.Add(1) // add to waitgroup
 		go func(job JobCreator) {
 			defer wg.Done() // mark job as done when function completes
-			if err = t.driver.TriggerJob(ctx, job); err != nil {
+			slot.Hold(job.Name())
+			if err := t.driver.TriggerJob(ctx, job); err != nil {
 				slog.Error("failed to trigger analysis job, name= %d, err= %v", job.Name(), err)
+				slot.Drop(job.Name())
+				return
 			}
+			t.opts.TrackUsage(ctx, job, req.AppID, repository, usage.KindAnalysis)
 		}(job)
 	}
 	wg.Wait() // wait for all jobs to complete
 	return nil
 }
+
+// rejectedCheck is a check the runner concludes without a job, as when its
+// images were rejected.
+type rejectedCheck struct {
+	checkSeq string
+	token    string
+	status   artifact.Status
+}
+
+// rejectRun publishes a failed result for every check of a run the runner
+// does not start.
+func (t *AnalysisTask) rejectRun(ctx context.Context, run *artifact.AnalysisRun, status artifact.Status) error {
+	rejected := make([]*rejectedCheck, 0, len(run.Checks))
+	for _, check := range run.Checks {
+		token, err := t.signer.GenerateToken(t.runner.ID, []string{ScopeAnalysis}, nil, 30*time.Minute)
+		if err != nil {
+			return err
+		}
+		rejected = append(rejected, &rejectedCheck{checkSeq: check.CheckSeq, token: token, status: status})
+	}
+	return t.reportAll(ctx, run.RunID, rejected)
+}
+
+// reportAll publishes a failed result for each of the rejected checks, and
+// returns the failures joined, so that one failure does not leave the other
+// checks pending.
+func (t *AnalysisTask) reportAll(ctx context.Context, runID string, rejected []*rejectedCheck) error {
+	var errs []error
+	for _, r := range rejected {
+		if err := t.reportRejected(ctx, runID, r); err != nil {
+			errs = append(errs, fmt.Errorf("check %s: %w", r.checkSeq, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reportAllRejected publishes a failed result for each of the rejected
+// checks, logging the failures.
+func (t *AnalysisTask) reportAllRejected(ctx context.Context, runID string, rejected []*rejectedCheck) {
+	for _, r := range rejected {
+		if err := t.reportRejected(ctx, runID, r); err != nil {
+			slog.Error("failed to report rejected check", slog.String("check_seq", r.checkSeq), slog.Any("err", err))
+		}
+	}
+}
+
+// reportRejected publishes a failed result for a rejected check, so that the
+// check does not stay pending.
+func (t *AnalysisTask) reportRejected(ctx context.Context, runID string, r *rejectedCheck) error {
+	payload := artifact.AnalysisResultCeleryTask{
+		ID:   uuid.NewString(),
+		Task: AnalysisResultTask,
+		KWArgs: artifact.AnalysisResult{
+			RunID:    runID,
+			CheckSeq: r.checkSeq,
+			Status:   r.status,
+			Report: artifact.AnalysisReport{
+				Issues:   []artifact.Issue{},
+				IsPassed: false,
+				Errors:   []artifact.AnalysisError{{HMessage: r.status.Err}},
+			},
+		},
+	}
+
+	return publishResult(ctx, t.client, t.opts.PublisherURL(analysisPublishPath), r.token, payload)
+}
@@ -39,6 +39,23 @@ type AnalysisOpts struct {
 
 	SentryDSN string
 
+	// MirrorReference is the path of the repository mirror coat clones with.
+	// Empty when the repository is not mirrored.
+	MirrorReference string
+	MirrorClaimName string
+
+	// SubmoduleCredentials are git-credential-store entries for the
+	// repository's private submodules.
+	SubmoduleCredentials string
+
+	CloneStrategy *CloneStrategy
+
+	// CredentialProfiles are injected into the analyzer container only.
+	CredentialProfiles []*CredentialProfile
+
+	// Images are the job images, resolved by the image policy.
+	Images *JobImages
+
 	KubernetesOpts *KubernetesOpts
 }
 
@@ -82,6 +99,7 @@ func (j *AnalysisDriverJob) PodLabels() map[string]string {
 	return map[string]string{
 		LabelNameApp:      j.Name(),
 		LabelNameRole:     "analysis",
+		LabelNameManager:  "runner",
 		LabelNameAnalyzer: j.check.AnalyzerMeta.Shortcode,
 	}
 }
@@ -100,9 +118,28 @@ func (j *AnalysisDriverJob) NodeSelector() map[string]string {
 }
 
 func (j *AnalysisDriverJob) Container() *Container {
+	profileMounts, profileEnv, profileSecrets := credentialMounts(j.opts.CredentialProfiles)
+	env := map[string]string{
+		EnvNameCodePath:                 "/code",
+		EnvNameToolboxPath:              "/toolbox",
+		EnvNameMemoryLimit:              j.check.AnalyzerMeta.MemoryLimit + "Mi",
+		EnvNameCPULimit:                 j.check.AnalyzerMeta.CPULimit + "m",
+		EnvNameTimeLimit:                "1500",
+		EnvNameOnPrem:                   "true",
+		EnvNamePublisher:                "http",
+		EnvNamePublisherURL:             j.opts.PublisherURL,
+		EnvNamePublisherToken:           j.opts.PublisherToken,
+		EnvNameResultTask:               AnalysisResultTask,
+		EnvNameArtifactsCredentialsPath: "/credentials/credentials",
+		EnvNameSentryDSN:                j.opts.SentryDSN,
+	}
+	for k, v := range profileEnv {
+		env[k] = v
+	}
 	return &Container{
-		Name:  "marvin",
-		Image: j.getMarvinImageURL(),
+		Name:       "marvin",
+		Image:      j.opts.Images.Marvin.Ref,
+		PullPolicy: j.opts.Images.Marvin.PullPolicy,
 		Limit: Resource{
 			CPU:    j.check.AnalyzerMeta.CPULimit + "m",
 			Memory: j.check.AnalyzerMeta.MemoryLimit + "Mi",
@@ -128,21 +165,10 @@ func (j *AnalysisDriverJob) Container() *Container {
 					j.opts.SnippetStorageBucket,
 				}, " "),
 		},
-		Env: map[string]string{
-			EnvNameCodePath:                 "/code",
-			EnvNameToolboxPath:              "/toolbox",
-			EnvNameMemoryLimit:              j.check.AnalyzerMeta.MemoryLimit + "Mi",
-			EnvNameCPULimit:                 j.check.AnalyzerMeta.CPULimit + "m",
-			EnvNameTimeLimit:                "1500",
-			EnvNameOnPrem:                   "true",
-			EnvNamePublisher:                "http",
-			EnvNamePublisherURL:             j.opts.PublisherURL,
-			EnvNamePublisherToken:           j.opts.PublisherToken,
-			EnvNameResultTask:               AnalysisResultTask,
-			EnvNameArtifactsCredentialsPath: "/credentials/credentials",
-			EnvNameSentryDSN:                j.opts.SentryDSN,
-		},
-		VolumeMounts: VolumeMounts,
+		Env:            env,
+		VolumeMounts:   VolumeMounts,
+		Mounts:         append(mirrorMounts(j.opts.MirrorClaimName, j.opts.MirrorReference), profileMounts...),
+		EnvFromSecrets: profileSecrets,
 	}
 }
 
@@ -152,9 +178,22 @@ func (j *AnalysisDriverJob) InitContainer() *Container {
 		log.Println("cannot convert artifacts to json string,error=", err)
 		artifactsStr = []byte("[]")
 	}
+	args := []string{
+		CoatArgNameRunID, j.run.RunID,
+		CoatArgNameCheckSeq, j.check.CheckSeq,
+		CoatArgNameRemoteURL, j.run.VCSMeta.RemoteURL,
+		CoatArgNameBaseBranch, j.run.VCSMeta.BaseBranch,
+		CoatArgNameCheckoutOid, j.run.VCSMeta.CheckoutOID,
+		CoatArgNameCloneSubmodules, strconv.FormatBool(j.run.VCSMeta.CloneSubmodules),
+		CoatArgArtifacts, string(artifactsStr),
+		CoatArgNameDecryptRemote, strconv.FormatBool(false),
+	}
+	args = append(args, mirrorArgs(j.opts.MirrorReference)...)
+	args = append(args, j.opts.CloneStrategy.Args(&j.run.Config)...)
 	return &Container{
-		Name:  "coat",
-		Image: j.getCoatImageURL(),
+		Name:       "coat",
+		Image:      j.opts.Images.Coat.Ref,
+		PullPolicy: j.opts.Images.Coat.PullPolicy,
 		Limit: Resource{
 			CPU:    CoatCPULimit,
 			Memory: CoatMemoryLimit,
@@ -163,17 +202,8 @@ func (j *AnalysisDriverJob) InitContainer() *Container {
 			CPU:    CoatCPURequest,
 			Memory: CoatMemoryRequest,
 		},
-		Cmd: []string{"/app/coat"},
-		Args: []string{
-			CoatArgNameRunID, j.run.RunID,
-			CoatArgNameCheckSeq, j.check.CheckSeq,
-			CoatArgNameRemoteURL, j.run.VCSMeta.RemoteURL,
-			CoatArgNameBaseBranch, j.run.VCSMeta.BaseBranch,
-			CoatArgNameCheckoutOid, j.run.VCSMeta.CheckoutOID,
-			CoatArgNameCloneSubmodules, strconv.FormatBool(j.run.VCSMeta.CloneSubmodules),
-			CoatArgArtifacts, string(artifactsStr),
-			CoatArgNameDecryptRemote, strconv.FormatBool(false),
-		},
+		Cmd:  []string{"/app/coat"},
+		Args: args,
 		Env: map[string]string{
 			EnvNameSSHPrivateKey:            j.run.Keys.SSH.Private,
 			EnvNameSSHPublicKey:             j.run.Keys.SSH.Public,
@@ -183,31 +213,13 @@ func (j *AnalysisDriverJob) InitContainer() *Container {
 			EnvNameArtifactsCredentialsPath: "/credentials/credentials",
 			EnvNameResultTask:               AnalysisResultTask,
 			EnvNameSentryDSN:                j.opts.SentryDSN,
+			EnvNameSubmoduleCredentials:     j.opts.SubmoduleCredentials,
 		},
 		VolumeMounts: VolumeMounts,
+		Mounts:       mirrorMounts(j.opts.MirrorClaimName, j.opts.MirrorReference),
 	}
 }
 
 func (j *AnalysisDriverJob) ImagePullSecrets() []string {
 	return j.opts.KubernetesOpts.ImagePullSecrets
 }
-
-func (j *AnalysisDriverJob) getMarvinImageURL() string {
-	imagePrefix := ""
-	if j.check.AnalyzerMeta.AnalyzerType == "core" {
-		imagePrefix = "marvin-"
-	}
-	return j.opts.KubernetesOpts.ImageURL.JoinPath(fmt.Sprintf(
-		"/%s%s:%s",
-		imagePrefix,
-		j.check.AnalyzerMeta.Shortcode,
-		j.check.AnalyzerMeta.Version,
-	)).String()
-}
-
-func (j *AnalysisDriverJob) getCoatImageURL() string {
-	return j.opts.KubernetesOpts.ImageURL.JoinPath(fmt.Sprintf(
-		"/coat:%s",
-		CoatVersion,
-	)).String()
-}
@@ -2,9 +2,14 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"time"
 
 	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/deepsourcecorp/runner/usage"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 const (
@@ -17,6 +22,7 @@ type AutofixTask struct {
 	driver   Driver
 	provider Provider
 	signer   Signer
+	client   *http.Client
 }
 
 type AutofixRunRequest struct {
@@ -32,31 +38,100 @@ func NewAutofixTask(runner *Runner, opts *TaskOpts, driver Driver, provider Prov
 		signer:   signer,
 		provider: provider,
 		runner:   runner,
+		client:   http.DefaultClient,
 	}
 }
 
 func (t *AutofixTask) Run(ctx context.Context, req *AutofixRunRequest) error {
-	remoteURL, err := t.provider.AuthenticatedRemoteURL(req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL)
+	repository := repositoryName(req.Run.VCSMeta.RemoteURL)
+	slot, err := t.opts.Dispatcher.Acquire(ctx, &DispatchRequest{
+		AppID:      req.AppID,
+		Repository: repository,
+		Class:      DispatchClassInteractive,
+	})
 	if err != nil {
 		return err
 	}
+	defer slot.Release()
 
 	token, err := t.signer.GenerateToken(t.runner.ID, []string{ScopeAutofix}, nil, 30*time.Minute)
 	if err != nil {
 		return err
 	}
 
+	meta := req.Run.Autofixer.AutofixMeta
+	policy := t.opts.OrgPolicy.Policy()
+	err = policy.CheckRepository(repository)
+	if err == nil {
+		err = policy.CheckAnalyzer(meta.Shortcode, meta.Version)
+	}
+	if err != nil {
+		slog.Warn("autofix run denied by org policy", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+		return t.report(ctx, req.Run.RunID, token, policyDeniedStatus(err))
+	}
+
+	if err := t.opts.CheckUsage(ctx, req.AppID); err != nil {
+		return err
+	}
+
+	mirrorReference := t.opts.MirrorReference(req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL)
+	var submoduleCredentials string
+	if req.Run.VCSMeta.CloneSubmodules {
+		submoduleCredentials = t.opts.SubmoduleCredentials(req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL, req.Run.VCSMeta.CheckoutOID)
+	}
+	remoteURL, err := t.provider.AuthenticatedRemoteURL(req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL)
+	if err != nil {
+		return err
+	}
+
+	images, err := t.opts.ResolveJobImages(ctx, autofixerImage(meta.Shortcode, meta.Version))
+	if err != nil {
+		slog.Error("autofix job image rejected", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+		return t.report(ctx, req.Run.RunID, token, imageRejectedStatus(err))
+	}
+
 	req.Run.VCSMeta.RemoteURL = remoteURL
 	job, err := NewAutofixDriverJob(req.Run, &AutofixOpts{
-		PublisherURL:         t.opts.RemoteHost + autofixPublishPath,
+		PublisherURL:         t.opts.PublisherURL(autofixPublishPath),
 		PublisherToken:       token,
 		SnippetStorageType:   t.opts.SnippetStorageType,
 		SnippetStorageBucket: t.opts.SnippetStorageBucket,
 		SentryDSN:            t.opts.SentryDSN,
+		MirrorReference:      mirrorReference,
+		MirrorClaimName:      t.opts.MirrorClaimName(),
+		SubmoduleCredentials: submoduleCredentials,
+		Images:               images,
 		KubernetesOpts:       t.opts.KubernetesOpts,
 	})
 	if err != nil {
 		return err
 	}
-	return t.driver.TriggerJob(ctx, job)
+	if err := t.opts.Quota.Admit(ctx, job); err != nil {
+		if errors.Is(err, ErrJobTooLarge) {
+			slog.Error("autofix job too large", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+			return t.report(ctx, req.Run.RunID, token, jobTooLargeStatus(err))
+		}
+		return err
+	}
+	slot.Hold(job.Name())
+	if err := t.driver.TriggerJob(ctx, job); err != nil {
+		slot.Drop(job.Name())
+		return err
+	}
+	t.opts.TrackUsage(ctx, job, req.AppID, repository, usage.KindAutofix)
+	return nil
+}
+
+// report publishes the result of an autofix run concluded without a job.
+func (t *AutofixTask) report(ctx context.Context, runID, token string, status artifact.Status) error {
+	payload := artifact.AutofixResultCeleryTask{
+		ID:   uuid.NewString(),
+		Task: AutofixResultTask,
+		KWArgs: artifact.AutofixResult{
+			RunID:  runID,
+			Status: status,
+			Report: artifact.AutofixReport{Patches: []artifact.Patch{}, Errors: []artifact.Error{}},
+		},
+	}
+	return publishResult(ctx, t.client, t.opts.PublisherURL(autofixPublishPath), token, payload)
 }
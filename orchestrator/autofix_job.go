@@ -29,6 +29,18 @@ type AutofixOpts struct {
 
 	SentryDSN string
 
+	// MirrorReference is the path of the repository mirror coat clones with.
+	// Empty when the repository is not mirrored.
+	MirrorReference string
+	MirrorClaimName string
+
+	// SubmoduleCredentials are git-credential-store entries for the
+	// repository's private submodules.
+	SubmoduleCredentials string
+
+	// Images are the job images, resolved by the image policy.
+	Images *JobImages
+
 	KubernetesOpts *KubernetesOpts
 }
 
@@ -78,6 +90,7 @@ func (j *AutofixDriverJob) PodLabels() map[string]string {
 	return map[string]string{
 		LabelNameApp:      j.Name(),
 		LabelNameRole:     "autofix",
+		LabelNameManager:  "runner",
 		LabelNameAnalyzer: j.run.Autofixer.AutofixMeta.Shortcode,
 	}
 }
@@ -93,8 +106,9 @@ func (*AutofixDriverJob) Volumes() []string {
 
 func (j *AutofixDriverJob) InitContainer() *Container {
 	return &Container{
-		Name:  "coat",
-		Image: j.getCoatImageURL(),
+		Name:       "coat",
+		Image:      j.opts.Images.Coat.Ref,
+		PullPolicy: j.opts.Images.Coat.PullPolicy,
 		Limit: Resource{
 			CPU:    CoatCPULimit,
 			Memory: CoatMemoryLimit,
@@ -104,7 +118,7 @@ func (j *AutofixDriverJob) InitContainer() *Container {
 			Memory: CoatMemoryRequest,
 		},
 		Cmd: []string{CoatCmdName},
-		Args: []string{
+		Args: append([]string{
 			CoatArgNameRunID, j.run.RunID,
 			CoatArgNameCheckSeq, "1",
 			CoatArgNameRemoteURL, j.run.VCSMeta.RemoteURL,
@@ -112,7 +126,7 @@ func (j *AutofixDriverJob) InitContainer() *Container {
 			CoatArgNameCheckoutOid, j.run.VCSMeta.CheckoutOID,
 			CoatArgNameCloneSubmodules, strconv.FormatBool(j.run.VCSMeta.CloneSubmodules),
 			CoatArgNameDecryptRemote, strconv.FormatBool(false),
-		},
+		}, mirrorArgs(j.opts.MirrorReference)...),
 		Env: map[string]string{
 			EnvNameSSHPrivateKey:            j.run.Keys.SSH.Private,
 			EnvNameSSHPublicKey:             j.run.Keys.SSH.Public,
@@ -122,15 +136,18 @@ func (j *AutofixDriverJob) InitContainer() *Container {
 			EnvNameResultTask:               AutofixResultTask,
 			EnvNameArtifactsCredentialsPath: "/credentials/credentials",
 			EnvNameSentryDSN:                j.opts.SentryDSN,
+			EnvNameSubmoduleCredentials:     j.opts.SubmoduleCredentials,
 		},
 		VolumeMounts: VolumeMounts,
+		Mounts:       mirrorMounts(j.opts.MirrorClaimName, j.opts.MirrorReference),
 	}
 }
 
 func (j *AutofixDriverJob) Container() *Container {
 	return &Container{
-		Name:  "marvin",
-		Image: j.getMarvinImageURL(),
+		Name:       "marvin",
+		Image:      j.opts.Images.Marvin.Ref,
+		PullPolicy: j.opts.Images.Marvin.PullPolicy,
 		Limit: Resource{
 			CPU:    j.run.Autofixer.AutofixMeta.CPULimit + "m",
 			Memory: j.run.Autofixer.AutofixMeta.MemoryLimit + "Mi",
@@ -171,25 +188,10 @@ func (j *AutofixDriverJob) Container() *Container {
 			EnvNameSentryDSN:                j.opts.SentryDSN,
 		},
 		VolumeMounts: VolumeMounts,
+		Mounts:       mirrorMounts(j.opts.MirrorClaimName, j.opts.MirrorReference),
 	}
 }
 
 func (j *AutofixDriverJob) ImagePullSecrets() []string {
 	return j.opts.KubernetesOpts.ImagePullSecrets
 }
-
-func (j *AutofixDriverJob) getMarvinImageURL() string {
-	return j.opts.KubernetesOpts.ImageURL.JoinPath(fmt.Sprintf(
-		"/%s%s:%s",
-		"marvin-",
-		j.run.Autofixer.AutofixMeta.Shortcode,
-		j.run.Autofixer.AutofixMeta.Version,
-	)).String()
-}
-
-func (j *AutofixDriverJob) getCoatImageURL() string {
-	return j.opts.KubernetesOpts.ImageURL.JoinPath(fmt.Sprintf(
-		"/coat:%s",
-		CoatVersion,
-	)).String()
-}
@@ -0,0 +1,110 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+)
+
+// deepsourceConfigPath is always checked out, even when excluded by a sparse
+// pattern.
+const deepsourceConfigPath = "/.deepsource.toml"
+
+// CloneStrategy controls how coat clones the repository.  The zero value is
+// a full clone.
+type CloneStrategy struct {
+	// Depth truncates the history to the given number of commits.
+	Depth int
+
+	// Filter is a partial clone filter, such as blob:none.
+	Filter string
+
+	// Sparse derives sparse checkout patterns from the exclude patterns of
+	// the run's DeepSource config.  Test patterns are only left out when
+	// ExcludeTestPatterns is set, since analyzers report issues in tests.
+	Sparse              bool
+	ExcludeTestPatterns bool
+
+	// SparsePaths are explicit sparse checkout patterns.  They take
+	// precedence over the patterns derived from the DeepSource config.
+	SparsePaths []string
+}
+
+// AppCloneStrategy is the clone strategy of an app, with overrides for
+// individual analyzers keyed by shortcode.
+type AppCloneStrategy struct {
+	CloneStrategy
+	Analyzers map[string]*CloneStrategy
+}
+
+// CloneStrategy returns the clone strategy for the analyzer's jobs of the
+// app.  Nil is returned when the app does not configure one.
+func (o *TaskOpts) CloneStrategy(appID, analyzer string) *CloneStrategy {
+	app := o.CloneStrategies[appID]
+	if app == nil {
+		return nil
+	}
+	if s, ok := app.Analyzers[analyzer]; ok {
+		return s
+	}
+	return &app.CloneStrategy
+}
+
+// Args returns the coat arguments for the strategy.
+func (s *CloneStrategy) Args(config *artifact.DSConfig) []string {
+	if s == nil {
+		return nil
+	}
+	var args []string
+	if s.Depth > 0 {
+		args = append(args, CoatArgNameDepth, strconv.Itoa(s.Depth))
+	}
+	if s.Filter != "" {
+		args = append(args, CoatArgNameFilter, s.Filter)
+	}
+	if patterns := s.sparsePatterns(config); len(patterns) > 0 {
+		b, err := json.Marshal(patterns)
+		if err == nil {
+			args = append(args, CoatArgNameSparseCheckout, string(b))
+		}
+	}
+	return args
+}
+
+// sparsePatterns returns the non-cone sparse checkout patterns for the
+// strategy.  Everything is checked out except the paths excluded in the
+// DeepSource config.  A full checkout is used when the exclusions cannot be
+// expressed as sparse patterns.
+func (s *CloneStrategy) sparsePatterns(config *artifact.DSConfig) []string {
+	if len(s.SparsePaths) > 0 {
+		return s.SparsePaths
+	}
+	if !s.Sparse || config == nil {
+		return nil
+	}
+
+	excluded := append([]string{}, config.ExcludePatterns...)
+	if s.ExcludeTestPatterns {
+		excluded = append(excluded, config.TestPatterns...)
+	}
+
+	patterns := []string{"/*"}
+	for _, p := range excluded {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		// Negated patterns re-include paths, which sparse checkout cannot
+		// express reliably once they are excluded.
+		if strings.HasPrefix(p, "!") {
+			return nil
+		}
+		patterns = append(patterns, "!"+p)
+	}
+	if len(patterns) == 1 {
+		return nil
+	}
+	return append(patterns, deepsourceConfigPath)
+}
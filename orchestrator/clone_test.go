@@ -0,0 +1,84 @@
+package orchestrator
+
+import (
+	"testing"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloneStrategy_Args(t *testing.T) {
+	config := &artifact.DSConfig{
+		ExcludePatterns: []string{"vendor/**", " ", "docs/"},
+		TestPatterns:    []string{"**/*_test.go"},
+	}
+
+	tests := []struct {
+		name     string
+		strategy *CloneStrategy
+		config   *artifact.DSConfig
+		want     []string
+	}{
+		{
+			name: "no strategy",
+			want: nil,
+		},
+		{
+			name:     "shallow partial clone",
+			strategy: &CloneStrategy{Depth: 1, Filter: "blob:none"},
+			config:   config,
+			want:     []string{CoatArgNameDepth, "1", CoatArgNameFilter, "blob:none"},
+		},
+		{
+			name:     "sparse from exclude patterns",
+			strategy: &CloneStrategy{Sparse: true},
+			config:   config,
+			want:     []string{CoatArgNameSparseCheckout, `["/*","!vendor/**","!docs/","/.deepsource.toml"]`},
+		},
+		{
+			name:     "sparse excluding tests",
+			strategy: &CloneStrategy{Sparse: true, ExcludeTestPatterns: true},
+			config:   config,
+			want:     []string{CoatArgNameSparseCheckout, `["/*","!vendor/**","!docs/","!**/*_test.go","/.deepsource.toml"]`},
+		},
+		{
+			name:     "sparse without exclusions",
+			strategy: &CloneStrategy{Sparse: true},
+			config:   &artifact.DSConfig{},
+			want:     nil,
+		},
+		{
+			name:     "sparse with negated pattern",
+			strategy: &CloneStrategy{Sparse: true},
+			config:   &artifact.DSConfig{ExcludePatterns: []string{"vendor/**", "!vendor/acme/**"}},
+			want:     nil,
+		},
+		{
+			name:     "explicit sparse paths",
+			strategy: &CloneStrategy{Sparse: true, SparsePaths: []string{"/services/api/"}},
+			config:   config,
+			want:     []string{CoatArgNameSparseCheckout, `["/services/api/"]`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.strategy.Args(tt.config))
+		})
+	}
+}
+
+func TestTaskOpts_CloneStrategy(t *testing.T) {
+	opts := &TaskOpts{
+		CloneStrategies: map[string]*AppCloneStrategy{
+			"app-id": {
+				CloneStrategy: CloneStrategy{Depth: 1},
+				Analyzers: map[string]*CloneStrategy{
+					"secrets": {},
+				},
+			},
+		},
+	}
+	assert.Equal(t, &CloneStrategy{Depth: 1}, opts.CloneStrategy("app-id", "go"))
+	assert.Equal(t, &CloneStrategy{}, opts.CloneStrategy("app-id", "secrets"))
+	assert.Nil(t, opts.CloneStrategy("other-app-id", "go"))
+}
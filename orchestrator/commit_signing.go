@@ -0,0 +1,83 @@
+package orchestrator
+
+import (
+	"strconv"
+)
+
+const (
+	// SigningFormatSSH is the only format of signing keys: git signs with an
+	// SSH key read from a file, while GPG keys would need a keyring.
+	SigningFormatSSH = "ssh"
+
+	// signingKeyPath is where the private signing key is mounted in the
+	// container that commits.
+	signingKeyPath       = "/signing/key"
+	signingKeyVolumeName = "commit-signing-key"
+	signingKeyDefaultKey = "signing.key"
+)
+
+// SigningKey is the identity the commits pushed for an app are signed with.
+// The private key is the Key of a Kubernetes Secret, and is only mounted
+// into the container that commits and pushes.
+type SigningKey struct {
+	AppID      string `json:"app_id"`
+	Format     string `json:"format"`
+	SecretName string `json:"-"`
+	Key        string `json:"-"`
+	PublicKey  string `json:"public_key"`
+
+	// Name and Email are the committer of signed commits.
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// SigningKey returns the commit signing key of the app, or nil when its
+// commits are not signed.
+func (o *TaskOpts) SigningKey(appID string) *SigningKey {
+	for _, k := range o.SigningKeys {
+		if k.AppID == appID {
+			return k
+		}
+	}
+	return nil
+}
+
+// signingMounts returns the mount of the private key and the environment
+// that makes git sign commits with it.  The key is passed to coat and marvin
+// as SIGNING_KEY_PATH and SIGNING_KEY_FORMAT, and git is configured through
+// GIT_CONFIG_* so that plain git commits are signed as well.
+func signingMounts(key *SigningKey) ([]Mount, map[string]string) {
+	if key == nil {
+		return nil, nil
+	}
+	secretKey := key.Key
+	if secretKey == "" {
+		secretKey = signingKeyDefaultKey
+	}
+	mounts := []Mount{{
+		Name:       signingKeyVolumeName,
+		MountPath:  signingKeyPath,
+		SubPath:    secretKey,
+		ReadOnly:   true,
+		SecretName: key.SecretName,
+	}}
+
+	gitConfig := [][2]string{
+		{"commit.gpgsign", "true"},
+		{"gpg.format", "ssh"},
+		{"user.signingkey", signingKeyPath},
+	}
+
+	env := map[string]string{
+		EnvNameSigningKeyPath:   signingKeyPath,
+		EnvNameSigningKeyFormat: key.Format,
+		EnvNameGitCommitterName: key.Name,
+		EnvNameGitCommitterMail: key.Email,
+		EnvNameGitConfigCount:   strconv.Itoa(len(gitConfig)),
+	}
+	for i, kv := range gitConfig {
+		env["GIT_CONFIG_KEY_"+strconv.Itoa(i)] = kv[0]
+		env["GIT_CONFIG_VALUE_"+strconv.Itoa(i)] = kv[1]
+	}
+	return mounts, env
+}
@@ -0,0 +1,118 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSigningKey(format string) *SigningKey {
+	return &SigningKey{
+		AppID:      "app-id",
+		Format:     format,
+		SecretName: "commit-signing",
+		PublicKey:  "ssh-ed25519 AAAA runner@acme.com",
+		Name:       "Acme Bot",
+		Email:      "bot@acme.com",
+	}
+}
+
+func TestSigningMounts(t *testing.T) {
+	mounts, env := signingMounts(nil)
+	assert.Empty(t, mounts)
+	assert.Empty(t, env)
+
+	mounts, env = signingMounts(testSigningKey(SigningFormatSSH))
+	assert.Equal(t, []Mount{{
+		Name:       signingKeyVolumeName,
+		MountPath:  signingKeyPath,
+		SubPath:    signingKeyDefaultKey,
+		ReadOnly:   true,
+		SecretName: "commit-signing",
+	}}, mounts)
+	assert.Equal(t, map[string]string{
+		EnvNameSigningKeyPath:   signingKeyPath,
+		EnvNameSigningKeyFormat: SigningFormatSSH,
+		EnvNameGitCommitterName: "Acme Bot",
+		EnvNameGitCommitterMail: "bot@acme.com",
+		EnvNameGitConfigCount:   "3",
+		"GIT_CONFIG_KEY_0":      "commit.gpgsign",
+		"GIT_CONFIG_VALUE_0":    "true",
+		"GIT_CONFIG_KEY_1":      "gpg.format",
+		"GIT_CONFIG_VALUE_1":    "ssh",
+		"GIT_CONFIG_KEY_2":      "user.signingkey",
+		"GIT_CONFIG_VALUE_2":    signingKeyPath,
+	}, env)
+
+	key := testSigningKey(SigningFormatSSH)
+	key.Key = "id_ed25519"
+	mounts, _ = signingMounts(key)
+	assert.Equal(t, "id_ed25519", mounts[0].SubPath)
+}
+
+func TestSigningKey_Jobs(t *testing.T) {
+	key := testSigningKey(SigningFormatSSH)
+	images := &JobImages{Coat: &ResolvedImage{Ref: "coat"}, Marvin: &ResolvedImage{Ref: "marvin"}}
+
+	patcher, err := NewPatcherDriverJob(&artifact.PatcherRun{RunID: "run-id"}, &PatcherJobOpts{
+		Images:         images,
+		SigningKey:     key,
+		KubernetesOpts: &KubernetesOpts{},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, signingKeyPath, patcher.Container().Env[EnvNameSigningKeyPath])
+	assert.Len(t, patcher.Container().Mounts, 1)
+
+	transformer, err := NewTransformerJob(&artifact.TransformerRun{RunID: "run-id"}, &TransformerOpts{
+		Images:         images,
+		SigningKey:     key,
+		KubernetesOpts: &KubernetesOpts{},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, signingKeyPath, transformer.Container().Env[EnvNameSigningKeyPath])
+	assert.Len(t, transformer.Container().Mounts, 1)
+	// The key is not exposed to coat, which only clones for transformers.
+	assert.Empty(t, transformer.InitContainer().Mounts)
+	assert.NotContains(t, transformer.InitContainer().Env, EnvNameSigningKeyPath)
+
+	unsigned, err := NewTransformerJob(&artifact.TransformerRun{RunID: "run-id"}, &TransformerOpts{
+		Images:         images,
+		KubernetesOpts: &KubernetesOpts{},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, unsigned.Container().Mounts)
+	assert.NotContains(t, unsigned.Container().Env, EnvNameSigningKeyPath)
+}
+
+func TestHandler_HandleSigningKey(t *testing.T) {
+	h := &Handler{signingKeys: []*SigningKey{testSigningKey(SigningFormatSSH)}}
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/admin/apps/app-id/signing-key", nil), rec)
+	c.SetParamNames("app_id")
+	c.SetParamValues("app-id")
+	require.NoError(t, h.HandleSigningKey(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, map[string]string{
+		"app_id":     "app-id",
+		"format":     "ssh",
+		"public_key": "ssh-ed25519 AAAA runner@acme.com",
+		"name":       "Acme Bot",
+		"email":      "bot@acme.com",
+	}, body)
+
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/admin/apps/other/signing-key", nil), httptest.NewRecorder())
+	c.SetParamNames("app_id")
+	c.SetParamValues("other")
+	assert.Error(t, h.HandleSigningKey(c))
+}
@@ -23,6 +23,12 @@ const (
 	EnvNameArtifactsCredentialsPath = "ARTIFACTS_CREDENTIALS_PATH"
 	EnvNameArtifactsSecretName      = "TASK_ARTIFACT_SECRET_NAME"
 	EnvNameSentryDSN                = "SENTRY_DSN"
+	EnvNameSubmoduleCredentials     = "SUBMODULE_CREDENTIALS"
+	EnvNameSigningKeyPath           = "SIGNING_KEY_PATH"
+	EnvNameSigningKeyFormat         = "SIGNING_KEY_FORMAT"
+	EnvNameGitCommitterName         = "GIT_COMMITTER_NAME"
+	EnvNameGitCommitterMail         = "GIT_COMMITTER_EMAIL"
+	EnvNameGitConfigCount           = "GIT_CONFIG_COUNT"
 
 	MarvinCmdCpy       = "cp /marvin/marvin /toolbox &&"
 	MarvinCmdBase      = "/toolbox/marvin"
@@ -49,6 +55,10 @@ const (
 	CoatArgNameBaseBranch       = "--base-branch"
 	CoatArgPatchMeta            = "--patch-meta"
 	CoatArgArtifacts            = "--artifacts"
+	CoatArgNameReference        = "--reference"
+	CoatArgNameDepth            = "--depth"
+	CoatArgNameFilter           = "--filter"
+	CoatArgNameSparseCheckout   = "--sparse-checkout"
 
 	CoatCPULimit      = "1400m"
 	CoatMemoryLimit   = "4000Mi"
@@ -59,6 +69,26 @@ const (
 	ScopeAutofix   = "autofix.*"
 	ScopeTransform = "transform.*"
 
+	// StatusCodeImageRejected is reported for checks whose images the image
+	// policy rejected or that failed signature verification.
+	StatusCodeImageRejected = 5002
+
+	// StatusCodeStaleChangeset is reported for patcher runs whose branch
+	// moved past the commit the changeset was generated for.
+	StatusCodeStaleChangeset = 5003
+
+	// StatusCodePushRejected is reported for patcher and transformer runs
+	// whose push the push policy does not allow.
+	StatusCodePushRejected = 5004
+
+	// StatusCodePolicyDenied is reported for runs and checks the
+	// organization policy denies.
+	StatusCodePolicyDenied = 5005
+
+	// StatusCodeJobTooLarge is reported for runs whose jobs never fit the
+	// namespace quota or limit range.
+	StatusCodeJobTooLarge = 5006
+
 	AnalysisResultTask    = "contrib.atlas.tasks.store_analysis_run_result"
 	AutofixResultTask     = "contrib.atlas.tasks.store_autofix_run_result"
 	TransformerResultTask = "contrib.atlas.tasks.store_transformer_run_result"
@@ -0,0 +1,142 @@
+package orchestrator
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	CredentialProfileNetrc = "netrc"
+	CredentialProfileNpmrc = "npmrc"
+	CredentialProfilePip   = "pip"
+	CredentialProfileMaven = "maven"
+	CredentialProfileEnv   = "env"
+
+	// homePath is the home directory of the user analyzers run as.
+	homePath = "/home/runner"
+
+	credentialProfileVolumePrefix = "profile-"
+)
+
+// credentialFile is the location a file credential profile is mounted at,
+// and the environment variable pointing tools to it, if any.
+type credentialFile struct {
+	key       string
+	mountPath string
+	env       string
+}
+
+var credentialFiles = map[string]credentialFile{
+	CredentialProfileNetrc: {key: ".netrc", mountPath: homePath + "/.netrc", env: "NETRC"},
+	CredentialProfileNpmrc: {key: ".npmrc", mountPath: homePath + "/.npmrc", env: "NPM_CONFIG_USERCONFIG"},
+	CredentialProfilePip:   {key: "pip.conf", mountPath: homePath + "/.config/pip/pip.conf", env: "PIP_CONFIG_FILE"},
+	CredentialProfileMaven: {key: "settings.xml", mountPath: homePath + "/.m2/settings.xml"},
+}
+
+// CredentialProfile injects credentials from a Kubernetes Secret into the
+// analyzer container of matching jobs.  Credentials are never exposed to
+// coat, which runs untrusted repository content through git.
+type CredentialProfile struct {
+	Name       string
+	Type       string
+	SecretName string
+
+	// Key is the key of the Secret holding the file.  Defaults to the
+	// conventional file name for Type.
+	Key string
+
+	Apps         []string
+	Repositories []string
+	Analyzers    []string
+}
+
+// Matches reports whether the profile applies to a job of the analyzer, for
+// the repository of the app.  Empty selectors match everything.
+func (p *CredentialProfile) Matches(appID, repository, analyzer string) bool {
+	return matchesAny(p.Apps, appID) &&
+		matchesAny(p.Repositories, repository) &&
+		matchesAny(p.Analyzers, analyzer)
+}
+
+func matchesAny(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchingCredentialProfiles returns the profiles that apply to the analyzer's jobs
+// for remoteURL.  Only the first matching profile of each file type is used,
+// since they are mounted at the same location.
+func (o *TaskOpts) MatchingCredentialProfiles(appID, remoteURL, analyzer string) []*CredentialProfile {
+	repository := repositoryName(remoteURL)
+
+	var profiles []*CredentialProfile
+	seen := make(map[string]bool)
+	for _, p := range o.CredentialProfiles {
+		if !p.Matches(appID, repository, analyzer) {
+			continue
+		}
+		if p.Type != CredentialProfileEnv {
+			if seen[p.Type] {
+				slog.Warn("skipping credential profile, another profile of the same type matches", slog.String("profile", p.Name))
+				continue
+			}
+			seen[p.Type] = true
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// credentialMounts returns the mounts, environment variables and env Secrets
+// of the profiles.
+func credentialMounts(profiles []*CredentialProfile) ([]Mount, map[string]string, []string) {
+	var (
+		mounts  []Mount
+		env     = make(map[string]string)
+		secrets []string
+	)
+	for _, p := range profiles {
+		if p.Type == CredentialProfileEnv {
+			secrets = append(secrets, p.SecretName)
+			continue
+		}
+		file, ok := credentialFiles[p.Type]
+		if !ok {
+			continue
+		}
+		key := p.Key
+		if key == "" {
+			key = file.key
+		}
+		mounts = append(mounts, Mount{
+			Name:       credentialProfileVolumePrefix + p.Name,
+			MountPath:  file.mountPath,
+			SubPath:    key,
+			ReadOnly:   true,
+			SecretName: p.SecretName,
+		})
+		if file.env != "" {
+			env[file.env] = file.mountPath
+		}
+	}
+	return mounts, env, secrets
+}
+
+// repositoryName returns the owner/name of the repository at remoteURL.
+func repositoryName(remoteURL string) string {
+	u, err := url.Parse(remoteURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(path.Clean("/"+u.Path), "/"), ".git")
+}
@@ -0,0 +1,65 @@
+package orchestrator
+
+import (
+	"net/url"
+	"testing"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTaskOpts_MatchingCredentialProfiles(t *testing.T) {
+	npm := &CredentialProfile{Name: "npm", Type: CredentialProfileNpmrc, SecretName: "npm", Analyzers: []string{"javascript"}}
+	npmWeb := &CredentialProfile{Name: "npm-web", Type: CredentialProfileNpmrc, SecretName: "npm-web", Repositories: []string{"acme/web"}}
+	env := &CredentialProfile{Name: "env", Type: CredentialProfileEnv, SecretName: "registry-env", Apps: []string{"app-id"}}
+	opts := &TaskOpts{CredentialProfiles: []*CredentialProfile{npmWeb, npm, env}}
+
+	assert.Equal(t, []*CredentialProfile{npmWeb, env}, opts.MatchingCredentialProfiles("app-id", "https://github.com/acme/web.git", "javascript"))
+	assert.Equal(t, []*CredentialProfile{npm}, opts.MatchingCredentialProfiles("other-app-id", "https://github.com/acme/api.git", "javascript"))
+	assert.Empty(t, opts.MatchingCredentialProfiles("other-app-id", "https://github.com/acme/api.git", "python"))
+}
+
+func TestAnalysisDriverJob_CredentialProfiles(t *testing.T) {
+	imageURL, _ := url.Parse("https://registry.example.com")
+	run := &artifact.AnalysisRun{RunID: "run-id", VCSMeta: artifact.AnalysisRunVCSMeta{RemoteURL: "https://github.com/acme/web.git"}}
+	check := artifact.Check{CheckSeq: "1", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "javascript", CPULimit: "1000", MemoryLimit: "1000"}}
+
+	kubernetesOpts := &KubernetesOpts{ImageURL: *imageURL}
+	images, err := kubernetesOpts.ResolveImages(analyzerImage(&check.AnalyzerMeta))
+	require.NoError(t, err)
+
+	creator, err := NewAnalysisDriverJob(run, check, &AnalysisOpts{
+		CredentialProfiles: []*CredentialProfile{
+			{Name: "npm", Type: CredentialProfileNpmrc, SecretName: "npm-registry"},
+			{Name: "env", Type: CredentialProfileEnv, SecretName: "registry-env"},
+		},
+		Images:         images,
+		KubernetesOpts: kubernetesOpts,
+	})
+	require.NoError(t, err)
+
+	job, err := (&MarvinK8sJob{JobCreator: creator}).Job()
+	require.NoError(t, err)
+	spec := job.Spec.Template.Spec
+
+	marvin := spec.Containers[0]
+	assert.Contains(t, marvin.VolumeMounts, corev1.VolumeMount{Name: "profile-npm", MountPath: "/home/runner/.npmrc", SubPath: ".npmrc", ReadOnly: true})
+	assert.Contains(t, marvin.Env, corev1.EnvVar{Name: "NPM_CONFIG_USERCONFIG", Value: "/home/runner/.npmrc"})
+	assert.Equal(t, "registry-env", marvin.EnvFrom[0].SecretRef.Name)
+
+	coat := spec.InitContainers[0]
+	assert.Empty(t, coat.EnvFrom)
+	for _, m := range coat.VolumeMounts {
+		assert.NotEqual(t, "profile-npm", m.Name)
+	}
+
+	var secretNames []string
+	for _, v := range spec.Volumes {
+		if v.Secret != nil {
+			secretNames = append(secretNames, v.Secret.SecretName)
+		}
+	}
+	assert.Contains(t, secretNames, "npm-registry")
+}
@@ -0,0 +1,364 @@
+package orchestrator
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DispatchClassDefault       = "default"
+	DispatchClassDefaultBranch = "default-branch"
+	DispatchClassInteractive   = "interactive"
+
+	DefaultDefaultBranchBoost = 2
+	DefaultInteractiveBoost   = 4
+
+	// DefaultDispatchQueueTimeout is how long tasks WithQueueTimeout wait
+	// for a slot, well within the timeout of HTTP and gRPC clients.
+	DefaultDispatchQueueTimeout = 20 * time.Second
+
+	// DefaultDispatchRetryAfter is the delay suggested to callers whose
+	// task timed out in the queue.
+	DefaultDispatchRetryAfter = 30 * time.Second
+)
+
+// DispatcherOpts configures the fair-share dispatcher.  Weights default to 1.
+type DispatcherOpts struct {
+	// MaxInFlight is the number of tasks dispatched at the same time.  A
+	// task holds its slot until its jobs finish.
+	MaxInFlight int
+
+	AppWeights        map[string]int
+	RepositoryWeights map[string]int
+
+	// DefaultBranchBoost multiplies the weight of default branch analysis
+	// runs.  Defaults to DefaultDefaultBranchBoost.
+	DefaultBranchBoost int
+
+	// InteractiveBoost multiplies the weight of tasks users wait on, like
+	// autofix and patcher runs.  Defaults to DefaultInteractiveBoost.
+	InteractiveBoost int
+
+	// JobTimeout is how long a job holds the slot of its task at most.
+	// Defaults to a minute past the job deadline, when jobs are killed.
+	JobTimeout time.Duration
+
+	// QueueTimeout is how long tasks WithQueueTimeout wait for a slot.
+	// Defaults to DefaultDispatchQueueTimeout.
+	QueueTimeout time.Duration
+
+	// RetryAfter is the delay suggested to callers whose task timed out in
+	// the queue.  Defaults to DefaultDispatchRetryAfter.
+	RetryAfter time.Duration
+}
+
+// DispatchRequest identifies the task waiting to be dispatched.
+type DispatchRequest struct {
+	AppID      string
+	Repository string
+	Class      string
+}
+
+// Dispatcher dispatches tasks fairly across apps and repositories.  At most
+// MaxInFlight tasks are in flight at once, from their dispatch until their
+// jobs finish; the others wait in a queue per app, repository and class, and
+// queues take turns by smooth weighted round-robin, so that a busy
+// repository cannot starve the others.
+//
+// The end of jobs is reported with JobDone, from the job watch.  Jobs whose
+// end is not reported, as with drivers that do not watch jobs, free their
+// slot after JobTimeout.
+type Dispatcher struct {
+	opts *DispatcherOpts
+
+	mu       sync.Mutex
+	inFlight int
+	queues   map[dispatchKey]*dispatchQueue
+	jobs     map[string]*heldJob
+}
+
+// DispatchSlot is the place of a dispatched task among the tasks in flight.
+// It is freed once the task released it and the jobs it holds are done.  A
+// nil *DispatchSlot holds nothing.
+type DispatchSlot struct {
+	d    *Dispatcher
+	refs int
+}
+
+type heldJob struct {
+	slot  *DispatchSlot
+	timer *time.Timer
+}
+
+type queueTimeoutKey struct{}
+
+// WithQueueTimeout returns a context for tasks whose caller waits a bounded
+// time for an answer, like HTTP clients.  Their tasks wait in the queue like
+// the others, but for QueueTimeout at most, after which Acquire returns a
+// *QuotaExceededError for them to retry later.
+func WithQueueTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queueTimeoutKey{}, true)
+}
+
+type dispatchKey struct {
+	appID      string
+	repository string
+	class      string
+}
+
+type dispatchQueue struct {
+	weight  int
+	current int
+	waiting []*dispatchWaiter
+}
+
+type dispatchWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+func NewDispatcher(opts *DispatcherOpts) *Dispatcher {
+	if opts.MaxInFlight < 1 {
+		opts.MaxInFlight = 1
+	}
+	if opts.DefaultBranchBoost < 1 {
+		opts.DefaultBranchBoost = DefaultDefaultBranchBoost
+	}
+	if opts.InteractiveBoost < 1 {
+		opts.InteractiveBoost = DefaultInteractiveBoost
+	}
+	if opts.JobTimeout <= 0 {
+		opts.JobTimeout = time.Duration(activeDeadlineSeconds)*time.Second + clonePhaseGrace
+	}
+	if opts.QueueTimeout <= 0 {
+		opts.QueueTimeout = DefaultDispatchQueueTimeout
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = DefaultDispatchRetryAfter
+	}
+	return &Dispatcher{
+		opts:   opts,
+		queues: make(map[dispatchKey]*dispatchQueue),
+		jobs:   make(map[string]*heldJob),
+	}
+}
+
+// Acquire waits until the task may be dispatched, or QueueTimeout when the
+// context is WithQueueTimeout.  The returned slot must be released once the task returns,
+// and holds the jobs the task triggered until they are done.  A nil
+// Dispatcher dispatches every task right away.
+func (d *Dispatcher) Acquire(ctx context.Context, req *DispatchRequest) (*DispatchSlot, error) {
+	if d == nil {
+		return nil, nil
+	}
+	d.mu.Lock()
+	if d.inFlight < d.opts.MaxInFlight && len(d.queues) == 0 {
+		d.inFlight++
+		d.mu.Unlock()
+		return d.slot(), nil
+	}
+	key := dispatchKey{appID: req.AppID, repository: req.Repository, class: req.Class}
+	q, ok := d.queues[key]
+	if !ok {
+		q = &dispatchQueue{weight: d.weight(req)}
+		d.queues[key] = q
+	}
+	w := &dispatchWaiter{ready: make(chan struct{})}
+	q.waiting = append(q.waiting, w)
+	d.dispatch()
+	d.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if bounded, _ := ctx.Value(queueTimeoutKey{}).(bool); bounded {
+		timer := time.NewTimer(d.opts.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case <-w.ready:
+		return d.slot(), nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = &QuotaExceededError{Resources: []string{"dispatcher/in-flight"}, RetryAfter: d.opts.RetryAfter}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !w.granted {
+		d.remove(key, w)
+		return nil, err
+	}
+	if ctx.Err() == nil {
+		// The slot was granted as the queue timed out.
+		return d.slot(), nil
+	}
+	d.inFlight--
+	d.dispatch()
+	return nil, err
+}
+
+func (d *Dispatcher) slot() *DispatchSlot {
+	return &DispatchSlot{d: d, refs: 1}
+}
+
+// Hold keeps the slot in flight until the job is done.  Jobs are held before
+// they are triggered, so that the end of jobs that finish right away is not
+// missed, and dropped if they fail to trigger.
+func (s *DispatchSlot) Hold(job string) {
+	if s == nil {
+		return
+	}
+	d := s.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.jobs[job]; ok {
+		return
+	}
+	s.refs++
+	d.jobs[job] = &heldJob{
+		slot:  s,
+		timer: time.AfterFunc(d.opts.JobTimeout, func() { d.JobDone(job) }),
+	}
+}
+
+// Drop gives up the hold of a job that failed to trigger.
+func (s *DispatchSlot) Drop(job string) {
+	if s == nil {
+		return
+	}
+	d := s.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	held, ok := d.jobs[job]
+	if !ok || held.slot != s {
+		return
+	}
+	delete(d.jobs, job)
+	held.timer.Stop()
+	s.unref()
+}
+
+// Release gives up the task's own hold on the slot.  The slot is freed once
+// the jobs it holds are done.
+func (s *DispatchSlot) Release() {
+	if s == nil {
+		return
+	}
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.unref()
+}
+
+// JobDone frees the hold of the job on the slot of its task.  Jobs that are
+// not held are ignored.
+func (d *Dispatcher) JobDone(job string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	held, ok := d.jobs[job]
+	if !ok {
+		return
+	}
+	delete(d.jobs, job)
+	held.timer.Stop()
+	held.slot.unref()
+}
+
+// unref must be called with d.mu held.
+func (s *DispatchSlot) unref() {
+	s.refs--
+	if s.refs == 0 {
+		s.d.inFlight--
+		s.d.dispatch()
+	}
+}
+
+// dispatch grants free slots to waiting tasks.  d.mu must be held.
+func (d *Dispatcher) dispatch() {
+	for d.inFlight < d.opts.MaxInFlight && len(d.queues) > 0 {
+		key, q := d.next()
+		w := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		if len(q.waiting) == 0 {
+			delete(d.queues, key)
+		}
+		w.granted = true
+		close(w.ready)
+		d.inFlight++
+	}
+}
+
+// next picks the queue to dispatch from by smooth weighted round-robin:
+// every queue earns its weight, and the richest queue is picked and pays the
+// total.  Ties go to the queue with the smallest key, to be deterministic.
+func (d *Dispatcher) next() (dispatchKey, *dispatchQueue) {
+	var (
+		bestKey dispatchKey
+		best    *dispatchQueue
+		total   int
+	)
+	for key, q := range d.queues {
+		q.current += q.weight
+		total += q.weight
+		if best == nil || q.current > best.current || q.current == best.current && key.less(bestKey) {
+			bestKey, best = key, q
+		}
+	}
+	best.current -= total
+	return bestKey, best
+}
+
+func (d *Dispatcher) remove(key dispatchKey, w *dispatchWaiter) {
+	q, ok := d.queues[key]
+	if !ok {
+		return
+	}
+	for i, waiter := range q.waiting {
+		if waiter == w {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			break
+		}
+	}
+	if len(q.waiting) == 0 {
+		delete(d.queues, key)
+	}
+}
+
+func (d *Dispatcher) weight(req *DispatchRequest) int {
+	weight := 1
+	if w, ok := d.opts.AppWeights[req.AppID]; ok && w > 0 {
+		weight *= w
+	}
+	if w, ok := d.opts.RepositoryWeights[req.Repository]; ok && w > 0 {
+		weight *= w
+	}
+	switch req.Class {
+	case DispatchClassDefaultBranch:
+		weight *= d.opts.DefaultBranchBoost
+	case DispatchClassInteractive:
+		weight *= d.opts.InteractiveBoost
+	}
+	return weight
+}
+
+// dispatchClass returns the class of an analysis run.
+func dispatchClass(isForDefaultBranch bool) string {
+	if isForDefaultBranch {
+		return DispatchClassDefaultBranch
+	}
+	return DispatchClassDefault
+}
+
+func (k dispatchKey) less(o dispatchKey) bool {
+	if k.appID != o.appID {
+		return k.appID < o.appID
+	}
+	if k.repository != o.repository {
+		return k.repository < o.repository
+	}
+	return k.class < o.class
+}
@@ -0,0 +1,317 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contend queues the requests, in order, behind a task holding the only
+// slot of the dispatcher, and returns the order they are dispatched in.
+func contend(t *testing.T, d *Dispatcher, reqs []*DispatchRequest) []int {
+	t.Helper()
+	hold, err := d.Acquire(context.Background(), &DispatchRequest{AppID: "holder"})
+	require.NoError(t, err)
+
+	type grant struct {
+		i    int
+		slot *DispatchSlot
+	}
+	grants := make(chan grant, len(reqs))
+	for i, req := range reqs {
+		go func(i int, req *DispatchRequest) {
+			slot, err := d.Acquire(context.Background(), req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			grants <- grant{i: i, slot: slot}
+		}(i, req)
+		require.Eventually(t, func() bool { return waiting(d) == i+1 }, time.Second, time.Millisecond)
+	}
+
+	hold.Release()
+	var order []int
+	for range reqs {
+		select {
+		case g := <-grants:
+			order = append(order, g.i)
+			g.slot.Release()
+		case <-time.After(time.Second):
+			t.Fatal("task not dispatched")
+		}
+	}
+	return order
+}
+
+func waiting(d *Dispatcher) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, q := range d.queues {
+		n += len(q.waiting)
+	}
+	return n
+}
+
+func repeat(req *DispatchRequest, n int) []*DispatchRequest {
+	reqs := make([]*DispatchRequest, n)
+	for i := range reqs {
+		reqs[i] = req
+	}
+	return reqs
+}
+
+func TestDispatcher_BusyRepository(t *testing.T) {
+	d := NewDispatcher(&DispatcherOpts{MaxInFlight: 1})
+	busy := &DispatchRequest{AppID: "app", Repository: "acme/busy", Class: DispatchClassDefault}
+	quiet := &DispatchRequest{AppID: "app", Repository: "acme/quiet", Class: DispatchClassDefault}
+
+	// The quiet repository queues behind six runs of the busy one.
+	order := contend(t, d, append(repeat(busy, 6), quiet, quiet))
+	assert.Equal(t, []int{0, 6, 1, 7, 2, 3, 4, 5}, order)
+}
+
+func TestDispatcher_Boosts(t *testing.T) {
+	d := NewDispatcher(&DispatcherOpts{MaxInFlight: 1})
+	analysis := &DispatchRequest{AppID: "app", Repository: "acme/web", Class: DispatchClassDefault}
+	defaultBranch := &DispatchRequest{AppID: "app", Repository: "acme/web", Class: DispatchClassDefaultBranch}
+	autofix := &DispatchRequest{AppID: "app", Repository: "acme/api", Class: DispatchClassInteractive}
+
+	order := contend(t, d, append(append(repeat(analysis, 4), repeat(defaultBranch, 4)...), autofix))
+	assert.Equal(t, 8, order[0], "interactive tasks go first")
+	// Default branch runs get two of every three dispatches.
+	assert.Equal(t, []int{4, 0, 5, 6, 1, 7}, order[1:7])
+}
+
+func TestDispatcher_AppWeights(t *testing.T) {
+	d := NewDispatcher(&DispatcherOpts{
+		MaxInFlight:       1,
+		AppWeights:        map[string]int{"app-a": 3},
+		RepositoryWeights: map[string]int{"acme/c": 2},
+	})
+	a := &DispatchRequest{AppID: "app-a", Repository: "acme/a"}
+	b := &DispatchRequest{AppID: "app-b", Repository: "acme/b"}
+	c := &DispatchRequest{AppID: "app-b", Repository: "acme/c"}
+
+	order := contend(t, d, append(append(repeat(a, 12), repeat(b, 12)...), repeat(c, 12)...))
+	counts := map[string]int{}
+	for _, i := range order[:12] {
+		counts[[]string{"a", "b", "c"}[i/12]]++
+	}
+	assert.Equal(t, map[string]int{"a": 6, "b": 2, "c": 4}, counts)
+}
+
+// dispatchDriver reports the names of the jobs it triggers.
+type dispatchDriver struct {
+	testDriver
+	triggered chan string
+}
+
+func (d *dispatchDriver) TriggerJob(_ context.Context, job JobCreator) error {
+	d.triggered <- job.Name()
+	return nil
+}
+
+func TestDispatcher_HTTP(t *testing.T) {
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	d := NewDispatcher(&DispatcherOpts{MaxInFlight: 1, QueueTimeout: time.Second})
+	driver := &dispatchDriver{triggered: make(chan string, 1)}
+	opts := &TaskOpts{KubernetesOpts: &KubernetesOpts{ImageURL: *registry}, Dispatcher: d}
+	h := NewHandler(NewTasks(opts, driver, testProvider{}, testProvider{}, testSigner{}, &Runner{ID: "runner-id"}, nil), nil)
+	e := echo.New()
+	e.POST("/apps/:app_id/tasks/analysis", h.HandleAnalysis)
+
+	type response struct {
+		i    int
+		code int
+	}
+	responses := make(chan response, 8)
+	analyze := func(i int, repository string) {
+		run := &artifact.AnalysisRun{
+			RunID:   "run-" + string(rune('a'+i)),
+			VCSMeta: artifact.AnalysisRunVCSMeta{RemoteURL: "https://github.com/" + repository + ".git"},
+			Checks:  []artifact.Check{{CheckSeq: "1", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "python", Version: "v1"}}},
+		}
+		body, _ := json.Marshal(run)
+		req := httptest.NewRequest(http.MethodPost, "/apps/app-id/tasks/analysis", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		responses <- response{i: i, code: rec.Code}
+	}
+
+	// HTTP tasks wait in their queue, so the quiet repository is not
+	// starved by the busy one retrying.
+	hold, err := d.Acquire(context.Background(), &DispatchRequest{AppID: "holder"})
+	require.NoError(t, err)
+	repositories := []string{"acme/busy", "acme/busy", "acme/busy", "acme/busy", "acme/busy", "acme/busy", "acme/quiet", "acme/quiet"}
+	for i, repository := range repositories {
+		go analyze(i, repository)
+		require.Eventually(t, func() bool { return waiting(d) == i+1 }, time.Second, time.Millisecond)
+	}
+	hold.Release()
+	var order []int
+	for range repositories {
+		select {
+		case r := <-responses:
+			assert.Equal(t, http.StatusOK, r.code)
+			order = append(order, r.i)
+			d.JobDone(<-driver.triggered)
+		case <-time.After(time.Second):
+			t.Fatal("task not dispatched")
+		}
+	}
+	assert.Equal(t, []int{0, 6, 1, 7, 2, 3, 4, 5}, order)
+
+	// Tasks still waiting after the queue timeout are answered with 503.
+	hold, err = d.Acquire(context.Background(), &DispatchRequest{AppID: "holder"})
+	require.NoError(t, err)
+	defer hold.Release()
+	d.opts.QueueTimeout = 10 * time.Millisecond
+	req := httptest.NewRequest(http.MethodPost, "/apps/app-id/tasks/analysis", bytes.NewReader([]byte(`{"run_id": "run-z"}`)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	err = h.HandleAnalysis(e.NewContext(req, rec))
+	var httpErr *httperror.Error
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+	assert.Equal(t, "30", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Zero(t, waiting(d))
+}
+
+// finishingDriver reports the jobs it triggers done right away, as the job
+// watch does for jobs that fail at once, or fails to trigger them.
+type finishingDriver struct {
+	testDriver
+	d   *Dispatcher
+	err error
+}
+
+func (f *finishingDriver) TriggerJob(_ context.Context, job JobCreator) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.d.JobDone(job.Name())
+	return nil
+}
+
+func TestDispatcher_FastJobs(t *testing.T) {
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	d := NewDispatcher(&DispatcherOpts{MaxInFlight: 1})
+	driver := &finishingDriver{d: d}
+	task := NewAnalysisTask(&Runner{ID: "runner-id"}, &TaskOpts{KubernetesOpts: &KubernetesOpts{ImageURL: *registry}, Dispatcher: d}, driver, testProvider{}, testSigner{})
+	analyze := func() error {
+		return task.Run(context.Background(), &AnalysisRunRequest{
+			AppID: "app-id",
+			Run: &artifact.AnalysisRun{
+				RunID:  "run-id",
+				Checks: []artifact.Check{{CheckSeq: "1", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "python", Version: "v1"}}},
+			},
+		})
+	}
+
+	require.NoError(t, analyze())
+	assert.Zero(t, d.inFlight, "jobs done before the task returns free the slot")
+	assert.Empty(t, d.jobs)
+
+	driver.err = errors.New("invalid job")
+	require.NoError(t, analyze())
+	assert.Zero(t, d.inFlight, "jobs that fail to trigger free the slot")
+	assert.Empty(t, d.jobs)
+}
+
+func TestDispatcher_Acquire(t *testing.T) {
+	t.Run("slots", func(t *testing.T) {
+		d := NewDispatcher(&DispatcherOpts{MaxInFlight: 2})
+		req := &DispatchRequest{AppID: "app", Repository: "acme/web"}
+		s1, err := d.Acquire(context.Background(), req)
+		require.NoError(t, err)
+		s2, err := d.Acquire(context.Background(), req)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = d.Acquire(ctx, req)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Zero(t, waiting(d), "cancelled tasks leave the queue")
+
+		s1.Release()
+		s2.Release()
+		s3, err := d.Acquire(context.Background(), req)
+		require.NoError(t, err)
+		s3.Release()
+		assert.Zero(t, d.inFlight)
+	})
+
+	t.Run("jobs hold the slot", func(t *testing.T) {
+		d := NewDispatcher(&DispatcherOpts{MaxInFlight: 1, QueueTimeout: 10 * time.Millisecond})
+		req := &DispatchRequest{AppID: "app", Repository: "acme/web"}
+		slot, err := d.Acquire(context.Background(), req)
+		require.NoError(t, err)
+		slot.Hold("analysis-s1")
+		slot.Hold("analysis-s2")
+		slot.Release()
+
+		_, err = d.Acquire(WithQueueTimeout(context.Background()), req)
+		var quotaErr *QuotaExceededError
+		require.ErrorAs(t, err, &quotaErr, "callers that time out in the queue are rejected")
+		assert.Equal(t, DefaultDispatchRetryAfter, quotaErr.RetryAfter)
+		assert.Zero(t, waiting(d))
+
+		d.JobDone("analysis-s1")
+		d.JobDone("analysis-s1")
+		assert.Equal(t, 1, d.inFlight)
+		d.JobDone("analysis-s2")
+		assert.Zero(t, d.inFlight)
+	})
+
+	t.Run("job events free the slot", func(t *testing.T) {
+		d := NewDispatcher(&DispatcherOpts{MaxInFlight: 1})
+		events := NewEvents(10)
+		events.dispatcher = d
+		slot, err := d.Acquire(context.Background(), &DispatchRequest{AppID: "app"})
+		require.NoError(t, err)
+		slot.Hold("analysis-s1")
+		slot.Release()
+
+		events.JobChanged(&JobState{Name: "analysis-s1", Phase: JobPhaseRunning})
+		assert.Equal(t, 1, d.inFlight)
+		events.JobChanged(&JobState{Name: "analysis-s1", Phase: JobPhaseSucceeded})
+		assert.Zero(t, d.inFlight)
+	})
+
+	t.Run("jobs time out", func(t *testing.T) {
+		d := NewDispatcher(&DispatcherOpts{MaxInFlight: 1, JobTimeout: 10 * time.Millisecond})
+		slot, err := d.Acquire(context.Background(), &DispatchRequest{AppID: "app"})
+		require.NoError(t, err)
+		slot.Hold("analysis-s1")
+		slot.Release()
+
+		next, err := d.Acquire(context.Background(), &DispatchRequest{AppID: "app"})
+		require.NoError(t, err)
+		next.Release()
+		assert.Zero(t, d.inFlight)
+	})
+
+	t.Run("nil dispatcher", func(t *testing.T) {
+		var d *Dispatcher
+		slot, err := d.Acquire(context.Background(), &DispatchRequest{})
+		require.NoError(t, err)
+		slot.Hold("analysis-s1")
+		slot.Release()
+		d.JobDone("analysis-s1")
+	})
+}
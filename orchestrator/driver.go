@@ -10,15 +10,41 @@ type Resource struct {
 	Memory string
 }
 
+// Mount is a volume mounted into a container in addition to the job's
+// emptyDir volumes.
+type Mount struct {
+	Name      string
+	MountPath string
+	ReadOnly  bool
+
+	// SubPath mounts a single key or directory of the volume instead of the
+	// whole volume.
+	SubPath string
+
+	// ClaimName is the persistent volume claim backing the volume.
+	ClaimName string
+
+	// SecretName is the Secret backing the volume.
+	SecretName string
+}
+
 type Container struct {
 	Env          map[string]string
 	VolumeMounts map[string]string
+	Mounts       []Mount
 	Limit        Resource
 	Requests     Resource
 	Name         string
 	Image        string
 	Cmd          []string
 	Args         []string
+
+	// EnvFromSecrets are Secrets whose keys are exposed as environment
+	// variables.
+	EnvFromSecrets []string
+
+	// PullPolicy is the image pull policy.  Defaults to Always.
+	PullPolicy string
 }
 
 type Driver interface {
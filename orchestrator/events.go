@@ -0,0 +1,460 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Types of job lifecycle events.
+const (
+	EventTaskReceived     = "task_received"
+	EventJobCreated       = "job_created"
+	EventPodScheduled     = "pod_scheduled"
+	EventContainerStarted = "container_started"
+	EventJobFinished      = "job_finished"
+	EventJobFailed        = "job_failed"
+	EventJobCancelled     = "job_cancelled"
+	EventJobCleanedUp     = "job_cleaned_up"
+)
+
+const (
+	DefaultEventBufferSize = 1000
+
+	eventsRewatchDelay = 5 * time.Second
+	eventsKeepAlive    = 15 * time.Second
+
+	// subscriberBuffer is how many events a subscriber can fall behind
+	// before it is dropped.
+	subscriberBuffer = 64
+)
+
+var errWatchClosed = errors.New("watch closed")
+
+// Event is a step in the lifecycle of a task and its jobs.
+type Event struct {
+	ID        uint64    `json:"id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Task      string    `json:"task,omitempty"`
+	AppID     string    `json:"app_id,omitempty"`
+	RunID     string    `json:"run_id,omitempty"`
+	Job       string    `json:"job,omitempty"`
+	Analyzer  string    `json:"analyzer,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// EventFilter selects events.  Empty fields match any event.
+type EventFilter struct {
+	AppID string
+	RunID string
+	Task  string
+}
+
+func (f *EventFilter) Match(e *Event) bool {
+	return (f.AppID == "" || f.AppID == e.AppID) &&
+		(f.RunID == "" || f.RunID == e.RunID) &&
+		(f.Task == "" || f.Task == e.Task)
+}
+
+// EventWatcher is implemented by drivers that report the lifecycle of jobs
+// as it happens.  Watches resume from the cursor when restarted.
+type EventWatcher interface {
+	WatchEvents(ctx context.Context, namespace string, events *Events, cursor *WatchCursor) error
+}
+
+// WatchCursor is where restarted watches resume from: the resource versions
+// of the last job and pod events.  Empty versions are listed again.
+type WatchCursor struct {
+	Jobs string
+	Pods string
+}
+
+// taskInfo identifies the task a job runs for.
+type taskInfo struct {
+	Task  string
+	AppID string
+	RunID string
+}
+
+type taskKey struct{}
+
+// trackedJob is what is known of a job, to attribute its events to its task
+// and report every step once.
+type trackedJob struct {
+	taskInfo
+	analyzer string
+	phase    string
+	pods     map[string]bool
+	started  map[string]bool
+	sampled  map[string]bool
+
+	// tracked is when the job was first seen, so that jobs created while
+	// the jobs were listed are not pruned.
+	tracked time.Time
+}
+
+// Events publishes the lifecycle events of the runner's jobs, and keeps the
+// last events so that subscribers can resume after reconnecting.  A nil
+// *Events drops events.
+type Events struct {
+	mu          sync.Mutex
+	buffer      []*Event
+	next        int
+	lastID      uint64
+	jobs        map[string]*trackedJob
+	subscribers map[chan *Event]*EventFilter
+
+	// dispatcher is told when jobs are done, to free the slots of their
+	// tasks.
+	dispatcher *Dispatcher
+
+	// sampler is told when the analyzers of pods complete.
+	sampler UsageSampler
+}
+
+func NewEvents(size int) *Events {
+	if size <= 0 {
+		size = DefaultEventBufferSize
+	}
+	return &Events{
+		buffer:      make([]*Event, 0, size),
+		jobs:        make(map[string]*trackedJob),
+		subscribers: make(map[chan *Event]*EventFilter),
+	}
+}
+
+// Subscribe returns the buffered events after lastID that match the filter,
+// and a channel of the events published from then on.  The channel is closed
+// by cancel, or when the subscriber falls too far behind; it can then
+// subscribe again from the last event it received.
+func (e *Events) Subscribe(lastID uint64, filter *EventFilter) (replay []*Event, events <-chan *Event, cancel func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := len(e.buffer)
+	for i := 0; i < n; i++ {
+		event := e.buffer[(e.next+i)%n]
+		if event.ID > lastID && filter.Match(event) {
+			replay = append(replay, event)
+		}
+	}
+	ch := make(chan *Event, subscriberBuffer)
+	e.subscribers[ch] = filter
+	return replay, ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.unsubscribe(ch)
+	}
+}
+
+func (e *Events) unsubscribe(ch chan *Event) {
+	if _, ok := e.subscribers[ch]; ok {
+		delete(e.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish must be called with the lock held.
+func (e *Events) publish(event *Event) {
+	e.lastID++
+	event.ID = e.lastID
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if len(e.buffer) < cap(e.buffer) {
+		e.buffer = append(e.buffer, event)
+	} else {
+		e.buffer[e.next] = event
+		e.next = (e.next + 1) % len(e.buffer)
+	}
+	for ch, filter := range e.subscribers {
+		if !filter.Match(event) {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			e.unsubscribe(ch)
+		}
+	}
+}
+
+func (e *Events) job(name string) *trackedJob {
+	j, ok := e.jobs[name]
+	if !ok {
+		j = &trackedJob{pods: make(map[string]bool), started: make(map[string]bool), sampled: make(map[string]bool), tracked: time.Now()}
+		e.jobs[name] = j
+	}
+	return j
+}
+
+func (e *Events) jobEvent(typ string, name string, j *trackedJob) *Event {
+	return &Event{Type: typ, Task: j.Task, AppID: j.AppID, RunID: j.RunID, Job: name, Analyzer: j.analyzer}
+}
+
+// TaskReceived publishes the receipt of a task, and returns the context to
+// run it with, so that its jobs are attributed to it.
+func (e *Events) TaskReceived(ctx context.Context, task, appID, runID string) context.Context {
+	info := taskInfo{Task: task, AppID: appID, RunID: runID}
+	if e != nil {
+		e.mu.Lock()
+		e.publish(&Event{Type: EventTaskReceived, Task: task, AppID: appID, RunID: runID})
+		e.mu.Unlock()
+	}
+	return context.WithValue(ctx, taskKey{}, info)
+}
+
+// JobCreated publishes the creation of a job for the task of the context.
+// The analyzer is empty for jobs that do not run one.
+func (e *Events) JobCreated(ctx context.Context, name, analyzer string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	j := e.job(name)
+	j.analyzer = analyzer
+	if info, ok := ctx.Value(taskKey{}).(taskInfo); ok {
+		j.taskInfo = info
+	}
+	e.publish(e.jobEvent(EventJobCreated, name, j))
+}
+
+// JobChanged publishes the end of a job, once.
+func (e *Events) JobChanged(state *JobState) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	j := e.job(state.Name)
+	if j.phase == state.Phase {
+		return
+	}
+	j.phase = state.Phase
+	if state.Done() {
+		e.dispatcher.JobDone(state.Name)
+	}
+	switch state.Phase {
+	case JobPhaseSucceeded:
+		e.publish(e.jobEvent(EventJobFinished, state.Name, j))
+	case JobPhaseFailed:
+		event := e.jobEvent(EventJobFailed, state.Name, j)
+		event.Message = state.Message
+		e.publish(event)
+	}
+}
+
+// JobDeleted publishes the deletion of a job: a clean up if the job was
+// done, a cancellation otherwise.
+func (e *Events) JobDeleted(state *JobState) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.jobDeleted(state)
+}
+
+// Prune forgets the jobs missing from a list of the existing jobs taken at
+// listedAt, as deleted while the watch was down.  Jobs first seen after the
+// list was taken are kept.
+func (e *Events) Prune(existing []string, listedAt time.Time) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	keep := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		keep[name] = true
+	}
+	for name, j := range e.jobs {
+		if !keep[name] && j.tracked.Before(listedAt) {
+			e.jobDeleted(&JobState{Name: name, Phase: j.phase})
+		}
+	}
+}
+
+func (e *Events) jobDeleted(state *JobState) {
+	j := e.job(state.Name)
+	typ := EventJobCancelled
+	if state.Done() {
+		typ = EventJobCleanedUp
+	}
+	e.publish(e.jobEvent(typ, state.Name, j))
+	delete(e.jobs, state.Name)
+	e.dispatcher.JobDone(state.Name)
+}
+
+// PodScheduled publishes the scheduling of a pod of a job, once.  Pods of
+// jobs that are not known, or already deleted, are ignored.
+func (e *Events) PodScheduled(job, pod, node string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	j, ok := e.jobs[job]
+	if !ok || j.pods[pod] {
+		return
+	}
+	j.pods[pod] = true
+	event := e.jobEvent(EventPodScheduled, job, j)
+	event.Pod, event.Message = pod, node
+	e.publish(event)
+}
+
+// ContainerStarted publishes the start of a container of a job, once.
+func (e *Events) ContainerStarted(job, pod, container string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	j, ok := e.jobs[job]
+	key := pod + "/" + container
+	if !ok || j.started[key] {
+		return
+	}
+	j.started[key] = true
+	event := e.jobEvent(EventContainerStarted, job, j)
+	event.Pod, event.Container = pod, container
+	e.publish(event)
+}
+
+// AnalyzerFinished reports the completion of the analyzer of a pod to the
+// usage sampler, once.
+func (e *Events) AnalyzerFinished(job, pod string) {
+	if e == nil || e.sampler == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	j, ok := e.jobs[job]
+	if !ok || j.sampled[pod] {
+		return
+	}
+	j.sampled[pod] = true
+	e.sampler.SamplePod(pod)
+}
+
+// WatchEvents reports the lifecycle of the runner's jobs and of their pods
+// until the context is cancelled or a watch closes.  The watches resume from
+// the cursor, which is advanced as events are received.  Without a version to
+// resume from, or once it expired, the jobs and pods are listed again, and
+// the jobs deleted in the meantime pruned.
+func (d *K8sDriver) WatchEvents(ctx context.Context, namespace string, events *Events, cursor *WatchCursor) error {
+	selector := LabelNameManager + "=runner"
+	if cursor.Jobs == "" {
+		listedAt := time.Now()
+		list, err := d.clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(list.Items))
+		for i := range list.Items {
+			names = append(names, list.Items[i].Name)
+			events.JobChanged(jobState(&list.Items[i]))
+		}
+		events.Prune(names, listedAt)
+		cursor.Jobs = list.ResourceVersion
+	}
+	if cursor.Pods == "" {
+		list, err := d.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			podEvents(events, &list.Items[i])
+		}
+		cursor.Pods = list.ResourceVersion
+	}
+
+	jobs, err := d.clientset.BatchV1().Jobs(namespace).Watch(ctx, metav1.ListOptions{LabelSelector: selector, ResourceVersion: cursor.Jobs, AllowWatchBookmarks: true})
+	if err != nil {
+		return resetCursor(err, &cursor.Jobs)
+	}
+	defer jobs.Stop()
+	pods, err := d.clientset.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{LabelSelector: selector, ResourceVersion: cursor.Pods, AllowWatchBookmarks: true})
+	if err != nil {
+		return resetCursor(err, &cursor.Pods)
+	}
+	defer pods.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-jobs.ResultChan():
+			if !ok {
+				return errWatchClosed
+			}
+			if e.Type == watch.Error {
+				return resetCursor(apierrors.FromObject(e.Object), &cursor.Jobs)
+			}
+			job, ok := e.Object.(*batchv1.Job)
+			if !ok {
+				continue
+			}
+			cursor.Jobs = job.ResourceVersion
+			switch e.Type {
+			case watch.Added, watch.Modified:
+				events.JobChanged(jobState(job))
+			case watch.Deleted:
+				events.JobDeleted(jobState(job))
+			}
+		case e, ok := <-pods.ResultChan():
+			if !ok {
+				return errWatchClosed
+			}
+			if e.Type == watch.Error {
+				return resetCursor(apierrors.FromObject(e.Object), &cursor.Pods)
+			}
+			pod, ok := e.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+			cursor.Pods = pod.ResourceVersion
+			if e.Type == watch.Added || e.Type == watch.Modified {
+				podEvents(events, pod)
+			}
+		}
+	}
+}
+
+// resetCursor clears the resource version of a watch that expired, so that
+// it is listed again, and returns the error.
+func resetCursor(err error, version *string) error {
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		*version = ""
+	}
+	return err
+}
+
+func podEvents(events *Events, pod *corev1.Pod) {
+	job := pod.Labels[LabelNameApp]
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionTrue {
+			events.PodScheduled(job, pod.Name, pod.Spec.NodeName)
+		}
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		if s.State.Running != nil || s.State.Terminated != nil {
+			events.ContainerStarted(job, pod.Name, s.Name)
+		}
+		if s.Name == "marvin" && s.State.Terminated != nil && pod.Labels[LabelNameRole] == "analysis" {
+			events.AnalyzerFinished(job, pod.Name)
+		}
+	}
+}
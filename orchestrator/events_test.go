@@ -0,0 +1,279 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/maps"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func eventTypes(events []*Event) []string {
+	types := make([]string, 0, len(events))
+	for _, e := range events {
+		types = append(types, e.Type+":"+e.Job)
+	}
+	return types
+}
+
+func TestEvents_Subscribe(t *testing.T) {
+	events := NewEvents(3)
+	driver := &recordingDriver{Driver: &testDriver{}, events: events}
+
+	ctx := events.TaskReceived(context.Background(), TaskNameAnalysis, "app-1", "run-1")
+	require.NoError(t, driver.TriggerJob(ctx, &namedJob{name: "analysis-1"}))
+	ctx = events.TaskReceived(context.Background(), TaskNameAutofix, "app-2", "run-2")
+	require.NoError(t, driver.TriggerJob(ctx, &namedJob{name: "autofix-2"}))
+
+	t.Run("replay is bounded", func(t *testing.T) {
+		replay, _, cancel := events.Subscribe(0, &EventFilter{})
+		defer cancel()
+		assert.Equal(t, []uint64{2, 3, 4}, []uint64{replay[0].ID, replay[1].ID, replay[2].ID})
+	})
+
+	t.Run("resume after last event", func(t *testing.T) {
+		replay, _, cancel := events.Subscribe(3, &EventFilter{})
+		defer cancel()
+		assert.Equal(t, []string{EventJobCreated + ":autofix-2"}, eventTypes(replay))
+		assert.Equal(t, &Event{ID: 4, Type: EventJobCreated, Time: replay[0].Time, Task: TaskNameAutofix, AppID: "app-2", RunID: "run-2", Job: "autofix-2"}, replay[0])
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		replay, ch, cancel := events.Subscribe(0, &EventFilter{AppID: "app-1"})
+		defer cancel()
+		assert.Equal(t, []string{EventJobCreated + ":analysis-1"}, eventTypes(replay))
+
+		events.JobChanged(&JobState{Name: "autofix-2", Phase: JobPhaseSucceeded})
+		events.JobChanged(&JobState{Name: "analysis-1", Phase: JobPhaseFailed, Message: "BackoffLimitExceeded"})
+		events.JobChanged(&JobState{Name: "analysis-1", Phase: JobPhaseFailed})
+		e := <-ch
+		assert.Equal(t, EventJobFailed, e.Type)
+		assert.Equal(t, "run-1", e.RunID)
+		assert.Equal(t, "BackoffLimitExceeded", e.Message)
+		assert.Empty(t, ch, "repeated states are not published")
+	})
+
+	t.Run("slow subscribers are dropped", func(t *testing.T) {
+		_, ch, cancel := events.Subscribe(0, &EventFilter{})
+		defer cancel()
+		for i := 0; i <= subscriberBuffer; i++ {
+			events.TaskReceived(context.Background(), TaskNameAnalysis, "app-1", "run")
+		}
+		n := 0
+		for range ch {
+			n++
+		}
+		assert.Equal(t, subscriberBuffer, n)
+	})
+
+	t.Run("nil events", func(t *testing.T) {
+		var events *Events
+		ctx := events.TaskReceived(context.Background(), TaskNameAnalysis, "app-1", "run-1")
+		events.JobCreated(ctx, "analysis-1", "python")
+		events.JobDeleted(&JobState{Name: "analysis-1"})
+	})
+}
+
+type testUsageSampler []string
+
+func (s *testUsageSampler) SamplePod(pod string) { *s = append(*s, pod) }
+
+func TestEvents_AnalyzerFinished(t *testing.T) {
+	events := NewEvents(0)
+	sampler := &testUsageSampler{}
+	events.sampler = sampler
+	events.JobCreated(context.Background(), "analysis-1", "python")
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "analysis-1-x", Labels: map[string]string{LabelNameApp: "analysis-1", LabelNameRole: "analysis"}},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "marvin", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}},
+		},
+	}
+	podEvents(events, pod)
+	assert.Empty(t, *sampler, "running analyzers are left to the sample interval")
+
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}
+	podEvents(events, pod)
+	podEvents(events, pod)
+	assert.Equal(t, testUsageSampler{"analysis-1-x"}, *sampler)
+}
+
+func TestK8sDriver_WatchEvents(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	driver := &K8sDriver{clientset: clientset}
+	events := NewEvents(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- driver.WatchEvents(ctx, "runner", events, &WatchCursor{}) }()
+	require.Eventually(t, func() bool { return len(clientset.Actions()) == 4 }, 5*time.Second, time.Millisecond)
+
+	jobs := clientset.BatchV1().Jobs("runner")
+	pods := clientset.CoreV1().Pods("runner")
+	events.JobCreated(events.TaskReceived(ctx, TaskNameAnalysis, "app-id", "run-id"), "analysis-1", "python")
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "analysis-1", Namespace: "runner", Labels: map[string]string{LabelNameManager: "runner"}}}
+	_, err := jobs.Create(ctx, job, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "analysis-1-x", Namespace: "runner", Labels: map[string]string{LabelNameApp: "analysis-1", LabelNameManager: "runner"}},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{
+			Conditions:        []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}},
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "marvin", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}},
+		},
+	}
+	_, err = pods.Create(ctx, pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = pods.Update(ctx, pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		replay, _, cancel := events.Subscribe(0, &EventFilter{})
+		cancel()
+		return len(replay) == 4
+	}, 5*time.Second, time.Millisecond)
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	_, err = jobs.Update(ctx, job, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, jobs.Delete(ctx, "analysis-1", metav1.DeleteOptions{}))
+
+	_, err = jobs.Create(ctx, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "analysis-2", Namespace: "runner", Labels: map[string]string{LabelNameManager: "runner"}}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, jobs.Delete(ctx, "analysis-2", metav1.DeleteOptions{}))
+
+	want := []string{
+		EventTaskReceived + ":",
+		EventJobCreated + ":analysis-1",
+		EventPodScheduled + ":analysis-1",
+		EventContainerStarted + ":analysis-1",
+		EventJobFinished + ":analysis-1",
+		EventJobCleanedUp + ":analysis-1",
+		EventJobCancelled + ":analysis-2",
+	}
+	require.Eventually(t, func() bool {
+		replay, _, cancel := events.Subscribe(0, &EventFilter{})
+		cancel()
+		return len(replay) == len(want)
+	}, 5*time.Second, time.Millisecond)
+
+	replay, _, unsubscribe := events.Subscribe(0, &EventFilter{RunID: "run-id"})
+	unsubscribe()
+	assert.Equal(t, want[:6], eventTypes(replay))
+	assert.Equal(t, "node-1", replay[2].Message)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestHandler_HandleEvents(t *testing.T) {
+	events := NewEvents(0)
+	events.TaskReceived(context.Background(), TaskNameAnalysis, "app-1", "run-1")
+	events.TaskReceived(context.Background(), TaskNameAutofix, "app-1", "run-2")
+	events.TaskReceived(context.Background(), TaskNameAnalysis, "app-2", "run-3")
+	h := &Handler{events: events}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/admin/events?app_id=app-1", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "1")
+	rec := httptest.NewRecorder()
+	done := make(chan error)
+	go func() { done <- h.HandleEvents(echo.New().NewContext(req, rec)) }()
+
+	require.Eventually(t, func() bool {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+		return len(events.subscribers) == 1
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+	body := rec.Body.String()
+	assert.True(t, strings.HasPrefix(body, "id: 2\nevent: task_received\ndata: {\"id\":2,"), body)
+	assert.NotContains(t, body, "run-1")
+	assert.NotContains(t, body, "run-3")
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/admin/events", nil)
+	req.Header.Set("Last-Event-ID", "latest")
+	err := h.HandleEvents(echo.New().NewContext(req, rec))
+	assert.Error(t, err)
+}
+
+func TestEvents_Prune(t *testing.T) {
+	events := NewEvents(0)
+	listedAt := time.Now()
+	events.JobCreated(context.Background(), "analysis-1", "python")
+	events.JobChanged(&JobState{Name: "analysis-2", Phase: JobPhaseSucceeded})
+	events.JobChanged(&JobState{Name: "analysis-3", Phase: JobPhaseRunning})
+	for _, j := range events.jobs {
+		j.tracked = listedAt.Add(-time.Second)
+	}
+	events.JobCreated(context.Background(), "analysis-4", "go")
+
+	// analysis-4 was created after the list was taken.
+	events.Prune([]string{"analysis-1"}, listedAt)
+	assert.ElementsMatch(t, []string{"analysis-1", "analysis-4"}, maps.Keys(events.jobs))
+
+	replay, _, cancel := events.Subscribe(0, &EventFilter{})
+	cancel()
+	types := eventTypes(replay)
+	assert.Contains(t, types, EventJobCleanedUp+":analysis-2")
+	assert.Contains(t, types, EventJobCancelled+":analysis-3")
+}
+
+func TestK8sDriver_WatchEvents_Resume(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "analysis-1", Namespace: "runner", Labels: map[string]string{LabelNameManager: "runner"}}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "runner"}},
+	)
+	var versions []string
+	clientset.PrependWatchReactor("jobs", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		versions = append(versions, action.(k8stesting.WatchActionImpl).WatchRestrictions.ResourceVersion)
+		w := watch.NewFake()
+		go func() {
+			if len(versions) == 1 {
+				w.Add(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "analysis-1", ResourceVersion: "7"}})
+				w.Stop()
+				return
+			}
+			w.Error(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonExpired})
+		}()
+		return true, w, nil
+	})
+	driver := &K8sDriver{clientset: clientset}
+	events := NewEvents(0)
+	events.JobChanged(&JobState{Name: "gone", Phase: JobPhaseRunning})
+	for _, j := range events.jobs {
+		j.tracked = time.Now().Add(-time.Minute)
+	}
+	cursor := &WatchCursor{}
+
+	// The first watch lists the jobs, and prunes those deleted.
+	err := driver.WatchEvents(context.Background(), "runner", events, cursor)
+	assert.ErrorIs(t, err, errWatchClosed)
+	assert.Equal(t, "7", cursor.Jobs)
+	assert.ElementsMatch(t, []string{"analysis-1"}, maps.Keys(events.jobs), "jobs of other managers are not listed")
+
+	// The restarted watch resumes from the last event, until it expires.
+	err = driver.WatchEvents(context.Background(), "runner", events, cursor)
+	assert.True(t, apierrors.IsResourceExpired(err))
+	assert.Equal(t, []string{"", "7"}, versions)
+	assert.Empty(t, cursor.Jobs, "expired watches are listed again")
+}
@@ -1,10 +1,15 @@
 package orchestrator
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/deepsourcecorp/runner/notify"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/exp/slog"
 )
 
 var (
@@ -22,11 +27,46 @@ type Opts struct {
 	Signer
 	Driver
 	*Runner
+
+	// CloneProvider, when set, generates the remote URLs for jobs that only
+	// fetch the repository (analysis and autofix).  Defaults to Provider.
+	CloneProvider Provider
+
+	// MaintenanceOpts configures maintenance mode.  Maintenance starts
+	// enabled when Maintenance is set.
+	*MaintenanceOpts
+	Maintenance bool
+
+	// EventBufferSize is the number of job lifecycle events kept for
+	// subscribers resuming their stream.
+	EventBufferSize int
+
+	// FailureAlerts configures the notifications of repeated job failures,
+	// sent to TaskOpts.Notifier.
+	FailureAlerts *FailureAlertOpts
+
+	// UsageSampler, when set, samples the usage of analysis pods as their
+	// analyzers complete.
+	UsageSampler UsageSampler
+
+	// PolicyReloadInterval is how often the file of TaskOpts.OrgPolicy is
+	// checked for changes.  The policy is not reloaded when it is zero.
+	PolicyReloadInterval time.Duration
 }
 
 type Facade struct {
 	OrchestratorHandler *Handler
 	Cleaner             *Cleaner
+	Maintenance         *Maintenance
+	Tasks               *Tasks
+	Events              *Events
+
+	driver           Driver
+	namespace        string
+	notifier         *notify.Notifier
+	failureAlertOpts *FailureAlertOpts
+	policy           *PolicyFile
+	policyInterval   time.Duration
 }
 
 func New(opts *Opts) (*Facade, error) {
@@ -34,19 +74,181 @@ func New(opts *Opts) (*Facade, error) {
 		return nil, ErrMissingOpts
 	}
 	cleaner := NewCleaner(opts.Driver, opts.CleanerOpts)
-	handler := NewHandler(opts.TaskOpts, opts.Driver, opts.Provider, opts.Signer, opts.Runner)
+	cloneProvider := opts.CloneProvider
+	if cloneProvider == nil {
+		cloneProvider = opts.Provider
+	}
+	maintenanceOpts := opts.MaintenanceOpts
+	if maintenanceOpts == nil {
+		maintenanceOpts = &MaintenanceOpts{}
+	}
+	if maintenanceOpts.Namespace == "" && opts.TaskOpts.KubernetesOpts != nil {
+		maintenanceOpts.Namespace = opts.TaskOpts.KubernetesOpts.Namespace
+	}
+	maintenance := NewMaintenance(opts.Driver, maintenanceOpts)
+	if opts.Maintenance {
+		maintenance.Enable("enabled at startup")
+	}
+	events := NewEvents(opts.EventBufferSize)
+	events.dispatcher = opts.TaskOpts.Dispatcher
+	events.sampler = opts.UsageSampler
+	tasks := NewTasks(opts.TaskOpts, opts.Driver, opts.Provider, cloneProvider, opts.Signer, opts.Runner, events)
+	handler := NewHandler(tasks, opts.TaskOpts.Quota)
+	handler.maintenance = maintenance
+	handler.events = events
+	handler.signingKeys = opts.TaskOpts.SigningKeys
+	handler.policy = opts.TaskOpts.OrgPolicy
 
 	return &Facade{
 		Cleaner:             cleaner,
 		OrchestratorHandler: handler,
+		Maintenance:         maintenance,
+		Tasks:               tasks,
+		Events:              events,
+		driver:              opts.Driver,
+		namespace:           maintenanceOpts.Namespace,
+		notifier:            opts.TaskOpts.Notifier,
+		failureAlertOpts:    opts.FailureAlerts,
+		policy:              opts.TaskOpts.OrgPolicy,
+		policyInterval:      opts.PolicyReloadInterval,
 	}, nil
 }
 
+// Schedule runs a task received other than through the task endpoints.
+// Like the endpoints, tasks that start jobs are paused in maintenance mode,
+// and counted in flight by drains.
+func (f *Facade) Schedule(ctx context.Context, msg *TaskMessage) error {
+	done, ok := f.admit(msg)
+	if !ok {
+		return ErrMaintenance
+	}
+	defer done()
+	return f.Tasks.Schedule(ctx, msg)
+}
+
+// Submit runs a task like Schedule, and returns the names of the jobs it
+// triggered.
+func (f *Facade) Submit(ctx context.Context, msg *TaskMessage) ([]string, error) {
+	done, ok := f.admit(msg)
+	if !ok {
+		return nil, ErrMaintenance
+	}
+	defer done()
+	return f.Tasks.Submit(ctx, msg)
+}
+
+func (f *Facade) admit(msg *TaskMessage) (func(), bool) {
+	if msg.Name == TaskNameCancelCheck {
+		return func() {}, true
+	}
+	return f.Maintenance.admit()
+}
+
+// JobStates returns the state of the runner's jobs with the names.
+func (f *Facade) JobStates(ctx context.Context, names []string) ([]*JobState, error) {
+	reader, ok := f.driver.(JobStateReader)
+	if !ok {
+		return nil, ErrJobStateUnsupported
+	}
+	return reader.JobStates(ctx, f.namespace, names)
+}
+
+// CancelJobs deletes the runner's jobs with the names, with their pods.
+func (f *Facade) CancelJobs(ctx context.Context, names []string) error {
+	states, err := f.JobStates(ctx, names)
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		if s.Phase == JobPhaseNotFound {
+			return fmt.Errorf("%w: %s", ErrJobNotFound, s.Name)
+		}
+	}
+	for _, name := range names {
+		if err := f.driver.DeleteJob(ctx, &jobRef{name: name, namespace: f.namespace}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchEvents publishes the lifecycle events of the jobs until the context
+// is cancelled, if the driver reports them.  Watches are restarted when they
+// close, from where they left off.
+func (f *Facade) WatchEvents(ctx context.Context) {
+	watcher, ok := f.driver.(EventWatcher)
+	if !ok {
+		return
+	}
+	cursor := &WatchCursor{}
+	for {
+		err := watcher.WatchEvents(ctx, f.namespace, f.Events, cursor)
+		if ctx.Err() != nil {
+			return
+		}
+		if !errors.Is(err, errWatchClosed) {
+			slog.Error("failed to watch job events", slog.Any("err", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventsRewatchDelay):
+		}
+	}
+}
+
+// ResumeClonePhases picks up the clone phase network policies of the jobs
+// started before the runner restarted, if the driver isolates jobs.
+func (f *Facade) ResumeClonePhases(ctx context.Context) {
+	resumer, ok := f.driver.(ClonePhaseResumer)
+	if !ok {
+		return
+	}
+	if err := resumer.ResumeClonePhases(ctx, f.namespace); err != nil {
+		slog.Error("failed to resume clone phase network policies", slog.Any("err", err))
+	}
+}
+
+// jobRef refers to a job by name.
+type jobRef struct {
+	name      string
+	namespace string
+}
+
+func (j *jobRef) Name() string      { return j.name }
+func (j *jobRef) Namespace() string { return j.namespace }
+
+// AddRoutes adds the task endpoints.  Tasks that start jobs are paused in
+// maintenance mode; cancelling checks keeps working so that a drain can be
+// sped up.
 func (f *Facade) AddRoutes(router Router, middleware []echo.MiddlewareFunc) Router {
-	router.AddRoute(http.MethodPost, "apps/:app_id/tasks/analysis", f.OrchestratorHandler.HandleAnalysis, middleware...)
-	router.AddRoute(http.MethodPost, "apps/:app_id/tasks/autofix", f.OrchestratorHandler.HandleAutofix, middleware...)
-	router.AddRoute(http.MethodPost, "apps/:app_id/tasks/transformer", f.OrchestratorHandler.HandleTransformer, middleware...)
+	paused := append(append([]echo.MiddlewareFunc{}, middleware...), f.Maintenance.Middleware)
+	router.AddRoute(http.MethodPost, "apps/:app_id/tasks/analysis", f.OrchestratorHandler.HandleAnalysis, paused...)
+	router.AddRoute(http.MethodPost, "apps/:app_id/tasks/autofix", f.OrchestratorHandler.HandleAutofix, paused...)
+	router.AddRoute(http.MethodPost, "apps/:app_id/tasks/transformer", f.OrchestratorHandler.HandleTransformer, paused...)
 	router.AddRoute(http.MethodPost, "apps/:app_id/tasks/cancelcheck", f.OrchestratorHandler.HandleCancelCheck, middleware...)
-	router.AddRoute(http.MethodPost, "apps/:app_id/tasks/commit", f.OrchestratorHandler.HandlePatcher, middleware...)
+	router.AddRoute(http.MethodPost, "apps/:app_id/tasks/commit", f.OrchestratorHandler.HandlePatcher, paused...)
+	return router
+}
+
+// WatchPolicy reloads the organization policy when its file changes, until
+// the context is done.
+func (f *Facade) WatchPolicy(ctx context.Context) {
+	if f.policy == nil || f.policyInterval <= 0 {
+		return
+	}
+	f.policy.Watch(ctx, f.policyInterval)
+}
+
+// AddAdminRoutes adds the operator endpoints of the orchestrator.
+func (f *Facade) AddAdminRoutes(router Router, middleware []echo.MiddlewareFunc) Router {
+	router.AddRoute(http.MethodGet, "/admin/quota", f.OrchestratorHandler.HandleQuota, middleware...)
+	router.AddRoute(http.MethodGet, "/admin/maintenance", f.OrchestratorHandler.HandleMaintenance, middleware...)
+	router.AddRoute(http.MethodPost, "/admin/maintenance", f.OrchestratorHandler.HandleSetMaintenance, middleware...)
+	router.AddRoute(http.MethodPost, "/admin/maintenance/drain", f.OrchestratorHandler.HandleDrain, middleware...)
+	router.AddRoute(http.MethodGet, "/admin/events", f.OrchestratorHandler.HandleEvents, middleware...)
+	router.AddRoute(http.MethodGet, "/admin/apps/:app_id/signing-key", f.OrchestratorHandler.HandleSigningKey, middleware...)
+	router.AddRoute(http.MethodGet, "/admin/policy", f.OrchestratorHandler.HandlePolicy, middleware...)
+	router.AddRoute(http.MethodPost, "/admin/policy/reload", f.OrchestratorHandler.HandleReloadPolicy, middleware...)
 	return router
 }
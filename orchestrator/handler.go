@@ -1,90 +1,93 @@
 package orchestrator
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"strconv"
+	"time"
 
 	artifact "github.com/DeepSourceCorp/artifacts/types"
 	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/deepsourcecorp/runner/usage"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/exp/slog"
 )
 
 type Handler struct {
-	analysisTask    *AnalysisTask
-	autofixTask     *AutofixTask
-	transformerTask *TransformerTask
-	cancelCheckTask *CancelCheckTask
-	patcherTask     *PatcherTask
-}
-
-func NewHandler(
-	opts *TaskOpts,
-	driver Driver,
-	provider Provider,
-	signer Signer,
-	runner *Runner,
-) *Handler {
+	tasks       *Tasks
+	quota       *Quota
+	maintenance *Maintenance
+	events      *Events
+	signingKeys []*SigningKey
+	policy      *PolicyFile
+}
+
+func NewHandler(tasks *Tasks, quota *Quota) *Handler {
 	return &Handler{
-		analysisTask:    NewAnalysisTask(runner, opts, driver, provider, signer),
-		autofixTask:     NewAutofixTask(runner, opts, driver, provider, signer),
-		transformerTask: NewTransformerTask(runner, opts, driver, provider, signer),
-		cancelCheckTask: NewCancelCheckTask(runner, opts, driver, signer, nil),
-		patcherTask:     NewPatcherTask(runner, opts, driver, provider, signer),
+		tasks: tasks,
+		quota: quota,
 	}
 }
 
 func (h *Handler) HandleAnalysis(c echo.Context) error {
 	log.Println("received analysis task")
-	ctx := c.Request().Context()
+	ctx := WithQueueTimeout(c.Request().Context())
 	run := new(artifact.AnalysisRun)
 	if err := c.Bind(&run); err != nil {
 		slog.Error("analysis task bind error", slog.Any("err", err))
 		return httperror.ErrMissingParams(err)
 	}
 	log.Println("Running analysis task")
-	if err := h.analysisTask.Run(ctx, &AnalysisRunRequest{
+	ctx = h.tasks.events.TaskReceived(ctx, TaskNameAnalysis, c.Param("app_id"), run.RunID)
+	if err := h.tasks.analysis.Run(ctx, &AnalysisRunRequest{
 		Run:            run,
 		AppID:          c.Param("app_id"),
 		InstallationID: c.Request().Header.Get("X-Installation-ID"),
 	}); err != nil {
 		slog.Error("analysis task run error", slog.Any("err", err))
-		return httperror.ErrUnknown(err)
+		return taskError(c, err)
 	}
 	return nil
 }
 
 func (h *Handler) HandleAutofix(c echo.Context) error {
-	ctx := c.Request().Context()
+	ctx := WithQueueTimeout(c.Request().Context())
 	run := new(artifact.AutofixRun)
 	if err := c.Bind(&run); err != nil {
 		slog.Error("autofix task bind error", slog.Any("err", err))
 		return httperror.ErrMissingParams(err)
 	}
-	if err := h.autofixTask.Run(ctx, &AutofixRunRequest{
+	ctx = h.tasks.events.TaskReceived(ctx, TaskNameAutofix, c.Param("app_id"), run.RunID)
+	if err := h.tasks.autofix.Run(ctx, &AutofixRunRequest{
 		Run:            run,
 		AppID:          c.Param("app_id"),
 		InstallationID: c.Request().Header.Get("X-Installation-ID"),
 	}); err != nil {
 		slog.Error("autofix task run error", slog.Any("err", err))
-		return httperror.ErrUnknown(err)
+		return taskError(c, err)
 	}
 	return nil
 }
 
 func (h *Handler) HandleTransformer(c echo.Context) error {
-	ctx := c.Request().Context()
+	ctx := WithQueueTimeout(c.Request().Context())
 	run := new(artifact.TransformerRun)
 	if err := c.Bind(&run); err != nil {
 		slog.Error("transformer task bind error", slog.Any("err", err))
 		return httperror.ErrMissingParams(err)
 	}
-	if err := h.transformerTask.Run(ctx, &TransformerRunRequest{
+	ctx = h.tasks.events.TaskReceived(ctx, TaskNameTransformer, c.Param("app_id"), run.RunID)
+	if err := h.tasks.transformer.Run(ctx, &TransformerRunRequest{
 		Run:            run,
 		AppID:          c.Param("app_id"),
 		InstallationID: c.Request().Header.Get("X-Installation-ID"),
 	}); err != nil {
 		slog.Error("transformer task run error", slog.Any("err", err))
-		return httperror.ErrUnknown(err)
+		return taskError(c, err)
 	}
 	return nil
 }
@@ -97,7 +100,8 @@ func (h *Handler) HandleCancelCheck(c echo.Context) error {
 		slog.Error("cancel check task bind error", slog.Any("err", err))
 		return httperror.ErrMissingParams(err)
 	}
-	if err := h.cancelCheckTask.Run(ctx, req); err != nil {
+	ctx = h.tasks.events.TaskReceived(ctx, TaskNameCancelCheck, c.Param("app_id"), req.RunID)
+	if err := h.tasks.cancelCheck.Run(ctx, req); err != nil {
 		slog.Error("cancel check task run error", slog.Any("err", err))
 		return httperror.ErrUnknown(err)
 	}
@@ -106,20 +110,184 @@ func (h *Handler) HandleCancelCheck(c echo.Context) error {
 
 // HandlePatcher handles the patching job workflow.
 func (h *Handler) HandlePatcher(c echo.Context) error {
-	ctx := c.Request().Context()
+	ctx := WithQueueTimeout(c.Request().Context())
 	req := new(artifact.PatcherRun)
 	if err := c.Bind(&req); err != nil {
 		slog.Error("patcher task bind error", slog.Any("err", err))
 		return httperror.ErrMissingParams(err)
 	}
 
-	if err := h.patcherTask.Run(ctx, &PatcherRunRequest{
+	ctx = h.tasks.events.TaskReceived(ctx, TaskNamePatcher, c.Param("app_id"), req.RunID)
+	if err := h.tasks.patcher.Run(ctx, &PatcherRunRequest{
 		Run:            req,
 		AppID:          c.Param("app_id"),
 		InstallationID: c.Request().Header.Get("X-Installation-ID"),
 	}); err != nil {
 		slog.Error("patcher task run error", slog.Any("err", err))
-		return httperror.ErrUnknown(err)
+		return taskError(c, err)
 	}
 	return nil
 }
+
+// HandleQuota returns the headroom of the namespace quotas jobs are admitted
+// against.
+func (h *Handler) HandleQuota(c echo.Context) error {
+	if h.quota == nil {
+		return httperror.ErrBadRequest(errors.New("quota admission is disabled"))
+	}
+	headroom, err := h.quota.Headroom(c.Request().Context())
+	if err != nil {
+		slog.Error("failed to read namespace quota", slog.Any("err", err))
+		return httperror.ErrUnknown(err)
+	}
+	return c.JSON(http.StatusOK, headroom)
+}
+
+// HandleSigningKey returns the public key the commits of the app are signed
+// with, to register with the VCS.
+func (h *Handler) HandleSigningKey(c echo.Context) error {
+	appID := c.Param("app_id")
+	for _, k := range h.signingKeys {
+		if k.AppID == appID {
+			return c.JSON(http.StatusOK, k)
+		}
+	}
+	return httperror.New(http.StatusNotFound, "commits of the app are not signed", nil)
+}
+
+// HandlePolicy returns the organization policy in effect, and the error of
+// its last reload, if any.
+func (h *Handler) HandlePolicy(c echo.Context) error {
+	if h.policy == nil {
+		return httperror.ErrBadRequest(errors.New("org policy is disabled"))
+	}
+	return c.JSON(http.StatusOK, h.policy.Status())
+}
+
+// HandleReloadPolicy reloads the organization policy from its file.  The
+// current policy is kept when the file is invalid.
+func (h *Handler) HandleReloadPolicy(c echo.Context) error {
+	if h.policy == nil {
+		return httperror.ErrBadRequest(errors.New("org policy is disabled"))
+	}
+	if err := h.policy.Reload(); err != nil {
+		slog.Error("failed to reload org policy", slog.Any("err", err))
+		return httperror.New(http.StatusUnprocessableEntity, err.Error(), err)
+	}
+	return c.JSON(http.StatusOK, h.policy.Status())
+}
+
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+// HandleMaintenance returns the state of maintenance mode and of the last
+// drain.
+func (h *Handler) HandleMaintenance(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.maintenance.Status())
+}
+
+// HandleSetMaintenance enables or disables maintenance mode.
+func (h *Handler) HandleSetMaintenance(c echo.Context) error {
+	req := new(MaintenanceRequest)
+	if err := c.Bind(req); err != nil {
+		return httperror.ErrBadRequest(err)
+	}
+	if req.Enabled {
+		h.maintenance.Enable(req.Reason)
+	} else {
+		h.maintenance.Disable()
+	}
+	return c.JSON(http.StatusOK, h.maintenance.Status())
+}
+
+// HandleDrain enables maintenance mode and starts waiting for the running
+// jobs to finish.  The progress is reported by HandleMaintenance.
+func (h *Handler) HandleDrain(c echo.Context) error {
+	reason := c.QueryParam("reason")
+	if reason == "" {
+		reason = "drain"
+	}
+	return c.JSON(http.StatusAccepted, h.maintenance.Drain(reason))
+}
+
+// HandleEvents streams the job lifecycle events matching the app_id, run_id
+// and task query parameters as server-sent events.  Clients resuming with
+// Last-Event-ID first receive the buffered events they missed.
+func (h *Handler) HandleEvents(c echo.Context) error {
+	var lastID uint64
+	if id := c.Request().Header.Get("Last-Event-ID"); id != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(id, 10, 64); err != nil {
+			return httperror.ErrBadRequest(err)
+		}
+	}
+	filter := &EventFilter{
+		AppID: c.QueryParam("app_id"),
+		RunID: c.QueryParam("run_id"),
+		Task:  c.QueryParam("task"),
+	}
+	replay, events, cancel := h.events.Subscribe(lastID, filter)
+	defer cancel()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	for _, e := range replay {
+		if err := writeEvent(res, e); err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := res.Write([]byte(": keep-alive\n\n")); err != nil {
+				return nil
+			}
+		case e, ok := <-events:
+			if !ok {
+				// Dropped for falling behind; the client resumes from
+				// the last event it received.
+				return nil
+			}
+			if err := writeEvent(res, e); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+func writeEvent(w io.Writer, e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// taskError returns the HTTP error for a failed task.  Tasks rejected for
+// lack of quota, or of a dispatch slot, are answered with 503 and a
+// Retry-After header, so that they are retried.  Tasks of apps over their
+// usage quota are answered with 429 and the quota they exceeded.
+func taskError(c echo.Context, err error) error {
+	var quotaErr *QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(quotaErr.RetryAfter.Seconds())))
+		return httperror.ErrUnavailable(err)
+	}
+	if errors.Is(err, usage.ErrQuotaExceeded) {
+		return httperror.New(http.StatusTooManyRequests, err.Error(), err)
+	}
+	return httperror.ErrUnknown(err)
+}
@@ -0,0 +1,233 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+)
+
+const (
+	PullPolicyAlways       = "Always"
+	PullPolicyIfNotPresent = "IfNotPresent"
+
+	coatImageName        = "coat"
+	transformerImageName = "bumblebee"
+	marvinImagePrefix    = "marvin-"
+)
+
+var (
+	ErrImageNotAllowed = errors.New("image not allowed by policy")
+	ErrImageNotPinned  = errors.New("image not pinned in catalog")
+)
+
+// Image is a job image, before the image policy is applied.
+type Image struct {
+	// Name is the repository of the image under the registry.
+	Name    string
+	Version string
+
+	// Analyzer is the shortcode of the analyzer or autofixer the image runs.
+	// Empty for runner images like coat.
+	Analyzer string
+
+	// Transformers are the shortcodes of the transformers the image runs.
+	Transformers []string
+}
+
+// ResolvedImage is the image reference a container is created with, and the
+// pull policy for it.
+type ResolvedImage struct {
+	Ref        string
+	PullPolicy string
+}
+
+// JobImages are the resolved images of the containers of a job.
+type JobImages struct {
+	Marvin *ResolvedImage
+	Coat   *ResolvedImage
+}
+
+// ImageRule matches analyzers by shortcode, and their versions, with shell
+// patterns.  Empty Versions matches every version.
+type ImageRule struct {
+	Analyzer string
+	Versions []string
+}
+
+func (r *ImageRule) Matches(analyzer, version string) bool {
+	if ok, _ := path.Match(r.Analyzer, analyzer); !ok {
+		return false
+	}
+	if len(r.Versions) == 0 {
+		return true
+	}
+	for _, v := range r.Versions {
+		if ok, _ := path.Match(v, version); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ImagePolicy controls the images jobs run.  A nil policy runs the images
+// DeepSource requests, by tag, from the configured registry.
+type ImagePolicy struct {
+	// Catalog maps "<name>:<version>" to the digest the image is pinned to.
+	// Pinned images are pulled by digest.
+	Catalog map[string]string
+
+	// RequireDigest rejects images that are not pinned in Catalog.
+	RequireDigest bool
+
+	// RegistryRewrites replaces registry hosts, for pulling through a mirror.
+	RegistryRewrites map[string]string
+
+	// Allow, when set, lists the only analyzers, autofixers and transformers
+	// that may run.  Deny takes precedence over Allow.
+	Allow []*ImageRule
+	Deny  []*ImageRule
+
+	// CoatVersion and AnalyzerVersions override the versions DeepSource
+	// requests.  AnalyzerVersions is keyed by shortcode.
+	CoatVersion      string
+	AnalyzerVersions map[string]string
+}
+
+// Resolve returns the reference of image under registry.  It fails with
+// ErrImageNotAllowed or ErrImageNotPinned when the policy rejects the image.
+func (p *ImagePolicy) Resolve(registry url.URL, image *Image) (*ResolvedImage, error) {
+	if p == nil {
+		return &ResolvedImage{
+			Ref:        imageRef(registry, image.Name) + ":" + image.Version,
+			PullPolicy: PullPolicyAlways,
+		}, nil
+	}
+
+	version := p.version(image)
+	if image.Analyzer != "" {
+		if err := p.admit(image.Analyzer, version); err != nil {
+			return nil, err
+		}
+	}
+	for _, transformer := range image.Transformers {
+		if err := p.admit(transformer, version); err != nil {
+			return nil, err
+		}
+	}
+
+	ref := imageRef(p.rewrite(registry), image.Name)
+	if digest, ok := p.Catalog[image.Name+":"+version]; ok {
+		return &ResolvedImage{Ref: ref + "@" + digest, PullPolicy: PullPolicyIfNotPresent}, nil
+	}
+	if p.RequireDigest {
+		return nil, fmt.Errorf("%w: %s:%s", ErrImageNotPinned, image.Name, version)
+	}
+	return &ResolvedImage{Ref: ref + ":" + version, PullPolicy: PullPolicyAlways}, nil
+}
+
+func (p *ImagePolicy) version(image *Image) string {
+	if image.Name == coatImageName && p.CoatVersion != "" {
+		return p.CoatVersion
+	}
+	if v, ok := p.AnalyzerVersions[image.Analyzer]; ok && image.Analyzer != "" {
+		return v
+	}
+	return image.Version
+}
+
+func (p *ImagePolicy) admit(analyzer, version string) error {
+	for _, rule := range p.Deny {
+		if rule.Matches(analyzer, version) {
+			return fmt.Errorf("%w: %s@%s is denied", ErrImageNotAllowed, analyzer, version)
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, rule := range p.Allow {
+		if rule.Matches(analyzer, version) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s@%s is not allowed", ErrImageNotAllowed, analyzer, version)
+}
+
+// rewrite replaces the registry host.  Registries configured without a
+// scheme parse with the host as the first path segment.
+func (p *ImagePolicy) rewrite(registry url.URL) url.URL {
+	if registry.Host != "" {
+		if host, ok := p.RegistryRewrites[registry.Host]; ok {
+			registry.Host = host
+		}
+		return registry
+	}
+	host, rest, _ := strings.Cut(strings.TrimPrefix(registry.Path, "/"), "/")
+	if rewritten, ok := p.RegistryRewrites[host]; ok {
+		registry.Path = strings.TrimSuffix(rewritten+"/"+rest, "/")
+	}
+	return registry
+}
+
+func imageRef(registry url.URL, name string) string {
+	return registry.JoinPath(name).String()
+}
+
+// ResolveImage applies the image policy to image.
+func (o *KubernetesOpts) ResolveImage(image *Image) (*ResolvedImage, error) {
+	return o.ImagePolicy.Resolve(o.ImageURL, image)
+}
+
+// ResolveImages applies the image policy to the images of a job.  marvin is
+// nil for jobs that only run coat.
+func (o *KubernetesOpts) ResolveImages(marvin *Image) (*JobImages, error) {
+	images := new(JobImages)
+	coat, err := o.ResolveImage(coatImage())
+	if err != nil {
+		return nil, err
+	}
+	images.Coat = coat
+	if marvin != nil {
+		if images.Marvin, err = o.ResolveImage(marvin); err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+func coatImage() *Image {
+	return &Image{Name: coatImageName, Version: CoatVersion}
+}
+
+// analyzerImage returns the image of an analyzer.  Only core analyzers are
+// published with the marvin prefix.
+func analyzerImage(meta *artifact.AnalyzerMeta) *Image {
+	name := meta.Shortcode
+	if meta.AnalyzerType == "core" {
+		name = marvinImagePrefix + meta.Shortcode
+	}
+	return &Image{Name: name, Version: meta.Version, Analyzer: meta.Shortcode}
+}
+
+func autofixerImage(shortcode, version string) *Image {
+	return &Image{Name: marvinImagePrefix + shortcode, Version: version, Analyzer: shortcode}
+}
+
+// transformerImage returns the image of a transformer run.  The tools of the
+// run are checked against the analyzer rules of the policy, by shortcode.
+func transformerImage(info *artifact.TransformerInfo) *Image {
+	return &Image{Name: transformerImageName, Version: info.Meta.Version, Transformers: info.Tools}
+}
+
+// imageRejectedStatus is the status of runs and checks whose images the
+// image policy rejected or that failed verification.
+func imageRejectedStatus(err error) artifact.Status {
+	return artifact.Status{
+		Code:     StatusCodeImageRejected,
+		HMessage: "Job image rejected by the runner",
+		Err:      err.Error(),
+	}
+}
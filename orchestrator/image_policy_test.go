@@ -0,0 +1,224 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDigest = "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+func TestImagePolicy_Resolve(t *testing.T) {
+	registry, _ := url.Parse("https://registry.deepsource.io/analyzers")
+	schemeless, _ := url.Parse("registry.deepsource.io/analyzers")
+
+	policy := &ImagePolicy{
+		Catalog:          map[string]string{"marvin-python:v1.2.0": testDigest},
+		RegistryRewrites: map[string]string{"registry.deepsource.io": "mirror.acme.internal"},
+		Deny:             []*ImageRule{{Analyzer: "*", Versions: []string{"*-beta*"}}},
+		Allow:            []*ImageRule{{Analyzer: "python"}, {Analyzer: "go", Versions: []string{"v0.*"}}},
+		CoatVersion:      "v3.1.0",
+		AnalyzerVersions: map[string]string{"python": "v1.2.0"},
+	}
+
+	tests := []struct {
+		name     string
+		policy   *ImagePolicy
+		registry *url.URL
+		image    *Image
+		want     *ResolvedImage
+		wantErr  error
+	}{
+		{
+			name:     "no policy",
+			registry: registry,
+			image:    &Image{Name: "marvin-python", Version: "v1.0.0", Analyzer: "python"},
+			want:     &ResolvedImage{Ref: "https://registry.deepsource.io/analyzers/marvin-python:v1.0.0", PullPolicy: PullPolicyAlways},
+		},
+		{
+			name:     "pinned with version override",
+			policy:   policy,
+			registry: registry,
+			image:    &Image{Name: "marvin-python", Version: "v1.0.0", Analyzer: "python"},
+			want:     &ResolvedImage{Ref: "https://mirror.acme.internal/analyzers/marvin-python@" + testDigest, PullPolicy: PullPolicyIfNotPresent},
+		},
+		{
+			name:     "coat override on registry without scheme",
+			policy:   policy,
+			registry: schemeless,
+			image:    coatImage(),
+			want:     &ResolvedImage{Ref: "mirror.acme.internal/analyzers/coat:v3.1.0", PullPolicy: PullPolicyAlways},
+		},
+		{
+			name:     "allowed version",
+			policy:   policy,
+			registry: registry,
+			image:    &Image{Name: "marvin-go", Version: "v0.4.1", Analyzer: "go"},
+			want:     &ResolvedImage{Ref: "https://mirror.acme.internal/analyzers/marvin-go:v0.4.1", PullPolicy: PullPolicyAlways},
+		},
+		{
+			name:     "version not allowed",
+			policy:   policy,
+			registry: registry,
+			image:    &Image{Name: "marvin-go", Version: "v1.0.0", Analyzer: "go"},
+			wantErr:  ErrImageNotAllowed,
+		},
+		{
+			name:     "analyzer not allowed",
+			policy:   policy,
+			registry: registry,
+			image:    &Image{Name: "marvin-ruby", Version: "v1.0.0", Analyzer: "ruby"},
+			wantErr:  ErrImageNotAllowed,
+		},
+		{
+			name:     "denied",
+			policy:   policy,
+			registry: registry,
+			image:    &Image{Name: "marvin-go", Version: "v0.5.0-beta1", Analyzer: "go"},
+			wantErr:  ErrImageNotAllowed,
+		},
+		{
+			name:     "transformer not allowed",
+			policy:   policy,
+			registry: registry,
+			image:    transformerImage(&artifact.TransformerInfo{Tools: []string{"python", "black"}, Meta: artifact.TransformerMeta{Version: "v1.0.0"}}),
+			wantErr:  ErrImageNotAllowed,
+		},
+		{
+			name:     "digest required",
+			policy:   &ImagePolicy{RequireDigest: true},
+			registry: registry,
+			image:    coatImage(),
+			wantErr:  ErrImageNotPinned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Resolve(*tt.registry, tt.image)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+type testDriver struct {
+	jobs []JobCreator
+}
+
+func (d *testDriver) TriggerJob(_ context.Context, job JobCreator) error {
+	d.jobs = append(d.jobs, job)
+	return nil
+}
+
+func (*testDriver) DeleteJob(context.Context, JobDeleter) error { return nil }
+
+func (*testDriver) CleanExpiredJobs(context.Context, string, *time.Duration) error { return nil }
+
+type testProvider struct{}
+
+func (testProvider) AuthenticatedRemoteURL(_, _, srcURL string) (string, error) { return srcURL, nil }
+
+type testSigner struct{}
+
+func (testSigner) GenerateToken(string, []string, map[string]interface{}, time.Duration) (string, error) {
+	return "token", nil
+}
+
+func TestAnalysisTask_ImageRejected(t *testing.T) {
+	var published []artifact.AnalysisResultCeleryTask
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, analysisPublishPath, r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var payload artifact.AnalysisResultCeleryTask
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		published = append(published, payload)
+	}))
+	defer server.Close()
+
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	driver := &testDriver{}
+	task := NewAnalysisTask(&Runner{ID: "runner-id"}, &TaskOpts{
+		RemoteHost: server.URL,
+		KubernetesOpts: &KubernetesOpts{
+			ImageURL:    *registry,
+			ImagePolicy: &ImagePolicy{Deny: []*ImageRule{{Analyzer: "ruby"}}},
+		},
+	}, driver, testProvider{}, testSigner{})
+
+	err := task.Run(context.Background(), &AnalysisRunRequest{
+		Run: &artifact.AnalysisRun{
+			RunID: "run-id",
+			Checks: []artifact.Check{
+				{CheckSeq: "1", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "python", AnalyzerType: "core", Version: "v1"}},
+				{CheckSeq: "2", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "ruby", AnalyzerType: "core", Version: "v1"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, driver.jobs, 1)
+	assert.Equal(t, "https://registry.deepsource.io/marvin-python:v1", driver.jobs[0].Container().Image)
+
+	require.Len(t, published, 1)
+	result := published[0].KWArgs
+	assert.Equal(t, AnalysisResultTask, published[0].Task)
+	assert.Equal(t, "run-id", result.RunID)
+	assert.Equal(t, "2", result.CheckSeq)
+	assert.Equal(t, StatusCodeImageRejected, result.Status.Code)
+	assert.False(t, result.Report.IsPassed)
+	assert.NotEmpty(t, result.Report.Errors)
+}
+
+func TestImageRejected_Tasks(t *testing.T) {
+	var published []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payload["path"] = r.URL.Path
+		published = append(published, payload)
+	}))
+	defer server.Close()
+
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	driver := &testDriver{}
+	opts := &TaskOpts{
+		RemoteHost: server.URL,
+		KubernetesOpts: &KubernetesOpts{
+			ImageURL:    *registry,
+			ImagePolicy: &ImagePolicy{RequireDigest: true},
+		},
+	}
+	runner := &Runner{ID: "runner-id"}
+
+	require.NoError(t, NewAutofixTask(runner, opts, driver, testProvider{}, testSigner{}).Run(context.Background(), &AutofixRunRequest{
+		Run: &artifact.AutofixRun{RunID: "autofix-run", Autofixer: artifact.Autofixer{AutofixMeta: artifact.AutofixMeta{Shortcode: "python", Version: "v1"}}},
+	}))
+	require.NoError(t, NewTransformerTask(runner, opts, driver, testProvider{}, testSigner{}).Run(context.Background(), &TransformerRunRequest{
+		Run: &artifact.TransformerRun{RunID: "transformer-run", Transformer: artifact.TransformerInfo{Tools: []string{"black"}}},
+	}))
+	require.NoError(t, NewPatcherTask(runner, opts, driver, testProvider{}, testSigner{}).Run(context.Background(), &PatcherRunRequest{
+		Run: &artifact.PatcherRun{RunID: "patcher-run", VCSMeta: artifact.PatcherVCSMeta{BaseBranch: "main"}},
+	}))
+	assert.Empty(t, driver.jobs)
+
+	wantPaths := []string{autofixPublishPath, transformerPublishPath, patcherPublishPath}
+	require.Len(t, published, len(wantPaths))
+	for i, path := range wantPaths {
+		assert.Equal(t, path, published[i]["path"])
+		status := published[i]["kwargs"].(map[string]interface{})["status"].(map[string]interface{})
+		assert.Equal(t, float64(StatusCodeImageRejected), status["code"])
+		assert.Contains(t, status["err"], ErrImageNotPinned.Error())
+	}
+}
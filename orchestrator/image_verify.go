@@ -0,0 +1,48 @@
+package orchestrator
+
+import (
+	"context"
+)
+
+// ImageVerifier verifies the signatures of job images before they are
+// scheduled.
+type ImageVerifier interface {
+	// Verify returns the reference of the image pinned to the verified
+	// digest, or an error if the image could not be verified.
+	Verify(ctx context.Context, ref string) (string, error)
+}
+
+// ResolveJobImages applies the image policy to the images of a job, and
+// verifies them.  marvin is nil for jobs that only run coat.  Jobs whose
+// images fail are not created; their run is reported with
+// imageRejectedStatus instead.
+func (o *TaskOpts) ResolveJobImages(ctx context.Context, marvin *Image) (*JobImages, error) {
+	images, err := o.KubernetesOpts.ResolveImages(marvin)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.VerifyImages(ctx, images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// VerifyImages verifies the images of a job, and pins them to the verified
+// digests.  It is a no-op when no verifier is configured.
+func (o *TaskOpts) VerifyImages(ctx context.Context, images *JobImages) error {
+	if o.ImageVerifier == nil {
+		return nil
+	}
+	for _, image := range []*ResolvedImage{images.Coat, images.Marvin} {
+		if image == nil {
+			continue
+		}
+		ref, err := o.ImageVerifier.Verify(ctx, image.Ref)
+		if err != nil {
+			return err
+		}
+		image.Ref = ref
+		image.PullPolicy = PullPolicyIfNotPresent
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Phases of jobs.
+const (
+	JobPhasePending   = "pending"
+	JobPhaseRunning   = "running"
+	JobPhaseSucceeded = "succeeded"
+	JobPhaseFailed    = "failed"
+	JobPhaseNotFound  = "not_found"
+)
+
+var (
+	ErrJobNotFound         = errors.New("job not found")
+	ErrJobStateUnsupported = errors.New("the driver does not report job states")
+)
+
+// JobState is the state of a job of the runner.
+type JobState struct {
+	Name       string     `json:"name"`
+	Phase      string     `json:"phase"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Message    string     `json:"message,omitempty"`
+}
+
+// Done reports whether the job will not change phase anymore.
+func (s *JobState) Done() bool {
+	return s.Phase == JobPhaseSucceeded || s.Phase == JobPhaseFailed || s.Phase == JobPhaseNotFound
+}
+
+// JobStateReader is implemented by drivers that report the state of jobs.
+// Jobs not managed by the runner are reported as not found.
+type JobStateReader interface {
+	JobStates(ctx context.Context, namespace string, names []string) ([]*JobState, error)
+}
+
+type jobsKey struct{}
+
+// jobRecorder collects the names of the jobs triggered by a task.
+type jobRecorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+// recordingDriver records the jobs it triggers in the jobRecorder of the
+// context, if any, and publishes their creation.
+type recordingDriver struct {
+	Driver
+	events *Events
+}
+
+func (d *recordingDriver) TriggerJob(ctx context.Context, job JobCreator) error {
+	if err := d.Driver.TriggerJob(ctx, job); err != nil {
+		return err
+	}
+	d.events.JobCreated(ctx, job.Name(), job.PodLabels()[LabelNameAnalyzer])
+	if r, ok := ctx.Value(jobsKey{}).(*jobRecorder); ok {
+		r.mu.Lock()
+		r.names = append(r.names, job.Name())
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// Submit runs the task like Schedule, and returns the names of the jobs it
+// triggered.
+func (t *Tasks) Submit(ctx context.Context, msg *TaskMessage) ([]string, error) {
+	r := &jobRecorder{}
+	if err := t.Schedule(context.WithValue(ctx, jobsKey{}, r), msg); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.names...), nil
+}
+
+// JobStates returns the state of the runner's jobs with the names.
+func (d *K8sDriver) JobStates(ctx context.Context, namespace string, names []string) ([]*JobState, error) {
+	states := make([]*JobState, 0, len(names))
+	for _, name := range names {
+		job, err := d.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) || err == nil && job.Labels[LabelNameManager] != "runner" {
+			states = append(states, &JobState{Name: name, Phase: JobPhaseNotFound})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, jobState(job))
+	}
+	return states, nil
+}
+
+func jobState(job *batchv1.Job) *JobState {
+	state := &JobState{Name: job.Name, Phase: JobPhasePending}
+	if job.Status.StartTime != nil {
+		started := job.Status.StartTime.Time
+		state.StartedAt = &started
+	}
+	if job.Status.Active > 0 {
+		state.Phase = JobPhaseRunning
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		finished := c.LastTransitionTime.Time
+		switch c.Type {
+		case batchv1.JobComplete:
+			state.Phase, state.FinishedAt = JobPhaseSucceeded, &finished
+		case batchv1.JobFailed:
+			state.Phase, state.FinishedAt, state.Message = JobPhaseFailed, &finished, c.Message
+		}
+	}
+	return state
+}
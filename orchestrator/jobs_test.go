@@ -0,0 +1,101 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testJobs() *fake.Clientset {
+	job := func(name string, labels map[string]string, status batchv1.JobStatus) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "runner", Labels: labels}, Status: status}
+	}
+	runner := map[string]string{LabelNameManager: "runner"}
+	started := metav1.NewTime(time.Date(2023, 11, 1, 10, 0, 0, 0, time.UTC))
+	finished := metav1.NewTime(time.Date(2023, 11, 1, 10, 5, 0, 0, time.UTC))
+	return fake.NewSimpleClientset(
+		job("pending", runner, batchv1.JobStatus{}),
+		job("running", runner, batchv1.JobStatus{StartTime: &started, Active: 1}),
+		job("succeeded", runner, batchv1.JobStatus{StartTime: &started, Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: finished},
+		}}),
+		job("failed", runner, batchv1.JobStatus{StartTime: &started, Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: finished, Message: "BackoffLimitExceeded"},
+		}}),
+		job("other", nil, batchv1.JobStatus{}),
+	)
+}
+
+func TestK8sDriver_JobStates(t *testing.T) {
+	driver := &K8sDriver{clientset: testJobs()}
+
+	states, err := driver.JobStates(context.Background(), "runner", []string{"pending", "running", "succeeded", "failed", "other", "missing"})
+	require.NoError(t, err)
+
+	phases := make(map[string]string)
+	for _, s := range states {
+		phases[s.Name] = s.Phase
+	}
+	assert.Equal(t, map[string]string{
+		"pending":   JobPhasePending,
+		"running":   JobPhaseRunning,
+		"succeeded": JobPhaseSucceeded,
+		"failed":    JobPhaseFailed,
+		"other":     JobPhaseNotFound,
+		"missing":   JobPhaseNotFound,
+	}, phases)
+
+	assert.False(t, states[1].Done())
+	assert.NotNil(t, states[1].StartedAt)
+	assert.True(t, states[3].Done())
+	assert.Equal(t, "BackoffLimitExceeded", states[3].Message)
+	assert.Equal(t, time.Date(2023, 11, 1, 10, 5, 0, 0, time.UTC), states[3].FinishedAt.UTC())
+}
+
+type namedJob struct {
+	JobCreator
+	name     string
+	analyzer string
+}
+
+func (j *namedJob) Name() string { return j.name }
+
+func (j *namedJob) PodLabels() map[string]string {
+	return map[string]string{LabelNameAnalyzer: j.analyzer}
+}
+
+func TestRecordingDriver(t *testing.T) {
+	driver := &recordingDriver{Driver: &testDriver{}}
+	r := &jobRecorder{}
+	ctx := context.WithValue(context.Background(), jobsKey{}, r)
+
+	require.NoError(t, driver.TriggerJob(ctx, &namedJob{name: "analysis-1"}))
+	require.NoError(t, driver.TriggerJob(ctx, &namedJob{name: "analysis-2"}))
+	require.NoError(t, driver.TriggerJob(context.Background(), &namedJob{name: "unrecorded"}))
+	assert.Equal(t, []string{"analysis-1", "analysis-2"}, r.names)
+}
+
+func TestFacade_CancelJobs(t *testing.T) {
+	clientset := testJobs()
+	f := &Facade{driver: &K8sDriver{clientset: clientset}, namespace: "runner"}
+
+	err := f.CancelJobs(context.Background(), []string{"running", "other"})
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	require.NoError(t, f.CancelJobs(context.Background(), []string{"running", "pending"}))
+	states, err := f.JobStates(context.Background(), []string{"running", "pending", "failed"})
+	require.NoError(t, err)
+	assert.Equal(t, JobPhaseNotFound, states[0].Phase)
+	assert.Equal(t, JobPhaseNotFound, states[1].Phase)
+	assert.Equal(t, JobPhaseFailed, states[2].Phase)
+
+	_, err = (&Facade{driver: &testDriver{}}).JobStates(context.Background(), []string{"running"})
+	assert.ErrorIs(t, err, ErrJobStateUnsupported)
+}
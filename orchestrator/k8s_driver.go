@@ -7,6 +7,8 @@ import (
 	"os"
 	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -14,11 +16,41 @@ import (
 
 const DefaultK8sTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 
+// K8sDriverOpts configures the Kubernetes objects created for jobs.
+type K8sDriverOpts struct {
+	// NetworkPolicy is nil when jobs are created without NetworkPolicies.
+	NetworkPolicy *NetworkPolicyOpts
+
+	// Security is the security profile of job pods.  Nil is baseline.
+	Security *SecurityProfile
+}
+
 type K8sDriver struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
+
+	networkPolicy *NetworkPolicyOpts
+	security      *SecurityProfile
+}
+
+func NewK8sDriver(tokenPath string, opts *K8sDriverOpts) (Driver, error) {
+	if opts == nil {
+		opts = &K8sDriverOpts{}
+	}
+	clientset, err := newK8sClientset(tokenPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &K8sDriver{
+		clientset:     clientset,
+		networkPolicy: opts.NetworkPolicy,
+		security:      opts.Security,
+	}, nil
 }
 
-func NewK8sDriver(tokenPath string) (Driver, error) {
+// newK8sClientset returns a clientset for the cluster the runner runs in,
+// authenticated with the service account token at tokenPath.
+func newK8sClientset(tokenPath string) (kubernetes.Interface, error) {
 	if tokenPath == "" {
 		tokenPath = DefaultK8sTokenPath
 	}
@@ -34,22 +66,19 @@ func NewK8sDriver(tokenPath string) (Driver, error) {
 			Insecure: true,
 		},
 	}
-
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, err
-	}
-
-	return &K8sDriver{clientset: clientset}, nil
+	return kubernetes.NewForConfig(config)
 }
 
 // TriggerJob creates the kubernetes job supplied as a parameter.
 func (d *K8sDriver) TriggerJob(ctx context.Context, job JobCreator) error {
-	k8sJob := &MarvinK8sJob{job}
+	k8sJob := &MarvinK8sJob{JobCreator: job, Security: d.security}
 	j, err := k8sJob.Job()
 	if err != nil {
 		return err
 	}
+	if d.networkPolicy != nil {
+		return d.triggerIsolatedJob(ctx, k8sJob, j)
+	}
 	_, err = d.clientset.BatchV1().Jobs(k8sJob.Namespace()).Create(ctx, j, metav1.CreateOptions{})
 	if err != nil {
 		return err
@@ -93,3 +122,32 @@ func (d *K8sDriver) CleanExpiredJobs(ctx context.Context, namespace string, inte
 	}
 	return nil
 }
+
+// RunningJobs returns the names of the runner's jobs that did not finish.
+func (d *K8sDriver) RunningJobs(ctx context.Context, namespace string) ([]string, error) {
+	jobs, err := d.clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: LabelNameManager + "=runner",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := range jobs.Items {
+		if !jobFinished(&jobs.Items[i]) {
+			names = append(names, jobs.Items[i].Name)
+		}
+	}
+	return names, nil
+}
+
+func jobFinished(job *batchv1.Job) bool {
+	if job.Status.CompletionTime != nil {
+		return true
+	}
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestK8sDriver_RunningJobs(t *testing.T) {
+	job := func(name string, labels map[string]string, status batchv1.JobStatus) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "runner", Labels: labels}, Status: status}
+	}
+	runner := map[string]string{LabelNameManager: "runner"}
+	clientset := fake.NewSimpleClientset(
+		job("running", runner, batchv1.JobStatus{}),
+		job("completed", runner, batchv1.JobStatus{CompletionTime: &metav1.Time{Time: time.Now()}}),
+		job("other", nil, batchv1.JobStatus{}),
+	)
+	driver := &K8sDriver{clientset: clientset}
+
+	jobs, err := driver.RunningJobs(context.Background(), "runner")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"running"}, jobs)
+}
@@ -12,19 +12,36 @@ const (
 	DriverPrinter = "printer"
 )
 
-type K8sPrinterDriver struct{}
+type K8sPrinterDriver struct {
+	networkPolicy *NetworkPolicyOpts
+	security      *SecurityProfile
+}
 
-func NewK8sPrinterDriver() Driver {
-	return &K8sPrinterDriver{}
+func NewK8sPrinterDriver(opts *K8sDriverOpts) Driver {
+	if opts == nil {
+		opts = &K8sDriverOpts{}
+	}
+	return &K8sPrinterDriver{networkPolicy: opts.NetworkPolicy, security: opts.Security}
 }
 
-func (*K8sPrinterDriver) TriggerJob(_ context.Context, job JobCreator) error {
-	k8sJob := &MarvinK8sJob{job}
+func (d *K8sPrinterDriver) TriggerJob(ctx context.Context, job JobCreator) error {
+	k8sJob := &MarvinK8sJob{JobCreator: job, Security: d.security}
 	j, err := k8sJob.Job()
 	if err != nil {
 		return err
 	}
 	printer := printers.YAMLPrinter{}
+	if d.networkPolicy != nil {
+		policies, err := d.networkPolicy.NetworkPolicies(ctx, k8sJob)
+		if err != nil {
+			return err
+		}
+		for _, policy := range policies {
+			if err := printer.PrintObj(policy, os.Stdout); err != nil {
+				return err
+			}
+		}
+	}
 	return printer.PrintObj(j, os.Stdout)
 }
 
@@ -0,0 +1,252 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	DefaultMaintenanceRetryAfter = time.Minute
+	DefaultDrainInterval         = 10 * time.Second
+)
+
+var ErrMaintenance = errors.New("runner is in maintenance mode")
+
+// JobLister is implemented by drivers that can list the runner's jobs that
+// are still running.
+type JobLister interface {
+	RunningJobs(ctx context.Context, namespace string) ([]string, error)
+}
+
+type MaintenanceOpts struct {
+	// Namespace is the namespace drained of jobs.
+	Namespace string
+
+	// RetryAfter is the delay suggested to clients of rejected tasks.
+	// Defaults to DefaultMaintenanceRetryAfter.
+	RetryAfter time.Duration
+
+	// DrainInterval is how often a drain checks the running jobs.  Defaults
+	// to DefaultDrainInterval.
+	DrainInterval time.Duration
+}
+
+// MaintenanceStatus is the state of maintenance mode.
+type MaintenanceStatus struct {
+	Enabled bool         `json:"enabled"`
+	Reason  string       `json:"reason,omitempty"`
+	Since   *time.Time   `json:"since,omitempty"`
+	Drain   *DrainStatus `json:"drain,omitempty"`
+}
+
+// DrainStatus is the progress of a drain.  Jobs are the jobs still running,
+// and Tasks the number of tasks admitted before maintenance that may still
+// create jobs.
+type DrainStatus struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Initial    int        `json:"initial"`
+	Running    int        `json:"running"`
+	Jobs       []string   `json:"jobs"`
+	Tasks      int        `json:"tasks"`
+	Done       bool       `json:"done"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Maintenance pauses new tasks, for example during cluster upgrades.  While
+// it is enabled, task endpoints answer 503 with a Retry-After header; other
+// endpoints, like webhooks and authentication, keep working.  A drain
+// enables maintenance and waits for the tasks in flight and the running jobs
+// to finish.
+type Maintenance struct {
+	driver Driver
+	opts   *MaintenanceOpts
+
+	mu     sync.Mutex
+	status MaintenanceStatus
+	tasks  int
+	cancel context.CancelFunc
+	now    func() time.Time
+}
+
+func NewMaintenance(driver Driver, opts *MaintenanceOpts) *Maintenance {
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = DefaultMaintenanceRetryAfter
+	}
+	if opts.DrainInterval <= 0 {
+		opts.DrainInterval = DefaultDrainInterval
+	}
+	return &Maintenance{driver: driver, opts: opts, now: time.Now}
+}
+
+// Enable pauses new tasks.
+func (m *Maintenance) Enable(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enable(reason)
+}
+
+func (m *Maintenance) enable(reason string) {
+	if !m.status.Enabled {
+		now := m.now()
+		m.status.Enabled = true
+		m.status.Since = &now
+		slog.Info("maintenance mode enabled", slog.String("reason", reason))
+	}
+	m.status.Reason = reason
+}
+
+// Disable resumes tasks and stops a running drain.
+func (m *Maintenance) Disable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	if m.status.Enabled {
+		slog.Info("maintenance mode disabled")
+	}
+	m.status = MaintenanceStatus{}
+}
+
+// Toggle enables maintenance when it is disabled, and disables it otherwise.
+func (m *Maintenance) Toggle(reason string) {
+	if m.Status().Enabled {
+		m.Disable()
+		return
+	}
+	m.Enable(reason)
+}
+
+// Status returns a copy of the state of maintenance mode.
+func (m *Maintenance) Status() *MaintenanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := m.status
+	if status.Drain != nil {
+		drain := *status.Drain
+		drain.Jobs = append([]string{}, drain.Jobs...)
+		status.Drain = &drain
+	}
+	return &status
+}
+
+// Drain enables maintenance and waits, in the background, for the tasks in
+// flight and the running jobs to finish.  Draining again restarts the drain.
+func (m *Maintenance) Drain(reason string) *MaintenanceStatus {
+	m.mu.Lock()
+	m.enable(reason)
+	if m.cancel != nil {
+		m.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.status.Drain = &DrainStatus{StartedAt: m.now(), Initial: -1, Jobs: []string{}}
+	m.mu.Unlock()
+
+	done := m.checkDrain(ctx)
+	if !done {
+		go m.drain(ctx)
+	}
+	return m.Status()
+}
+
+func (m *Maintenance) drain(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.DrainInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if m.checkDrain(ctx) {
+				return
+			}
+		}
+	}
+}
+
+// checkDrain updates the drain with the tasks in flight and the running
+// jobs, and reports whether it is done.  Tasks are counted before jobs are
+// listed, so that the jobs of a task that just finished are listed.
+func (m *Maintenance) checkDrain(ctx context.Context) bool {
+	m.mu.Lock()
+	tasks := m.tasks
+	m.mu.Unlock()
+
+	var (
+		jobs []string
+		err  error
+	)
+	if lister, ok := m.driver.(JobLister); ok {
+		jobs, err = lister.RunningJobs(ctx, m.opts.Namespace)
+	}
+	if ctx.Err() != nil {
+		return true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	drain := m.status.Drain
+	if drain == nil {
+		return true
+	}
+	if err != nil {
+		slog.Error("failed to list running jobs", slog.Any("err", err))
+		drain.Error = err.Error()
+		return false
+	}
+	if jobs == nil {
+		jobs = []string{}
+	}
+	if drain.Initial < 0 {
+		drain.Initial = len(jobs)
+	}
+	drain.Jobs, drain.Running, drain.Tasks, drain.Error = jobs, len(jobs), tasks, ""
+	if len(jobs) == 0 && tasks == 0 {
+		now := m.now()
+		drain.Done, drain.FinishedAt = true, &now
+		slog.Info("drain finished")
+		return true
+	}
+	slog.Info("draining jobs", slog.Int("running", len(jobs)), slog.Int("tasks", tasks))
+	return false
+}
+
+// admit counts a task in flight unless maintenance is enabled.  The returned
+// function must be called once the task returns.
+func (m *Maintenance) admit() (func(), bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.status.Enabled {
+		return nil, false
+	}
+	m.tasks++
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.tasks--
+	}, true
+}
+
+// Middleware rejects requests with 503 and a Retry-After header while
+// maintenance is enabled, and counts the requests it lets through until
+// they return.
+func (m *Maintenance) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if done, ok := m.admit(); ok {
+			defer done()
+			return next(c)
+		}
+		c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(m.opts.RetryAfter.Seconds())))
+		return httperror.ErrUnavailable(ErrMaintenance)
+	}
+}
@@ -0,0 +1,117 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testJobLister struct {
+	testDriver
+	mu      sync.Mutex
+	running []string
+}
+
+func (d *testJobLister) RunningJobs(context.Context, string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string{}, d.running...), nil
+}
+
+func (d *testJobLister) finish(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, n := range d.running {
+		if n == name {
+			d.running = append(d.running[:i], d.running[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestMaintenance_Middleware(t *testing.T) {
+	m := NewMaintenance(&testDriver{}, &MaintenanceOpts{RetryAfter: 2 * time.Minute})
+	handler := m.Middleware(func(c echo.Context) error { return c.NoContent(http.StatusAccepted) })
+	serve := func() (*httptest.ResponseRecorder, error) {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/apps/app-id/tasks/analysis", nil), rec)
+		return rec, handler(c)
+	}
+
+	rec, err := serve()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	m.Toggle("upgrade")
+	assert.Equal(t, "upgrade", m.Status().Reason)
+	rec, err = serve()
+	var httpErr *httperror.Error
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+	assert.Equal(t, "120", rec.Header().Get(echo.HeaderRetryAfter))
+
+	m.Toggle("")
+	assert.False(t, m.Status().Enabled)
+	_, err = serve()
+	assert.NoError(t, err)
+}
+
+func TestMaintenance_Drain(t *testing.T) {
+	driver := &testJobLister{running: []string{"analysis-s1", "autofix-s2"}}
+	m := NewMaintenance(driver, &MaintenanceOpts{DrainInterval: 10 * time.Millisecond})
+
+	status := m.Drain("upgrade")
+	assert.True(t, status.Enabled)
+	require.NotNil(t, status.Drain)
+	assert.Equal(t, 2, status.Drain.Initial)
+	assert.Equal(t, []string{"analysis-s1", "autofix-s2"}, status.Drain.Jobs)
+	assert.False(t, status.Drain.Done)
+
+	driver.finish("analysis-s1")
+	require.Eventually(t, func() bool { return m.Status().Drain.Running == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"autofix-s2"}, m.Status().Drain.Jobs)
+
+	driver.finish("autofix-s2")
+	require.Eventually(t, func() bool { return m.Status().Drain.Done }, time.Second, 5*time.Millisecond)
+	status = m.Status()
+	assert.NotNil(t, status.Drain.FinishedAt)
+	assert.Equal(t, 2, status.Drain.Initial)
+	assert.True(t, status.Enabled, "drains keep maintenance enabled")
+
+	m.Disable()
+	assert.Nil(t, m.Status().Drain)
+}
+
+func TestMaintenance_Drain_TasksInFlight(t *testing.T) {
+	m := NewMaintenance(&testJobLister{}, &MaintenanceOpts{DrainInterval: 10 * time.Millisecond})
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := m.Middleware(func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.NoContent(http.StatusAccepted)
+	})
+	served := make(chan error, 1)
+	go func() {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/apps/app-id/tasks/analysis", nil), httptest.NewRecorder())
+		served <- handler(c)
+	}()
+	<-started
+
+	status := m.Drain("upgrade")
+	require.NotNil(t, status.Drain)
+	assert.Equal(t, 1, status.Drain.Tasks)
+	assert.False(t, status.Drain.Done, "drains wait for tasks admitted before maintenance")
+
+	close(release)
+	require.NoError(t, <-served)
+	require.Eventually(t, func() bool { return m.Status().Drain.Done }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, m.Status().Drain.Tasks)
+}
@@ -19,16 +19,19 @@ var (
 	manualSelector           = true
 	backoffLimit             = int32(0)
 	activeDeadlineSeconds    = int64(300)
-	shareProcessNamespace    = true
 	uid                      = int64(1000)
 	gid                      = int64(3000)
 	fsGroup                  = int64(2000)
 	runAsNonRoot             = true
 	allowPrivilegeEscalation = false
+	secretDefaultMode        = int32(0o440)
 )
 
 type MarvinK8sJob struct {
 	JobCreator
+
+	// Security is the security profile of the pod.  Nil is baseline.
+	Security *SecurityProfile
 }
 
 func (j *MarvinK8sJob) Job() (*batchv1.Job, error) {
@@ -53,16 +56,19 @@ func (j *MarvinK8sJob) Job() (*batchv1.Job, error) {
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: j.PodLabels(),
+					Labels:      j.PodLabels(),
+					Annotations: j.podAnnotations(),
 				},
 				Spec: corev1.PodSpec{
-					ShareProcessNamespace: &shareProcessNamespace,
-					Volumes:               j.volumes(),
-					SecurityContext:       j.podSecContext(),
-					NodeSelector:          j.NodeSelector(),
-					ImagePullSecrets:      j.imagePullSecrets(),
-					RestartPolicy:         corev1.RestartPolicyNever,
-					Containers:            []corev1.Container{*j.container()},
+					ShareProcessNamespace:        j.Security.shareProcessNamespace(),
+					AutomountServiceAccountToken: j.Security.automountServiceAccountToken(),
+					RuntimeClassName:             j.Security.runtimeClassName(),
+					Volumes:                      j.volumes(),
+					SecurityContext:              j.Security.podSecContext(),
+					NodeSelector:                 j.NodeSelector(),
+					ImagePullSecrets:             j.imagePullSecrets(),
+					RestartPolicy:                corev1.RestartPolicyNever,
+					Containers:                   []corev1.Container{*j.container()},
 				},
 			},
 		},
@@ -80,7 +86,7 @@ func (j *MarvinK8sJob) container() *corev1.Container {
 	return &corev1.Container{
 		Name:            c.Name,
 		Image:           c.Image,
-		ImagePullPolicy: corev1.PullPolicy("Always"),
+		ImagePullPolicy: j.pullPolicy(c),
 		Resources: corev1.ResourceRequirements{
 			Limits: corev1.ResourceList{
 				"cpu":    resource.MustParse(c.Limit.CPU),
@@ -90,8 +96,9 @@ func (j *MarvinK8sJob) container() *corev1.Container {
 		Command:         c.Cmd,
 		Args:            c.Args,
 		Env:             j.env(c),
+		EnvFrom:         j.envFrom(c),
 		VolumeMounts:    j.mounts(c),
-		SecurityContext: j.conSecContext(),
+		SecurityContext: j.Security.conSecContext(),
 	}
 }
 
@@ -104,7 +111,7 @@ func (j *MarvinK8sJob) initContainer() *corev1.Container {
 	return &corev1.Container{
 		Name:            c.Name,
 		Image:           c.Image,
-		ImagePullPolicy: corev1.PullPolicy("Always"),
+		ImagePullPolicy: j.pullPolicy(c),
 		Resources: corev1.ResourceRequirements{
 			Limits: corev1.ResourceList{
 				"cpu":    resource.MustParse(c.Limit.CPU),
@@ -118,8 +125,9 @@ func (j *MarvinK8sJob) initContainer() *corev1.Container {
 		Command:         c.Cmd,
 		Args:            c.Args,
 		Env:             j.env(c),
+		EnvFrom:         j.envFrom(c),
 		VolumeMounts:    j.mounts(c),
-		SecurityContext: j.conSecContext(),
+		SecurityContext: j.Security.conSecContext(),
 	}
 }
 
@@ -135,6 +143,9 @@ func (j *MarvinK8sJob) volumes() []corev1.Volume {
 		})
 	}
 
+	volumes = append(volumes, j.mountVolumes()...)
+	volumes = append(volumes, j.Security.scratchVolumes()...)
+
 	// Append the volume for mounting the service account for uploading/downloading
 	// artifacts from remote storage.
 	volumes = append(volumes, corev1.Volume{
@@ -148,6 +159,55 @@ func (j *MarvinK8sJob) volumes() []corev1.Volume {
 	return volumes
 }
 
+// mountVolumes returns the volumes backing the Mounts of the job's
+// containers.  A volume mounted in both containers is only added once.
+func (j *MarvinK8sJob) mountVolumes() []corev1.Volume {
+	var volumes []corev1.Volume
+
+	seen := make(map[string]bool)
+	for _, c := range []*Container{j.InitContainer(), j.Container()} {
+		if c == nil {
+			continue
+		}
+		for _, m := range c.Mounts {
+			if seen[m.Name] {
+				continue
+			}
+			var source corev1.VolumeSource
+			switch {
+			case m.ClaimName != "":
+				source.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: m.ClaimName,
+					ReadOnly:  m.ReadOnly,
+				}
+			case m.SecretName != "":
+				source.Secret = &corev1.SecretVolumeSource{
+					SecretName:  m.SecretName,
+					DefaultMode: &secretDefaultMode,
+				}
+			default:
+				continue
+			}
+			seen[m.Name] = true
+			volumes = append(volumes, corev1.Volume{
+				Name:         m.Name,
+				VolumeSource: source,
+			})
+		}
+	}
+	return volumes
+}
+
+// podAnnotations returns the annotations of the pod, which carry its
+// containers' AppArmor profiles.
+func (j *MarvinK8sJob) podAnnotations() map[string]string {
+	containers := []string{j.Container().Name}
+	if c := j.InitContainer(); c != nil {
+		containers = append(containers, c.Name)
+	}
+	return j.Security.podAnnotations(containers...)
+}
+
 func (j *MarvinK8sJob) imagePullSecrets() []corev1.LocalObjectReference {
 	var imagePullSecrets []corev1.LocalObjectReference
 
@@ -159,26 +219,6 @@ func (j *MarvinK8sJob) imagePullSecrets() []corev1.LocalObjectReference {
 	return imagePullSecrets
 }
 
-func (*MarvinK8sJob) podSecContext() *corev1.PodSecurityContext {
-	return &corev1.PodSecurityContext{
-		RunAsUser:    &uid,
-		RunAsGroup:   &gid,
-		FSGroup:      &fsGroup,
-		RunAsNonRoot: &runAsNonRoot,
-	}
-}
-
-func (*MarvinK8sJob) conSecContext() *corev1.SecurityContext {
-	return &corev1.SecurityContext{
-		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
-		Capabilities: &corev1.Capabilities{
-			Drop: []corev1.Capability{
-				"all",
-			},
-		},
-	}
-}
-
 // env is a helper function to convert the IDriverJob's Env to corev1.EnvVars.
 func (*MarvinK8sJob) env(c *Container) []corev1.EnvVar {
 	var env []corev1.EnvVar
@@ -189,9 +229,33 @@ func (*MarvinK8sJob) env(c *Container) []corev1.EnvVar {
 	return env
 }
 
+// envFrom is a helper function to convert the IDriverJob's EnvFromSecrets to
+// corev1.EnvFromSources.
+func (*MarvinK8sJob) envFrom(c *Container) []corev1.EnvFromSource {
+	var envFrom []corev1.EnvFromSource
+
+	for _, name := range c.EnvFromSecrets {
+		envFrom = append(envFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			},
+		})
+	}
+	return envFrom
+}
+
+// pullPolicy returns the container's image pull policy.  Images are pulled
+// on every run unless the policy pinned them by digest.
+func (*MarvinK8sJob) pullPolicy(c *Container) corev1.PullPolicy {
+	if c.PullPolicy == "" {
+		return corev1.PullAlways
+	}
+	return corev1.PullPolicy(c.PullPolicy)
+}
+
 // mounts is a helper function to convert the IDriverJob's VolumeMounts to
 // corev1.VolumeMounts.
-func (*MarvinK8sJob) mounts(c *Container) []corev1.VolumeMount {
+func (j *MarvinK8sJob) mounts(c *Container) []corev1.VolumeMount {
 	var mounts []corev1.VolumeMount
 
 	for k, v := range c.VolumeMounts {
@@ -200,9 +264,18 @@ func (*MarvinK8sJob) mounts(c *Container) []corev1.VolumeMount {
 			MountPath: v,
 		})
 	}
+	for _, m := range c.Mounts {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      m.Name,
+			MountPath: m.MountPath,
+			SubPath:   m.SubPath,
+			ReadOnly:  m.ReadOnly,
+		})
+	}
 	mounts = append(mounts, corev1.VolumeMount{
 		Name:      "credentialsdir",
 		MountPath: "/credentials",
 	})
+	mounts = append(mounts, j.Security.scratchVolumeMounts()...)
 	return mounts
 }
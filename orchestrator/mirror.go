@@ -0,0 +1,71 @@
+package orchestrator
+
+const (
+	mirrorVolumeName = "mirrordir"
+	MirrorMountPath  = "/mirror"
+)
+
+// MirrorCache resolves the shared bare mirror of a repository that jobs
+// clone with as a reference.
+type MirrorCache interface {
+	// Reference returns the path of the repository's mirror relative to the
+	// cache volume.  ok is false when the repository is not mirrored yet.
+	Reference(appID, installationID, remoteURL string) (string, bool)
+}
+
+type MirrorOpts struct {
+	Cache MirrorCache
+
+	// ClaimName is the persistent volume claim holding the mirrors.  Job pods
+	// mount the mirror of their repository read-only at MirrorMountPath.
+	ClaimName string
+}
+
+// MirrorReference returns the path, relative to the cache volume, of the
+// mirror to clone remoteURL with.  An empty string is returned when the cache
+// is disabled or does not hold the repository yet.
+func (o *TaskOpts) MirrorReference(appID, installationID, remoteURL string) string {
+	if o.Mirror == nil || o.Mirror.Cache == nil {
+		return ""
+	}
+	p, ok := o.Mirror.Cache.Reference(appID, installationID, remoteURL)
+	if !ok {
+		return ""
+	}
+	return p
+}
+
+// MirrorClaimName returns the claim holding the mirrors, if the cache is
+// enabled.
+func (o *TaskOpts) MirrorClaimName() string {
+	if o.Mirror == nil {
+		return ""
+	}
+	return o.Mirror.ClaimName
+}
+
+// mirrorArgs returns the coat arguments to clone with the mirror at
+// reference.  The mirror is mounted at MirrorMountPath.
+func mirrorArgs(reference string) []string {
+	if reference == "" {
+		return nil
+	}
+	return []string{CoatArgNameReference, MirrorMountPath}
+}
+
+// mirrorMounts returns the read-only mount of the mirror at reference.  Only
+// the mirror of the job's repository is mounted, never the whole volume, so
+// that jobs cannot read the mirrors of other repositories.  The mirror is
+// mounted in both containers, since the clone borrows objects from it.
+func mirrorMounts(claimName, reference string) []Mount {
+	if reference == "" || claimName == "" {
+		return nil
+	}
+	return []Mount{{
+		Name:      mirrorVolumeName,
+		MountPath: MirrorMountPath,
+		SubPath:   reference,
+		ReadOnly:  true,
+		ClaimName: claimName,
+	}}
+}
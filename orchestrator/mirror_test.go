@@ -0,0 +1,36 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMirrorCache map[string]string
+
+func (c testMirrorCache) Reference(_, installationID, _ string) (string, bool) {
+	key, ok := c[installationID]
+	return key, ok
+}
+
+func TestMirrorMounts(t *testing.T) {
+	opts := &TaskOpts{Mirror: &MirrorOpts{
+		Cache:     testMirrorCache{"42": "app-id/42/github.com/acme/api.git"},
+		ClaimName: "mirrors",
+	}}
+
+	reference := opts.MirrorReference("app-id", "42", "https://github.com/acme/api")
+	assert.Equal(t, []string{CoatArgNameReference, MirrorMountPath}, mirrorArgs(reference))
+	assert.Equal(t, []Mount{{
+		Name:      mirrorVolumeName,
+		MountPath: MirrorMountPath,
+		SubPath:   "app-id/42/github.com/acme/api.git",
+		ReadOnly:  true,
+		ClaimName: "mirrors",
+	}}, mirrorMounts(opts.MirrorClaimName(), reference))
+
+	// Repositories without a mirror mount nothing.
+	reference = opts.MirrorReference("app-id", "43", "https://github.com/acme/api")
+	assert.Empty(t, mirrorArgs(reference))
+	assert.Empty(t, mirrorMounts(opts.MirrorClaimName(), reference))
+}
@@ -0,0 +1,349 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	KindNetworkPolicy       = "NetworkPolicy"
+	APIVersionNetworkingV1  = "networking.k8s.io/v1"
+	networkPolicyClonePhase = "-clone"
+
+	// clonePhaseGrace is how long after the job deadline the clone phase
+	// policy is kept at most.
+	clonePhaseGrace = time.Minute
+)
+
+// clonePhaseRewatchDelay is how long to wait before watching the pods of a
+// job again when the watch closed.
+var clonePhaseRewatchDelay = 5 * time.Second
+
+// EgressPeer is a destination jobs may connect to.  Exactly one of CIDR and
+// Host is set.  A zero Port allows every port.
+type EgressPeer struct {
+	CIDR string
+	Host string
+	Port int
+}
+
+// NetworkPolicyOpts configures the NetworkPolicies created for jobs.  Egress
+// maps container names ("coat", "marvin") to the destinations they may
+// reach.  Containers without an entry get no egress.
+type NetworkPolicyOpts struct {
+	Egress map[string][]*EgressPeer
+
+	// AllowDNS allows egress to port 53, which resolving any host needs.
+	AllowDNS bool
+
+	// LookupIP resolves Host peers, since NetworkPolicies only match IPs.
+	// Defaults to the system resolver.
+	LookupIP func(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// NetworkPolicies returns the NetworkPolicies of the job.  The first policy
+// selects the pod of the job and allows the egress of its container, and
+// denies all ingress.  Jobs with an init container get a second, clone phase
+// policy allowing the egress of the init container.  Since policies are
+// additive, deleting it once the init container is done leaves the main
+// container isolated to its own egress.
+func (o *NetworkPolicyOpts) NetworkPolicies(ctx context.Context, job JobCreator) ([]*networkingv1.NetworkPolicy, error) {
+	egress, err := o.egressRules(ctx, job.Container().Name)
+	if err != nil {
+		return nil, err
+	}
+	policies := []*networkingv1.NetworkPolicy{
+		o.networkPolicy(job, job.Name(), egress, true),
+	}
+	if init := job.InitContainer(); init != nil {
+		egress, err := o.egressRules(ctx, init.Name)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, o.networkPolicy(job, job.Name()+networkPolicyClonePhase, egress, false))
+	}
+	return policies, nil
+}
+
+func (o *NetworkPolicyOpts) networkPolicy(job JobCreator, name string, egress []networkingv1.NetworkPolicyEgressRule, denyIngress bool) *networkingv1.NetworkPolicy {
+	policyTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}
+	if denyIngress {
+		policyTypes = append(policyTypes, networkingv1.PolicyTypeIngress)
+	}
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: APIVersionNetworkingV1,
+			Kind:       KindNetworkPolicy,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: job.Namespace(),
+			Labels: map[string]string{
+				LabelNameApp:     job.Name(),
+				LabelNameManager: "runner",
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{LabelNameApp: job.Name()},
+			},
+			PolicyTypes: policyTypes,
+			Egress:      egress,
+		},
+	}
+}
+
+func (o *NetworkPolicyOpts) egressRules(ctx context.Context, container string) ([]networkingv1.NetworkPolicyEgressRule, error) {
+	var rules []networkingv1.NetworkPolicyEgressRule
+	if o.AllowDNS {
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{
+			Ports: []networkingv1.NetworkPolicyPort{port(corev1.ProtocolUDP, 53), port(corev1.ProtocolTCP, 53)},
+		})
+	}
+	for _, peer := range o.Egress[container] {
+		cidrs, err := o.cidrs(ctx, peer)
+		if err != nil {
+			return nil, err
+		}
+		rule := networkingv1.NetworkPolicyEgressRule{}
+		for _, cidr := range cidrs {
+			rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		if peer.Port != 0 {
+			rule.Ports = []networkingv1.NetworkPolicyPort{port(corev1.ProtocolTCP, peer.Port)}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// cidrs returns the CIDRs of peer, resolving hosts to single address blocks.
+func (o *NetworkPolicyOpts) cidrs(ctx context.Context, peer *EgressPeer) ([]string, error) {
+	if peer.CIDR != "" {
+		return []string{peer.CIDR}, nil
+	}
+	lookup := o.LookupIP
+	if lookup == nil {
+		lookup = func(ctx context.Context, host string) ([]net.IP, error) {
+			return net.DefaultResolver.LookupIP(ctx, "ip", host)
+		}
+	}
+	ips, err := lookup(ctx, peer.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve network policy host %s: %w", peer.Host, err)
+	}
+	cidrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		cidrs = append(cidrs, ip.String()+"/"+strconv.Itoa(bits))
+	}
+	return cidrs, nil
+}
+
+func port(protocol corev1.Protocol, p int) networkingv1.NetworkPolicyPort {
+	v := intstr.FromInt(p)
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &v}
+}
+
+// ownedBy makes the policy owned by the job, so that it is garbage collected
+// with it.
+func ownedBy(policy *networkingv1.NetworkPolicy, job *batchv1.Job) {
+	controller := true
+	policy.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: APIVersionV1,
+		Kind:       KindJob,
+		Name:       job.Name,
+		UID:        job.UID,
+		Controller: &controller,
+	}}
+}
+
+// triggerIsolatedJob creates the job with its NetworkPolicies.  Policies
+// are created before the job, so that its pod never runs without them, and
+// are owned by the job once it exists.
+func (d *K8sDriver) triggerIsolatedJob(ctx context.Context, k8sJob *MarvinK8sJob, j *batchv1.Job) error {
+	policies, err := d.networkPolicy.NetworkPolicies(ctx, k8sJob)
+	if err != nil {
+		return err
+	}
+	client := d.clientset.NetworkingV1().NetworkPolicies(j.Namespace)
+	for i, policy := range policies {
+		created, err := client.Create(ctx, policy, metav1.CreateOptions{})
+		if err != nil {
+			d.deleteNetworkPolicies(j.Namespace, policies[:i])
+			return err
+		}
+		policies[i] = created
+	}
+
+	created, err := d.clientset.BatchV1().Jobs(j.Namespace).Create(ctx, j, metav1.CreateOptions{})
+	if err != nil {
+		d.deleteNetworkPolicies(j.Namespace, policies)
+		return err
+	}
+	for _, policy := range policies {
+		ownedBy(policy, created)
+		if _, err := client.Update(ctx, policy, metav1.UpdateOptions{}); err != nil {
+			slog.Error("failed to set network policy owner", slog.String("policy", policy.Name), slog.Any("err", err))
+		}
+	}
+	if len(policies) > 1 {
+		go d.endClonePhase(j.Namespace, j.Name, policies[1].Name, clonePhaseDeadline(created))
+	}
+	return nil
+}
+
+// clonePhaseDeadline returns when the clone phase policy of the job is
+// deleted at the latest.
+func clonePhaseDeadline(job *batchv1.Job) time.Time {
+	return job.CreationTimestamp.Add(time.Duration(activeDeadlineSeconds)*time.Second + clonePhaseGrace)
+}
+
+// endClonePhase deletes the clone phase policy of the job once the init
+// container of its pod terminated, or the deadline passed.  The pods are
+// listed and watched again when the watch closes, so that a closed watch is
+// not taken for a finished clone.
+func (d *K8sDriver) endClonePhase(namespace, jobName, policyName string, deadline time.Time) {
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	defer d.deleteNetworkPolicies(namespace, []*networkingv1.NetworkPolicy{{ObjectMeta: metav1.ObjectMeta{Name: policyName}}})
+
+	for {
+		done, err := d.watchClone(ctx, namespace, jobName)
+		if done || ctx.Err() != nil {
+			return
+		}
+		if !errors.Is(err, errWatchClosed) {
+			slog.Warn("failed to watch job pods", slog.String("job", jobName), slog.Any("err", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(clonePhaseRewatchDelay):
+		}
+	}
+}
+
+// watchClone reports whether the clone of the job is done, watching its pods
+// until it is, the context is done or the watch closes.
+func (d *K8sDriver) watchClone(ctx context.Context, namespace, jobName string) (bool, error) {
+	selector := LabelNameApp + "=" + jobName
+	pods, err := d.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return false, err
+	}
+	for i := range pods.Items {
+		if cloneDone(&pods.Items[i]) {
+			return true, nil
+		}
+	}
+
+	w, err := d.clientset.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{
+		LabelSelector:   selector,
+		ResourceVersion: pods.ResourceVersion,
+	})
+	if err != nil {
+		return false, err
+	}
+	defer w.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return false, errWatchClosed
+			}
+			if pod, ok := event.Object.(*corev1.Pod); ok && cloneDone(pod) {
+				return true, nil
+			}
+		}
+	}
+}
+
+// ClonePhaseResumer is implemented by drivers that isolate the clone phase
+// of jobs, and need to pick it up again when the runner restarts.
+type ClonePhaseResumer interface {
+	ResumeClonePhases(ctx context.Context, namespace string) error
+}
+
+// ResumeClonePhases picks up the NetworkPolicies left by a previous runner
+// process.  Policies of jobs that were never created are deleted, policies
+// missing their owner get it, and the clone phase policies of existing jobs
+// are deleted once their clone is done.  Policies created within
+// clonePhaseGrace are skipped, since their job may be being created.
+func (d *K8sDriver) ResumeClonePhases(ctx context.Context, namespace string) error {
+	client := d.clientset.NetworkingV1().NetworkPolicies(namespace)
+	policies, err := client.List(ctx, metav1.ListOptions{LabelSelector: LabelNameManager + "=runner"})
+	if err != nil {
+		return err
+	}
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		jobName := policy.Labels[LabelNameApp]
+		if jobName == "" {
+			continue
+		}
+		job, err := d.clientset.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if time.Since(policy.CreationTimestamp.Time) > clonePhaseGrace {
+				d.deleteNetworkPolicies(namespace, []*networkingv1.NetworkPolicy{policy})
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(policy.OwnerReferences) == 0 {
+			ownedBy(policy, job)
+			if _, err := client.Update(ctx, policy, metav1.UpdateOptions{}); err != nil {
+				slog.Error("failed to set network policy owner", slog.String("policy", policy.Name), slog.Any("err", err))
+			}
+		}
+		if strings.HasSuffix(policy.Name, networkPolicyClonePhase) {
+			go d.endClonePhase(namespace, jobName, policy.Name, clonePhaseDeadline(job))
+		}
+	}
+	return nil
+}
+
+// cloneDone reports whether the init containers of the pod terminated.
+func cloneDone(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+	if len(pod.Status.InitContainerStatuses) == 0 {
+		return false
+	}
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.State.Terminated == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *K8sDriver) deleteNetworkPolicies(namespace string, policies []*networkingv1.NetworkPolicy) {
+	for _, policy := range policies {
+		err := d.clientset.NetworkingV1().NetworkPolicies(namespace).Delete(context.Background(), policy.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			slog.Error("failed to delete network policy", slog.String("policy", policy.Name), slog.Any("err", err))
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package orchestrator
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type testJob struct {
+	name string
+}
+
+func (j *testJob) Name() string                  { return j.name }
+func (*testJob) Namespace() string               { return "runner" }
+func (j *testJob) JobLabels() map[string]string  { return map[string]string{LabelNameApp: j.name} }
+func (j *testJob) PodLabels() map[string]string  { return map[string]string{LabelNameApp: j.name} }
+func (*testJob) Volumes() []string               { return nil }
+func (*testJob) NodeSelector() map[string]string { return nil }
+func (*testJob) ImagePullSecrets() []string      { return nil }
+func (*testJob) Container() *Container           { return testContainer("marvin") }
+func (*testJob) InitContainer() *Container       { return testContainer("coat") }
+
+func testContainer(name string) *Container {
+	return &Container{
+		Name:     name,
+		Image:    "registry.example.com/" + name + ":latest",
+		Limit:    Resource{CPU: "100m", Memory: "100Mi"},
+		Requests: Resource{CPU: "100m", Memory: "100Mi"},
+	}
+}
+
+func testNetworkPolicyOpts() *NetworkPolicyOpts {
+	return &NetworkPolicyOpts{
+		Egress: map[string][]*EgressPeer{
+			"coat":   {{Host: "github.com", Port: 443}},
+			"marvin": {{CIDR: "10.0.0.0/8"}},
+		},
+		AllowDNS: true,
+		LookupIP: func(_ context.Context, host string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("140.82.121.4"), net.ParseIP("2001:db8::1")}, nil
+		},
+	}
+}
+
+func TestNetworkPolicyOpts_NetworkPolicies(t *testing.T) {
+	policies, err := testNetworkPolicyOpts().NetworkPolicies(context.Background(), &testJob{name: "analysis-s1"})
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+
+	main, clone := policies[0], policies[1]
+	assert.Equal(t, "analysis-s1", main.Name)
+	assert.Equal(t, map[string]string{LabelNameApp: "analysis-s1"}, main.Spec.PodSelector.MatchLabels)
+	assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeEgress, networkingv1.PolicyTypeIngress}, main.Spec.PolicyTypes)
+	assert.Empty(t, main.Spec.Ingress)
+	require.Len(t, main.Spec.Egress, 2)
+	assert.Len(t, main.Spec.Egress[0].Ports, 2, "dns")
+	assert.Equal(t, "10.0.0.0/8", main.Spec.Egress[1].To[0].IPBlock.CIDR)
+	assert.Empty(t, main.Spec.Egress[1].Ports)
+
+	assert.Equal(t, "analysis-s1-clone", clone.Name)
+	assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}, clone.Spec.PolicyTypes)
+	require.Len(t, clone.Spec.Egress, 2)
+	github := clone.Spec.Egress[1]
+	assert.Equal(t, "140.82.121.4/32", github.To[0].IPBlock.CIDR)
+	assert.Equal(t, "2001:db8::1/128", github.To[1].IPBlock.CIDR)
+	assert.Equal(t, 443, github.Ports[0].Port.IntValue())
+}
+
+func TestK8sDriver_TriggerJob_NetworkPolicies(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	driver := &K8sDriver{clientset: clientset, networkPolicy: testNetworkPolicyOpts()}
+	ctx := context.Background()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "analysis-s1-pod", Namespace: "runner", Labels: map[string]string{LabelNameApp: "analysis-s1"}},
+		Status: corev1.PodStatus{
+			Phase:                 corev1.PodPending,
+			InitContainerStatuses: []corev1.ContainerStatus{{Name: "coat", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}},
+		},
+	}
+	_, err := clientset.CoreV1().Pods("runner").Create(ctx, pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, driver.TriggerJob(ctx, &testJob{name: "analysis-s1"}))
+
+	_, err = clientset.BatchV1().Jobs("runner").Get(ctx, "analysis-s1", metav1.GetOptions{})
+	require.NoError(t, err)
+	policy, err := clientset.NetworkingV1().NetworkPolicies("runner").Get(ctx, "analysis-s1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, policy.OwnerReferences, 1)
+	assert.Equal(t, KindJob, policy.OwnerReferences[0].Kind)
+	assert.Equal(t, "analysis-s1", policy.OwnerReferences[0].Name)
+	_, err = clientset.NetworkingV1().NetworkPolicies("runner").Get(ctx, "analysis-s1-clone", metav1.GetOptions{})
+	require.NoError(t, err, "clone policy is kept while coat runs")
+
+	pod.Status.InitContainerStatuses[0].State = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}
+	assert.Eventually(t, func() bool {
+		_, _ = clientset.CoreV1().Pods("runner").UpdateStatus(ctx, pod, metav1.UpdateOptions{})
+		_, err := clientset.NetworkingV1().NetworkPolicies("runner").Get(ctx, "analysis-s1-clone", metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = clientset.NetworkingV1().NetworkPolicies("runner").Get(ctx, "analysis-s1", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestK8sDriver_EndClonePhase_WatchClosed(t *testing.T) {
+	delay := clonePhaseRewatchDelay
+	clonePhaseRewatchDelay = 10 * time.Millisecond
+	defer func() { clonePhaseRewatchDelay = delay }()
+
+	clientset := fake.NewSimpleClientset(&networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "analysis-s1-clone", Namespace: "runner"},
+	})
+	watches := make(chan *watch.FakeWatcher, 2)
+	clientset.PrependWatchReactor("pods", func(k8stesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		watches <- w
+		return true, w, nil
+	})
+	driver := &K8sDriver{clientset: clientset}
+	ctx := context.Background()
+	go driver.endClonePhase("runner", "analysis-s1", "analysis-s1-clone", time.Now().Add(time.Minute))
+
+	// A closed watch is restarted, the policy is kept.
+	(<-watches).Stop()
+	w := <-watches
+	_, err := clientset.NetworkingV1().NetworkPolicies("runner").Get(ctx, "analysis-s1-clone", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	w.Modify(&corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodSucceeded}})
+	assert.Eventually(t, func() bool {
+		_, err := clientset.NetworkingV1().NetworkPolicies("runner").Get(ctx, "analysis-s1-clone", metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestK8sDriver_ResumeClonePhases(t *testing.T) {
+	stale := metav1.NewTime(time.Now().Add(-time.Hour))
+	policy := func(name, job string, created metav1.Time) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "runner",
+			CreationTimestamp: created,
+			Labels:            map[string]string{LabelNameApp: job, LabelNameManager: "runner"},
+		}}
+	}
+	clientset := fake.NewSimpleClientset(
+		policy("analysis-gone", "analysis-gone", stale),
+		policy("analysis-new", "analysis-new", metav1.Now()),
+		policy("analysis-s1", "analysis-s1", stale),
+		policy("analysis-s1-clone", "analysis-s1", stale),
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "analysis-s1", Namespace: "runner", UID: "job-uid", CreationTimestamp: metav1.Now()}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "analysis-s1-pod", Namespace: "runner", Labels: map[string]string{LabelNameApp: "analysis-s1"}},
+			Status: corev1.PodStatus{
+				Phase:                 corev1.PodPending,
+				InitContainerStatuses: []corev1.ContainerStatus{{Name: "coat", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}},
+			},
+		},
+	)
+	driver := &K8sDriver{clientset: clientset}
+	ctx := context.Background()
+	require.NoError(t, driver.ResumeClonePhases(ctx, "runner"))
+
+	policies := clientset.NetworkingV1().NetworkPolicies("runner")
+	_, err := policies.Get(ctx, "analysis-gone", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "policy of a job never created is deleted")
+	_, err = policies.Get(ctx, "analysis-new", metav1.GetOptions{})
+	assert.NoError(t, err, "job of a new policy may be being created")
+	main, err := policies.Get(ctx, "analysis-s1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, main.OwnerReferences, 1)
+	assert.Equal(t, types.UID("job-uid"), main.OwnerReferences[0].UID)
+	_, err = policies.Get(ctx, "analysis-s1-clone", metav1.GetOptions{})
+	require.NoError(t, err, "clone policy is kept while coat runs")
+
+	pod, err := clientset.CoreV1().Pods("runner").Get(ctx, "analysis-s1-pod", metav1.GetOptions{})
+	require.NoError(t, err)
+	pod.Status.InitContainerStatuses[0].State = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}
+	assert.Eventually(t, func() bool {
+		_, _ = clientset.CoreV1().Pods("runner").UpdateStatus(ctx, pod, metav1.UpdateOptions{})
+		_, err := policies.Get(ctx, "analysis-s1-clone", metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	}, 5*time.Second, 50*time.Millisecond)
+}
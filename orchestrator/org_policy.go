@@ -0,0 +1,254 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"golang.org/x/exp/slog"
+)
+
+// ErrPolicyDenied is wrapped by the denials of the organization policy.
+var ErrPolicyDenied = errors.New("denied by org policy")
+
+// OrgPolicy is the organization policy incoming runs are checked against
+// before they are scheduled.  A nil policy allows every run.
+type OrgPolicy struct {
+	// Analyzers and Transformers allow and deny analyzers, autofixers and
+	// transformers by shortcode.
+	Analyzers    PolicyList `json:"analyzers"`
+	Transformers PolicyList `json:"transformers"`
+
+	// MaxChecks limits the checks of an analysis run.  Zero is unlimited.
+	MaxChecks int `json:"max_checks,omitempty"`
+
+	// ExcludedRepositories are owner/name patterns of the repositories never
+	// analyzed or autofixed, as matched by path.Match.
+	ExcludedRepositories []string `json:"excluded_repositories,omitempty"`
+
+	// RequiredVersions maps analyzers to patterns of the versions they must
+	// run at.
+	RequiredVersions map[string]string `json:"required_versions,omitempty"`
+}
+
+// PolicyList allows and denies items by name.  Denied items are never
+// allowed, and when Allow is set, only the items in it are.
+type PolicyList struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+func (l *PolicyList) check(kind, name string) error {
+	for _, deny := range l.Deny {
+		if strings.EqualFold(deny, name) {
+			return fmt.Errorf("%w: %s %s is denied", ErrPolicyDenied, kind, name)
+		}
+	}
+	if len(l.Allow) > 0 && !matchesAny(l.Allow, name) {
+		return fmt.Errorf("%w: %s %s is not allowed", ErrPolicyDenied, kind, name)
+	}
+	return nil
+}
+
+// CheckRepository denies analyzing and autofixing excluded repositories.
+func (p *OrgPolicy) CheckRepository(repository string) error {
+	if p == nil {
+		return nil
+	}
+	for _, pattern := range p.ExcludedRepositories {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(repository)); ok {
+			return fmt.Errorf("%w: repository %s is excluded from analysis", ErrPolicyDenied, repository)
+		}
+	}
+	return nil
+}
+
+// CheckAnalysis denies analysis runs of excluded repositories, and runs with
+// too many checks.
+func (p *OrgPolicy) CheckAnalysis(run *artifact.AnalysisRun) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.CheckRepository(repositoryName(run.VCSMeta.RemoteURL)); err != nil {
+		return err
+	}
+	if p.MaxChecks > 0 && len(run.Checks) > p.MaxChecks {
+		return fmt.Errorf("%w: %d checks, at most %d allowed", ErrPolicyDenied, len(run.Checks), p.MaxChecks)
+	}
+	return nil
+}
+
+// CheckAnalyzer denies analyzers and autofixers not allowed, or not at a
+// required version.
+func (p *OrgPolicy) CheckAnalyzer(shortcode, version string) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.Analyzers.check("analyzer", shortcode); err != nil {
+		return err
+	}
+	for analyzer, pattern := range p.RequiredVersions {
+		if !strings.EqualFold(analyzer, shortcode) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, version); !ok {
+			return fmt.Errorf("%w: analyzer %s is at version %s, %s required", ErrPolicyDenied, shortcode, version, pattern)
+		}
+	}
+	return nil
+}
+
+// CheckTransformer denies transformer runs using tools not allowed.
+func (p *OrgPolicy) CheckTransformer(run *artifact.TransformerRun) error {
+	if p == nil {
+		return nil
+	}
+	for _, tool := range run.Transformer.Tools {
+		if err := p.Transformers.check("transformer", tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// policyDeniedStatus is the status of runs and checks the policy denies.
+func policyDeniedStatus(err error) artifact.Status {
+	return artifact.Status{
+		Code:     StatusCodePolicyDenied,
+		HMessage: "Denied by the organization policy of the runner",
+		Err:      err.Error(),
+	}
+}
+
+// PolicyFile is the organization policy read from a file.  The file is
+// reloaded when it changes, so that the policy can be updated without a
+// restart.
+type PolicyFile struct {
+	path  string
+	parse func([]byte) (*OrgPolicy, error)
+
+	mu     sync.RWMutex
+	policy *OrgPolicy
+	// modTime is the modification time of the file last read, even if it
+	// failed to load, so that a broken file is not read again until it
+	// changes.
+	modTime  time.Time
+	loadedAt time.Time
+	err      error
+}
+
+// PolicyStatus is the state of the policy file, as reported to operators.
+type PolicyStatus struct {
+	Path     string     `json:"path"`
+	Policy   *OrgPolicy `json:"policy"`
+	LoadedAt time.Time  `json:"loaded_at"`
+
+	// Error is the error of the last reload, if it failed.  The previous
+	// policy is kept in that case.
+	Error string `json:"error,omitempty"`
+}
+
+// NewPolicyFile loads the policy from the file at path.
+func NewPolicyFile(path string, parse func([]byte) (*OrgPolicy, error)) (*PolicyFile, error) {
+	f := &PolicyFile{path: path, parse: parse}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Policy returns the current policy.  It is nil for a nil file.
+func (f *PolicyFile) Policy() *OrgPolicy {
+	if f == nil {
+		return nil
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.policy
+}
+
+// Reload reads the policy file again.  The current policy is kept if the
+// file cannot be read or is invalid.
+func (f *PolicyFile) Reload() error {
+	policy, modTime, err := f.load()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !modTime.IsZero() {
+		f.modTime = modTime
+	}
+	if err != nil {
+		f.err = fmt.Errorf("failed to load org policy %s: %w", f.path, err)
+		return f.err
+	}
+	f.policy, f.loadedAt, f.err = policy, time.Now(), nil
+	return nil
+}
+
+// load reads and parses the file.  The modification time is returned
+// whenever the file could be stat'ed, even if it failed to load.
+func (f *PolicyFile) load() (*OrgPolicy, time.Time, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, info.ModTime(), err
+	}
+	policy, err := f.parse(data)
+	if err != nil {
+		return nil, info.ModTime(), err
+	}
+	return policy, info.ModTime(), nil
+}
+
+// Status returns the state of the policy file.
+func (f *PolicyFile) Status() *PolicyStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	status := &PolicyStatus{Path: f.path, Policy: f.policy, LoadedAt: f.loadedAt}
+	if f.err != nil {
+		status.Error = f.err.Error()
+	}
+	return status
+}
+
+// Watch reloads the policy file whenever its modification time changes, as
+// checked every interval, until the context is done.  A file that fails to
+// load is reported once, and read again when it changes.
+func (f *PolicyFile) Watch(ctx context.Context, interval time.Duration) {
+	if f == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(f.path)
+		if err != nil {
+			slog.Warn("failed to check org policy", slog.String("path", f.path), slog.Any("err", err))
+			continue
+		}
+		f.mu.RLock()
+		changed := !info.ModTime().Equal(f.modTime)
+		f.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := f.Reload(); err != nil {
+			slog.Error("failed to reload org policy, keeping the current policy", slog.Any("err", err))
+			continue
+		}
+		slog.Info("reloaded org policy", slog.String("path", f.path))
+	}
+}
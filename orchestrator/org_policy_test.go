@@ -0,0 +1,263 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrgPolicy_Check(t *testing.T) {
+	policy := &OrgPolicy{
+		Analyzers:            PolicyList{Deny: []string{"php"}},
+		Transformers:         PolicyList{Allow: []string{"black", "gofmt"}},
+		MaxChecks:            2,
+		ExcludedRepositories: []string{"acme/legacy-*"},
+		RequiredVersions:     map[string]string{"python": "v2.*"},
+	}
+
+	assert.NoError(t, policy.CheckRepository("acme/api"))
+	assert.ErrorIs(t, policy.CheckRepository("Acme/Legacy-App"), ErrPolicyDenied)
+
+	run := &artifact.AnalysisRun{
+		VCSMeta: artifact.AnalysisRunVCSMeta{RemoteURL: "https://github.com/acme/api.git"},
+		Checks:  []artifact.Check{{CheckSeq: "1"}, {CheckSeq: "2"}},
+	}
+	assert.NoError(t, policy.CheckAnalysis(run))
+	run.Checks = append(run.Checks, artifact.Check{CheckSeq: "3"})
+	assert.ErrorIs(t, policy.CheckAnalysis(run), ErrPolicyDenied)
+
+	assert.NoError(t, policy.CheckAnalyzer("go", "v1"))
+	assert.NoError(t, policy.CheckAnalyzer("python", "v2.1"))
+	assert.ErrorIs(t, policy.CheckAnalyzer("python", "v1.9"), ErrPolicyDenied)
+	assert.ErrorIs(t, policy.CheckAnalyzer("PHP", "v1"), ErrPolicyDenied)
+
+	transform := &artifact.TransformerRun{Transformer: artifact.TransformerInfo{Tools: []string{"black"}}}
+	assert.NoError(t, policy.CheckTransformer(transform))
+	transform.Transformer.Tools = append(transform.Transformer.Tools, "prettier")
+	assert.ErrorIs(t, policy.CheckTransformer(transform), ErrPolicyDenied)
+
+	var none *OrgPolicy
+	assert.NoError(t, none.CheckAnalysis(run))
+	assert.NoError(t, none.CheckAnalyzer("php", "v1"))
+	assert.NoError(t, none.CheckTransformer(transform))
+}
+
+func testPolicyParser(data []byte) (*OrgPolicy, error) {
+	policy := new(OrgPolicy)
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func TestPolicyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"max_checks": 2}`), 0o600))
+
+	f, err := NewPolicyFile(path, testPolicyParser)
+	require.NoError(t, err)
+	assert.Equal(t, 2, f.Policy().MaxChecks)
+
+	t.Run("invalid file keeps the policy", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`{"max_checks": `), 0o600))
+		assert.Error(t, f.Reload())
+		assert.Equal(t, 2, f.Policy().MaxChecks)
+		assert.NotEmpty(t, f.Status().Error)
+	})
+
+	t.Run("watch reloads a changed file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`{"max_checks": 5}`), 0o600))
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go f.Watch(ctx, 10*time.Millisecond)
+		assert.Eventually(t, func() bool { return f.Policy().MaxChecks == 5 }, time.Second, 10*time.Millisecond)
+		assert.Empty(t, f.Status().Error)
+	})
+
+	t.Run("watch reads a failed file once", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"max_checks": 5}`), 0o600))
+		var reads atomic.Int32
+		f, err := NewPolicyFile(path, func(data []byte) (*OrgPolicy, error) {
+			reads.Add(1)
+			return testPolicyParser(data)
+		})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, []byte(`{"max_checks": `), 0o600))
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go f.Watch(ctx, 10*time.Millisecond)
+		assert.Eventually(t, func() bool { return f.Status().Error != "" }, time.Second, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, int32(2), reads.Load())
+		assert.Equal(t, 5, f.Policy().MaxChecks)
+	})
+
+	_, err = NewPolicyFile(filepath.Join(t.TempDir(), "missing.json"), testPolicyParser)
+	assert.Error(t, err)
+
+	var none *PolicyFile
+	assert.Nil(t, none.Policy())
+}
+
+func TestOrgPolicy_Tasks(t *testing.T) {
+	var published []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payload["path"] = r.URL.Path
+		published = append(published, payload)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"analyzers": {"deny": ["ruby"]},
+		"transformers": {"allow": ["black"]},
+		"excluded_repositories": ["acme/legacy"]
+	}`), 0o600))
+	policy, err := NewPolicyFile(path, testPolicyParser)
+	require.NoError(t, err)
+
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	driver := &testDriver{}
+	opts := &TaskOpts{
+		RemoteHost:     server.URL,
+		KubernetesOpts: &KubernetesOpts{ImageURL: *registry},
+		OrgPolicy:      policy,
+	}
+	runner := &Runner{ID: "runner-id"}
+	analysis := NewAnalysisTask(runner, opts, driver, testProvider{}, testSigner{})
+	autofix := NewAutofixTask(runner, opts, driver, testProvider{}, testSigner{})
+	transformer := NewTransformerTask(runner, opts, driver, testProvider{}, testSigner{})
+
+	analyze := func(repository string) error {
+		return analysis.Run(context.Background(), &AnalysisRunRequest{
+			Run: &artifact.AnalysisRun{
+				RunID:   "analysis-run",
+				VCSMeta: artifact.AnalysisRunVCSMeta{RemoteURL: "https://github.com/" + repository},
+				Checks: []artifact.Check{
+					{CheckSeq: "1", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "python", AnalyzerType: "core", Version: "v1"}},
+					{CheckSeq: "2", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "ruby", AnalyzerType: "core", Version: "v1"}},
+				},
+			},
+		})
+	}
+	// The denied check is concluded, the other one runs.
+	require.NoError(t, analyze("acme/api"))
+	assert.Len(t, driver.jobs, 1)
+	// No check of an excluded repository runs.
+	require.NoError(t, analyze("acme/legacy"))
+	assert.Len(t, driver.jobs, 1)
+
+	fix := func(shortcode string) error {
+		return autofix.Run(context.Background(), &AutofixRunRequest{
+			Run: &artifact.AutofixRun{
+				RunID:     "autofix-run",
+				VCSMeta:   artifact.AutofixVCSMeta{RemoteURL: "https://github.com/acme/api"},
+				Autofixer: artifact.Autofixer{AutofixMeta: artifact.AutofixMeta{Shortcode: shortcode, Version: "v1"}},
+			},
+		})
+	}
+	require.NoError(t, fix("python"))
+	require.NoError(t, fix("ruby"))
+	assert.Len(t, driver.jobs, 2)
+
+	transform := func(tools ...string) error {
+		return transformer.Run(context.Background(), &TransformerRunRequest{
+			Run: &artifact.TransformerRun{
+				RunID:       "transformer-run",
+				Transformer: artifact.TransformerInfo{Tools: tools},
+			},
+		})
+	}
+	require.NoError(t, transform("black"))
+	require.NoError(t, transform("black", "isort"))
+	assert.Len(t, driver.jobs, 3)
+
+	wantPaths := []string{analysisPublishPath, analysisPublishPath, analysisPublishPath, autofixPublishPath, transformerPublishPath}
+	require.Len(t, published, len(wantPaths))
+	for i, path := range wantPaths {
+		assert.Equal(t, path, published[i]["path"])
+		status := published[i]["kwargs"].(map[string]interface{})["status"].(map[string]interface{})
+		assert.Equal(t, float64(StatusCodePolicyDenied), status["code"])
+		assert.Contains(t, status["err"], ErrPolicyDenied.Error())
+	}
+}
+
+// countingProvider counts the remote URLs it generates.
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) AuthenticatedRemoteURL(_, _, srcURL string) (string, error) {
+	p.calls++
+	return srcURL, nil
+}
+
+func TestOrgPolicy_DeniedChecks(t *testing.T) {
+	var published []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload artifact.AnalysisResultCeleryTask
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		published = append(published, payload.KWArgs.CheckSeq)
+		if payload.KWArgs.CheckSeq == "1" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"analyzers": {"deny": ["ruby", "php"]}, "excluded_repositories": ["acme/legacy"]}`), 0o600))
+	policy, err := NewPolicyFile(path, testPolicyParser)
+	require.NoError(t, err)
+
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	driver := &testDriver{}
+	provider := &countingProvider{}
+	analysis := NewAnalysisTask(&Runner{ID: "runner-id"}, &TaskOpts{
+		RemoteHost:     server.URL,
+		KubernetesOpts: &KubernetesOpts{ImageURL: *registry},
+		OrgPolicy:      policy,
+	}, driver, provider, testSigner{})
+	analyze := func(repository string) error {
+		return analysis.Run(context.Background(), &AnalysisRunRequest{
+			Run: &artifact.AnalysisRun{
+				RunID:   "analysis-run",
+				VCSMeta: artifact.AnalysisRunVCSMeta{RemoteURL: "https://github.com/" + repository},
+				Checks: []artifact.Check{
+					{CheckSeq: "1", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "ruby", AnalyzerType: "core", Version: "v1"}},
+					{CheckSeq: "2", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "php", AnalyzerType: "core", Version: "v1"}},
+				},
+			},
+		})
+	}
+
+	// Every check is reported, despite the failure of the first.
+	assert.Error(t, analyze("acme/api"))
+	assert.Equal(t, []string{"1", "2"}, published)
+	assert.Zero(t, provider.calls, "no job is set up when every check is denied")
+	assert.Empty(t, driver.jobs)
+
+	published = nil
+	err = analyze("acme/legacy")
+	assert.ErrorContains(t, err, "check 1")
+	assert.Equal(t, []string{"1", "2"}, published)
+}
@@ -2,9 +2,16 @@ package orchestrator
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
 	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/deepsourcecorp/runner/usage"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 const (
@@ -18,6 +25,7 @@ type PatcherTask struct {
 	provider Provider
 	signer   Signer
 	opts     *TaskOpts
+	client   *http.Client
 }
 
 // PatcherRunRequest represents the data corresponding to the patcher run including the
@@ -36,33 +44,139 @@ func NewPatcherTask(runner *Runner, opts *TaskOpts, driver Driver, provider Prov
 		signer:   signer,
 		opts:     opts,
 		runner:   runner,
+		client:   http.DefaultClient,
 	}
 }
 
 // PatcherTask.Run creates a new patcher job based on the data passed to it
 // and then triggers that job using the specified driver in the task.
 func (p *PatcherTask) Run(ctx context.Context, req *PatcherRunRequest) error {
-	remoteURL, err := p.provider.AuthenticatedRemoteURL(req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL)
+	repository := repositoryName(req.Run.VCSMeta.RemoteURL)
+	slot, err := p.opts.Dispatcher.Acquire(ctx, &DispatchRequest{
+		AppID:      req.AppID,
+		Repository: repository,
+		Class:      DispatchClassInteractive,
+	})
 	if err != nil {
 		return err
 	}
+	defer slot.Release()
 
 	token, err := p.signer.GenerateToken(p.runner.ID, []string{ScopeAutofix}, nil, 30*time.Minute)
 	if err != nil {
 		return err
 	}
 
+	if head, ok := p.stale(req); ok {
+		slog.Info("skipping stale changeset", slog.String("run_id", req.Run.RunID), slog.String("head", head))
+		return p.reportStale(ctx, req.Run.RunID, head, token)
+	}
+
+	push := p.push(req.Run)
+	if p.opts.PushPolicy.ProtectsDefaultBranch() {
+		push.DefaultBranch = p.opts.DefaultBranch(req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL)
+	}
+	if err := p.opts.PushPolicy.Check(push); err != nil {
+		slog.Warn("patcher run rejected by push policy", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+		return p.report(ctx, req.Run.RunID, token, pushRejectedStatus(err))
+	}
+
+	if err := p.opts.CheckUsage(ctx, req.AppID); err != nil {
+		return err
+	}
+
+	remoteURL, err := p.opts.PushRemoteURL(p.provider, req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL, push.Branch)
+	if err != nil {
+		return err
+	}
+
+	images, err := p.opts.ResolveJobImages(ctx, nil)
+	if err != nil {
+		slog.Error("patcher job image rejected", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+		return p.report(ctx, req.Run.RunID, token, imageRejectedStatus(err))
+	}
+
 	req.Run.VCSMeta.RemoteURL = remoteURL
 	job, err := NewPatcherDriverJob(req.Run, &PatcherJobOpts{
-		PublisherURL:         p.opts.RemoteHost + patcherPublishPath,
+		PublisherURL:         p.opts.PublisherURL(patcherPublishPath),
 		PublisherToken:       token,
 		SnippetStorageType:   p.opts.SnippetStorageType,
 		SnippetStorageBucket: p.opts.SnippetStorageBucket,
 		SentryDSN:            p.opts.SentryDSN,
+		Images:               images,
+		SigningKey:           p.opts.SigningKey(req.AppID),
 		KubernetesOpts:       p.opts.KubernetesOpts,
 	})
 	if err != nil {
 		return err
 	}
-	return p.driver.TriggerJob(ctx, job)
+	if err := p.opts.Quota.Admit(ctx, job); err != nil {
+		if errors.Is(err, ErrJobTooLarge) {
+			slog.Error("patcher job too large", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+			return p.report(ctx, req.Run.RunID, token, jobTooLargeStatus(err))
+		}
+		return err
+	}
+	slot.Hold(job.Name())
+	if err := p.driver.TriggerJob(ctx, job); err != nil {
+		slot.Drop(job.Name())
+		return err
+	}
+	p.opts.TrackUsage(ctx, job, req.AppID, repository, usage.KindPatcher)
+	return nil
+}
+
+// stale reports whether the branch of the run has moved past the commit the
+// changeset was generated for, and returns its current head.  Runs whose head
+// cannot be resolved are not stale, and are left to the patcher to check.
+func (p *PatcherTask) stale(req *PatcherRunRequest) (string, bool) {
+	meta := req.Run.VCSMeta
+	if meta.CheckoutOID == "" {
+		return "", false
+	}
+	head := p.opts.BranchHead(req.AppID, req.InstallationID, meta.RemoteURL, meta.BaseBranch)
+	return head, head != "" && head != meta.CheckoutOID
+}
+
+// reportStale publishes a stale changeset result for a patcher run, instead
+// of spending a job on patches that no longer apply.
+func (p *PatcherTask) reportStale(ctx context.Context, runID, head, token string) error {
+	return p.report(ctx, runID, token, artifact.Status{
+		Code:     StatusCodeStaleChangeset,
+		HMessage: "The branch has new commits since the changes were generated",
+		Err:      fmt.Sprintf("stale changeset: branch head is %s", head),
+	})
+}
+
+// push returns the push the patcher run would make.  Runs whose patch meta
+// cannot be decoded push an unknown number of files and patches, which the
+// size limits of the policy reject.
+func (*PatcherTask) push(run *artifact.PatcherRun) *Push {
+	push := &Push{Branch: run.VCSMeta.BaseBranch, BaseBranch: run.VCSMeta.BaseBranch, Files: -1, Patches: -1}
+	var meta artifact.PatchMeta
+	if err := json.Unmarshal([]byte(run.PatchMeta), &meta); err != nil {
+		slog.Warn("failed to decode patch meta", slog.String("run_id", run.RunID), slog.Any("err", err))
+		return push
+	}
+	if branch := meta.PatchCommit.Commit.DestinationBranch; branch != "" {
+		push.Branch = branch
+	}
+	push.Files, push.Patches = len(meta.Patches), 0
+	for _, patch := range meta.Patches {
+		push.Patches += len(patch.PatchIDs)
+	}
+	return push
+}
+
+// report publishes the result of a patcher run concluded without a job.
+func (p *PatcherTask) report(ctx context.Context, runID, token string, status artifact.Status) error {
+	payload := artifact.PatcherResultCeleryTask{
+		ID:   uuid.NewString(),
+		Task: PatcherResultTask,
+		KWArgs: artifact.PatcherResult{
+			RunID:  runID,
+			Status: status,
+		},
+	}
+	return publishResult(ctx, p.client, p.opts.PublisherURL(patcherPublishPath), token, payload)
 }
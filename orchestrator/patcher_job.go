@@ -30,6 +30,13 @@ type PatcherJobOpts struct {
 
 	SentryDSN string
 
+	// Images are the job images, resolved by the image policy.
+	Images *JobImages
+
+	// SigningKey signs the commits pushed by the job.  Nil when commits are
+	// not signed.
+	SigningKey *SigningKey
+
 	KubernetesOpts *KubernetesOpts
 }
 
@@ -73,8 +80,9 @@ func (j *PatcherDriverJob) NodeSelector() map[string]string {
 
 func (j *PatcherDriverJob) PodLabels() map[string]string {
 	return map[string]string{
-		LabelNameApp:  j.taskID(),
-		LabelNameRole: "patcher",
+		LabelNameApp:     j.taskID(),
+		LabelNameRole:    "patcher",
+		LabelNameManager: "runner",
 	}
 }
 
@@ -88,9 +96,28 @@ func (*PatcherDriverJob) Volumes() []string {
 }
 
 func (j *PatcherDriverJob) Container() *Container {
+	keyMounts, keyEnv := signingMounts(j.opts.SigningKey)
+	env := map[string]string{
+		EnvNameCodePath:                 "/code",
+		EnvNameToolboxPath:              "/toolbox",
+		EnvNameArtifactsCredentialsPath: "/credentials/credentials",
+		EnvNameSSHPrivateKey:            j.run.Keys.SSH.Private,
+		EnvNameSSHPublicKey:             j.run.Keys.SSH.Public,
+		EnvNameTimeLimit:                "1500",
+		EnvNameOnPrem:                   "true",
+		EnvNamePublisher:                "http",
+		EnvNamePublisherURL:             j.opts.PublisherURL,
+		EnvNamePublisherToken:           j.opts.PublisherToken,
+		EnvNameResultTask:               PatcherResultTask,
+		EnvNameSentryDSN:                j.opts.SentryDSN,
+	}
+	for k, v := range keyEnv {
+		env[k] = v
+	}
 	return &Container{
-		Name:  "coat",
-		Image: j.getCoatImageURL(),
+		Name:       "coat",
+		Image:      j.opts.Images.Coat.Ref,
+		PullPolicy: j.opts.Images.Coat.PullPolicy,
 		Limit: Resource{
 			CPU:    CoatCPULimit,
 			Memory: CoatMemoryLimit,
@@ -112,21 +139,9 @@ func (j *PatcherDriverJob) Container() *Container {
 			CoatArgSnippetStorageType, j.opts.SnippetStorageType,
 			CoatArgSnippetStorageBucket, j.opts.SnippetStorageBucket,
 		},
-		Env: map[string]string{
-			EnvNameCodePath:                 "/code",
-			EnvNameToolboxPath:              "/toolbox",
-			EnvNameArtifactsCredentialsPath: "/credentials/credentials",
-			EnvNameSSHPrivateKey:            j.run.Keys.SSH.Private,
-			EnvNameSSHPublicKey:             j.run.Keys.SSH.Public,
-			EnvNameTimeLimit:                "1500",
-			EnvNameOnPrem:                   "true",
-			EnvNamePublisher:                "http",
-			EnvNamePublisherURL:             j.opts.PublisherURL,
-			EnvNamePublisherToken:           j.opts.PublisherToken,
-			EnvNameResultTask:               PatcherResultTask,
-			EnvNameSentryDSN:                j.opts.SentryDSN,
-		},
+		Env:          env,
 		VolumeMounts: VolumeMounts,
+		Mounts:       keyMounts,
 	}
 }
 
@@ -137,10 +152,3 @@ func (j *PatcherDriverJob) ImagePullSecrets() []string {
 func (*PatcherDriverJob) InitContainer() *Container {
 	return nil
 }
-
-func (j *PatcherDriverJob) getCoatImageURL() string {
-	return j.opts.KubernetesOpts.ImageURL.JoinPath(fmt.Sprintf(
-		"coat:%s",
-		CoatVersion,
-	)).String()
-}
@@ -0,0 +1,82 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBranches maps branches to their heads, and HEAD to the default
+// branch.
+type testBranches map[string]string
+
+func (b testBranches) BranchHead(_, _, _, branch string) (string, error) {
+	head, ok := b[branch]
+	if !ok {
+		return "", errors.New("branch not found")
+	}
+	return head, nil
+}
+
+func (b testBranches) DefaultBranch(_, _, _ string) (string, error) {
+	return b.BranchHead("", "", "", "HEAD")
+}
+
+func TestPatcherTask_StaleChangeset(t *testing.T) {
+	var published []artifact.PatcherResultCeleryTask
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, patcherPublishPath, r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var payload artifact.PatcherResultCeleryTask
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		published = append(published, payload)
+	}))
+	defer server.Close()
+
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	driver := &testDriver{}
+	task := NewPatcherTask(&Runner{ID: "runner-id"}, &TaskOpts{
+		RemoteHost:     server.URL,
+		KubernetesOpts: &KubernetesOpts{ImageURL: *registry},
+		Branches:       testBranches{"main": "def456", "dev": "abc123"},
+	}, driver, testProvider{}, testSigner{})
+
+	run := func(branch string) error {
+		return task.Run(context.Background(), &PatcherRunRequest{
+			AppID: "app-id",
+			Run: &artifact.PatcherRun{
+				RunID: "run-id",
+				VCSMeta: artifact.PatcherVCSMeta{
+					RemoteURL:   "https://github.com/acme/app.git",
+					BaseBranch:  branch,
+					CheckoutOID: "abc123",
+				},
+			},
+		})
+	}
+
+	// The branch moved: the result is published without a job.
+	require.NoError(t, run("main"))
+	assert.Empty(t, driver.jobs)
+	require.Len(t, published, 1)
+	assert.Equal(t, PatcherResultTask, published[0].Task)
+	assert.Equal(t, "run-id", published[0].KWArgs.RunID)
+	assert.Equal(t, StatusCodeStaleChangeset, published[0].KWArgs.Status.Code)
+
+	// The branch is at the commit of the changeset.
+	require.NoError(t, run("dev"))
+	assert.Len(t, driver.jobs, 1)
+
+	// The head is not known: the patcher checks for itself.
+	require.NoError(t, run("unknown"))
+	assert.Len(t, driver.jobs, 2)
+	assert.Len(t, published, 1)
+}
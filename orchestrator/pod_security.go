@@ -0,0 +1,118 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/pod-security-admission/api"
+	"k8s.io/pod-security-admission/policy"
+)
+
+var ErrPodSecurityViolation = errors.New("pod spec violates pod security restricted level")
+
+// podSecurity evaluates pods against the Pod Security Standards, with the
+// checks Pod Security Admission runs.
+var podSecurity = func() policy.Evaluator {
+	evaluator, err := policy.NewEvaluator(policy.DefaultChecks())
+	if err != nil {
+		panic(err)
+	}
+	return evaluator
+}()
+
+// CheckPodSecurityRestricted returns the violations of the Pod Security
+// Standards restricted level (which includes baseline) by the pod, as
+// enforced by Pod Security Admission.
+func CheckPodSecurityRestricted(meta *metav1.ObjectMeta, spec *corev1.PodSpec) []string {
+	lv := api.LevelVersion{Level: api.LevelRestricted, Version: api.LatestVersion()}
+	var violations []string
+	for _, result := range podSecurity.EvaluatePod(lv, meta, spec) {
+		if !result.Allowed {
+			violations = append(violations, result.ForbiddenReason+" ("+result.ForbiddenDetail+")")
+		}
+	}
+	return violations
+}
+
+// ValidateSecurityProfile checks that the pods of every kind of job pass the
+// Pod Security Admission restricted level under the profile.  Baseline
+// profiles are not checked.
+func ValidateSecurityProfile(profile *SecurityProfile, opts *TaskOpts) error {
+	if !profile.hardened() {
+		return nil
+	}
+	creators, err := validationJobs(opts)
+	if err != nil {
+		return err
+	}
+	for _, creator := range creators {
+		job, err := (&MarvinK8sJob{JobCreator: creator, Security: profile}).Job()
+		if err != nil {
+			return err
+		}
+		template := job.Spec.Template
+		if violations := CheckPodSecurityRestricted(&template.ObjectMeta, &template.Spec); len(violations) > 0 {
+			return fmt.Errorf("%w: %s: %s job: %s", ErrPodSecurityViolation, profile.Name, creator.Name(), strings.Join(violations, "; "))
+		}
+	}
+	return nil
+}
+
+// validationJobs returns a job of each kind the runner creates, with every
+// mount the options may add.
+func validationJobs(opts *TaskOpts) ([]JobCreator, error) {
+	image := &ResolvedImage{Ref: "validate", PullPolicy: PullPolicyAlways}
+	images := &JobImages{Marvin: image, Coat: image}
+	var signingKey *SigningKey
+	if len(opts.SigningKeys) > 0 {
+		signingKey = opts.SigningKeys[0]
+	}
+
+	analysis, err := NewAnalysisDriverJob(
+		&artifact.AnalysisRun{RunID: "validate", RunSerial: "0"},
+		artifact.Check{
+			CheckSeq:     "0",
+			AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "validate", CPULimit: "1000", MemoryLimit: "1000"},
+		},
+		&AnalysisOpts{
+			MirrorReference:    "validate/validate/validate.git",
+			MirrorClaimName:    opts.MirrorClaimName(),
+			CredentialProfiles: opts.CredentialProfiles,
+			Images:             images,
+			KubernetesOpts:     opts.KubernetesOpts,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	autofix, err := NewAutofixDriverJob(&artifact.AutofixRun{RunID: "validate", RunSerial: "0"}, &AutofixOpts{
+		MirrorReference: "validate/validate/validate.git",
+		MirrorClaimName: opts.MirrorClaimName(),
+		Images:          images,
+		KubernetesOpts:  opts.KubernetesOpts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	transformer, err := NewTransformerJob(&artifact.TransformerRun{RunID: "validate", RunSerial: "0"}, &TransformerOpts{
+		Images:         images,
+		SigningKey:     signingKey,
+		KubernetesOpts: opts.KubernetesOpts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	patcher, err := NewPatcherDriverJob(&artifact.PatcherRun{RunID: "validate", RunSerial: "0"}, &PatcherJobOpts{
+		Images:         images,
+		SigningKey:     signingKey,
+		KubernetesOpts: opts.KubernetesOpts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []JobCreator{analysis, autofix, transformer, patcher}, nil
+}
@@ -0,0 +1,79 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMarvinK8sJob_SecurityProfiles(t *testing.T) {
+	for _, name := range []string{SecurityProfileRestricted, SecurityProfileSandboxed} {
+		t.Run(name, func(t *testing.T) {
+			job, err := (&MarvinK8sJob{JobCreator: &testJob{name: "analysis-s1"}, Security: &SecurityProfile{Name: name}}).Job()
+			require.NoError(t, err)
+			template := job.Spec.Template
+			assert.Empty(t, CheckPodSecurityRestricted(&template.ObjectMeta, &template.Spec))
+			assert.False(t, *template.Spec.AutomountServiceAccountToken)
+			assert.True(t, *template.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem)
+			assert.Contains(t, template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "tmpdir", MountPath: "/tmp"})
+			if name == SecurityProfileSandboxed {
+				assert.Equal(t, DefaultSandboxRuntimeClass, *template.Spec.RuntimeClassName)
+			} else {
+				assert.Nil(t, template.Spec.RuntimeClassName)
+			}
+		})
+	}
+
+	t.Run(SecurityProfileBaseline, func(t *testing.T) {
+		job, err := (&MarvinK8sJob{JobCreator: &testJob{name: "analysis-s1"}}).Job()
+		require.NoError(t, err)
+		template := job.Spec.Template
+		assert.NotEmpty(t, CheckPodSecurityRestricted(&template.ObjectMeta, &template.Spec))
+		assert.True(t, *template.Spec.ShareProcessNamespace)
+		assert.Nil(t, template.Annotations)
+	})
+}
+
+func TestCheckPodSecurityRestricted(t *testing.T) {
+	job, err := (&MarvinK8sJob{JobCreator: &testJob{name: "analysis-s1"}, Security: &SecurityProfile{Name: SecurityProfileRestricted}}).Job()
+	require.NoError(t, err)
+
+	template := job.Spec.Template.DeepCopy()
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name:         "host",
+		VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}},
+	})
+	privileged := true
+	template.Spec.Containers[0].SecurityContext.Privileged = &privileged
+	template.Spec.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"SYS_ADMIN"}
+
+	violations := CheckPodSecurityRestricted(&template.ObjectMeta, &template.Spec)
+	assert.ElementsMatch(t, []string{
+		`restricted volume types (volume "host" uses restricted volume type "hostPath")`,
+		`privileged (container "marvin" must not set securityContext.privileged=true)`,
+		`unrestricted capabilities (container "marvin" must not include "SYS_ADMIN" in securityContext.capabilities.add)`,
+	}, violations)
+}
+
+func TestValidateSecurityProfile(t *testing.T) {
+	opts := &TaskOpts{
+		CredentialProfiles: []*CredentialProfile{{Name: "npm", Type: CredentialProfileNpmrc, SecretName: "npm"}},
+		SigningKeys:        []*SigningKey{{AppID: "app", Format: SigningFormatSSH, SecretName: "signing-key"}},
+		KubernetesOpts:     &KubernetesOpts{Namespace: "runner"},
+	}
+	assert.NoError(t, ValidateSecurityProfile(nil, opts))
+	assert.NoError(t, ValidateSecurityProfile(&SecurityProfile{Name: SecurityProfileRestricted}, opts))
+	assert.NoError(t, ValidateSecurityProfile(&SecurityProfile{Name: SecurityProfileSandboxed, RuntimeClassName: "kata"}, opts))
+}
+
+func TestValidationJobs(t *testing.T) {
+	jobs, err := validationJobs(&TaskOpts{KubernetesOpts: &KubernetesOpts{Namespace: "runner"}})
+	require.NoError(t, err)
+	require.Len(t, jobs, 4)
+	assert.IsType(t, &AnalysisDriverJob{}, jobs[0])
+	assert.IsType(t, &AutofixDriverJob{}, jobs[1])
+	assert.IsType(t, &TransformerJob{}, jobs[2])
+	assert.IsType(t, &PatcherDriverJob{}, jobs[3])
+}
@@ -1,5 +1,85 @@
 package orchestrator
 
+import (
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
 type Provider interface {
 	AuthenticatedRemoteURL(appID, installationID string, srcURL string) (string, error)
 }
+
+// SubmoduleResolver generates credentials for the submodules of a repository
+// that belong to the same installation.
+type SubmoduleResolver interface {
+	SubmoduleCredentials(appID, installationID, srcURL, ref string) ([]string, error)
+}
+
+// BranchResolver resolves the current head of a branch of a repository, and
+// its default branch.
+type BranchResolver interface {
+	BranchHead(appID, installationID, srcURL, branch string) (string, error)
+	DefaultBranch(appID, installationID, srcURL string) (string, error)
+}
+
+// PushProvider generates remote URLs that can only push to one branch.
+type PushProvider interface {
+	PushRemoteURL(appID, installationID, srcURL, branch string) (string, error)
+}
+
+// SubmoduleCredentials returns the git-credential-store entries passed to
+// coat for cloning the submodules of remoteURL at ref.  Failures are logged
+// and yield no credentials, in which case only public submodules can be
+// cloned.
+func (o *TaskOpts) SubmoduleCredentials(appID, installationID, remoteURL, ref string) string {
+	if o.Submodules == nil {
+		return ""
+	}
+	credentials, err := o.Submodules.SubmoduleCredentials(appID, installationID, remoteURL, ref)
+	if err != nil {
+		slog.Warn("failed to generate submodule credentials", slog.Any("err", err))
+		return ""
+	}
+	return strings.Join(credentials, "\n")
+}
+
+// BranchHead returns the commit at the head of the branch of remoteURL.
+// Failures are logged and yield an empty commit, in which case the head is
+// not known.
+func (o *TaskOpts) BranchHead(appID, installationID, remoteURL, branch string) string {
+	if o.Branches == nil || branch == "" {
+		return ""
+	}
+	head, err := o.Branches.BranchHead(appID, installationID, remoteURL, branch)
+	if err != nil {
+		slog.Warn("failed to resolve branch head", slog.String("branch", branch), slog.Any("err", err))
+		return ""
+	}
+	return head
+}
+
+// DefaultBranch returns the default branch of the repository of remoteURL.
+// Failures are logged and yield an empty branch, in which case the default
+// branch is not known.
+func (o *TaskOpts) DefaultBranch(appID, installationID, remoteURL string) string {
+	if o.Branches == nil {
+		return ""
+	}
+	branch, err := o.Branches.DefaultBranch(appID, installationID, remoteURL)
+	if err != nil {
+		slog.Warn("failed to resolve default branch", slog.Any("err", err))
+		return ""
+	}
+	return branch
+}
+
+// PushRemoteURL returns the remote URL for a job pushing to branch.  Through
+// the git proxy, the job can only push to that branch.  Otherwise, it is the
+// provider's remote URL, which can push to any branch.
+func (o *TaskOpts) PushRemoteURL(provider Provider, appID, installationID, remoteURL, branch string) (string, error) {
+	if o.Pushes == nil {
+		return provider.AuthenticatedRemoteURL(appID, installationID, remoteURL)
+	}
+	return o.Pushes.PushRemoteURL(appID, installationID, remoteURL, branch)
+}
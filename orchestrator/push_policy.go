@@ -0,0 +1,112 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+)
+
+// ErrPushPolicy is wrapped by the violations of the push policy.
+var ErrPushPolicy = errors.New("push policy violation")
+
+// PushPolicy restricts the pushes of transformer and patcher jobs.  It is
+// checked before the jobs are created, and violations are reported as the
+// result of the run.  With the git proxy, jobs can then only push to the
+// branch that was checked.
+type PushPolicy struct {
+	// ProtectedBranches are globs of the branches never pushed to, as
+	// matched by path.Match.
+	ProtectedBranches []string
+
+	// ProtectDefaultBranch forbids pushing to the default branch of the
+	// repository, as resolved through the provider.
+	ProtectDefaultBranch bool
+
+	// RequirePullRequest forbids pushing to the branch the changes are based
+	// on, so that they can only land through a pull request.
+	RequirePullRequest bool
+
+	// MaxFiles and MaxPatches limit the files changed and the patches
+	// applied by a push.  Zero is unlimited.
+	MaxFiles   int
+	MaxPatches int
+}
+
+// Push is a push checked against the policy.  Files and Patches are
+// negative when not known before the job runs.  DefaultBranch is empty when
+// not known.
+type Push struct {
+	Branch        string
+	BaseBranch    string
+	DefaultBranch string
+	Files         int
+	Patches       int
+}
+
+// ProtectsDefaultBranch reports whether checking a push needs the default
+// branch of the repository.
+func (p *PushPolicy) ProtectsDefaultBranch() bool {
+	return p != nil && p.ProtectDefaultBranch
+}
+
+// CheckBranch returns an error wrapping ErrPushPolicy if the branch of the
+// push violates the policy.  A nil policy allows every push.
+func (p *PushPolicy) CheckBranch(push *Push) error {
+	if p == nil {
+		return nil
+	}
+	for _, pattern := range p.ProtectedBranches {
+		if ok, _ := path.Match(pattern, push.Branch); ok {
+			return fmt.Errorf("%w: branch %s is protected", ErrPushPolicy, push.Branch)
+		}
+	}
+	if p.ProtectDefaultBranch {
+		if push.DefaultBranch == "" {
+			return fmt.Errorf("%w: the default branch of the repository is not known", ErrPushPolicy)
+		}
+		if push.Branch == push.DefaultBranch {
+			return fmt.Errorf("%w: branch %s is the default branch", ErrPushPolicy, push.Branch)
+		}
+	}
+	if p.RequirePullRequest && push.Branch == push.BaseBranch {
+		return fmt.Errorf("%w: changes to %s require a pull request", ErrPushPolicy, push.BaseBranch)
+	}
+	return nil
+}
+
+// Check returns an error wrapping ErrPushPolicy if the branch or the size of
+// the push violates the policy.  Pushes of unknown size are rejected when
+// the size is limited.  A nil policy allows every push.
+func (p *PushPolicy) Check(push *Push) error {
+	if err := p.CheckBranch(push); err != nil || p == nil {
+		return err
+	}
+	if p.MaxFiles > 0 {
+		if push.Files < 0 {
+			return fmt.Errorf("%w: the number of files changed is not known, at most %d allowed", ErrPushPolicy, p.MaxFiles)
+		}
+		if push.Files > p.MaxFiles {
+			return fmt.Errorf("%w: %d files changed, at most %d allowed", ErrPushPolicy, push.Files, p.MaxFiles)
+		}
+	}
+	if p.MaxPatches > 0 {
+		if push.Patches < 0 {
+			return fmt.Errorf("%w: the number of patches is not known, at most %d allowed", ErrPushPolicy, p.MaxPatches)
+		}
+		if push.Patches > p.MaxPatches {
+			return fmt.Errorf("%w: %d patches, at most %d allowed", ErrPushPolicy, push.Patches, p.MaxPatches)
+		}
+	}
+	return nil
+}
+
+// pushRejectedStatus is the status of runs rejected by the push policy.
+func pushRejectedStatus(err error) artifact.Status {
+	return artifact.Status{
+		Code:     StatusCodePushRejected,
+		HMessage: "The changes were not pushed, as the runner's push policy does not allow it",
+		Err:      err.Error(),
+	}
+}
@@ -0,0 +1,195 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushPolicy_Check(t *testing.T) {
+	policy := &PushPolicy{
+		ProtectedBranches:  []string{"main", "release/*"},
+		RequirePullRequest: true,
+		MaxFiles:           2,
+		MaxPatches:         5,
+	}
+	tests := []struct {
+		name    string
+		push    *Push
+		allowed bool
+	}{
+		{"new branch", &Push{Branch: "deepsource/fix", BaseBranch: "develop", Files: 2, Patches: 5}, true},
+		{"unknown files", &Push{Branch: "deepsource/fix", BaseBranch: "develop", Files: -1}, false},
+		{"unknown patches", &Push{Branch: "deepsource/fix", BaseBranch: "develop", Patches: -1}, false},
+		{"protected", &Push{Branch: "main", BaseBranch: "develop"}, false},
+		{"protected glob", &Push{Branch: "release/1.0", BaseBranch: "develop"}, false},
+		{"not a pull request", &Push{Branch: "develop", BaseBranch: "develop"}, false},
+		{"too many files", &Push{Branch: "deepsource/fix", BaseBranch: "develop", Files: 3}, false},
+		{"too many patches", &Push{Branch: "deepsource/fix", BaseBranch: "develop", Patches: 6}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.push)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrPushPolicy)
+			}
+		})
+	}
+
+	// Branch checks leave the size to the job.
+	assert.NoError(t, policy.CheckBranch(&Push{Branch: "deepsource/fix", BaseBranch: "develop", Files: -1, Patches: -1}))
+	assert.ErrorIs(t, policy.CheckBranch(&Push{Branch: "main", BaseBranch: "develop", Files: -1, Patches: -1}), ErrPushPolicy)
+
+	defaults := &PushPolicy{ProtectDefaultBranch: true}
+	assert.True(t, defaults.ProtectsDefaultBranch())
+	assert.NoError(t, defaults.Check(&Push{Branch: "main", DefaultBranch: "trunk"}))
+	assert.ErrorIs(t, defaults.Check(&Push{Branch: "trunk", DefaultBranch: "trunk"}), ErrPushPolicy)
+	assert.ErrorIs(t, defaults.Check(&Push{Branch: "main"}), ErrPushPolicy, "unknown default branches are protected")
+
+	var none *PushPolicy
+	assert.False(t, none.ProtectsDefaultBranch())
+	assert.NoError(t, none.Check(&Push{Branch: "main", BaseBranch: "main", Files: -1, Patches: -1}))
+}
+
+// testPushes records the branches of the push remote URLs it generates.
+type testPushes struct {
+	branches []string
+}
+
+func (p *testPushes) PushRemoteURL(_, _, srcURL, branch string) (string, error) {
+	p.branches = append(p.branches, branch)
+	return srcURL + "#" + branch, nil
+}
+
+func TestPushPolicy_DefaultBranch(t *testing.T) {
+	var published int
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { published++ }))
+	defer server.Close()
+
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	driver := &testDriver{}
+	pushes := &testPushes{}
+	opts := &TaskOpts{
+		RemoteHost:     server.URL,
+		KubernetesOpts: &KubernetesOpts{ImageURL: *registry},
+		PushPolicy:     &PushPolicy{ProtectDefaultBranch: true},
+		Branches:       testBranches{"HEAD": "trunk"},
+		Pushes:         pushes,
+	}
+	patcher := NewPatcherTask(&Runner{ID: "runner-id"}, opts, driver, testProvider{}, testSigner{})
+	transformer := NewTransformerTask(&Runner{ID: "runner-id"}, opts, driver, testProvider{}, testSigner{})
+
+	patch := func(branch string) error {
+		return patcher.Run(context.Background(), &PatcherRunRequest{
+			AppID: "app-id",
+			Run: &artifact.PatcherRun{
+				RunID:     "patcher-run",
+				VCSMeta:   artifact.PatcherVCSMeta{RemoteURL: "https://github.com/acme/app.git", BaseBranch: branch},
+				PatchMeta: "{}",
+			},
+		})
+	}
+	transform := func(branch string) error {
+		return transformer.Run(context.Background(), &TransformerRunRequest{
+			AppID: "app-id",
+			Run: &artifact.TransformerRun{
+				RunID:   "transformer-run",
+				VCSMeta: artifact.TransformerVCSMeta{RemoteURL: "https://github.com/acme/app.git", BaseBranch: branch},
+			},
+		})
+	}
+
+	require.NoError(t, patch("trunk"))
+	require.NoError(t, transform("trunk"))
+	assert.Empty(t, driver.jobs)
+	assert.Equal(t, 2, published)
+
+	// Jobs get remote URLs that can only push to the checked branch.
+	require.NoError(t, patch("main"))
+	require.NoError(t, transform("develop"))
+	require.Len(t, driver.jobs, 2)
+	assert.Equal(t, []string{"main", "develop"}, pushes.branches)
+	assert.Equal(t, "https://github.com/acme/app.git#main", driver.jobs[0].(*PatcherDriverJob).run.VCSMeta.RemoteURL)
+	assert.Equal(t, "https://github.com/acme/app.git#develop", driver.jobs[1].(*TransformerJob).run.VCSMeta.RemoteURL)
+
+	// Without a provider resolving it, the default branch is not known.
+	opts.Branches = nil
+	require.NoError(t, patch("main"))
+	assert.Len(t, driver.jobs, 2)
+	assert.Equal(t, 3, published)
+}
+
+func TestPushPolicy_Tasks(t *testing.T) {
+	var published []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payload["path"] = r.URL.Path
+		published = append(published, payload)
+	}))
+	defer server.Close()
+
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	driver := &testDriver{}
+	opts := &TaskOpts{
+		RemoteHost:     server.URL,
+		KubernetesOpts: &KubernetesOpts{ImageURL: *registry},
+		PushPolicy:     &PushPolicy{ProtectedBranches: []string{"main"}, MaxFiles: 1},
+	}
+	patcher := NewPatcherTask(&Runner{ID: "runner-id"}, opts, driver, testProvider{}, testSigner{})
+	transformer := NewTransformerTask(&Runner{ID: "runner-id"}, opts, driver, testProvider{}, testSigner{})
+
+	patch := func(files int, branch string) error {
+		meta := artifact.PatchMeta{PatchCommit: artifact.PatchCommit{Commit: artifact.Commit{DestinationBranch: branch}}}
+		for i := 0; i < files; i++ {
+			meta.Patches = append(meta.Patches, artifact.PatchData{PatchIDs: []string{"1"}})
+		}
+		metaJSON, _ := json.Marshal(meta)
+		return patcher.Run(context.Background(), &PatcherRunRequest{
+			AppID: "app-id",
+			Run: &artifact.PatcherRun{
+				RunID:     "patcher-run",
+				VCSMeta:   artifact.PatcherVCSMeta{BaseBranch: "develop"},
+				PatchMeta: string(metaJSON),
+			},
+		})
+	}
+
+	require.NoError(t, patch(2, "deepsource/fix"))
+	require.NoError(t, patch(1, "main"))
+	require.NoError(t, patch(1, "deepsource/fix"))
+	assert.Len(t, driver.jobs, 1)
+
+	transform := func(branch string) error {
+		return transformer.Run(context.Background(), &TransformerRunRequest{
+			AppID: "app-id",
+			Run: &artifact.TransformerRun{
+				RunID:       "transformer-run",
+				VCSMeta:     artifact.TransformerVCSMeta{BaseBranch: "main"},
+				PatchCommit: artifact.PatchCommit{Commit: artifact.Commit{DestinationBranch: branch}},
+			},
+		})
+	}
+	// Without a destination branch, the transformer pushes to its base.
+	require.NoError(t, transform(""))
+	// The size of transformer pushes is unknown, so size limits reject them.
+	require.NoError(t, transform("deepsource/transform"))
+	assert.Len(t, driver.jobs, 1)
+
+	require.Len(t, published, 4)
+	for i, path := range []string{patcherPublishPath, patcherPublishPath, transformerPublishPath, transformerPublishPath} {
+		assert.Equal(t, path, published[i]["path"])
+		status := published[i]["kwargs"].(map[string]interface{})["status"].(map[string]interface{})
+		assert.Equal(t, float64(StatusCodePushRejected), status["code"])
+		assert.Contains(t, status["err"], ErrPushPolicy.Error())
+	}
+}
@@ -0,0 +1,396 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/deepsourcecorp/runner/notify"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	DefaultQuotaRetryAfter  = 30 * time.Second
+	DefaultQuotaReservation = time.Minute
+)
+
+var (
+	// ErrQuotaExceeded is returned when the namespace has no room for jobs
+	// right now.  The jobs fit once running jobs finish.
+	ErrQuotaExceeded = errors.New("namespace quota exceeded")
+
+	// ErrJobTooLarge is returned for jobs that never fit the namespace quota
+	// or limit range.
+	ErrJobTooLarge = errors.New("job exceeds namespace quota or limit range")
+)
+
+// jobTooLargeStatus is the status reported for runs whose jobs never fit the
+// namespace.
+func jobTooLargeStatus(err error) artifact.Status {
+	return artifact.Status{
+		Code:     StatusCodeJobTooLarge,
+		HMessage: "Job exceeds the resource quota of the runner",
+		Err:      err.Error(),
+	}
+}
+
+// QuotaExceededError is returned by Quota.Admit when the jobs do not fit the
+// current headroom of the namespace.
+type QuotaExceededError struct {
+	// Resources are the quota resources the jobs do not fit in.
+	Resources []string
+
+	// RetryAfter is how long to wait before submitting the jobs again.
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrQuotaExceeded, strings.Join(e.Resources, ", "))
+}
+
+func (*QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+type QuotaOpts struct {
+	Namespace string
+
+	// RetryAfter is the delay suggested to clients of rejected jobs.
+	// Defaults to DefaultQuotaRetryAfter.
+	RetryAfter time.Duration
+
+	// Reservation is how long admitted jobs count against the headroom
+	// before their pods show up in the quota usage.  Defaults to
+	// DefaultQuotaReservation.
+	Reservation time.Duration
+
+	// Notifier is notified of rejected jobs.  Nil drops notifications.
+	Notifier *notify.Notifier
+}
+
+// Quota admits jobs against the ResourceQuotas and LimitRanges of the
+// namespace.  Jobs are created even when their pods do not fit the quota,
+// and then stay pending until their deadline; Quota rejects them upfront
+// instead.
+type Quota struct {
+	clientset kubernetes.Interface
+	opts      *QuotaOpts
+
+	mu           sync.Mutex
+	reservations []*quotaReservation
+	now          func() time.Time
+}
+
+type quotaReservation struct {
+	usage   corev1.ResourceList
+	expires time.Time
+}
+
+// QuotaHeadroom is the state of a ResourceQuota of the namespace.
+type QuotaHeadroom struct {
+	Name      string            `json:"name"`
+	Hard      map[string]string `json:"hard"`
+	Used      map[string]string `json:"used"`
+	Reserved  map[string]string `json:"reserved"`
+	Available map[string]string `json:"available"`
+}
+
+func NewQuota(clientset kubernetes.Interface, opts *QuotaOpts) *Quota {
+	if opts.RetryAfter == 0 {
+		opts.RetryAfter = DefaultQuotaRetryAfter
+	}
+	if opts.Reservation == 0 {
+		opts.Reservation = DefaultQuotaReservation
+	}
+	return &Quota{
+		clientset: clientset,
+		opts:      opts,
+		now:       time.Now,
+	}
+}
+
+// NewK8sQuota returns a Quota for the cluster the runner runs in.
+func NewK8sQuota(tokenPath string, opts *QuotaOpts) (*Quota, error) {
+	clientset, err := newK8sClientset(tokenPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewQuota(clientset, opts), nil
+}
+
+// Admit checks that the pods of the jobs fit in the namespace, and reserves
+// their resources until they are accounted for in the quota usage.  A nil
+// Quota admits every job.
+func (q *Quota) Admit(ctx context.Context, jobs ...JobCreator) error {
+	if q == nil || len(jobs) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	limitRanges, err := q.clientset.CoreV1().LimitRanges(q.opts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	quotas, err := q.clientset.CoreV1().ResourceQuotas(q.opts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	requested := corev1.ResourceList{}
+	for _, job := range jobs {
+		j, err := (&MarvinK8sJob{JobCreator: job}).Job()
+		if err != nil {
+			return err
+		}
+		spec := &j.Spec.Template.Spec
+		if err := checkLimitRanges(limitRanges.Items, spec); err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrJobTooLarge, job.Name(), err)
+		}
+		addResources(requested, podUsage(limitRanges.Items, spec))
+	}
+
+	reserved := q.reserved()
+	var exceeded []string
+	for i := range quotas.Items {
+		quota := &quotas.Items[i]
+		if !quotaApplies(quota) {
+			continue
+		}
+		hard, used := quotaHardUsed(quota)
+		for name, limit := range hard {
+			want, ok := requested[name]
+			if !ok {
+				continue
+			}
+			if want.Cmp(limit) > 0 {
+				return fmt.Errorf("%w: %s needs %s, quota %s allows %s", ErrJobTooLarge, name, want.String(), quota.Name, limit.String())
+			}
+			total := used[name].DeepCopy()
+			total.Add(reserved[name])
+			total.Add(want)
+			if total.Cmp(limit) > 0 {
+				exceeded = append(exceeded, quota.Name+"/"+string(name))
+			}
+		}
+	}
+	if len(exceeded) > 0 {
+		sort.Strings(exceeded)
+		err := &QuotaExceededError{Resources: exceeded, RetryAfter: q.opts.RetryAfter}
+		q.opts.Notifier.Notify(&notify.Notification{
+			Type:    notify.TypeQuotaExhausted,
+			Title:   fmt.Sprintf("Namespace %s is out of quota", q.opts.Namespace),
+			Message: err.Error(),
+			Fields:  map[string]string{"namespace": q.opts.Namespace, "quota": "namespace"},
+		})
+		return err
+	}
+
+	q.reservations = append(q.reservations, &quotaReservation{
+		usage:   requested,
+		expires: q.now().Add(q.opts.Reservation),
+	})
+	return nil
+}
+
+// Headroom returns the state of the ResourceQuotas of the namespace.
+func (q *Quota) Headroom(ctx context.Context) ([]*QuotaHeadroom, error) {
+	quotas, err := q.clientset.CoreV1().ResourceQuotas(q.opts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	reserved := q.reserved()
+	q.mu.Unlock()
+
+	headroom := []*QuotaHeadroom{}
+	for i := range quotas.Items {
+		quota := &quotas.Items[i]
+		if !quotaApplies(quota) {
+			continue
+		}
+		hard, used := quotaHardUsed(quota)
+		h := &QuotaHeadroom{
+			Name:      quota.Name,
+			Hard:      make(map[string]string),
+			Used:      make(map[string]string),
+			Reserved:  make(map[string]string),
+			Available: make(map[string]string),
+		}
+		for name, limit := range hard {
+			available := limit.DeepCopy()
+			u := used[name]
+			r := reserved[name]
+			available.Sub(u)
+			available.Sub(r)
+			if available.Sign() < 0 {
+				available = resource.Quantity{}
+			}
+			h.Hard[string(name)] = limit.String()
+			h.Used[string(name)] = u.String()
+			h.Reserved[string(name)] = r.String()
+			h.Available[string(name)] = available.String()
+		}
+		headroom = append(headroom, h)
+	}
+	return headroom, nil
+}
+
+// reserved returns the resources of the reservations that did not expire,
+// dropping the expired ones.  q.mu must be held.
+func (q *Quota) reserved() corev1.ResourceList {
+	now := q.now()
+	reserved := corev1.ResourceList{}
+	active := q.reservations[:0]
+	for _, r := range q.reservations {
+		if now.Before(r.expires) {
+			active = append(active, r)
+			addResources(reserved, r.usage)
+		}
+	}
+	q.reservations = active
+	return reserved
+}
+
+// quotaApplies reports whether the quota restricts job pods.  Job pods have
+// no pod deadline, priority class or cross-namespace affinity, and are not
+// best effort.
+func quotaApplies(quota *corev1.ResourceQuota) bool {
+	if quota.Spec.ScopeSelector != nil {
+		return false
+	}
+	for _, scope := range quota.Spec.Scopes {
+		if scope != corev1.ResourceQuotaScopeNotTerminating && scope != corev1.ResourceQuotaScopeNotBestEffort {
+			return false
+		}
+	}
+	return true
+}
+
+// quotaHardUsed returns the limits and usage of the quota.  The quota
+// controller may not have filled in the status of a new quota yet.
+func quotaHardUsed(quota *corev1.ResourceQuota) (hard, used corev1.ResourceList) {
+	hard = quota.Status.Hard
+	if hard == nil {
+		hard = quota.Spec.Hard
+	}
+	used = quota.Status.Used
+	if used == nil {
+		used = corev1.ResourceList{}
+	}
+	return hard, used
+}
+
+// podUsage returns the quota usage of a job pod, after the defaults of the
+// limit ranges are applied to its containers.
+func podUsage(limitRanges []corev1.LimitRange, spec *corev1.PodSpec) corev1.ResourceList {
+	requests, limits := corev1.ResourceList{}, corev1.ResourceList{}
+	for i := range spec.Containers {
+		r, l := containerResources(limitRanges, &spec.Containers[i])
+		addResources(requests, r)
+		addResources(limits, l)
+	}
+	// Init containers run before the containers, so the pod needs the
+	// larger of the two.
+	for i := range spec.InitContainers {
+		r, l := containerResources(limitRanges, &spec.InitContainers[i])
+		maxResources(requests, r)
+		maxResources(limits, l)
+	}
+
+	usage := corev1.ResourceList{
+		corev1.ResourcePods:                     resource.MustParse("1"),
+		corev1.ResourceName("count/pods"):       resource.MustParse("1"),
+		corev1.ResourceName("count/jobs.batch"): resource.MustParse("1"),
+	}
+	for name, q := range requests {
+		usage[name] = q
+		usage[corev1.ResourceName("requests."+string(name))] = q
+	}
+	for name, q := range limits {
+		usage[corev1.ResourceName("limits."+string(name))] = q
+	}
+	return usage
+}
+
+// containerResources returns the requests and limits of the container.
+// Missing limits default to the limit range default, and missing requests
+// to the limit range default request, or else to the limit.
+func containerResources(limitRanges []corev1.LimitRange, c *corev1.Container) (requests, limits corev1.ResourceList) {
+	requests, limits = c.Resources.Requests.DeepCopy(), c.Resources.Limits.DeepCopy()
+	if requests == nil {
+		requests = corev1.ResourceList{}
+	}
+	if limits == nil {
+		limits = corev1.ResourceList{}
+	}
+	for _, lr := range limitRanges {
+		for _, item := range lr.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			for name, q := range item.Default {
+				if _, ok := limits[name]; !ok {
+					limits[name] = q
+				}
+			}
+			for name, q := range item.DefaultRequest {
+				if _, ok := requests[name]; !ok {
+					requests[name] = q
+				}
+			}
+		}
+	}
+	for name, q := range limits {
+		if _, ok := requests[name]; !ok {
+			requests[name] = q
+		}
+	}
+	return requests, limits
+}
+
+// checkLimitRanges checks the limits of the containers against the maximum
+// of the limit ranges.
+func checkLimitRanges(limitRanges []corev1.LimitRange, spec *corev1.PodSpec) error {
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, lr := range limitRanges {
+		for _, item := range lr.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			for i := range containers {
+				_, limits := containerResources(limitRanges, &containers[i])
+				for name, max := range item.Max {
+					if limit, ok := limits[name]; ok && limit.Cmp(max) > 0 {
+						return fmt.Errorf("container %s %s limit %s exceeds %s maximum %s", containers[i].Name, name, limit.String(), lr.Name, max.String())
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func addResources(dst, src corev1.ResourceList) {
+	for name, q := range src {
+		sum := dst[name].DeepCopy()
+		sum.Add(q)
+		dst[name] = sum
+	}
+}
+
+func maxResources(dst, src corev1.ResourceList) {
+	for name, q := range src {
+		if cur, ok := dst[name]; !ok || q.Cmp(cur) > 0 {
+			dst[name] = q
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/deepsourcecorp/runner/usage"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testResourceQuota(name, hardCPU, usedCPU string, scopes ...corev1.ResourceQuotaScope) *corev1.ResourceQuota {
+	hard := corev1.ResourceList{
+		corev1.ResourceRequestsCPU: resource.MustParse(hardCPU),
+		corev1.ResourcePods:        resource.MustParse("10"),
+	}
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "runner"},
+		Spec:       corev1.ResourceQuotaSpec{Hard: hard, Scopes: scopes},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: hard,
+			Used: corev1.ResourceList{
+				corev1.ResourceRequestsCPU: resource.MustParse(usedCPU),
+				corev1.ResourcePods:        resource.MustParse("2"),
+			},
+		},
+	}
+}
+
+func TestQuota_Admit(t *testing.T) {
+	t.Run("admitted jobs are reserved", func(t *testing.T) {
+		quota := NewQuota(fake.NewSimpleClientset(testResourceQuota("compute", "1", "800m")), &QuotaOpts{Namespace: "runner"})
+		now := time.Now()
+		quota.now = func() time.Time { return now }
+
+		// Pods need the larger of the init container and container requests.
+		require.NoError(t, quota.Admit(context.Background(), &testJob{name: "analysis-s1"}, &testJob{name: "analysis-s2"}))
+
+		err := quota.Admit(context.Background(), &testJob{name: "analysis-s3"})
+		var quotaErr *QuotaExceededError
+		require.ErrorAs(t, err, &quotaErr)
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.Equal(t, []string{"compute/requests.cpu"}, quotaErr.Resources)
+		assert.Equal(t, DefaultQuotaRetryAfter, quotaErr.RetryAfter)
+
+		now = now.Add(DefaultQuotaReservation)
+		assert.NoError(t, quota.Admit(context.Background(), &testJob{name: "analysis-s3"}))
+	})
+
+	t.Run("job too large", func(t *testing.T) {
+		quota := NewQuota(fake.NewSimpleClientset(testResourceQuota("compute", "50m", "0")), &QuotaOpts{Namespace: "runner"})
+		err := quota.Admit(context.Background(), &testJob{name: "analysis-s1"})
+		assert.ErrorIs(t, err, ErrJobTooLarge)
+		assert.NotErrorIs(t, err, ErrQuotaExceeded)
+	})
+
+	t.Run("limit range maximum", func(t *testing.T) {
+		limitRange := &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{Name: "limits", Namespace: "runner"},
+			Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+				Type: corev1.LimitTypeContainer,
+				Max:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+			}}},
+		}
+		quota := NewQuota(fake.NewSimpleClientset(limitRange), &QuotaOpts{Namespace: "runner"})
+		err := quota.Admit(context.Background(), &testJob{name: "analysis-s1"})
+		assert.ErrorIs(t, err, ErrJobTooLarge)
+	})
+
+	t.Run("scoped quotas", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(
+			testResourceQuota("terminating", "1", "1", corev1.ResourceQuotaScopeTerminating),
+			testResourceQuota("best-effort", "1", "1", corev1.ResourceQuotaScopeBestEffort),
+		)
+		quota := NewQuota(clientset, &QuotaOpts{Namespace: "runner"})
+		assert.NoError(t, quota.Admit(context.Background(), &testJob{name: "analysis-s1"}))
+	})
+
+	t.Run("nil quota", func(t *testing.T) {
+		var quota *Quota
+		assert.NoError(t, quota.Admit(context.Background(), &testJob{name: "analysis-s1"}))
+	})
+}
+
+func TestQuota_Headroom(t *testing.T) {
+	quota := NewQuota(fake.NewSimpleClientset(testResourceQuota("compute", "1", "800m")), &QuotaOpts{Namespace: "runner"})
+	require.NoError(t, quota.Admit(context.Background(), &testJob{name: "analysis-s1"}))
+
+	headroom, err := quota.Headroom(context.Background())
+	require.NoError(t, err)
+	require.Len(t, headroom, 1)
+	assert.Equal(t, "compute", headroom[0].Name)
+	assert.Equal(t, "100m", headroom[0].Reserved["requests.cpu"])
+	assert.Equal(t, "100m", headroom[0].Available["requests.cpu"])
+	assert.Equal(t, "7", headroom[0].Available["pods"])
+}
+
+func TestTaskError(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+
+	err := taskError(c, &QuotaExceededError{Resources: []string{"compute/pods"}, RetryAfter: 45 * time.Second})
+	var httpErr *httperror.Error
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+	assert.Equal(t, "45", rec.Header().Get(echo.HeaderRetryAfter))
+
+	err = taskError(c, &usage.QuotaError{AppID: "app-id", Period: "daily", UsedMinutes: 121, LimitMinutes: 120})
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusTooManyRequests, httpErr.Code)
+	assert.Equal(t, "app app-id exceeded its daily quota of 120 job-minutes (121 used)", httpErr.Message)
+
+	err = taskError(c, errors.New("boom"))
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusInternalServerError, httpErr.Code)
+}
+
+func TestJobTooLarge_Tasks(t *testing.T) {
+	var published []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payload["path"] = r.URL.Path
+		published = append(published, payload)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"analyzers": {"deny": ["ruby"]}}`), 0o600))
+	policy, err := NewPolicyFile(path, testPolicyParser)
+	require.NoError(t, err)
+
+	registry, _ := url.Parse("https://registry.deepsource.io")
+	driver := &testDriver{}
+	opts := &TaskOpts{
+		RemoteHost:     server.URL,
+		KubernetesOpts: &KubernetesOpts{ImageURL: *registry},
+		OrgPolicy:      policy,
+	}
+	runner := &Runner{ID: "runner-id"}
+	analysis := NewAnalysisTask(runner, opts, driver, testProvider{}, testSigner{})
+	autofix := NewAutofixTask(runner, opts, driver, testProvider{}, testSigner{})
+	analyze := func() error {
+		return analysis.Run(context.Background(), &AnalysisRunRequest{
+			Run: &artifact.AnalysisRun{
+				RunID:   "analysis-run",
+				VCSMeta: artifact.AnalysisRunVCSMeta{RemoteURL: "https://github.com/acme/api"},
+				Checks: []artifact.Check{
+					{CheckSeq: "1", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "python", AnalyzerType: "core", Version: "v1", CPULimit: "100", MemoryLimit: "100"}},
+					{CheckSeq: "2", AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "ruby", AnalyzerType: "core", Version: "v1"}},
+				},
+			},
+		})
+	}
+	statusCode := func(i int) float64 {
+		status := published[i]["kwargs"].(map[string]interface{})["status"].(map[string]interface{})
+		return status["code"].(float64)
+	}
+
+	t.Run("rejected checks are reported before admission", func(t *testing.T) {
+		published = nil
+		opts.Quota = NewQuota(fake.NewSimpleClientset(testResourceQuota("compute", "10", "10")), &QuotaOpts{Namespace: "runner"})
+		assert.ErrorIs(t, analyze(), ErrQuotaExceeded)
+		require.Len(t, published, 1)
+		assert.Equal(t, float64(StatusCodePolicyDenied), statusCode(0))
+	})
+
+	t.Run("jobs too large are concluded", func(t *testing.T) {
+		published = nil
+		opts.Quota = NewQuota(fake.NewSimpleClientset(testResourceQuota("compute", "50m", "0")), &QuotaOpts{Namespace: "runner"})
+		require.NoError(t, analyze())
+		require.NoError(t, autofix.Run(context.Background(), &AutofixRunRequest{
+			Run: &artifact.AutofixRun{
+				RunID:     "autofix-run",
+				VCSMeta:   artifact.AutofixVCSMeta{RemoteURL: "https://github.com/acme/api"},
+				Autofixer: artifact.Autofixer{AutofixMeta: artifact.AutofixMeta{Shortcode: "python", Version: "v1"}},
+			},
+		}))
+		assert.Empty(t, driver.jobs)
+
+		wantPaths := []string{analysisPublishPath, analysisPublishPath, autofixPublishPath}
+		wantCodes := []int{StatusCodePolicyDenied, StatusCodeJobTooLarge, StatusCodeJobTooLarge}
+		require.Len(t, published, len(wantPaths))
+		for i := range wantPaths {
+			assert.Equal(t, wantPaths[i], published[i]["path"])
+			assert.Equal(t, float64(wantCodes[i]), statusCode(i))
+		}
+	})
+}
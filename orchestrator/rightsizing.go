@@ -0,0 +1,107 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/deepsourcecorp/runner/rightsizing"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/rest"
+)
+
+// metricsPath is the path of the pod metrics of the Kubernetes metrics API.
+const metricsPath = "/apis/metrics.k8s.io/v1beta1/namespaces"
+
+// LimitRecommender returns the limits analysis jobs of an analyzer run with
+// instead of those of the analyzer.
+type LimitRecommender interface {
+	Limits(analyzer string) (cpuMillis, memoryMiB int64, ok bool)
+}
+
+// UsageSampler samples the usage of an analysis pod once its analyzer
+// completes, so that pods shorter than the sample interval are sampled too.
+type UsageSampler interface {
+	SamplePod(pod string)
+}
+
+// RightSize replaces the limits of the check's analyzer with the recommended
+// ones, when there are.
+func (o *TaskOpts) RightSize(check *artifact.Check) {
+	if o.Rightsizing == nil {
+		return
+	}
+	cpu, memory, ok := o.Rightsizing.Limits(check.AnalyzerMeta.Shortcode)
+	if !ok {
+		return
+	}
+	check.AnalyzerMeta.CPULimit = strconv.FormatInt(cpu, 10)
+	check.AnalyzerMeta.MemoryLimit = strconv.FormatInt(memory, 10)
+}
+
+// K8sPodMetrics reads the usage of the analyzer containers of analysis pods
+// from the Kubernetes metrics API.
+type K8sPodMetrics struct {
+	client    rest.Interface
+	namespace string
+}
+
+func NewK8sPodMetrics(tokenPath, namespace string) (*K8sPodMetrics, error) {
+	clientset, err := newK8sClientset(tokenPath)
+	if err != nil {
+		return nil, err
+	}
+	return &K8sPodMetrics{client: clientset.CoreV1().RESTClient(), namespace: namespace}, nil
+}
+
+// podMetricsList is the subset of the metrics.k8s.io PodMetricsList the
+// runner reads.
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+		Containers []struct {
+			Name  string                       `json:"name"`
+			Usage map[string]resource.Quantity `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+func (m *K8sPodMetrics) PodMetrics(ctx context.Context) ([]*rightsizing.PodMetrics, error) {
+	body, err := m.client.Get().
+		AbsPath(metricsPath, m.namespace, "pods").
+		Param("labelSelector", LabelNameRole+"=analysis").
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	var list podMetricsList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+
+	var metrics []*rightsizing.PodMetrics
+	for _, item := range list.Items {
+		analyzer := item.Metadata.Labels[LabelNameAnalyzer]
+		if analyzer == "" {
+			continue
+		}
+		for _, c := range item.Containers {
+			if c.Name != "marvin" {
+				continue
+			}
+			cpu, memory := c.Usage["cpu"], c.Usage["memory"]
+			metrics = append(metrics, &rightsizing.PodMetrics{
+				Pod:       item.Metadata.Name,
+				Analyzer:  analyzer,
+				CPUMillis: cpu.MilliValue(),
+				Memory:    memory.Value(),
+			})
+		}
+	}
+	return metrics, nil
+}
@@ -0,0 +1,77 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/deepsourcecorp/runner/rightsizing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/scheme"
+	restfake "k8s.io/client-go/rest/fake"
+)
+
+type testLimitRecommender map[string][2]int64
+
+func (r testLimitRecommender) Limits(analyzer string) (int64, int64, bool) {
+	l, ok := r[analyzer]
+	return l[0], l[1], ok
+}
+
+func TestTaskOpts_RightSize(t *testing.T) {
+	opts := &TaskOpts{Rightsizing: testLimitRecommender{"python": {1500, 3072}}}
+
+	check := &artifact.Check{AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "python", CPULimit: "4000", MemoryLimit: "8192"}}
+	opts.RightSize(check)
+	assert.Equal(t, "1500", check.AnalyzerMeta.CPULimit)
+	assert.Equal(t, "3072", check.AnalyzerMeta.MemoryLimit)
+
+	check = &artifact.Check{AnalyzerMeta: artifact.AnalyzerMeta{Shortcode: "go", CPULimit: "4000", MemoryLimit: "8192"}}
+	opts.RightSize(check)
+	assert.Equal(t, "4000", check.AnalyzerMeta.CPULimit)
+	assert.Equal(t, "8192", check.AnalyzerMeta.MemoryLimit)
+}
+
+func TestK8sPodMetrics_PodMetrics(t *testing.T) {
+	body := `{
+  "kind": "PodMetricsList",
+  "apiVersion": "metrics.k8s.io/v1beta1",
+  "items": [
+    {
+      "metadata": {"name": "analysis-s1-pod", "labels": {"role": "analysis", "analyzer": "python"}},
+      "containers": [
+        {"name": "marvin", "usage": {"cpu": "1250m", "memory": "512Mi"}},
+        {"name": "istio-proxy", "usage": {"cpu": "10m", "memory": "64Mi"}}
+      ]
+    },
+    {
+      "metadata": {"name": "unlabelled", "labels": {"role": "analysis"}},
+      "containers": [{"name": "marvin", "usage": {"cpu": "1", "memory": "1Gi"}}]
+    }
+  ]
+}`
+	client := &restfake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Resp: &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		},
+	}
+	metrics := &K8sPodMetrics{client: client, namespace: "runner"}
+
+	got, err := metrics.PodMetrics(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*rightsizing.PodMetrics{{
+		Pod:       "analysis-s1-pod",
+		Analyzer:  "python",
+		CPUMillis: 1250,
+		Memory:    512 << 20,
+	}}, got)
+	assert.Equal(t, "/apis/metrics.k8s.io/v1beta1/namespaces/runner/pods", client.Req.URL.Path)
+	assert.Equal(t, "role=analysis", client.Req.URL.Query().Get("labelSelector"))
+}
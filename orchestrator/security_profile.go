@@ -0,0 +1,148 @@
+package orchestrator
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	SecurityProfileBaseline   = "baseline"
+	SecurityProfileRestricted = "restricted"
+	SecurityProfileSandboxed  = "sandboxed"
+
+	DefaultSandboxRuntimeClass = "gvisor"
+
+	annotationAppArmorPrefix = "container.apparmor.security.beta.kubernetes.io/"
+	appArmorRuntimeDefault   = "runtime/default"
+)
+
+// scratchMounts are the writable directories of containers with a read-only
+// root filesystem.
+var scratchMounts = []corev1.VolumeMount{
+	{Name: "tmpdir", MountPath: "/tmp"},
+	{Name: "toolboxdir", MountPath: "/toolbox"},
+}
+
+// SecurityProfile selects the security settings of job pods.
+//
+//   - baseline runs as a fixed non-root user without privilege escalation
+//     or capabilities.
+//   - restricted adds the RuntimeDefault seccomp and AppArmor profiles, a
+//     read-only root filesystem, and turns off process namespace sharing and
+//     the service account token.  It passes the Pod Security Admission
+//     restricted level.
+//   - sandboxed is restricted, run with a sandboxed runtime like gVisor or
+//     Kata Containers.
+//
+// A nil profile is baseline.
+type SecurityProfile struct {
+	Name string
+
+	// RuntimeClassName is the RuntimeClass of sandboxed pods.  Defaults to
+	// DefaultSandboxRuntimeClass.
+	RuntimeClassName string
+}
+
+func (p *SecurityProfile) hardened() bool {
+	return p != nil && (p.Name == SecurityProfileRestricted || p.Name == SecurityProfileSandboxed)
+}
+
+func (p *SecurityProfile) runtimeClassName() *string {
+	if p == nil || p.Name != SecurityProfileSandboxed {
+		return nil
+	}
+	name := p.RuntimeClassName
+	if name == "" {
+		name = DefaultSandboxRuntimeClass
+	}
+	return &name
+}
+
+func (p *SecurityProfile) shareProcessNamespace() *bool {
+	share := !p.hardened()
+	return &share
+}
+
+func (p *SecurityProfile) automountServiceAccountToken() *bool {
+	if !p.hardened() {
+		return nil
+	}
+	automount := false
+	return &automount
+}
+
+func (p *SecurityProfile) seccompProfile() *corev1.SeccompProfile {
+	if !p.hardened() {
+		return nil
+	}
+	return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+}
+
+// podAnnotations returns the AppArmor annotations for the containers.
+func (p *SecurityProfile) podAnnotations(containers ...string) map[string]string {
+	if !p.hardened() {
+		return nil
+	}
+	annotations := make(map[string]string)
+	for _, c := range containers {
+		annotations[annotationAppArmorPrefix+c] = appArmorRuntimeDefault
+	}
+	return annotations
+}
+
+func (p *SecurityProfile) podSecContext() *corev1.PodSecurityContext {
+	return &corev1.PodSecurityContext{
+		RunAsUser:      &uid,
+		RunAsGroup:     &gid,
+		FSGroup:        &fsGroup,
+		RunAsNonRoot:   &runAsNonRoot,
+		SeccompProfile: p.seccompProfile(),
+	}
+}
+
+func (p *SecurityProfile) conSecContext() *corev1.SecurityContext {
+	if !p.hardened() {
+		return &corev1.SecurityContext{
+			AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{
+					"all",
+				},
+			},
+		}
+	}
+	readOnlyRootFilesystem := true
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		RunAsNonRoot:             &runAsNonRoot,
+		ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+		SeccompProfile:           p.seccompProfile(),
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{
+				"ALL",
+			},
+		},
+	}
+}
+
+// scratchVolumes returns the writable volumes of containers with a
+// read-only root filesystem.
+func (p *SecurityProfile) scratchVolumes() []corev1.Volume {
+	if !p.hardened() {
+		return nil
+	}
+	var volumes []corev1.Volume
+	for _, m := range scratchMounts {
+		volumes = append(volumes, corev1.Volume{
+			Name:         m.Name,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+	}
+	return volumes
+}
+
+func (p *SecurityProfile) scratchVolumeMounts() []corev1.VolumeMount {
+	if !p.hardened() {
+		return nil
+	}
+	return scratchMounts
+}
@@ -0,0 +1,118 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	artifact "github.com/DeepSourceCorp/artifacts/types"
+)
+
+// Task names, as in the paths of the task endpoints.
+const (
+	TaskNameAnalysis    = "analysis"
+	TaskNameAutofix     = "autofix"
+	TaskNameTransformer = "transformer"
+	TaskNameCancelCheck = "cancelcheck"
+	TaskNamePatcher     = "commit"
+)
+
+// ErrInvalidTask is returned for tasks that can never be run, like unknown
+// tasks or malformed runs.
+var ErrInvalidTask = errors.New("invalid task")
+
+// TaskMessage is a task received other than through the task endpoints, as
+// from a queue.
+type TaskMessage struct {
+	Name           string
+	AppID          string
+	InstallationID string
+
+	// Body is the run of the task, as posted to its endpoint.
+	Body json.RawMessage
+}
+
+// Tasks runs the tasks of the runner, however they are received.
+type Tasks struct {
+	analysis    *AnalysisTask
+	autofix     *AutofixTask
+	transformer *TransformerTask
+	cancelCheck *CancelCheckTask
+	patcher     *PatcherTask
+	events      *Events
+}
+
+func NewTasks(
+	opts *TaskOpts,
+	driver Driver,
+	provider Provider,
+	cloneProvider Provider,
+	signer Signer,
+	runner *Runner,
+	events *Events,
+) *Tasks {
+	driver = &recordingDriver{Driver: driver, events: events}
+	return &Tasks{
+		analysis:    NewAnalysisTask(runner, opts, driver, cloneProvider, signer),
+		autofix:     NewAutofixTask(runner, opts, driver, cloneProvider, signer),
+		transformer: NewTransformerTask(runner, opts, driver, provider, signer),
+		cancelCheck: NewCancelCheckTask(runner, opts, driver, signer, nil),
+		patcher:     NewPatcherTask(runner, opts, driver, provider, signer),
+		events:      events,
+	}
+}
+
+// Schedule decodes the run of the task and runs it.  Errors wrapping
+// ErrInvalidTask are returned for tasks that would fail again if retried.
+func (t *Tasks) Schedule(ctx context.Context, msg *TaskMessage) error {
+	if msg.Name != TaskNameCancelCheck && msg.AppID == "" {
+		return fmt.Errorf("%w: %s: missing app ID", ErrInvalidTask, msg.Name)
+	}
+	switch msg.Name {
+	case TaskNameAnalysis:
+		run := new(artifact.AnalysisRun)
+		if err := decodeTask(msg, run); err != nil {
+			return err
+		}
+		ctx = t.events.TaskReceived(ctx, msg.Name, msg.AppID, run.RunID)
+		return t.analysis.Run(ctx, &AnalysisRunRequest{Run: run, AppID: msg.AppID, InstallationID: msg.InstallationID})
+	case TaskNameAutofix:
+		run := new(artifact.AutofixRun)
+		if err := decodeTask(msg, run); err != nil {
+			return err
+		}
+		ctx = t.events.TaskReceived(ctx, msg.Name, msg.AppID, run.RunID)
+		return t.autofix.Run(ctx, &AutofixRunRequest{Run: run, AppID: msg.AppID, InstallationID: msg.InstallationID})
+	case TaskNameTransformer:
+		run := new(artifact.TransformerRun)
+		if err := decodeTask(msg, run); err != nil {
+			return err
+		}
+		ctx = t.events.TaskReceived(ctx, msg.Name, msg.AppID, run.RunID)
+		return t.transformer.Run(ctx, &TransformerRunRequest{Run: run, AppID: msg.AppID, InstallationID: msg.InstallationID})
+	case TaskNameCancelCheck:
+		run := new(artifact.CancelCheckRun)
+		if err := decodeTask(msg, run); err != nil {
+			return err
+		}
+		ctx = t.events.TaskReceived(ctx, msg.Name, msg.AppID, run.RunID)
+		return t.cancelCheck.Run(ctx, run)
+	case TaskNamePatcher:
+		run := new(artifact.PatcherRun)
+		if err := decodeTask(msg, run); err != nil {
+			return err
+		}
+		ctx = t.events.TaskReceived(ctx, msg.Name, msg.AppID, run.RunID)
+		return t.patcher.Run(ctx, &PatcherRunRequest{Run: run, AppID: msg.AppID, InstallationID: msg.InstallationID})
+	default:
+		return fmt.Errorf("%w: unknown task %q", ErrInvalidTask, msg.Name)
+	}
+}
+
+func decodeTask(msg *TaskMessage, v interface{}) error {
+	if err := json.Unmarshal(msg.Body, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidTask, msg.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTasks_Schedule(t *testing.T) {
+	tasks := &Tasks{}
+	tests := []struct {
+		name string
+		msg  *TaskMessage
+	}{
+		{"unknown task", &TaskMessage{Name: "lint", AppID: "app-id", Body: json.RawMessage(`{}`)}},
+		{"missing app ID", &TaskMessage{Name: TaskNameAnalysis, Body: json.RawMessage(`{}`)}},
+		{"malformed run", &TaskMessage{Name: TaskNameAutofix, AppID: "app-id", Body: json.RawMessage(`[]`)}},
+		{"malformed cancellation", &TaskMessage{Name: TaskNameCancelCheck, Body: json.RawMessage(`"run"`)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tasks.Schedule(context.Background(), tt.msg), ErrInvalidTask)
+		})
+	}
+}
+
+func TestFacade_Schedule(t *testing.T) {
+	f := &Facade{Tasks: &Tasks{}, Maintenance: NewMaintenance(&testDriver{}, &MaintenanceOpts{})}
+	f.Maintenance.Enable("upgrade")
+
+	err := f.Schedule(context.Background(), &TaskMessage{Name: TaskNameAnalysis, AppID: "app-id", Body: json.RawMessage(`{}`)})
+	assert.ErrorIs(t, err, ErrMaintenance)
+
+	// Cancelling checks is not paused.
+	err = f.Schedule(context.Background(), &TaskMessage{Name: TaskNameCancelCheck, Body: json.RawMessage(`"run"`)})
+	assert.ErrorIs(t, err, ErrInvalidTask)
+}
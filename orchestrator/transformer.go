@@ -2,9 +2,14 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"time"
 
 	artifact "github.com/DeepSourceCorp/artifacts/types"
+	"github.com/deepsourcecorp/runner/usage"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 const (
@@ -17,6 +22,7 @@ type TransformerTask struct {
 	driver   Driver
 	provider Provider
 	signer   Signer
+	client   *http.Client
 }
 
 type TransformerRunRequest struct {
@@ -32,32 +38,111 @@ func NewTransformerTask(runner *Runner, opts *TaskOpts, driver Driver, provider
 		provider: provider,
 		signer:   signer,
 		runner:   runner,
+		client:   http.DefaultClient,
 	}
 }
 
 func (t *TransformerTask) Run(ctx context.Context, req *TransformerRunRequest) error {
-	remoteURL, err := t.provider.AuthenticatedRemoteURL(req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL)
+	repository := repositoryName(req.Run.VCSMeta.RemoteURL)
+	slot, err := t.opts.Dispatcher.Acquire(ctx, &DispatchRequest{
+		AppID:      req.AppID,
+		Repository: repository,
+		Class:      DispatchClassDefault,
+	})
 	if err != nil {
 		return err
 	}
+	defer slot.Release()
 
 	token, err := t.signer.GenerateToken(t.runner.ID, []string{ScopeTransform}, nil, 30*time.Minute)
 	if err != nil {
 		return err
 	}
 
+	// The changes of transformers are only known once they ran, so runs are
+	// rejected when the policy limits the size of pushes.
+	push := &Push{
+		Branch:     transformerBranch(req.Run),
+		BaseBranch: req.Run.VCSMeta.BaseBranch,
+		Files:      -1,
+		Patches:    -1,
+	}
+	if t.opts.PushPolicy.ProtectsDefaultBranch() {
+		push.DefaultBranch = t.opts.DefaultBranch(req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL)
+	}
+	if err := t.opts.PushPolicy.Check(push); err != nil {
+		slog.Warn("transformer run rejected by push policy", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+		return t.report(ctx, req.Run.RunID, token, pushRejectedStatus(err))
+	}
+
+	if err := t.opts.OrgPolicy.Policy().CheckTransformer(req.Run); err != nil {
+		slog.Warn("transformer run denied by org policy", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+		return t.report(ctx, req.Run.RunID, token, policyDeniedStatus(err))
+	}
+
+	if err := t.opts.CheckUsage(ctx, req.AppID); err != nil {
+		return err
+	}
+
+	remoteURL, err := t.opts.PushRemoteURL(t.provider, req.AppID, req.InstallationID, req.Run.VCSMeta.RemoteURL, push.Branch)
+	if err != nil {
+		return err
+	}
+
+	images, err := t.opts.ResolveJobImages(ctx, transformerImage(&req.Run.Transformer))
+	if err != nil {
+		slog.Error("transformer job image rejected", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+		return t.report(ctx, req.Run.RunID, token, imageRejectedStatus(err))
+	}
+
 	req.Run.VCSMeta.RemoteURL = remoteURL
 	job, err := NewTransformerJob(
 		req.Run,
 		&TransformerOpts{
-			PublisherURL:   t.opts.RemoteHost + transformerPublishPath,
+			PublisherURL:   t.opts.PublisherURL(transformerPublishPath),
 			PublisherToken: token,
 			SentryDSN:      t.opts.SentryDSN,
+			Images:         images,
+			SigningKey:     t.opts.SigningKey(req.AppID),
 			KubernetesOpts: t.opts.KubernetesOpts,
 		},
 	)
 	if err != nil {
 		return err
 	}
-	return t.driver.TriggerJob(ctx, job)
+	if err := t.opts.Quota.Admit(ctx, job); err != nil {
+		if errors.Is(err, ErrJobTooLarge) {
+			slog.Error("transformer job too large", slog.String("run_id", req.Run.RunID), slog.Any("err", err))
+			return t.report(ctx, req.Run.RunID, token, jobTooLargeStatus(err))
+		}
+		return err
+	}
+	slot.Hold(job.Name())
+	if err := t.driver.TriggerJob(ctx, job); err != nil {
+		slot.Drop(job.Name())
+		return err
+	}
+	t.opts.TrackUsage(ctx, job, req.AppID, repository, usage.KindTransformer)
+	return nil
+}
+
+// transformerBranch returns the branch the transformer pushes to.
+func transformerBranch(run *artifact.TransformerRun) string {
+	if branch := run.PatchCommit.Commit.DestinationBranch; branch != "" {
+		return branch
+	}
+	return run.VCSMeta.BaseBranch
+}
+
+// report publishes the result of a transformer run concluded without a job.
+func (t *TransformerTask) report(ctx context.Context, runID, token string, status artifact.Status) error {
+	payload := artifact.TransformerResultCeleryTask{
+		ID:   uuid.NewString(),
+		Task: TransformerResultTask,
+		KWArgs: artifact.TransformerResult{
+			RunID:  runID,
+			Status: status,
+		},
+	}
+	return publishResult(ctx, t.client, t.opts.PublisherURL(transformerPublishPath), token, payload)
 }
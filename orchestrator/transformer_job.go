@@ -23,6 +23,13 @@ type TransformerOpts struct {
 
 	SentryDSN string
 
+	// Images are the job images, resolved by the image policy.
+	Images *JobImages
+
+	// SigningKey signs the commits pushed by the job.  Nil when commits are
+	// not signed.
+	SigningKey *SigningKey
+
 	KubernetesOpts *KubernetesOpts
 }
 
@@ -67,8 +74,9 @@ func (j *TransformerJob) NodeSelector() map[string]string {
 
 func (j *TransformerJob) PodLabels() map[string]string {
 	return map[string]string{
-		LabelNameApp:  j.Name(),
-		LabelNameRole: "transformer",
+		LabelNameApp:     j.Name(),
+		LabelNameRole:    "transformer",
+		LabelNameManager: "runner",
 	}
 }
 
@@ -83,8 +91,9 @@ func (*TransformerJob) Volumes() []string {
 
 func (j *TransformerJob) InitContainer() *Container {
 	return &Container{
-		Name:  "coat",
-		Image: j.getCoatImageURL(),
+		Name:       "coat",
+		Image:      j.opts.Images.Coat.Ref,
+		PullPolicy: j.opts.Images.Coat.PullPolicy,
 		Limit: Resource{
 			CPU:    CoatCPULimit,
 			Memory: CoatMemoryLimit,
@@ -116,9 +125,28 @@ func (j *TransformerJob) InitContainer() *Container {
 }
 
 func (j *TransformerJob) Container() *Container {
+	keyMounts, keyEnv := signingMounts(j.opts.SigningKey)
+	env := map[string]string{
+		EnvNameCodePath:                 "/code",
+		EnvNameToolboxPath:              "/toolbox",
+		EnvNameArtifactsCredentialsPath: "/credentials/credentials",
+		EnvNameMemoryLimit:              j.run.Transformer.Meta.MemoryLimit + "Mi",
+		EnvNameCPULimit:                 j.run.Transformer.Meta.CPULimit + "m",
+		EnvNameTimeLimit:                "1500",
+		EnvNameOnPrem:                   "true",
+		EnvNamePublisher:                "http",
+		EnvNamePublisherURL:             j.opts.PublisherURL,
+		EnvNamePublisherToken:           j.opts.PublisherToken,
+		EnvNameResultTask:               TransformerResultTask,
+		EnvNameSentryDSN:                j.opts.SentryDSN,
+	}
+	for k, v := range keyEnv {
+		env[k] = v
+	}
 	return &Container{
-		Name:  "marvin",
-		Image: j.getMarvinImageURL(),
+		Name:       "marvin",
+		Image:      j.opts.Images.Marvin.Ref,
+		PullPolicy: j.opts.Images.Marvin.PullPolicy,
 		Limit: Resource{
 			CPU:    j.run.Transformer.Meta.CPULimit + "m",
 			Memory: j.run.Transformer.Meta.MemoryLimit + "Mi",
@@ -140,38 +168,12 @@ func (j *TransformerJob) Container() *Container {
 					fmt.Sprintf("'%s'", string(j.deepsourceConfigBytes)),
 				}, " "),
 		},
-		Env: map[string]string{
-			EnvNameCodePath:                 "/code",
-			EnvNameToolboxPath:              "/toolbox",
-			EnvNameArtifactsCredentialsPath: "/credentials/credentials",
-			EnvNameMemoryLimit:              j.run.Transformer.Meta.MemoryLimit + "Mi",
-			EnvNameCPULimit:                 j.run.Transformer.Meta.CPULimit + "m",
-			EnvNameTimeLimit:                "1500",
-			EnvNameOnPrem:                   "true",
-			EnvNamePublisher:                "http",
-			EnvNamePublisherURL:             j.opts.PublisherURL,
-			EnvNamePublisherToken:           j.opts.PublisherToken,
-			EnvNameResultTask:               TransformerResultTask,
-			EnvNameSentryDSN:                j.opts.SentryDSN,
-		},
+		Env:          env,
 		VolumeMounts: VolumeMounts,
+		Mounts:       keyMounts,
 	}
 }
 
 func (j *TransformerJob) ImagePullSecrets() []string {
 	return j.opts.KubernetesOpts.ImagePullSecrets
 }
-
-func (j *TransformerJob) getMarvinImageURL() string {
-	return j.opts.KubernetesOpts.ImageURL.JoinPath(fmt.Sprintf(
-		"/bumblebee:%s",
-		j.run.Transformer.Meta.Version,
-	)).String()
-}
-
-func (j *TransformerJob) getCoatImageURL() string {
-	return j.opts.KubernetesOpts.ImageURL.JoinPath(fmt.Sprintf(
-		"coat:%s",
-		CoatVersion,
-	)).String()
-}
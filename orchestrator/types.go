@@ -4,6 +4,8 @@ import (
 	"errors"
 	"net/url"
 	"time"
+
+	"github.com/deepsourcecorp/runner/notify"
 )
 
 const (
@@ -26,14 +28,91 @@ type KubernetesOpts struct {
 	NodeSelector     map[string]string
 	ImageURL         url.URL
 	ImagePullSecrets []string
+
+	// ImagePolicy is applied to every job image.  Nil runs the images
+	// DeepSource requests.
+	ImagePolicy *ImagePolicy
 }
 
 type TaskOpts struct {
 	RemoteHost           string
 	SnippetStorageType   string
 	SnippetStorageBucket string
-	
-	SentryDSN            string
+
+	SentryDSN string
+
+	// RelayHost is the runner URL jobs publish their results to when the
+	// results relay is enabled.
+	RelayHost string
+
+	// Mirror is the shared repository mirror cache.  Nil when disabled.
+	Mirror *MirrorOpts
+
+	// CloneStrategies maps app IDs to the clone strategy of their analysis
+	// jobs.  Apps without one get a full clone.
+	CloneStrategies map[string]*AppCloneStrategy
+
+	// CredentialProfiles are the credentials injected into analyzer
+	// containers.
+	CredentialProfiles []*CredentialProfile
+
+	// Submodules generates credentials for private submodules.  Nil when the
+	// provider does not support it.
+	Submodules SubmoduleResolver
+
+	// Branches resolves the heads of branches, to detect stale changesets
+	// before patching, and default branches.  Nil when the provider does not
+	// support it.
+	Branches BranchResolver
+
+	// Pushes generates the remote URLs of transformer and patcher jobs,
+	// restricted to the branch they push to.  Nil when the git proxy is
+	// disabled, in which case the push policy is only checked before the
+	// jobs run.
+	Pushes PushProvider
+
+	// SigningKeys are the identities the commits pushed for apps are signed
+	// with.  Commits of apps without one are not signed.
+	SigningKeys []*SigningKey
+
+	// PushPolicy restricts the pushes of transformer and patcher jobs.  Nil
+	// allows every push.
+	PushPolicy *PushPolicy
+
+	// OrgPolicy is the organization policy incoming runs are checked
+	// against.  Nil allows every run.
+	OrgPolicy *PolicyFile
+
+	// ImageVerifier verifies job image signatures.  Nil when disabled.
+	ImageVerifier ImageVerifier
+
+	// Quota admits jobs against the namespace quota.  Nil when disabled.
+	Quota *Quota
+
+	// Dispatcher shares dispatching fairly across apps and repositories.
+	// Nil dispatches tasks first-come first-served.
+	Dispatcher *Dispatcher
+
+	// Usage accounts the jobs of apps and enforces their quotas.  Nil when
+	// usage accounting is disabled.
+	Usage UsageMeter
+
+	// Rightsizing recommends the limits of analyzers from their observed
+	// usage.  Nil when recommendations are not applied.
+	Rightsizing LimitRecommender
+
+	// Notifier notifies runner problems, like exhausted quotas.  Nil drops
+	// notifications.
+	Notifier *notify.Notifier
 
 	KubernetesOpts *KubernetesOpts
 }
+
+// PublisherURL returns the URL jobs post the results for path to.  Results go
+// to the runner's relay when one is configured, and to DeepSource otherwise.
+func (o *TaskOpts) PublisherURL(path string) string {
+	if o.RelayHost != "" {
+		return o.RelayHost + path
+	}
+	return o.RemoteHost + path
+}
@@ -0,0 +1,105 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/deepsourcecorp/runner/notify"
+	"github.com/deepsourcecorp/runner/usage"
+	"golang.org/x/exp/slog"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// UsageMeter records the usage of the jobs of apps and enforces their
+// job-minute quotas.
+type UsageMeter interface {
+	Check(ctx context.Context, appID string) error
+	Track(ctx context.Context, job *usage.Job) error
+}
+
+// CheckUsage returns an error when the app is over its usage quota.
+func (o *TaskOpts) CheckUsage(ctx context.Context, appID string) error {
+	if o.Usage == nil {
+		return nil
+	}
+	err := o.Usage.Check(ctx, appID)
+	if errors.Is(err, usage.ErrQuotaExceeded) {
+		o.Notifier.Notify(&notify.Notification{
+			Type:    notify.TypeQuotaExhausted,
+			Title:   fmt.Sprintf("App %s is over its job-minute quota", appID),
+			Message: err.Error(),
+			Fields:  map[string]string{"app_id": appID, "quota": "usage"},
+		})
+	}
+	return err
+}
+
+// TrackUsage records a triggered job with the resources its pod requests.
+// Failures are logged, since the job already runs.
+func (o *TaskOpts) TrackUsage(ctx context.Context, job JobCreator, appID, repository, kind string) {
+	if o.Usage == nil {
+		return
+	}
+	j, err := (&MarvinK8sJob{JobCreator: job}).Job()
+	if err != nil {
+		slog.Error("failed to account job usage", slog.String("job", job.Name()), slog.Any("err", err))
+		return
+	}
+	requests := podUsage(nil, &j.Spec.Template.Spec)
+	cpu, memory := requests[corev1.ResourceRequestsCPU], requests[corev1.ResourceRequestsMemory]
+	err = o.Usage.Track(ctx, &usage.Job{
+		Name:       job.Name(),
+		AppID:      appID,
+		Repository: repository,
+		Kind:       kind,
+		CPUMillis:  cpu.MilliValue(),
+		Memory:     memory.Value(),
+	})
+	if err != nil {
+		slog.Error("failed to account job usage", slog.String("job", job.Name()), slog.Any("err", err))
+	}
+}
+
+// K8sJobStatus reads the start and finish times of jobs from Kubernetes.
+type K8sJobStatus struct {
+	clientset kubernetes.Interface
+	namespace string
+}
+
+func NewK8sJobStatus(tokenPath, namespace string) (*K8sJobStatus, error) {
+	clientset, err := newK8sClientset(tokenPath)
+	if err != nil {
+		return nil, err
+	}
+	return &K8sJobStatus{clientset: clientset, namespace: namespace}, nil
+}
+
+// JobTimes returns the start and finish times of the job.  Finished is zero
+// while the job runs.
+func (s *K8sJobStatus) JobTimes(ctx context.Context, name string) (started, finished time.Time, found bool, err error) {
+	job, err := s.clientset.BatchV1().Jobs(s.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	if job.Status.StartTime != nil {
+		started = job.Status.StartTime.Time
+	}
+	if job.Status.CompletionTime != nil {
+		return started, job.Status.CompletionTime.Time, true, nil
+	}
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return started, c.LastTransitionTime.Time, true, nil
+		}
+	}
+	return started, time.Time{}, true, nil
+}
@@ -0,0 +1,78 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deepsourcecorp/runner/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type testUsageMeter struct {
+	jobs []*usage.Job
+}
+
+func (*testUsageMeter) Check(context.Context, string) error { return nil }
+
+func (m *testUsageMeter) Track(_ context.Context, j *usage.Job) error {
+	m.jobs = append(m.jobs, j)
+	return nil
+}
+
+func TestTaskOpts_TrackUsage(t *testing.T) {
+	meter := &testUsageMeter{}
+	opts := &TaskOpts{Usage: meter}
+	opts.TrackUsage(context.Background(), &testJob{name: "analysis-s1"}, "app-id", "acme/web", usage.KindAnalysis)
+
+	require.Len(t, meter.jobs, 1)
+	assert.Equal(t, &usage.Job{
+		Name:       "analysis-s1",
+		AppID:      "app-id",
+		Repository: "acme/web",
+		Kind:       usage.KindAnalysis,
+		CPUMillis:  100,
+		Memory:     100 << 20,
+	}, meter.jobs[0])
+}
+
+func TestK8sJobStatus_JobTimes(t *testing.T) {
+	started := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	failed := started.Add(5 * time.Minute)
+	job := func(name string, status batchv1.JobStatus) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "runner"}, Status: status}
+	}
+	clientset := fake.NewSimpleClientset(
+		job("running", batchv1.JobStatus{StartTime: &metav1.Time{Time: started}}),
+		job("failed", batchv1.JobStatus{
+			StartTime: &metav1.Time{Time: started},
+			Conditions: []batchv1.JobCondition{{
+				Type:               batchv1.JobFailed,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.Time{Time: failed},
+			}},
+		}),
+	)
+	status := &K8sJobStatus{clientset: clientset, namespace: "runner"}
+
+	s, f, found, err := status.JobTimes(context.Background(), "running")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, started, s)
+	assert.True(t, f.IsZero())
+
+	s, f, found, err = status.JobTimes(context.Background(), "failed")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, started, s)
+	assert.Equal(t, failed, f)
+
+	_, _, found, err = status.JobTimes(context.Background(), "deleted")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
@@ -1,10 +1,13 @@
 package provider
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/deepsourcecorp/runner/httperror"
 	"github.com/deepsourcecorp/runner/provider/github"
+	"github.com/deepsourcecorp/runner/provider/model"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/exp/slog"
 )
@@ -64,6 +67,41 @@ func (a *Adapter) HandleInstallation(c echo.Context) error {
 	return provider.HandleInstallation(c)
 }
 
+// HandlePullRequest opens a pull request through the provider of the app.
+// It responds with 201 when the pull request was created, and with 200 when
+// one of the head into the base was already open.
+func (a *Adapter) HandlePullRequest(c echo.Context) error {
+	req := new(model.PullRequestRequest)
+	if err := c.Bind(req); err != nil {
+		return httperror.ErrMissingParams(err)
+	}
+	if err := req.Validate(); err != nil {
+		return httperror.ErrMissingParams(err)
+	}
+	installationID := c.Request().Header.Get("X-Installation-ID")
+	if installationID == "" {
+		return httperror.ErrMissingParams(errors.New("missing installation id"))
+	}
+
+	provider, err := a.getProvider(c.Param("app_id"))
+	if err != nil {
+		slog.Error("failed to get provider", slog.Any("err", err))
+		return httperror.ErrAppInvalid(err)
+	}
+	pr, err := provider.CreatePullRequest(c.Param("app_id"), installationID, req)
+	if errors.Is(err, model.ErrInvalidPullRequest) {
+		return httperror.New(http.StatusUnprocessableEntity, err.Error(), err)
+	}
+	if err != nil {
+		slog.Error("failed to create pull request", slog.String("repo", req.Repository), slog.Any("err", err))
+		return httperror.ErrUpstreamFailed(err)
+	}
+	if pr.Created {
+		return c.JSON(http.StatusCreated, pr)
+	}
+	return c.JSON(http.StatusOK, pr)
+}
+
 // AuthenticatedRemoteURL returns an authenticated remote URL for a specific app,
 // installation, and source URL.
 func (a *Adapter) AuthenticatedRemoteURL(appID, installationID, srcURL string) (string, error) {
@@ -74,6 +112,34 @@ func (a *Adapter) AuthenticatedRemoteURL(appID, installationID, srcURL string) (
 	return provider.AuthenticatedRemoteURL(appID, installationID, srcURL)
 }
 
+// SubmoduleCredentials returns credentials for the submodules of a repository
+// that belong to the same installation.
+func (a *Adapter) SubmoduleCredentials(appID, installationID, srcURL, ref string) ([]string, error) {
+	provider, err := a.getProvider(appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate submodule credentials: %w", err)
+	}
+	return provider.SubmoduleCredentials(appID, installationID, srcURL, ref)
+}
+
+// BranchHead returns the commit at the head of a branch of a repository.
+func (a *Adapter) BranchHead(appID, installationID, srcURL, branch string) (string, error) {
+	provider, err := a.getProvider(appID)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve branch head: %w", err)
+	}
+	return provider.BranchHead(appID, installationID, srcURL, branch)
+}
+
+// DefaultBranch returns the name of the default branch of a repository.
+func (a *Adapter) DefaultBranch(appID, installationID, srcURL string) (string, error) {
+	provider, err := a.getProvider(appID)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve default branch: %w", err)
+	}
+	return provider.DefaultBranch(appID, installationID, srcURL)
+}
+
 // getProvider retrieves the VCS provider based on the given appID.
 func (a *Adapter) getProvider(appID string) (Provider, error) {
 	app := a.apps[appID]
@@ -89,6 +155,10 @@ type Provider interface {
 	HandleWebhook(c echo.Context) error
 	HandleInstallation(c echo.Context) error
 	AuthenticatedRemoteURL(appID, installationID, srcURL string) (string, error)
+	SubmoduleCredentials(appID, installationID, srcURL, ref string) ([]string, error)
+	BranchHead(appID, installationID, srcURL, branch string) (string, error)
+	DefaultBranch(appID, installationID, srcURL string) (string, error)
+	CreatePullRequest(appID, installationID string, req *model.PullRequestRequest) (*model.PullRequest, error)
 }
 
 // App represents an application with a specific VCS provider.
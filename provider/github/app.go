@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/deepsourcecorp/runner/internal/signer"
+	"github.com/deepsourcecorp/runner/notify"
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/exp/slog"
 )
@@ -21,6 +22,10 @@ type App struct {
 	BaseHost      url.URL
 	APIHost       url.URL
 	PrivateKey    *rsa.PrivateKey
+
+	// Notifier is notified when forwarding the app's webhooks or generating
+	// its installation tokens fails.  Nil drops notifications.
+	Notifier *notify.Notifier
 }
 
 // Generate a JWT token for the GitHub App.
@@ -0,0 +1,51 @@
+package github
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// BranchHead returns the SHA of the commit at the head of the branch of the
+// repository at srcURL, as seen by the installation.
+func (h *Handler) BranchHead(appID, installationID, srcURL, branch string) (string, error) {
+	client, token, owner, repo, err := h.repositoryClient(appID, installationID, srcURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve branch head: %w", err)
+	}
+	return client.BranchHead(token, owner, repo, branch)
+}
+
+// DefaultBranch returns the name of the default branch of the repository at
+// srcURL, as seen by the installation.
+func (h *Handler) DefaultBranch(appID, installationID, srcURL string) (string, error) {
+	client, token, owner, repo, err := h.repositoryClient(appID, installationID, srcURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve default branch: %w", err)
+	}
+	return client.DefaultBranch(token, owner, repo)
+}
+
+// repositoryClient returns the installation client and access token for the
+// repository at srcURL, with the owner and name of the repository.
+func (h *Handler) repositoryClient(appID, installationID, srcURL string) (client *InstallationClient, token, owner, repo string, err error) {
+	app := h.appFactory.GetApp(appID)
+	if app == nil {
+		return nil, "", "", "", ErrAppNotFound
+	}
+
+	u, err := url.Parse(srcURL)
+	if err != nil {
+		return nil, "", "", "", fmt.Errorf("failed to parse url: %w", err)
+	}
+	owner, repo, ok := repositoryPath(u.Path)
+	if !ok {
+		return nil, "", "", "", fmt.Errorf("failed to parse repository from url: %s", u.Redacted())
+	}
+
+	client = NewInstallationClient(app, installationID, h.httpClient)
+	token, err = client.AccessToken()
+	if err != nil {
+		return nil, "", "", "", err
+	}
+	return client, token, owner, repo, nil
+}
@@ -0,0 +1,58 @@
+package github
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_BranchHead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/test-installation-id/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token": "test-token"}`))
+		case "/repos/acme/app/branches/main":
+			assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"name": "main", "commit": {"sha": "def456"}}`))
+		case "/repos/acme/app":
+			assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"name": "app", "default_branch": "trunk"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	handler := &Handler{
+		appFactory: NewAppFactory(map[string]*App{
+			"test-app-id": {ID: "test-app-id", APIHost: *serverURL, PrivateKey: privateKey},
+		}),
+		httpClient: http.DefaultClient,
+	}
+
+	head, err := handler.BranchHead("test-app-id", "test-installation-id", "https://github.com/acme/app.git", "main")
+	require.NoError(t, err)
+	assert.Equal(t, "def456", head)
+
+	_, err = handler.BranchHead("test-app-id", "test-installation-id", "https://github.com/acme/app.git", "gone")
+	assert.Error(t, err)
+
+	_, err = handler.BranchHead("unknown-app-id", "test-installation-id", "https://github.com/acme/app.git", "main")
+	assert.ErrorIs(t, err, ErrAppNotFound)
+
+	branch, err := handler.DefaultBranch("test-app-id", "test-installation-id", "https://github.com/acme/app.git")
+	require.NoError(t, err)
+	assert.Equal(t, "trunk", branch)
+
+	_, err = handler.DefaultBranch("test-app-id", "test-installation-id", "https://github.com/acme/gone.git")
+	assert.Error(t, err)
+}
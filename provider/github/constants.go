@@ -11,6 +11,7 @@ const (
 	HeaderRunnerID        = "x-deepsource-runner-id"
 	HeaderAppID           = "x-deepsource-app-id"
 	HeaderInstallationID  = "X-Installation-Id"
+	HeaderGithubEvent     = "X-GitHub-Event"
 
 	HeaderContentType    = "Content-Type"
 	HeaderAuthorization  = "Authorization"
@@ -18,6 +19,9 @@ const (
 	HeaderAcceptEncoding = "Accept-Encoding"
 
 	HeaderValueGithubAccept = "application/vnd.github+json"
+	HeaderValueGithubRaw    = "application/vnd.github.raw"
+
+	EventPush = "push"
 )
 
 var (
@@ -1,17 +1,24 @@
 package github
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/deepsourcecorp/runner/notify"
+	"github.com/deepsourcecorp/runner/provider/model"
 )
 
 var (
 	GithubURLAccessTokenFmt = "/app/installations/%s/access_tokens"
 )
 
+const maxContentsSize = 1 << 20
+
 type InstallationClient struct {
 	app            *App
 	installationID string
@@ -36,6 +43,32 @@ type AccessTokenResponse struct {
 // installation.
 // (https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-an-installation-access-token-for-a-github-app)
 func (c *InstallationClient) AccessToken() (string, error) {
+	return c.notifyTokenFailure(c.accessToken(nil))
+}
+
+// ScopedAccessToken returns an installation access token limited to the
+// repositories, named without their owner, and to the permissions.
+// (https://docs.github.com/en/rest/apps/apps#create-an-installation-access-token-for-an-app)
+func (c *InstallationClient) ScopedAccessToken(repositories []string, permissions map[string]string) (string, error) {
+	return c.notifyTokenFailure(c.accessToken(map[string]interface{}{
+		"repositories": repositories,
+		"permissions":  permissions,
+	}))
+}
+
+func (c *InstallationClient) notifyTokenFailure(token string, err error) (string, error) {
+	if err != nil {
+		c.app.Notifier.Notify(&notify.Notification{
+			Type:    notify.TypeGitHubTokenFailed,
+			Title:   fmt.Sprintf("Failed to generate a GitHub token for app %s", c.app.ID),
+			Message: err.Error(),
+			Fields:  map[string]string{"app_id": c.app.ID, "installation_id": c.installationID},
+		})
+	}
+	return token, err
+}
+
+func (c *InstallationClient) accessToken(scope map[string]interface{}) (string, error) {
 	accessTokenURL := c.app.APIHost.JoinPath(fmt.Sprintf(GithubURLAccessTokenFmt, c.installationID)).String()
 
 	jwtToken, err := c.app.JWT()
@@ -43,13 +76,24 @@ func (c *InstallationClient) AccessToken() (string, error) {
 		return "", fmt.Errorf("failed to generate jwt token: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, accessTokenURL, http.NoBody)
+	var body io.Reader = http.NoBody
+	if scope != nil {
+		scopeJSON, err := json.Marshal(scope)
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewReader(scopeJSON)
+	}
+	req, err := http.NewRequest(http.MethodPost, accessTokenURL, body)
 	if err != nil {
 		return "", fmt.Errorf("failed to create http request: %w", err)
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", jwtToken))
 	req.Header.Set("Accept", HeaderValueGithubAccept)
+	if scope != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -81,3 +125,235 @@ func (c *InstallationClient) ProxyURL(path string) *url.URL {
 	prefixToRemove := fmt.Sprintf("/apps/%s/api/", c.app.ID)
 	return c.app.APIHost.JoinPath(strings.TrimPrefix(path, prefixToRemove))
 }
+
+// Contents returns the raw content of the file at path in the repository, at
+// the given ref.  Nil is returned when the file does not exist.
+// (https://docs.github.com/en/rest/repos/contents#get-repository-content)
+func (c *InstallationClient) Contents(token, owner, repo, path, ref string) ([]byte, error) {
+	u := c.app.APIHost.JoinPath("repos", owner, repo, "contents", path)
+	if ref != "" {
+		u.RawQuery = url.Values{"ref": []string{ref}}.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Accept", HeaderValueGithubRaw)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request for contents failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(io.LimitReader(resp.Body, maxContentsSize))
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+// HasRepository reports whether the installation token grants access to the
+// repository.
+// (https://docs.github.com/en/rest/repos/repos#get-a-repository)
+func (c *InstallationClient) HasRepository(token, owner, repo string) (bool, error) {
+	u := c.app.APIHost.JoinPath("repos", owner, repo)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return false, fmt.Errorf("failed to create http request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Accept", HeaderValueGithubAccept)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("http request for repository failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound, http.StatusForbidden:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+// BranchHead returns the SHA of the commit at the head of the branch.
+// (https://docs.github.com/en/rest/branches/branches#get-a-branch)
+func (c *InstallationClient) BranchHead(token, owner, repo, branch string) (string, error) {
+	u := c.app.APIHost.JoinPath("repos", owner, repo, "branches", branch)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create http request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Accept", HeaderValueGithubAccept)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("http request for branch failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var body struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode branch: %w", err)
+	}
+	if body.Commit.SHA == "" {
+		return "", fmt.Errorf("branch %s has no head commit", branch)
+	}
+	return body.Commit.SHA, nil
+}
+
+// DefaultBranch returns the name of the default branch of the repository.
+func (c *InstallationClient) DefaultBranch(token, owner, repo string) (string, error) {
+	u := c.app.APIHost.JoinPath("repos", owner, repo)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create http request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Accept", HeaderValueGithubAccept)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("http request for repository failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var body struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode repository: %w", err)
+	}
+	if body.DefaultBranch == "" {
+		return "", fmt.Errorf("repository %s/%s has no default branch", owner, repo)
+	}
+	return body.DefaultBranch, nil
+}
+
+// pullRequest is the subset of a GitHub pull request the runner uses.
+type pullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+	Draft   bool   `json:"draft"`
+	Head    struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+// OpenPullRequest returns the open pull request of head into base, or nil if
+// there is none.  head is qualified by its owner, as owner:branch.
+// (https://docs.github.com/en/rest/pulls/pulls#list-pull-requests)
+func (c *InstallationClient) OpenPullRequest(token, owner, repo, head, base string) (*pullRequest, error) {
+	u := c.app.APIHost.JoinPath("repos", owner, repo, "pulls")
+	u.RawQuery = url.Values{"state": {"open"}, "head": {head}, "base": {base}}.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Accept", HeaderValueGithubAccept)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request for pull requests failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var pulls []*pullRequest
+	if err := json.NewDecoder(resp.Body).Decode(&pulls); err != nil {
+		return nil, fmt.Errorf("failed to decode pull requests: %w", err)
+	}
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+	return pulls[0], nil
+}
+
+// CreatePullRequest opens a pull request.  Requests GitHub rejects as
+// invalid, including when a pull request of head into base is already open,
+// wrap model.ErrInvalidPullRequest.
+// (https://docs.github.com/en/rest/pulls/pulls#create-a-pull-request)
+func (c *InstallationClient) CreatePullRequest(token, owner, repo string, pr *model.PullRequestRequest) (*pullRequest, error) {
+	u := c.app.APIHost.JoinPath("repos", owner, repo, "pulls")
+
+	body, err := json.Marshal(map[string]interface{}{
+		"title": pr.Title,
+		"head":  pr.Head,
+		"base":  pr.Base,
+		"body":  pr.Body,
+		"draft": pr.Draft,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Accept", HeaderValueGithubAccept)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request for pull request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		created := new(pullRequest)
+		if err := json.NewDecoder(resp.Body).Decode(created); err != nil {
+			return nil, fmt.Errorf("failed to decode pull request: %w", err)
+		}
+		return created, nil
+	case http.StatusUnprocessableEntity:
+		var ghErr struct {
+			Message string `json:"message"`
+			Errors  []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, maxContentsSize)).Decode(&ghErr)
+		msg := ghErr.Message
+		for _, e := range ghErr.Errors {
+			msg += ": " + e.Message
+		}
+		return nil, fmt.Errorf("%w: %s", model.ErrInvalidPullRequest, msg)
+	default:
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
@@ -1,14 +1,18 @@
 package github
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 
+	"github.com/deepsourcecorp/runner/notify"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInstallationClient_AccessToken(t *testing.T) {
@@ -58,12 +62,32 @@ func TestInstallationClient_AccessToken_Error(t *testing.T) {
 		PrivateKey: privateKey,
 	}
 
+	sink := &notificationSink{}
+	app.Notifier, _ = notify.New("runner-id", &notify.Route{Name: "test", Sink: sink})
+
 	installationClient := &InstallationClient{app: app, installationID: "test-installation-id", client: http.DefaultClient}
 
 	accessToken, err := installationClient.AccessToken()
 
 	assert.Error(t, err)
 	assert.Equal(t, "", accessToken)
+
+	app.Notifier.Wait()
+	require.Len(t, sink.sent, 1)
+	assert.Equal(t, notify.TypeGitHubTokenFailed, sink.sent[0].Type)
+	assert.Equal(t, map[string]string{"app_id": "test-app-id", "installation_id": "test-installation-id"}, sink.sent[0].Fields)
+}
+
+type notificationSink struct {
+	mu   sync.Mutex
+	sent []*notify.Notification
+}
+
+func (s *notificationSink) Send(_ context.Context, n *notify.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, n)
+	return nil
 }
 
 func TestInstallationClient_AccessToken_Error_JWT(t *testing.T) {
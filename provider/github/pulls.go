@@ -0,0 +1,66 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/deepsourcecorp/runner/provider/model"
+)
+
+// CreatePullRequest opens a pull request of the head into the base, or
+// returns the one already open.
+func (h *Handler) CreatePullRequest(appID, installationID string, req *model.PullRequestRequest) (*model.PullRequest, error) {
+	app := h.appFactory.GetApp(appID)
+	if app == nil {
+		return nil, ErrAppNotFound
+	}
+	owner, repo, ok := req.OwnerRepo()
+	if !ok {
+		return nil, fmt.Errorf("invalid repository: %s", req.Repository)
+	}
+	// GitHub only filters pull requests by heads qualified with their owner.
+	head := req.Head
+	if !strings.Contains(head, ":") {
+		head = owner + ":" + head
+	}
+
+	installationClient := NewInstallationClient(app, installationID, h.httpClient)
+	token, err := installationClient.AccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pull request: %w", err)
+	}
+
+	existing, err := installationClient.OpenPullRequest(token, owner, repo, head, req.Base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	if existing != nil {
+		return normalizePullRequest(existing, false), nil
+	}
+
+	created, err := installationClient.CreatePullRequest(token, owner, repo, req)
+	if errors.Is(err, model.ErrInvalidPullRequest) {
+		// The pull request may have been opened since it was looked up.
+		if existing, _ := installationClient.OpenPullRequest(token, owner, repo, head, req.Base); existing != nil {
+			return normalizePullRequest(existing, false), nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalizePullRequest(created, true), nil
+}
+
+func normalizePullRequest(pr *pullRequest, created bool) *model.PullRequest {
+	return &model.PullRequest{
+		Number:  pr.Number,
+		URL:     pr.HTMLURL,
+		State:   pr.State,
+		Draft:   pr.Draft,
+		Head:    pr.Head.Ref,
+		Base:    pr.Base.Ref,
+		HeadSHA: pr.Head.SHA,
+		Created: created,
+	}
+}
@@ -0,0 +1,124 @@
+package github
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/deepsourcecorp/runner/provider/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPullRequest = `{"number": 7, "html_url": "https://github.com/acme/app/pull/7", "state": "open", "draft": true, "head": {"ref": "fix", "sha": "abc123"}, "base": {"ref": "main"}}`
+
+func TestHandler_CreatePullRequest(t *testing.T) {
+	// open is the pull request listed as open, and conflict whether creating
+	// fails as if it was opened concurrently.
+	var (
+		open     string
+		conflict bool
+		created  int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/app/installations/test-installation-id/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token": "test-token"}`))
+		case r.URL.Path == "/repos/acme/app/pulls" && r.Method == http.MethodGet:
+			assert.Equal(t, "acme:fix", r.URL.Query().Get("head"))
+			assert.Equal(t, "main", r.URL.Query().Get("base"))
+			assert.Equal(t, "open", r.URL.Query().Get("state"))
+			_, _ = w.Write([]byte("[" + open + "]"))
+		case r.URL.Path == "/repos/acme/app/pulls" && r.Method == http.MethodPost:
+			assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+			var body map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, map[string]interface{}{"title": "Fix issues", "head": "fix", "base": "main", "body": "Autofix", "draft": true}, body)
+			if conflict {
+				open = testPullRequest
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"message": "Validation Failed", "errors": [{"message": "A pull request already exists for acme:fix."}]}`))
+				return
+			}
+			created++
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(testPullRequest))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	handler := &Handler{
+		appFactory: NewAppFactory(map[string]*App{
+			"test-app-id": {ID: "test-app-id", APIHost: *serverURL, PrivateKey: privateKey},
+		}),
+		httpClient: http.DefaultClient,
+	}
+	req := &model.PullRequestRequest{Repository: "acme/app", Head: "fix", Base: "main", Title: "Fix issues", Body: "Autofix", Draft: true}
+	want := &model.PullRequest{
+		Number:  7,
+		URL:     "https://github.com/acme/app/pull/7",
+		State:   "open",
+		Draft:   true,
+		Head:    "fix",
+		Base:    "main",
+		HeadSHA: "abc123",
+	}
+
+	t.Run("created", func(t *testing.T) {
+		open, conflict = "", false
+		pr, err := handler.CreatePullRequest("test-app-id", "test-installation-id", req)
+		require.NoError(t, err)
+		want := *want
+		want.Created = true
+		assert.Equal(t, &want, pr)
+		assert.Equal(t, 1, created)
+	})
+
+	t.Run("already open", func(t *testing.T) {
+		open, conflict = testPullRequest, false
+		pr, err := handler.CreatePullRequest("test-app-id", "test-installation-id", req)
+		require.NoError(t, err)
+		assert.Equal(t, want, pr)
+		assert.Equal(t, 1, created)
+	})
+
+	t.Run("opened concurrently", func(t *testing.T) {
+		open, conflict = "", true
+		pr, err := handler.CreatePullRequest("test-app-id", "test-installation-id", req)
+		require.NoError(t, err)
+		assert.Equal(t, want, pr)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		// GitHub rejects the pull request, and none is open.
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/app/installations/test-installation-id/access_tokens":
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"token": "test-token"}`))
+			case r.Method == http.MethodGet:
+				_, _ = w.Write([]byte(`[]`))
+			default:
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"message": "Validation Failed", "errors": [{"message": "No commits between main and fix"}]}`))
+			}
+		})
+		_, err := handler.CreatePullRequest("test-app-id", "test-installation-id", req)
+		assert.ErrorIs(t, err, model.ErrInvalidPullRequest)
+		assert.ErrorContains(t, err, "No commits between main and fix")
+	})
+
+	t.Run("unknown app", func(t *testing.T) {
+		_, err := handler.CreatePullRequest("unknown-app-id", "test-installation-id", req)
+		assert.ErrorIs(t, err, ErrAppNotFound)
+	})
+}
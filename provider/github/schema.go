@@ -59,3 +59,14 @@ func (r *WebhookRequest) Validate() error {
 	}
 	return nil
 }
+
+// PushPayload is the subset of the push webhook payload the runner uses.
+type PushPayload struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+	} `json:"repository"`
+	Installation struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+}
@@ -0,0 +1,160 @@
+package github
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+const gitmodulesPath = ".gitmodules"
+
+// scpURLRegexp matches scp-like SSH URLs, such as git@github.com:owner/repo.git.
+var scpURLRegexp = regexp.MustCompile(`^(?:[\w.-]+@)?([\w.-]+):([^/].*)$`)
+
+// SubmoduleCredentials returns credentials for the submodules of the
+// repository at srcURL that belong to the same installation, as entries in
+// the git-credential-store format.  Submodules on other hosts, of other
+// owners, or not accessible to the installation are skipped.  The
+// credentials carry a token that can only read the contents of the
+// submodules.
+func (h *Handler) SubmoduleCredentials(appID, installationID, srcURL, ref string) ([]string, error) {
+	app := h.appFactory.GetApp(appID)
+	if app == nil {
+		return nil, ErrAppNotFound
+	}
+
+	base, err := url.Parse(srcURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+	owner, repo, ok := repositoryPath(base.Path)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse repository from url: %s", base.Redacted())
+	}
+
+	installationClient := NewInstallationClient(app, installationID, h.httpClient)
+	token, err := installationClient.AccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate submodule credentials: %w", err)
+	}
+
+	gitmodules, err := installationClient.Contents(token, owner, repo, gitmodulesPath, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", gitmodulesPath, err)
+	}
+
+	var submodules []*url.URL
+	var repositories []string
+	seen := make(map[string]bool)
+	for _, raw := range ParseGitmodules(gitmodules) {
+		u, ok := ResolveSubmoduleURL(base, raw)
+		if !ok {
+			continue
+		}
+		subOwner, subRepo, ok := repositoryPath(u.Path)
+		if !ok || !strings.EqualFold(subOwner, owner) || seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+
+		accessible, err := installationClient.HasRepository(token, subOwner, subRepo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check submodule access: %w", err)
+		}
+		if !accessible {
+			slog.Info("submodule is not accessible to the installation", slog.String("url", u.String()))
+			continue
+		}
+		submodules = append(submodules, u)
+		repositories = append(repositories, subRepo)
+	}
+	if len(submodules) == 0 {
+		return nil, nil
+	}
+
+	// The job only gets a token that can read the submodules, never the
+	// installation token.
+	submoduleToken, err := installationClient.ScopedAccessToken(repositories, map[string]string{"contents": "read"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate submodule credentials: %w", err)
+	}
+	credentials := make([]string, 0, len(submodules))
+	for _, u := range submodules {
+		u.User = url.UserPassword("x-access-token", submoduleToken)
+		credentials = append(credentials, u.String())
+	}
+	return credentials, nil
+}
+
+// ParseGitmodules returns the URLs of the submodules declared in a
+// .gitmodules file.
+func ParseGitmodules(content []byte) []string {
+	var urls []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	inSubmodule := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inSubmodule = strings.HasPrefix(line, "[submodule")
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inSubmodule || !ok || strings.TrimSpace(key) != "url" {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if value != "" {
+			urls = append(urls, value)
+		}
+	}
+	return urls
+}
+
+// ResolveSubmoduleURL resolves a submodule URL against the URL of the
+// superproject, and returns it as an HTTPS URL.  Relative, SSH and HTTPS
+// URLs are supported.  ok is false when the submodule is on another host.
+func ResolveSubmoduleURL(base *url.URL, raw string) (*url.URL, bool) {
+	var host, p string
+	switch {
+	case strings.HasPrefix(raw, "./") || strings.HasPrefix(raw, "../"):
+		host, p = base.Hostname(), path.Join(base.Path, raw)
+	case strings.Contains(raw, "://"):
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, false
+		}
+		if u.Scheme != "https" && u.Scheme != "http" && u.Scheme != "ssh" && u.Scheme != "git" {
+			return nil, false
+		}
+		host, p = u.Hostname(), u.Path
+	default:
+		m := scpURLRegexp.FindStringSubmatch(raw)
+		if m == nil {
+			return nil, false
+		}
+		host, p = m[1], "/"+m[2]
+	}
+	if !strings.EqualFold(host, base.Hostname()) {
+		return nil, false
+	}
+	return &url.URL{Scheme: base.Scheme, Host: base.Host, Path: path.Clean(p)}, true
+}
+
+// repositoryPath returns the owner and name of the repository at the path
+// of a remote URL.
+func repositoryPath(p string) (string, string, bool) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], strings.TrimSuffix(parts[1], ".git"), true
+}
@@ -2,12 +2,15 @@ package github
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/deepsourcecorp/runner/forwarder"
 	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/deepsourcecorp/runner/notify"
 	"github.com/deepsourcecorp/runner/provider/model"
 	"golang.org/x/exp/slog"
 )
@@ -17,14 +20,17 @@ type WebhookService struct {
 	runner     *model.Runner
 	deepsource *model.DeepSource
 	client     *http.Client
+
+	pushNotifier *model.PushNotifier
 }
 
-func NewWebhookService(appFactory *AppFactory, runner *model.Runner, deepsource *model.DeepSource, client *http.Client) *WebhookService {
+func NewWebhookService(appFactory *AppFactory, runner *model.Runner, deepsource *model.DeepSource, client *http.Client, pushNotifier *model.PushNotifier) *WebhookService {
 	return &WebhookService{
-		appFactory: appFactory,
-		runner:     runner,
-		deepsource: deepsource,
-		client:     client,
+		appFactory:   appFactory,
+		runner:       runner,
+		deepsource:   deepsource,
+		client:       client,
+		pushNotifier: pushNotifier,
 	}
 }
 
@@ -52,6 +58,10 @@ func (s *WebhookService) Process(req *WebhookRequest) (*http.Response, error) {
 		)
 	}
 
+	if req.HTTPRequest.Header.Get(HeaderGithubEvent) == EventPush {
+		s.notifyPush(app, body)
+	}
+
 	// generate signature for cloud server
 	signature, err := s.runner.SignPayload(body)
 	if err != nil {
@@ -69,16 +79,49 @@ func (s *WebhookService) Process(req *WebhookRequest) (*http.Response, error) {
 		Query:     nil,
 	})
 
-	slog.Info("Status code from DeepSource", slog.Int("status_code", res.StatusCode))
-
 	if err != nil {
 		err := fmt.Errorf("failed to proxy request: %w", err)
+		s.notifyForwardFailure(app, err.Error())
 		return nil, httperror.ErrUpstreamFailed(err)
 	}
 
+	slog.Info("Status code from DeepSource", slog.Int("status_code", res.StatusCode))
+	if res.StatusCode >= http.StatusInternalServerError {
+		s.notifyForwardFailure(app, fmt.Sprintf("DeepSource responded with status code %d", res.StatusCode))
+	}
+
 	return res, nil
 }
 
+// notifyForwardFailure notifies that a webhook of the app could not be
+// delivered to DeepSource.
+func (s *WebhookService) notifyForwardFailure(app *App, message string) {
+	app.Notifier.Notify(&notify.Notification{
+		Type:    notify.TypeWebhookForwardFailed,
+		Title:   fmt.Sprintf("Failed to forward a webhook of app %s to DeepSource", app.ID),
+		Message: message,
+		Fields:  map[string]string{"app_id": app.ID},
+	})
+}
+
+// notifyPush notifies the push listeners of a verified push webhook.
+func (s *WebhookService) notifyPush(app *App, body []byte) {
+	payload := &PushPayload{}
+	if err := json.Unmarshal(body, payload); err != nil {
+		slog.Warn("failed to parse push webhook", slog.Any("err", err))
+		return
+	}
+	if payload.Repository.CloneURL == "" {
+		return
+	}
+	s.pushNotifier.Notify(&model.PushEvent{
+		AppID:          app.ID,
+		InstallationID: strconv.FormatInt(payload.Installation.ID, 10),
+		RemoteURL:      payload.Repository.CloneURL,
+		Ref:            payload.Ref,
+	})
+}
+
 func (s *WebhookService) prepareHeader(app *App, signature string) http.Header {
 	header := http.Header{}
 	header.Set(HeaderRunnerID, s.runner.ID)
@@ -7,8 +7,11 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/deepsourcecorp/runner/internal/signer"
+	"github.com/deepsourcecorp/runner/notify"
 	"github.com/deepsourcecorp/runner/provider/model"
 	"github.com/deepsourcecorp/runner/testutil"
 	"github.com/stretchr/testify/assert"
@@ -59,7 +62,7 @@ func TestWebhookService_Process(t *testing.T) {
 			},
 		}, runner, &model.DeepSource{
 			Host: *serverURL,
-		}, http.DefaultClient)
+		}, http.DefaultClient, nil)
 
 	_, err := service.Process(wr)
 
@@ -132,3 +135,101 @@ func TestWebhookService_Process_InvalidBody(t *testing.T) {
 	assert.Equal(t, "unknown error", err.(*httperror.Error).Message)
 
 }
+
+type pushRecorder struct {
+	events chan *model.PushEvent
+}
+
+func (r *pushRecorder) OnPush(event *model.PushEvent) {
+	r.events <- event
+}
+
+func TestWebhookService_Process_Push(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main","repository":{"clone_url":"https://github.com/deepsourcelabs/demo-go.git"},"installation":{"id":42}}`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	recorder := &pushRecorder{events: make(chan *model.PushEvent, 1)}
+	notifier := model.NewPushNotifier()
+	notifier.Subscribe(recorder)
+
+	service := NewWebhookService(
+		&AppFactory{
+			apps: map[string]*App{
+				"test-app-id": {ID: "test-app-id", WebhookSecret: "app-webhook-secret"},
+			},
+		},
+		&model.Runner{WebhookSecret: "runner-webhook-secret"},
+		&model.DeepSource{Host: *serverURL},
+		http.DefaultClient,
+		notifier,
+	)
+
+	s, _ := signer.NewSHA256Signer([]byte("app-webhook-secret"))
+	signature, _ := s.Sign(body)
+
+	httpRequest := httptest.NewRequest(http.MethodPost, "https://example.com", bytes.NewReader(body))
+	httpRequest.Header.Set(HeaderGithubEvent, EventPush)
+	_, err := service.Process(&WebhookRequest{
+		AppID:       "test-app-id",
+		HTTPRequest: httpRequest,
+		Signature:   signature,
+	})
+	assert.NoError(t, err)
+
+	select {
+	case event := <-recorder.events:
+		assert.Equal(t, &model.PushEvent{
+			AppID:          "test-app-id",
+			InstallationID: "42",
+			RemoteURL:      "https://github.com/deepsourcelabs/demo-go.git",
+			Ref:            "refs/heads/main",
+		}, event)
+	case <-time.After(time.Second):
+		t.Fatal("push listener was not notified")
+	}
+}
+
+func TestWebhookService_Process_ForwardFailure(t *testing.T) {
+	body := []byte("test-body")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	sink := &notificationSink{}
+	notifier, _ := notify.New("test-runner-id", &notify.Route{Name: "test", Sink: sink})
+	app := &App{ID: "test-app-id", WebhookSecret: "app-webhook-secret", Notifier: notifier}
+	service := NewWebhookService(&AppFactory{apps: map[string]*App{"test-app-id": app}},
+		&model.Runner{ID: "test-runner-id", WebhookSecret: "runner-webhook-secret"},
+		&model.DeepSource{Host: *serverURL}, http.DefaultClient, nil)
+
+	res, err := service.Process(&WebhookRequest{
+		AppID:       "test-app-id",
+		HTTPRequest: httptest.NewRequest(http.MethodGet, "https://example.com", bytes.NewReader(body)),
+		Signature:   "sha256=825e0c233e2943e5eeffe9be54ed00a1c178c4b9457337cb8abf10a61645e347",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+
+	// Unreachable DeepSource.
+	server.Close()
+	_, err = service.Process(&WebhookRequest{
+		AppID:       "test-app-id",
+		HTTPRequest: httptest.NewRequest(http.MethodGet, "https://example.com", bytes.NewReader(body)),
+		Signature:   "sha256=825e0c233e2943e5eeffe9be54ed00a1c178c4b9457337cb8abf10a61645e347",
+	})
+	assert.Error(t, err)
+
+	notifier.Wait()
+	assert.Len(t, sink.sent, 2)
+	for _, n := range sink.sent {
+		assert.Equal(t, notify.TypeWebhookForwardFailed, n.Type)
+		assert.Equal(t, "test-app-id", n.Fields["app_id"])
+	}
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrInvalidPullRequest is returned when the VCS rejects a pull request,
+	// as when the head has no commits over the base.
+	ErrInvalidPullRequest = errors.New("invalid pull request")
+
+	errMissingPullRequestFields = errors.New("repo, head, base and title are required")
+	errInvalidRepository        = errors.New("repo must be owner/name")
+)
+
+// PullRequestRequest is a pull request to open, the same for every VCS.
+type PullRequestRequest struct {
+	// Repository is the owner/name of the repository.
+	Repository string `json:"repo"`
+
+	// Head is the branch with the changes.  Branches of forks are prefixed
+	// with their owner, as owner:branch.
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Draft bool   `json:"draft"`
+}
+
+func (r *PullRequestRequest) Validate() error {
+	if r.Repository == "" || r.Head == "" || r.Base == "" || r.Title == "" {
+		return errMissingPullRequestFields
+	}
+	if _, _, ok := r.OwnerRepo(); !ok {
+		return errInvalidRepository
+	}
+	return nil
+}
+
+// OwnerRepo returns the owner and name of the repository.
+func (r *PullRequestRequest) OwnerRepo() (string, string, bool) {
+	owner, repo, ok := strings.Cut(r.Repository, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", "", false
+	}
+	return owner, repo, true
+}
+
+// PullRequest is an open pull request, the same for every VCS.
+type PullRequest struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+	State  string `json:"state"`
+	Draft  bool   `json:"draft"`
+	Head   string `json:"head"`
+	Base   string `json:"base"`
+
+	// HeadSHA is the commit at the head of the pull request.
+	HeadSHA string `json:"head_sha"`
+
+	// Created is false when the pull request already existed.
+	Created bool `json:"created"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import "sync"
+
+// PushEvent is a push to a repository, received as a VCS webhook.
+type PushEvent struct {
+	AppID          string
+	InstallationID string
+	RemoteURL      string
+	Ref            string
+}
+
+type PushListener interface {
+	OnPush(event *PushEvent)
+}
+
+// PushNotifier fans out push events to the subscribed listeners.  Listeners
+// are called in their own goroutine so that webhook delivery is not delayed.
+type PushNotifier struct {
+	mu        sync.RWMutex
+	listeners []PushListener
+}
+
+func NewPushNotifier() *PushNotifier {
+	return &PushNotifier{}
+}
+
+func (n *PushNotifier) Subscribe(l PushListener) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.listeners = append(n.listeners, l)
+}
+
+func (n *PushNotifier) Notify(event *PushEvent) {
+	if n == nil {
+		return
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, l := range n.listeners {
+		go l.OnPush(event)
+	}
+}
@@ -1,7 +1,10 @@
 package provider
 
 import (
+	"net/http"
+
 	"github.com/deepsourcecorp/runner/provider/github"
+	"github.com/deepsourcecorp/runner/provider/model"
 	"github.com/labstack/echo/v4"
 )
 
@@ -10,18 +13,23 @@ type Router interface {
 }
 
 type Facade struct {
-	Adapter *Adapter
+	Adapter      *Adapter
+	PushNotifier *model.PushNotifier
 }
 
-func NewFacade(apps map[string]*App, githubProvider *github.Handler) *Facade {
+func NewFacade(apps map[string]*App, githubProvider *github.Handler, pushNotifier *model.PushNotifier) *Facade {
 	return &Facade{
-		Adapter: NewAdapter(apps, githubProvider),
+		Adapter:      NewAdapter(apps, githubProvider),
+		PushNotifier: pushNotifier,
 	}
 }
 
-func (f *Facade) AddRoutes(r Router) Router {
+// AddRoutes adds the provider endpoints.  The middleware authenticates the
+// endpoints DeepSource calls to act on the VCS, like opening pull requests.
+func (f *Facade) AddRoutes(r Router, middleware []echo.MiddlewareFunc) Router {
 	r.AddRoute("*", "apps/:app_id/webhook", f.Adapter.HandleWebhook)
 	r.AddRoute("*", "apps/:app_id/api/*", f.Adapter.HandleAPI)
 	r.AddRoute("*", "apps/:app_id/installation/new", f.Adapter.HandleInstallation)
+	r.AddRoute(http.MethodPost, "apps/:app_id/pulls", f.Adapter.HandlePullRequest, middleware...)
 	return r
 }
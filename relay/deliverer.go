@@ -0,0 +1,177 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	DefaultMaxAttempts = 10
+	DefaultBaseBackoff = 5 * time.Second
+	DefaultMaxBackoff  = 30 * time.Minute
+
+	deliveryBatchSize = 50
+	tokenExpiry       = 5 * time.Minute
+)
+
+type Signer interface {
+	GenerateToken(issuer string, scope []string, claims map[string]interface{}, expiry time.Duration) (string, error)
+}
+
+type DelivererOpts struct {
+	RunnerID       string
+	DeepSourceHost url.URL
+	MaxAttempts    int
+	BaseBackoff    time.Duration
+	MaxBackoff     time.Duration
+	Interval       time.Duration
+}
+
+// Deliverer forwards persisted payloads to DeepSource.  Failed deliveries are
+// retried with exponential backoff until MaxAttempts is reached, after which
+// the payload is moved to the dead-letter state.
+type Deliverer struct {
+	store  Store
+	signer Signer
+	client *http.Client
+	opts   *DelivererOpts
+	kick   chan struct{}
+}
+
+func NewDeliverer(store Store, signer Signer, client *http.Client, opts *DelivererOpts) *Deliverer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = DefaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = opts.BaseBackoff
+	}
+	return &Deliverer{
+		store:  store,
+		signer: signer,
+		client: client,
+		opts:   opts,
+		kick:   make(chan struct{}, 1),
+	}
+}
+
+// Start delivers pending payloads until the context is cancelled.  Deliveries
+// happen on every tick and whenever Notify is called.
+func (d *Deliverer) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("shutting down results relay")
+			return
+		case <-ticker.C:
+		case <-d.kick:
+		}
+		if err := d.DeliverPending(ctx); err != nil {
+			slog.Error("failed to deliver relayed results", slog.Any("err", err))
+		}
+	}
+}
+
+// Notify wakes up the delivery loop without waiting for the next tick.
+func (d *Deliverer) Notify() {
+	select {
+	case d.kick <- struct{}{}:
+	default:
+	}
+}
+
+// DeliverPending makes a single delivery attempt for every payload that is
+// due.
+func (d *Deliverer) DeliverPending(ctx context.Context) error {
+	payloads, err := d.store.Pending(time.Now(), deliveryBatchSize)
+	if err != nil {
+		return fmt.Errorf("relay: failed to fetch pending payloads: %w", err)
+	}
+	for _, p := range payloads {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := d.deliver(ctx, p); err != nil {
+			d.fail(p, err)
+			continue
+		}
+		if err := d.store.MarkDelivered(p.ID); err != nil {
+			slog.Error("failed to mark relayed payload as delivered", slog.String("id", p.ID), slog.Any("err", err))
+		}
+	}
+	return nil
+}
+
+func (d *Deliverer) deliver(ctx context.Context, p *Payload) error {
+	token, err := d.signer.GenerateToken(d.opts.RunnerID, []string{p.Scope}, nil, tokenExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	target := d.opts.DeepSourceHost.JoinPath(p.Path).String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(p.Body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if p.ContentType != "" {
+		req.Header.Set("Content-Type", p.ContentType)
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver payload: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("unexpected status code: %d, body=%s", res.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (d *Deliverer) fail(p *Payload, cause error) {
+	attempts := p.Attempts + 1
+	slog.Warn("failed to deliver relayed payload", slog.String("id", p.ID), slog.Int("attempts", attempts), slog.Any("err", cause))
+
+	var err error
+	if attempts >= d.opts.MaxAttempts {
+		err = d.store.MarkDead(p.ID, attempts, cause.Error())
+	} else {
+		err = d.store.MarkFailed(p.ID, attempts, time.Now().Add(d.backoff(attempts)), cause.Error())
+	}
+	if err != nil {
+		slog.Error("failed to update relayed payload", slog.String("id", p.ID), slog.Any("err", err))
+	}
+}
+
+// backoff returns the delay before the next attempt, doubling with every
+// failed attempt up to MaxBackoff.
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	delay := d.opts.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.opts.MaxBackoff {
+			return d.opts.MaxBackoff
+		}
+	}
+	return delay
+}
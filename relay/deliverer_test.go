@@ -0,0 +1,194 @@
+package relay
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore struct {
+	mu       sync.Mutex
+	payloads map[string]*Payload
+}
+
+func newMemStore() *memStore {
+	return &memStore{payloads: make(map[string]*Payload)}
+}
+
+func (s *memStore) Save(p *Payload) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *p
+	s.payloads[p.ID] = &cp
+	return nil
+}
+
+func (s *memStore) Pending(before time.Time, limit int) ([]*Payload, error) {
+	return s.filter(func(p *Payload) bool {
+		return p.Status == StatusPending && !p.NextAttemptAt.After(before)
+	}, limit), nil
+}
+
+func (s *memStore) MarkDelivered(id string) error {
+	return s.update(id, func(p *Payload) { p.Status = StatusDelivered })
+}
+
+func (s *memStore) MarkFailed(id string, attempts int, nextAttemptAt time.Time, lastErr string) error {
+	return s.update(id, func(p *Payload) {
+		p.Attempts = attempts
+		p.NextAttemptAt = nextAttemptAt
+		p.LastError = lastErr
+	})
+}
+
+func (s *memStore) MarkDead(id string, attempts int, lastErr string) error {
+	return s.update(id, func(p *Payload) {
+		p.Status = StatusDead
+		p.Attempts = attempts
+		p.LastError = lastErr
+	})
+}
+
+func (s *memStore) DeadLetters(limit int) ([]*Payload, error) {
+	return s.filter(func(p *Payload) bool { return p.Status == StatusDead }, limit), nil
+}
+
+func (s *memStore) get(id string) *Payload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.payloads[id]
+}
+
+func (s *memStore) update(id string, fn func(p *Payload)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payloads[id]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(p)
+	return nil
+}
+
+func (s *memStore) filter(fn func(p *Payload) bool, limit int) []*Payload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Payload
+	for _, p := range s.payloads {
+		if fn(p) {
+			cp := *p
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func newTestDeliverer(t *testing.T, store Store, handler http.HandlerFunc, maxAttempts int) *Deliverer {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	host, _ := url.Parse(server.URL)
+
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	return NewDeliverer(store, jwtutil.NewSigner(privateKey), http.DefaultClient, &DelivererOpts{
+		RunnerID:       "runner-id",
+		DeepSourceHost: *host,
+		MaxAttempts:    maxAttempts,
+		BaseBackoff:    time.Nanosecond,
+		MaxBackoff:     time.Nanosecond,
+	})
+}
+
+func TestDeliverer_DeliverPending(t *testing.T) {
+	t.Run("delivers payload", func(t *testing.T) {
+		store := newMemStore()
+		_ = store.Save(&Payload{ID: "1", Path: "/api/runner/analysis/results", Scope: "analysis.*", ContentType: "application/json", Body: []byte(`{"run_id":"1"}`), Status: StatusPending})
+
+		d := newTestDeliverer(t, store, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/runner/analysis/results", r.URL.Path)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.NotEmpty(t, r.Header.Get("Authorization"))
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, `{"run_id":"1"}`, string(body))
+			w.WriteHeader(http.StatusOK)
+		}, 3)
+
+		require.NoError(t, d.DeliverPending(context.Background()))
+		assert.Equal(t, StatusDelivered, store.get("1").Status)
+	})
+
+	t.Run("retries until delivered", func(t *testing.T) {
+		store := newMemStore()
+		_ = store.Save(&Payload{ID: "1", Path: "/api/runner/autofix/results", Scope: "autofix.*", Status: StatusPending})
+
+		calls := 0
+		d := newTestDeliverer(t, store, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls < 2 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}, 3)
+
+		require.NoError(t, d.DeliverPending(context.Background()))
+		p := store.get("1")
+		assert.Equal(t, StatusPending, p.Status)
+		assert.Equal(t, 1, p.Attempts)
+		assert.Contains(t, p.LastError, "502")
+
+		time.Sleep(time.Millisecond)
+		require.NoError(t, d.DeliverPending(context.Background()))
+		assert.Equal(t, StatusDelivered, store.get("1").Status)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("dead letters after max attempts", func(t *testing.T) {
+		store := newMemStore()
+		_ = store.Save(&Payload{ID: "1", Path: "/api/runner/transformer/results", Scope: "transform.*", Status: StatusPending})
+
+		d := newTestDeliverer(t, store, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, 2)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, d.DeliverPending(context.Background()))
+			time.Sleep(time.Millisecond)
+		}
+		p := store.get("1")
+		assert.Equal(t, StatusDead, p.Status)
+		assert.Equal(t, 2, p.Attempts)
+
+		dead, err := store.DeadLetters(10)
+		require.NoError(t, err)
+		assert.Len(t, dead, 1)
+	})
+}
+
+func TestDeliverer_backoff(t *testing.T) {
+	d := NewDeliverer(newMemStore(), nil, nil, &DelivererOpts{
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Second,
+	})
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 4*time.Second, d.backoff(3))
+	assert.Equal(t, 5*time.Second, d.backoff(4))
+	assert.Equal(t, 5*time.Second, d.backoff(10))
+}
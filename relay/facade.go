@@ -0,0 +1,49 @@
+package relay
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrMissingOpts = errors.New("missing required options")
+
+type Router interface {
+	AddRoute(method string, path string, handlerFunc echo.HandlerFunc, middleware ...echo.MiddlewareFunc)
+}
+
+type Opts struct {
+	RunnerID string
+	Verifier *jwtutil.Verifier
+	Signer   Signer
+	Store    Store
+
+	*DelivererOpts
+}
+
+// Facade wires up the results relay.  Jobs post their results to the runner,
+// which persists them and forwards them to DeepSource, so that analysis pods
+// never need to reach the internet.
+type Facade struct {
+	Handler   *Handler
+	Deliverer *Deliverer
+}
+
+func New(opts *Opts, client *http.Client) (*Facade, error) {
+	if opts == nil || opts.Verifier == nil || opts.Signer == nil || opts.Store == nil || opts.DelivererOpts == nil {
+		return nil, ErrMissingOpts
+	}
+	deliverer := NewDeliverer(opts.Store, opts.Signer, client, opts.DelivererOpts)
+	return &Facade{
+		Handler:   NewHandler(opts.RunnerID, opts.Verifier, opts.Store, deliverer),
+		Deliverer: deliverer,
+	}, nil
+}
+
+func (f *Facade) AddRoutes(r Router, adminMiddleware []echo.MiddlewareFunc) Router {
+	r.AddRoute(http.MethodPost, PathPrefix+"/api/runner/*", f.Handler.HandleResult)
+	r.AddRoute(http.MethodGet, "/admin/relay/deadletters", f.Handler.HandleDeadLetters, adminMiddleware...)
+	return r
+}
@@ -0,0 +1,143 @@
+package relay
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+	"github.com/segmentio/ksuid"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// PathPrefix is the prefix under which the relay accepts results.  Jobs
+	// publish to PathPrefix + <DeepSource result path>.
+	PathPrefix = "/relay"
+
+	maxBodySize            = 64 << 20
+	defaultDeadLetterLimit = 100
+)
+
+// publishScopes maps the result paths jobs publish to onto the token scope
+// the job is issued for that path.
+var publishScopes = map[string]string{
+	"/api/runner/analysis/results":          "analysis.*",
+	"/api/runner/autofix/results":           "autofix.*",
+	"/api/runner/autofix/committer/results": "autofix.*",
+	"/api/runner/transformer/results":       "transform.*",
+}
+
+var (
+	errUnknownPath  = errors.New("unknown result path")
+	errInvalidToken = errors.New("invalid job token")
+	errBodyTooLarge = errors.New("payload too large")
+)
+
+type Handler struct {
+	runnerID  string
+	verifier  *jwtutil.Verifier
+	store     Store
+	deliverer *Deliverer
+}
+
+func NewHandler(runnerID string, verifier *jwtutil.Verifier, store Store, deliverer *Deliverer) *Handler {
+	return &Handler{
+		runnerID:  runnerID,
+		verifier:  verifier,
+		store:     store,
+		deliverer: deliverer,
+	}
+}
+
+// HandleResult accepts a result from a job, persists it and schedules it for
+// delivery to DeepSource.  The job is acknowledged as soon as the payload is
+// persisted.
+func (h *Handler) HandleResult(c echo.Context) error {
+	path := strings.TrimPrefix(c.Request().URL.Path, PathPrefix)
+	scope, ok := publishScopes[path]
+	if !ok {
+		return httperror.New(http.StatusNotFound, "unknown result path", errUnknownPath)
+	}
+
+	if err := h.verifyToken(c.Request().Header.Get("Authorization"), scope); err != nil {
+		slog.Error("relay: rejected result", slog.String("path", path), slog.Any("err", err))
+		return httperror.ErrUnauthorized(err)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxBodySize+1))
+	if err != nil {
+		return httperror.ErrBadRequest(err)
+	}
+	if len(body) > maxBodySize {
+		return httperror.New(http.StatusRequestEntityTooLarge, "payload too large", errBodyTooLarge)
+	}
+
+	now := time.Now()
+	payload := &Payload{
+		ID:            ksuid.New().String(),
+		Path:          path,
+		Scope:         scope,
+		ContentType:   c.Request().Header.Get("Content-Type"),
+		Body:          body,
+		Status:        StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := h.store.Save(payload); err != nil {
+		slog.Error("relay: failed to persist result", slog.String("path", path), slog.Any("err", err))
+		return httperror.ErrUnknown(err)
+	}
+	h.deliverer.Notify()
+
+	return c.NoContent(http.StatusOK)
+}
+
+// HandleDeadLetters lists the payloads that could not be delivered to
+// DeepSource.
+func (h *Handler) HandleDeadLetters(c echo.Context) error {
+	limit := defaultDeadLetterLimit
+	if v := c.QueryParam("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			return httperror.ErrBadRequest(err)
+		}
+		limit = l
+	}
+	payloads, err := h.store.DeadLetters(limit)
+	if err != nil {
+		return httperror.ErrUnknown(err)
+	}
+	if payloads == nil {
+		payloads = []*Payload{}
+	}
+	return c.JSON(http.StatusOK, payloads)
+}
+
+// verifyToken checks that the bearer token was issued by this runner for
+// the given scope.
+func (h *Handler) verifyToken(authorization, scope string) error {
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return errInvalidToken
+	}
+	claims, err := h.verifier.Verify(parts[1])
+	if err != nil {
+		return err
+	}
+	if claims["iss"] != h.runnerID {
+		return errInvalidToken
+	}
+	scp, _ := claims["scp"].(string)
+	for _, s := range strings.Split(scp, " ") {
+		if s == scope {
+			return nil
+		}
+	}
+	return errInvalidToken
+}
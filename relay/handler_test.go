@@ -0,0 +1,97 @@
+package relay
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_HandleResult(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	signer := jwtutil.NewSigner(privateKey)
+	verifier := jwtutil.NewVerifier(&privateKey.PublicKey)
+
+	newRequest := func(path, token string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"run_id":"1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		return echo.New().NewContext(req, rec), rec
+	}
+
+	t.Run("persists valid result", func(t *testing.T) {
+		store := newMemStore()
+		h := NewHandler("runner-id", verifier, store, NewDeliverer(store, signer, nil, &DelivererOpts{}))
+		token, _ := signer.GenerateToken("runner-id", []string{"analysis.*"}, nil, time.Minute)
+
+		c, rec := newRequest("/relay/api/runner/analysis/results", token)
+		require.NoError(t, h.HandleResult(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		pending, _ := store.Pending(time.Now(), 10)
+		require.Len(t, pending, 1)
+		assert.Equal(t, "/api/runner/analysis/results", pending[0].Path)
+		assert.Equal(t, "analysis.*", pending[0].Scope)
+		assert.Equal(t, "application/json", pending[0].ContentType)
+		assert.Equal(t, `{"run_id":"1"}`, string(pending[0].Body))
+	})
+
+	t.Run("rejects unknown path", func(t *testing.T) {
+		store := newMemStore()
+		h := NewHandler("runner-id", verifier, store, NewDeliverer(store, signer, nil, &DelivererOpts{}))
+		token, _ := signer.GenerateToken("runner-id", []string{"analysis.*"}, nil, time.Minute)
+
+		c, _ := newRequest("/relay/api/runner/unknown", token)
+		err := h.HandleResult(c)
+		var httpErr *httperror.Error
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusNotFound, httpErr.Code)
+	})
+
+	t.Run("rejects token with wrong scope", func(t *testing.T) {
+		store := newMemStore()
+		h := NewHandler("runner-id", verifier, store, NewDeliverer(store, signer, nil, &DelivererOpts{}))
+		token, _ := signer.GenerateToken("runner-id", []string{"autofix.*"}, nil, time.Minute)
+
+		c, _ := newRequest("/relay/api/runner/analysis/results", token)
+		err := h.HandleResult(c)
+		var httpErr *httperror.Error
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusUnauthorized, httpErr.Code)
+	})
+
+	t.Run("rejects token from another issuer", func(t *testing.T) {
+		store := newMemStore()
+		h := NewHandler("runner-id", verifier, store, NewDeliverer(store, signer, nil, &DelivererOpts{}))
+		token, _ := signer.GenerateToken("other-runner", []string{"analysis.*"}, nil, time.Minute)
+
+		c, _ := newRequest("/relay/api/runner/analysis/results", token)
+		err := h.HandleResult(c)
+		var httpErr *httperror.Error
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusUnauthorized, httpErr.Code)
+	})
+
+	t.Run("rejects missing token", func(t *testing.T) {
+		store := newMemStore()
+		h := NewHandler("runner-id", verifier, store, NewDeliverer(store, signer, nil, &DelivererOpts{}))
+
+		c, _ := newRequest("/relay/api/runner/analysis/results", "")
+		err := h.HandleResult(c)
+		var httpErr *httperror.Error
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusUnauthorized, httpErr.Code)
+	})
+}
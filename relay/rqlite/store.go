@@ -0,0 +1,144 @@
+package rqlite
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/deepsourcecorp/runner/relay"
+	"github.com/rqlite/gorqlite"
+)
+
+var tableName = "relay_payloads"
+
+var columns = []string{"id", "path", "scope", "content_type", "body", "status", "attempts", "last_error", "next_attempt_at", "created_at"}
+
+type Store struct {
+	db *gorqlite.Connection
+}
+
+func New(db *gorqlite.Connection) relay.Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Save(p *relay.Payload) error {
+	builder := squirrel.Insert(tableName).
+		Columns(columns...).
+		Values(
+			p.ID,
+			p.Path,
+			p.Scope,
+			p.ContentType,
+			base64.StdEncoding.EncodeToString(p.Body),
+			p.Status,
+			p.Attempts,
+			p.LastError,
+			p.NextAttemptAt.Unix(),
+			p.CreatedAt.Unix(),
+		)
+	return s.write(builder)
+}
+
+func (s *Store) Pending(before time.Time, limit int) ([]*relay.Payload, error) {
+	builder := squirrel.Select(columns...).
+		From(tableName).
+		Where(squirrel.Eq{"status": relay.StatusPending}).
+		Where(squirrel.LtOrEq{"next_attempt_at": before.Unix()}).
+		OrderBy("next_attempt_at").
+		Limit(uint64(limit))
+	return s.query(builder)
+}
+
+func (s *Store) MarkDelivered(id string) error {
+	builder := squirrel.Update(tableName).
+		Set("status", relay.StatusDelivered).
+		Set("body", "").
+		Where(squirrel.Eq{"id": id})
+	return s.write(builder)
+}
+
+func (s *Store) MarkFailed(id string, attempts int, nextAttemptAt time.Time, lastErr string) error {
+	builder := squirrel.Update(tableName).
+		Set("attempts", attempts).
+		Set("next_attempt_at", nextAttemptAt.Unix()).
+		Set("last_error", lastErr).
+		Where(squirrel.Eq{"id": id})
+	return s.write(builder)
+}
+
+func (s *Store) MarkDead(id string, attempts int, lastErr string) error {
+	builder := squirrel.Update(tableName).
+		Set("status", relay.StatusDead).
+		Set("attempts", attempts).
+		Set("last_error", lastErr).
+		Where(squirrel.Eq{"id": id})
+	return s.write(builder)
+}
+
+func (s *Store) DeadLetters(limit int) ([]*relay.Payload, error) {
+	builder := squirrel.Select(columns...).
+		From(tableName).
+		Where(squirrel.Eq{"status": relay.StatusDead}).
+		OrderBy("created_at DESC").
+		Limit(uint64(limit))
+	return s.query(builder)
+}
+
+type sqlizer interface {
+	ToSql() (string, []interface{}, error)
+}
+
+func (s *Store) write(builder sqlizer) error {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return fmt.Errorf("relay/rqlite: failed to build query: %w", err)
+	}
+	_, err = s.db.WriteOneParameterized(
+		gorqlite.ParameterizedStatement{
+			Query:     query,
+			Arguments: args,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("relay/rqlite: failed to write to rqlite: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) query(builder squirrel.SelectBuilder) ([]*relay.Payload, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("relay/rqlite: failed to build query: %w", err)
+	}
+	rows, err := s.db.QueryOneParameterized(
+		gorqlite.ParameterizedStatement{
+			Query:     query,
+			Arguments: args,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("relay/rqlite: failed to query rqlite: %w", err)
+	}
+
+	var payloads []*relay.Payload
+	for rows.Next() {
+		var (
+			p                        relay.Payload
+			body                     string
+			nextAttemptAt, createdAt int64
+		)
+		err := rows.Scan(&p.ID, &p.Path, &p.Scope, &p.ContentType, &body, &p.Status, &p.Attempts, &p.LastError, &nextAttemptAt, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("relay/rqlite: failed to scan row: %w", err)
+		}
+		p.Body, err = base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("relay/rqlite: failed to decode body: %w", err)
+		}
+		p.NextAttemptAt = time.Unix(nextAttemptAt, 0)
+		p.CreatedAt = time.Unix(createdAt, 0)
+		payloads = append(payloads, &p)
+	}
+	return payloads, nil
+}
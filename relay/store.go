@@ -0,0 +1,40 @@
+package relay
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+var ErrNotFound = errors.New("relay: payload not found")
+
+// Payload is a job result received by the relay, along with its delivery
+// state.
+type Payload struct {
+	ID            string    `json:"id"`
+	Path          string    `json:"path"`
+	Scope         string    `json:"scope"`
+	ContentType   string    `json:"content_type"`
+	Body          []byte    `json:"-"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Store persists payloads so that results survive runner restarts until they
+// are delivered to DeepSource.
+type Store interface {
+	Save(p *Payload) error
+	Pending(before time.Time, limit int) ([]*Payload, error)
+	MarkDelivered(id string) error
+	MarkFailed(id string, attempts int, nextAttemptAt time.Time, lastErr string) error
+	MarkDead(id string, attempts int, lastErr string) error
+	DeadLetters(limit int) ([]*Payload, error)
+}
@@ -0,0 +1,51 @@
+package rightsizing
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+var ErrMissingOpts = errors.New("missing required options")
+
+type Router interface {
+	AddRoute(method string, path string, handlerFunc echo.HandlerFunc, middleware ...echo.MiddlewareFunc)
+}
+
+type Opts struct {
+	Store Store
+
+	// Metrics reads the usage of running pods.  Nil when jobs are not run,
+	// as with the printer driver.
+	Metrics MetricsClient
+
+	*RecommenderOpts
+}
+
+// Facade wires up right-sizing: the recommender sampling pod usage, and the
+// recommendations endpoints.
+type Facade struct {
+	Handler     *Handler
+	Recommender *Recommender
+}
+
+func New(opts *Opts) (*Facade, error) {
+	if opts == nil || opts.Store == nil || opts.RecommenderOpts == nil {
+		return nil, ErrMissingOpts
+	}
+	recommender, err := NewRecommender(opts.Store, opts.Metrics, opts.RecommenderOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &Facade{
+		Handler:     NewHandler(recommender),
+		Recommender: recommender,
+	}, nil
+}
+
+func (f *Facade) AddRoutes(r Router, adminMiddleware []echo.MiddlewareFunc) Router {
+	r.AddRoute(http.MethodGet, "/admin/rightsizing", f.Handler.HandleRecommendations, adminMiddleware...)
+	r.AddRoute(http.MethodGet, "/admin/rightsizing/:shortcode", f.Handler.HandleRecommendation, adminMiddleware...)
+	return r
+}
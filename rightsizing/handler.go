@@ -0,0 +1,35 @@
+package rightsizing
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+var errNoRecommendation = errors.New("not enough samples to recommend limits for the analyzer")
+
+type Handler struct {
+	recommender *Recommender
+}
+
+func NewHandler(recommender *Recommender) *Handler {
+	return &Handler{recommender: recommender}
+}
+
+// HandleRecommendations returns the recommended limits of all analyzers with
+// enough samples.
+func (h *Handler) HandleRecommendations(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.recommender.Recommendations())
+}
+
+// HandleRecommendation returns the recommended limits of the analyzer with
+// the shortcode.
+func (h *Handler) HandleRecommendation(c echo.Context) error {
+	rec := h.recommender.Recommendation(c.Param("shortcode"))
+	if rec == nil {
+		return httperror.New(http.StatusNotFound, errNoRecommendation.Error(), errNoRecommendation)
+	}
+	return c.JSON(http.StatusOK, rec)
+}
@@ -0,0 +1,47 @@
+package rightsizing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	store := newMemStore()
+	require.NoError(t, store.SavePercentiles(&Percentiles{
+		Analyzer:  "python",
+		Samples:   20,
+		CPUMillis: &Distribution{P95: 1000},
+		Memory:    &Distribution{P95: 1 << 30},
+	}))
+	r, err := NewRecommender(store, nil, &RecommenderOpts{})
+	require.NoError(t, err)
+	require.NoError(t, r.Load())
+	h := NewHandler(r)
+
+	t.Run("all analyzers", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin/rightsizing", nil), rec)
+		require.NoError(t, h.HandleRecommendations(c))
+		var recommendations []*Recommendation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &recommendations))
+		require.Len(t, recommendations, 1)
+		assert.Equal(t, int64(1200), recommendations[0].CPUMillis)
+		assert.Equal(t, int64(1229), recommendations[0].MemoryMiB)
+	})
+
+	t.Run("unknown analyzer", func(t *testing.T) {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin/rightsizing/go", nil), httptest.NewRecorder())
+		c.SetParamNames("shortcode")
+		c.SetParamValues("go")
+		var httpErr *httperror.Error
+		require.ErrorAs(t, h.HandleRecommendation(c), &httpErr)
+		assert.Equal(t, http.StatusNotFound, httpErr.Code)
+	})
+}
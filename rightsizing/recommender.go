@@ -0,0 +1,378 @@
+package rightsizing
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	DefaultSampleInterval = 30 * time.Second
+	DefaultWindow         = 30 * 24 * time.Hour
+	DefaultPercentile     = 95
+	DefaultHeadroom       = 0.2
+	DefaultMinSamples     = 20
+)
+
+const mebibyte = 1 << 20
+
+// completedBuffer is how many completed pods can wait to be sampled before
+// more are dropped.
+const completedBuffer = 64
+
+var ErrInvalidPercentile = errors.New("rightsizing: percentile must be one of 50, 90, 95, 99 or 100")
+
+// PodMetrics is the current usage of the analyzer container of a job pod.
+type PodMetrics struct {
+	Pod       string
+	Analyzer  string
+	CPUMillis int64
+	Memory    int64
+}
+
+// MetricsClient reads the usage of the running analysis pods, as from the
+// Kubernetes metrics API.
+type MetricsClient interface {
+	PodMetrics(ctx context.Context) ([]*PodMetrics, error)
+}
+
+// Bounds are the limits recommendations are clamped to.  Zero is unbounded.
+type Bounds struct {
+	MinCPUMillis int64
+	MaxCPUMillis int64
+	MinMemoryMiB int64
+	MaxMemoryMiB int64
+}
+
+type RecommenderOpts struct {
+	// SampleInterval is how often the usage of running pods is read.
+	SampleInterval time.Duration
+
+	// Window is how long samples are kept and used for percentiles.
+	Window time.Duration
+
+	// Percentile of the peak usage of pods recommendations are based on.
+	Percentile int
+
+	// Headroom is added on top of the percentile, as a fraction of it.
+	// DefaultHeadroom when nil, so that no headroom can be configured.
+	Headroom *float64
+
+	// MinSamples is the number of samples an analyzer needs before limits
+	// are recommended for it.
+	MinSamples int
+
+	// Apply, when set, makes analysis jobs use the recommended limits
+	// instead of those of the analyzer.
+	Apply  bool
+	Bounds *Bounds
+}
+
+// Recommendation is the recommended limits of an analyzer, in millicores
+// and MiB.
+type Recommendation struct {
+	Analyzer    string       `json:"analyzer"`
+	CPUMillis   int64        `json:"cpu_millis"`
+	MemoryMiB   int64        `json:"memory_mib"`
+	Applied     bool         `json:"applied"`
+	Percentiles *Percentiles `json:"percentiles"`
+}
+
+// Recommender samples the usage of analysis pods while they run, and
+// recommends analyzer limits from the percentiles of their peak usage.  The
+// peak of a pod is recorded as a sample once the pod no longer reports
+// metrics, or once it is reported completed.
+type Recommender struct {
+	store   Store
+	metrics MetricsClient
+	opts    *RecommenderOpts
+	now     func() time.Time
+
+	// peaks are the peak usage of the running pods, by pod name.
+	peaks map[string]*Sample
+
+	// completed are the pods reported completed, to sample before their
+	// metrics are gone.
+	completed chan string
+
+	mu          sync.RWMutex
+	percentiles map[string]*Percentiles
+}
+
+func NewRecommender(store Store, metrics MetricsClient, opts *RecommenderOpts) (*Recommender, error) {
+	if opts.SampleInterval <= 0 {
+		opts.SampleInterval = DefaultSampleInterval
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.Percentile == 0 {
+		opts.Percentile = DefaultPercentile
+	}
+	switch opts.Percentile {
+	case 50, 90, 95, 99, 100:
+	default:
+		return nil, ErrInvalidPercentile
+	}
+	if opts.Headroom == nil {
+		headroom := DefaultHeadroom
+		opts.Headroom = &headroom
+	}
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = DefaultMinSamples
+	}
+	return &Recommender{
+		store:       store,
+		metrics:     metrics,
+		opts:        opts,
+		now:         time.Now,
+		peaks:       make(map[string]*Sample),
+		completed:   make(chan string, completedBuffer),
+		percentiles: make(map[string]*Percentiles),
+	}, nil
+}
+
+// Load reads the stored percentiles, so that recommendations survive
+// restarts.
+func (r *Recommender) Load() error {
+	percentiles, err := r.store.Percentiles()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range percentiles {
+		r.percentiles[p.Analyzer] = p
+	}
+	return nil
+}
+
+// Start samples the usage of running pods, and of the pods reported
+// completed, until the context is cancelled.
+func (r *Recommender) Start(ctx context.Context) {
+	if err := r.Load(); err != nil {
+		slog.Error("rightsizing: failed to load percentiles", slog.Any("err", err))
+	}
+	if r.metrics == nil {
+		return
+	}
+	ticker := time.NewTicker(r.opts.SampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Sample(ctx); err != nil {
+				slog.Error("rightsizing: failed to sample pod usage", slog.Any("err", err))
+			}
+		case pod := <-r.completed:
+			if err := r.sample(ctx, pod); err != nil {
+				slog.Error("rightsizing: failed to sample completed pod usage", slog.String("pod", pod), slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// SamplePod reports the completion of the analyzer of a pod.  The pod is
+// sampled once more, so that pods shorter than the sample interval are
+// sampled too, and its peak saved.  Pods are dropped when too many are
+// waiting.
+func (r *Recommender) SamplePod(pod string) {
+	if r == nil || r.metrics == nil {
+		return
+	}
+	select {
+	case r.completed <- pod:
+	default:
+		slog.Warn("rightsizing: dropped completed pod", slog.String("pod", pod))
+	}
+}
+
+// Sample reads the usage of the running pods.  The peaks of the pods that
+// finished since the last call are saved, and the percentiles of their
+// analyzers updated.
+func (r *Recommender) Sample(ctx context.Context) error {
+	return r.sample(ctx, "")
+}
+
+// sample is Sample, with the completed pod saved even if it still reports
+// metrics.
+func (r *Recommender) sample(ctx context.Context, completed string) error {
+	metrics, err := r.metrics.PodMetrics(ctx)
+	if err != nil {
+		return err
+	}
+	now := r.now()
+
+	running := make(map[string]bool, len(metrics))
+	for _, m := range metrics {
+		running[m.Pod] = true
+		peak, ok := r.peaks[m.Pod]
+		if !ok {
+			peak = &Sample{Pod: m.Pod, Analyzer: m.Analyzer}
+			r.peaks[m.Pod] = peak
+		}
+		if m.CPUMillis > peak.CPUMillis {
+			peak.CPUMillis = m.CPUMillis
+		}
+		if m.Memory > peak.Memory {
+			peak.Memory = m.Memory
+		}
+	}
+
+	finished := make(map[string]bool)
+	for pod, peak := range r.peaks {
+		if running[pod] && pod != completed {
+			continue
+		}
+		peak.CreatedAt = now
+		if err := r.store.SaveSample(peak); err != nil {
+			return err
+		}
+		delete(r.peaks, pod)
+		finished[peak.Analyzer] = true
+	}
+	if len(finished) == 0 {
+		return nil
+	}
+
+	since := now.Add(-r.opts.Window)
+	if err := r.store.DeleteSamples(since); err != nil {
+		return err
+	}
+	for analyzer := range finished {
+		if err := r.update(analyzer, since, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recommender) update(analyzer string, since, now time.Time) error {
+	samples, err := r.store.Samples(analyzer, since)
+	if err != nil {
+		return err
+	}
+	cpu := make([]int64, len(samples))
+	memory := make([]int64, len(samples))
+	for i, s := range samples {
+		cpu[i], memory[i] = s.CPUMillis, s.Memory
+	}
+	p := &Percentiles{
+		Analyzer:  analyzer,
+		Samples:   len(samples),
+		CPUMillis: distribution(cpu),
+		Memory:    distribution(memory),
+		UpdatedAt: now,
+	}
+	if err := r.store.SavePercentiles(p); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.percentiles[analyzer] = p
+	r.mu.Unlock()
+	return nil
+}
+
+// Recommendations returns the recommended limits of the analyzers with
+// enough samples, sorted by analyzer.
+func (r *Recommender) Recommendations() []*Recommendation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	recommendations := []*Recommendation{}
+	for analyzer := range r.percentiles {
+		if rec := r.recommendation(analyzer); rec != nil {
+			recommendations = append(recommendations, rec)
+		}
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Analyzer < recommendations[j].Analyzer
+	})
+	return recommendations
+}
+
+// Recommendation returns the recommended limits of the analyzer, or nil when
+// it does not have enough samples.
+func (r *Recommender) Recommendation(analyzer string) *Recommendation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.recommendation(analyzer)
+}
+
+func (r *Recommender) recommendation(analyzer string) *Recommendation {
+	p, ok := r.percentiles[analyzer]
+	if !ok || p.Samples < r.opts.MinSamples {
+		return nil
+	}
+	headroom := *r.opts.Headroom
+	cpu := int64(math.Ceil(float64(p.CPUMillis.Percentile(r.opts.Percentile)) * (1 + headroom)))
+	memory := int64(math.Ceil(float64(p.Memory.Percentile(r.opts.Percentile)) * (1 + headroom) / mebibyte))
+	if b := r.opts.Bounds; b != nil {
+		cpu = clamp(cpu, b.MinCPUMillis, b.MaxCPUMillis)
+		memory = clamp(memory, b.MinMemoryMiB, b.MaxMemoryMiB)
+	}
+	return &Recommendation{
+		Analyzer:    analyzer,
+		CPUMillis:   cpu,
+		MemoryMiB:   memory,
+		Applied:     r.opts.Apply,
+		Percentiles: p,
+	}
+}
+
+// Applied reports whether the recommendations are applied to jobs.
+func (r *Recommender) Applied() bool {
+	return r.opts.Apply
+}
+
+// Limits returns the limits analysis jobs of the analyzer run with, in
+// millicores and MiB.  ok is false unless recommendations are applied and
+// the analyzer has one.
+func (r *Recommender) Limits(analyzer string) (cpuMillis, memoryMiB int64, ok bool) {
+	if !r.opts.Apply {
+		return 0, 0, false
+	}
+	rec := r.Recommendation(analyzer)
+	if rec == nil || rec.CPUMillis <= 0 || rec.MemoryMiB <= 0 {
+		return 0, 0, false
+	}
+	return rec.CPUMillis, rec.MemoryMiB, true
+}
+
+// distribution returns the nearest-rank percentiles of the values.
+func distribution(values []int64) *Distribution {
+	if len(values) == 0 {
+		return &Distribution{}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := func(p float64) int64 {
+		i := int(math.Ceil(p/100*float64(len(values)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return values[i]
+	}
+	return &Distribution{
+		P50: rank(50),
+		P90: rank(90),
+		P95: rank(95),
+		P99: rank(99),
+		Max: values[len(values)-1],
+	}
+}
+
+func clamp(v, lo, hi int64) int64 {
+	if lo > 0 && v < lo {
+		v = lo
+	}
+	if hi > 0 && v > hi {
+		v = hi
+	}
+	return v
+}
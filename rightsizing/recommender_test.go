@@ -0,0 +1,203 @@
+package rightsizing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore struct {
+	mu          sync.Mutex
+	samples     map[string]*Sample
+	percentiles map[string]*Percentiles
+}
+
+func newMemStore() *memStore {
+	return &memStore{samples: make(map[string]*Sample), percentiles: make(map[string]*Percentiles)}
+}
+
+func (s *memStore) SaveSample(sample *Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *sample
+	s.samples[sample.Pod] = &cp
+	return nil
+}
+
+func (s *memStore) Samples(analyzer string, since time.Time) ([]*Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var samples []*Sample
+	for _, sample := range s.samples {
+		if sample.Analyzer == analyzer && !sample.CreatedAt.Before(since) {
+			cp := *sample
+			samples = append(samples, &cp)
+		}
+	}
+	return samples, nil
+}
+
+func (s *memStore) DeleteSamples(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pod, sample := range s.samples {
+		if sample.CreatedAt.Before(before) {
+			delete(s.samples, pod)
+		}
+	}
+	return nil
+}
+
+func (s *memStore) SavePercentiles(p *Percentiles) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.percentiles[p.Analyzer] = p
+	return nil
+}
+
+func (s *memStore) Percentiles() ([]*Percentiles, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var percentiles []*Percentiles
+	for _, p := range s.percentiles {
+		percentiles = append(percentiles, p)
+	}
+	sort.Slice(percentiles, func(i, j int) bool { return percentiles[i].Analyzer < percentiles[j].Analyzer })
+	return percentiles, nil
+}
+
+// fakeMetrics returns the pod metrics it is set to.
+type fakeMetrics struct {
+	pods []*PodMetrics
+}
+
+func (m *fakeMetrics) PodMetrics(context.Context) ([]*PodMetrics, error) {
+	return m.pods, nil
+}
+
+var testNow = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+// runPods samples n pods of the analyzer through two polls each, the second
+// being their peak, and then their completion.
+func runPods(t *testing.T, r *Recommender, metrics *fakeMetrics, analyzer string, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		pod := fmt.Sprintf("%s-%d", analyzer, i)
+		metrics.pods = []*PodMetrics{{Pod: pod, Analyzer: analyzer, CPUMillis: int64(i * 5), Memory: int64(i) << 20}}
+		require.NoError(t, r.Sample(context.Background()))
+		metrics.pods = []*PodMetrics{{Pod: pod, Analyzer: analyzer, CPUMillis: int64(i * 10), Memory: int64(i*10) << 20}}
+		require.NoError(t, r.Sample(context.Background()))
+	}
+	metrics.pods = nil
+	require.NoError(t, r.Sample(context.Background()))
+}
+
+func TestRecommender_Sample(t *testing.T) {
+	store := newMemStore()
+	metrics := &fakeMetrics{}
+	r, err := NewRecommender(store, metrics, &RecommenderOpts{MinSamples: 10})
+	require.NoError(t, err)
+	r.now = func() time.Time { return testNow }
+
+	runPods(t, r, metrics, "python", 100)
+	require.Len(t, store.samples, 100)
+	assert.Equal(t, &Sample{Pod: "python-7", Analyzer: "python", CPUMillis: 70, Memory: 70 << 20, CreatedAt: testNow}, store.samples["python-7"])
+
+	p := store.percentiles["python"]
+	require.NotNil(t, p)
+	assert.Equal(t, 100, p.Samples)
+	assert.Equal(t, &Distribution{P50: 500, P90: 900, P95: 950, P99: 990, Max: 1000}, p.CPUMillis)
+
+	rec := r.Recommendation("python")
+	require.NotNil(t, rec)
+	assert.Equal(t, int64(1140), rec.CPUMillis, "p95 with 20% headroom")
+	assert.Equal(t, int64(1140), rec.MemoryMiB)
+	assert.False(t, rec.Applied)
+
+	runPods(t, r, metrics, "go", 5)
+	assert.Nil(t, r.Recommendation("go"), "too few samples")
+	assert.Len(t, r.Recommendations(), 1)
+
+	// Samples older than the window are dropped.
+	r.now = func() time.Time { return testNow.Add(DefaultWindow + time.Hour) }
+	runPods(t, r, metrics, "go", 10)
+	assert.Len(t, store.samples, 10)
+}
+
+func TestRecommender_Limits(t *testing.T) {
+	store := newMemStore()
+	require.NoError(t, store.SavePercentiles(&Percentiles{
+		Analyzer:  "python",
+		Samples:   50,
+		CPUMillis: &Distribution{P50: 100, P90: 200, P95: 300, P99: 4000, Max: 5000},
+		Memory:    &Distribution{P50: 64 << 20, P90: 96 << 20, P95: 100 << 20, P99: 128 << 20, Max: 256 << 20},
+	}))
+
+	headroom := 0.5
+	r, err := NewRecommender(store, nil, &RecommenderOpts{
+		Percentile: 99,
+		Headroom:   &headroom,
+		Bounds:     &Bounds{MaxCPUMillis: 2000, MinMemoryMiB: 256},
+	})
+	require.NoError(t, err)
+	require.NoError(t, r.Load())
+
+	_, _, ok := r.Limits("python")
+	assert.False(t, ok, "recommendations are only applied with Apply")
+
+	r.opts.Apply = true
+	cpu, memory, ok := r.Limits("python")
+	require.True(t, ok)
+	assert.Equal(t, int64(2000), cpu, "clamped to the maximum")
+	assert.Equal(t, int64(256), memory, "clamped to the minimum")
+
+	_, _, ok = r.Limits("go")
+	assert.False(t, ok)
+}
+
+func TestNewRecommender(t *testing.T) {
+	_, err := NewRecommender(newMemStore(), nil, &RecommenderOpts{Percentile: 75})
+	assert.ErrorIs(t, err, ErrInvalidPercentile)
+}
+
+func TestRecommender_SamplePod(t *testing.T) {
+	store := newMemStore()
+	metrics := &fakeMetrics{}
+	r, err := NewRecommender(store, metrics, &RecommenderOpts{})
+	require.NoError(t, err)
+	r.now = func() time.Time { return testNow }
+
+	// The pod completes before the sample interval, while it still reports
+	// metrics.
+	metrics.pods = []*PodMetrics{{Pod: "python-1", Analyzer: "python", CPUMillis: 300, Memory: 64 << 20}}
+	r.SamplePod("python-1")
+	require.NoError(t, r.sample(context.Background(), <-r.completed))
+	assert.Equal(t, &Sample{Pod: "python-1", Analyzer: "python", CPUMillis: 300, Memory: 64 << 20, CreatedAt: testNow}, store.samples["python-1"])
+	assert.Equal(t, 1, store.percentiles["python"].Samples)
+	assert.Empty(t, r.peaks)
+}
+
+func TestRecommender_Headroom(t *testing.T) {
+	store := newMemStore()
+	require.NoError(t, store.SavePercentiles(&Percentiles{
+		Analyzer:  "python",
+		Samples:   50,
+		CPUMillis: &Distribution{P95: 1000},
+		Memory:    &Distribution{P95: 512 << 20},
+	}))
+	headroom := 0.0
+	r, err := NewRecommender(store, nil, &RecommenderOpts{Headroom: &headroom})
+	require.NoError(t, err)
+	require.NoError(t, r.Load())
+
+	rec := r.Recommendation("python")
+	require.NotNil(t, rec)
+	assert.Equal(t, int64(1000), rec.CPUMillis, "zero headroom is kept")
+	assert.Equal(t, int64(512), rec.MemoryMiB)
+}
@@ -0,0 +1,153 @@
+package rqlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/deepsourcecorp/runner/rightsizing"
+	"github.com/rqlite/gorqlite"
+)
+
+var (
+	samplesTable     = "rightsizing_samples"
+	percentilesTable = "rightsizing_percentiles"
+)
+
+var sampleColumns = []string{"pod", "analyzer", "cpu_millis", "memory", "created_at"}
+
+var percentileColumns = []string{
+	"analyzer",
+	"samples",
+	"cpu_p50", "cpu_p90", "cpu_p95", "cpu_p99", "cpu_max",
+	"memory_p50", "memory_p90", "memory_p95", "memory_p99", "memory_max",
+	"updated_at",
+}
+
+type Store struct {
+	db *gorqlite.Connection
+}
+
+func New(db *gorqlite.Connection) rightsizing.Store {
+	return &Store{db: db}
+}
+
+func (s *Store) SaveSample(sample *rightsizing.Sample) error {
+	builder := squirrel.Insert(samplesTable).
+		Options("OR REPLACE").
+		Columns(sampleColumns...).
+		Values(sample.Pod, sample.Analyzer, sample.CPUMillis, sample.Memory, sample.CreatedAt.Unix())
+	return s.write(builder)
+}
+
+func (s *Store) Samples(analyzer string, since time.Time) ([]*rightsizing.Sample, error) {
+	builder := squirrel.Select(sampleColumns...).
+		From(samplesTable).
+		Where(squirrel.Eq{"analyzer": analyzer}).
+		Where(squirrel.GtOrEq{"created_at": since.Unix()})
+	rows, err := s.query(builder)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []*rightsizing.Sample
+	for rows.Next() {
+		var (
+			sample    rightsizing.Sample
+			createdAt int64
+		)
+		if err := rows.Scan(&sample.Pod, &sample.Analyzer, &sample.CPUMillis, &sample.Memory, &createdAt); err != nil {
+			return nil, fmt.Errorf("rightsizing/rqlite: failed to scan row: %w", err)
+		}
+		sample.CreatedAt = time.Unix(createdAt, 0)
+		samples = append(samples, &sample)
+	}
+	return samples, nil
+}
+
+func (s *Store) DeleteSamples(before time.Time) error {
+	builder := squirrel.Delete(samplesTable).Where(squirrel.Lt{"created_at": before.Unix()})
+	return s.write(builder)
+}
+
+func (s *Store) SavePercentiles(p *rightsizing.Percentiles) error {
+	builder := squirrel.Insert(percentilesTable).
+		Options("OR REPLACE").
+		Columns(percentileColumns...).
+		Values(
+			p.Analyzer,
+			p.Samples,
+			p.CPUMillis.P50, p.CPUMillis.P90, p.CPUMillis.P95, p.CPUMillis.P99, p.CPUMillis.Max,
+			p.Memory.P50, p.Memory.P90, p.Memory.P95, p.Memory.P99, p.Memory.Max,
+			p.UpdatedAt.Unix(),
+		)
+	return s.write(builder)
+}
+
+func (s *Store) Percentiles() ([]*rightsizing.Percentiles, error) {
+	builder := squirrel.Select(percentileColumns...).From(percentilesTable).OrderBy("analyzer")
+	rows, err := s.query(builder)
+	if err != nil {
+		return nil, err
+	}
+
+	var percentiles []*rightsizing.Percentiles
+	for rows.Next() {
+		var (
+			cpu, memory rightsizing.Distribution
+			p           = rightsizing.Percentiles{CPUMillis: &cpu, Memory: &memory}
+			updatedAt   int64
+		)
+		err := rows.Scan(
+			&p.Analyzer,
+			&p.Samples,
+			&cpu.P50, &cpu.P90, &cpu.P95, &cpu.P99, &cpu.Max,
+			&memory.P50, &memory.P90, &memory.P95, &memory.P99, &memory.Max,
+			&updatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("rightsizing/rqlite: failed to scan row: %w", err)
+		}
+		p.UpdatedAt = time.Unix(updatedAt, 0)
+		percentiles = append(percentiles, &p)
+	}
+	return percentiles, nil
+}
+
+type sqlizer interface {
+	ToSql() (string, []interface{}, error)
+}
+
+func (s *Store) write(builder sqlizer) error {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return fmt.Errorf("rightsizing/rqlite: failed to build query: %w", err)
+	}
+	_, err = s.db.WriteOneParameterized(
+		gorqlite.ParameterizedStatement{
+			Query:     query,
+			Arguments: args,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("rightsizing/rqlite: failed to write to rqlite: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) query(builder squirrel.SelectBuilder) (gorqlite.QueryResult, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return gorqlite.QueryResult{}, fmt.Errorf("rightsizing/rqlite: failed to build query: %w", err)
+	}
+	rows, err := s.db.QueryOneParameterized(
+		gorqlite.ParameterizedStatement{
+			Query:     query,
+			Arguments: args,
+		},
+	)
+	if err != nil {
+		return gorqlite.QueryResult{}, fmt.Errorf("rightsizing/rqlite: failed to query rqlite: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,64 @@
+package rightsizing
+
+import "time"
+
+// Sample is the peak usage of the analyzer container of one job pod.
+type Sample struct {
+	Pod       string
+	Analyzer  string
+	CPUMillis int64
+	Memory    int64
+	CreatedAt time.Time
+}
+
+// Distribution is the percentiles of the peak usage of an analyzer.
+type Distribution struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P95 int64 `json:"p95"`
+	P99 int64 `json:"p99"`
+	Max int64 `json:"max"`
+}
+
+// Percentile returns the percentile p, one of 50, 90, 95, 99 or 100.
+func (d *Distribution) Percentile(p int) int64 {
+	switch p {
+	case 50:
+		return d.P50
+	case 90:
+		return d.P90
+	case 95:
+		return d.P95
+	case 99:
+		return d.P99
+	default:
+		return d.Max
+	}
+}
+
+// Percentiles are the usage percentiles of an analyzer, in millicores and
+// bytes, over the samples of the window.
+type Percentiles struct {
+	Analyzer  string        `json:"analyzer"`
+	Samples   int           `json:"samples"`
+	CPUMillis *Distribution `json:"cpu_millis"`
+	Memory    *Distribution `json:"memory"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type Store interface {
+	// SaveSample records the peak usage of a finished pod.
+	SaveSample(s *Sample) error
+
+	// Samples returns the samples of the analyzer created since the time.
+	Samples(analyzer string, since time.Time) ([]*Sample, error)
+
+	// DeleteSamples deletes the samples created before the time.
+	DeleteSamples(before time.Time) error
+
+	// SavePercentiles replaces the percentiles of the analyzer.
+	SavePercentiles(p *Percentiles) error
+
+	// Percentiles returns the percentiles of all analyzers.
+	Percentiles() ([]*Percentiles, error)
+}
@@ -0,0 +1,6 @@
+package migrations
+
+const (
+	Up002   = `CREATE TABLE IF NOT EXISTS relay_payloads (id TEXT PRIMARY KEY, path TEXT, scope TEXT, content_type TEXT, body TEXT, status TEXT, attempts INTEGER, last_error TEXT, next_attempt_at INTEGER, created_at INTEGER) WITHOUT ROWID;`
+	Down002 = `DROP TABLE relay_payloads`
+)
@@ -0,0 +1,6 @@
+package migrations
+
+const (
+	Up003   = `CREATE TABLE IF NOT EXISTS usage_jobs (name TEXT PRIMARY KEY, app_id TEXT, repository TEXT, kind TEXT, cpu_millis INTEGER, memory INTEGER, created_at INTEGER, started_at INTEGER, finished_at INTEGER) WITHOUT ROWID;`
+	Down003 = `DROP TABLE usage_jobs`
+)
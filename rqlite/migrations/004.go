@@ -0,0 +1,6 @@
+package migrations
+
+const (
+	Up004   = `CREATE TABLE IF NOT EXISTS rightsizing_samples (pod TEXT PRIMARY KEY, analyzer TEXT, cpu_millis INTEGER, memory INTEGER, created_at INTEGER) WITHOUT ROWID;`
+	Down004 = `DROP TABLE rightsizing_samples`
+)
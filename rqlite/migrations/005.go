@@ -0,0 +1,6 @@
+package migrations
+
+const (
+	Up005   = `CREATE TABLE IF NOT EXISTS rightsizing_percentiles (analyzer TEXT PRIMARY KEY, samples INTEGER, cpu_p50 INTEGER, cpu_p90 INTEGER, cpu_p95 INTEGER, cpu_p99 INTEGER, cpu_max INTEGER, memory_p50 INTEGER, memory_p90 INTEGER, memory_p95 INTEGER, memory_p99 INTEGER, memory_max INTEGER, updated_at INTEGER) WITHOUT ROWID;`
+	Down005 = `DROP TABLE rightsizing_percentiles`
+)
@@ -0,0 +1,6 @@
+package migrations
+
+const (
+	Up006   = `ALTER TABLE usage_jobs ADD COLUMN lost INTEGER NOT NULL DEFAULT 0;`
+	Down006 = `ALTER TABLE usage_jobs DROP COLUMN lost`
+)
@@ -23,6 +23,31 @@ var migrations = []Migration{
 		Up:   Up001,
 		Down: Down001,
 	},
+	{
+		Name: "002",
+		Up:   Up002,
+		Down: Down002,
+	},
+	{
+		Name: "003",
+		Up:   Up003,
+		Down: Down003,
+	},
+	{
+		Name: "004",
+		Up:   Up004,
+		Down: Down004,
+	},
+	{
+		Name: "005",
+		Up:   Up005,
+		Down: Down005,
+	},
+	{
+		Name: "006",
+		Up:   Up006,
+		Down: Down006,
+	},
 }
 
 func NewMigrator(db *gorqlite.Connection) (*Migrator, error) {
@@ -30,6 +30,10 @@ type Runner struct {
 	ClientID      string
 	ClientSecret  string
 	WebhookSecret string
+
+	// Outbound is set when the runner polls DeepSource for requests instead
+	// of accepting connections.
+	Outbound bool
 }
 
 type Payload struct {
@@ -38,6 +42,7 @@ type Payload struct {
 	ClientID      string `json:"client_id"`
 	ClientSecret  string `json:"client_secret"`
 	WebhookSecret string `json:"webhook_secret"`
+	Outbound      bool   `json:"outbound"`
 	Apps          []App  `json:"apps"`
 }
 
@@ -64,6 +69,7 @@ func (s *Syncer) Sync() error {
 		ClientID:      s.runner.ClientID,
 		ClientSecret:  s.runner.ClientSecret,
 		WebhookSecret: s.runner.WebhookSecret,
+		Outbound:      s.runner.Outbound,
 		Apps:          s.apps,
 	}
 
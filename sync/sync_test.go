@@ -27,6 +27,7 @@ func TestSyncer_Sync(t *testing.T) {
 		ClientID:      "client-id",
 		ClientSecret:  "client-secret",
 		WebhookSecret: "webhook-secret",
+		Outbound:      true,
 	}
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +44,7 @@ func TestSyncer_Sync(t *testing.T) {
 		assert.Equal(t, runner.Host.String(), payload.BaseURL)
 		assert.Equal(t, runner.ClientID, payload.ClientID)
 		assert.Equal(t, runner.ClientSecret, payload.ClientSecret)
+		assert.True(t, payload.Outbound)
 		assert.Equal(t, runner.WebhookSecret, payload.WebhookSecret)
 		assert.Equal(t, apps, payload.Apps)
 	}))
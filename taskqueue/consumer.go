@@ -0,0 +1,362 @@
+package taskqueue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/deepsourcecorp/runner/auth"
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"github.com/deepsourcecorp/runner/usage"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	DefaultPrefetch       = 4
+	DefaultMaxRetries     = 5
+	DefaultRetryDelay     = 30 * time.Second
+	DefaultReconnectDelay = 5 * time.Second
+
+	consumerTag = "runner"
+
+	// retryQueueExpiry is how long a retry queue outlives its messages once
+	// it is no longer used.
+	retryQueueExpiry = time.Minute
+)
+
+var (
+	errChannelClosed   = errors.New("taskqueue: channel closed")
+	errUnauthenticated = errors.New("taskqueue: invalid token")
+)
+
+// Channel is the part of *amqp.Channel the consumer uses.
+type Channel interface {
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+// Dialer opens a channel to the broker.
+type Dialer func() (Channel, error)
+
+// DialURL returns a Dialer connecting to the broker at the AMQP URL.
+func DialURL(url string) Dialer {
+	return func() (Channel, error) {
+		conn, err := amqp.Dial(url)
+		if err != nil {
+			return nil, err
+		}
+		ch, err := conn.Channel()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return &connChannel{Channel: ch, conn: conn}, nil
+	}
+}
+
+// connChannel closes its connection with the channel.
+type connChannel struct {
+	*amqp.Channel
+	conn *amqp.Connection
+}
+
+func (c *connChannel) Close() error {
+	c.Channel.Close()
+	return c.conn.Close()
+}
+
+// Authenticator verifies the authorization header of a message.
+type Authenticator func(authorization string) error
+
+// DeepSourceAuthenticator verifies DeepSource tokens for the runner, like
+// auth.DeepSourceTokenMiddleware.
+func DeepSourceAuthenticator(runnerID string, verifier *jwtutil.Verifier) Authenticator {
+	return func(authorization string) error {
+		return auth.VerifyDeepSourceToken(runnerID, verifier, authorization)
+	}
+}
+
+// Scheduler runs tasks, like orchestrator.Facade.
+type Scheduler interface {
+	Schedule(ctx context.Context, msg *orchestrator.TaskMessage) error
+}
+
+type Opts struct {
+	Queue string
+
+	// DeadLetterQueue receives the messages that cannot be scheduled.
+	// Defaults to the queue name suffixed with ".dead".
+	DeadLetterQueue string
+
+	// Prefetch is the number of tasks scheduled concurrently.
+	Prefetch int
+
+	// MaxRetries is how many times a task that failed to schedule is
+	// retried before it is dead-lettered.  Tasks deferred by maintenance
+	// mode, the namespace quota or the usage quota of their app are
+	// retried indefinitely.
+	MaxRetries int
+
+	// RetryDelay is how long a task waits before it is retried.
+	RetryDelay time.Duration
+
+	ReconnectDelay time.Duration
+
+	// Authenticator verifies the token of every message.  Messages are
+	// dead-lettered when it is nil.
+	Authenticator Authenticator
+}
+
+// Consumer schedules the tasks of an AMQP queue.  A message is acked once
+// its task is scheduled.  When scheduling fails, the message is acked and
+// republished to a retry queue, whose TTL dead-letters it back to the queue
+// after the retry delay, so that failing tasks do not hold prefetch slots.
+// Messages that are not valid or authenticated tasks, tasks that cannot
+// succeed, and tasks that failed more than MaxRetries times are rejected to
+// the dead-letter queue.
+type Consumer struct {
+	dial      Dialer
+	scheduler Scheduler
+	opts      *Opts
+}
+
+func NewConsumer(dial Dialer, scheduler Scheduler, opts *Opts) *Consumer {
+	if opts.DeadLetterQueue == "" {
+		opts.DeadLetterQueue = opts.Queue + ".dead"
+	}
+	if opts.Prefetch <= 0 {
+		opts.Prefetch = DefaultPrefetch
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	if opts.ReconnectDelay <= 0 {
+		opts.ReconnectDelay = DefaultReconnectDelay
+	}
+	return &Consumer{
+		dial:      dial,
+		scheduler: scheduler,
+		opts:      opts,
+	}
+}
+
+// Start consumes the queue until the context is cancelled, reconnecting
+// when the connection is lost.
+func (c *Consumer) Start(ctx context.Context) {
+	for {
+		err := c.Consume(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("taskqueue: consumer stopped, reconnecting", slog.Any("err", err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.opts.ReconnectDelay):
+		}
+	}
+}
+
+// Consume declares the queues and schedules the tasks of the queue until
+// the context is cancelled or the channel is closed.
+func (c *Consumer) Consume(ctx context.Context) error {
+	ch, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if _, err := ch.QueueDeclare(c.opts.DeadLetterQueue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	_, err = ch.QueueDeclare(c.opts.Queue, true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": c.opts.DeadLetterQueue,
+	})
+	if err != nil {
+		return err
+	}
+	if err := ch.Qos(c.opts.Prefetch, 0, false); err != nil {
+		return err
+	}
+	deliveries, err := ch.Consume(c.opts.Queue, consumerTag, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.opts.Prefetch; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				d := d
+				c.handle(ctx, ch, &d)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Closing the channel ends the deliveries, and requeues the
+		// unacknowledged ones.
+		ch.Close()
+		<-done
+		return ctx.Err()
+	case <-done:
+		return errChannelClosed
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, ch Channel, d *amqp.Delivery) {
+	e, err := decode(d)
+	if err != nil {
+		c.deadLetter(d, "", err)
+		return
+	}
+	key := e.ID
+	if key == "" {
+		sum := sha256.Sum256(d.Body)
+		key = hex.EncodeToString(sum[:])
+	}
+	if err := c.authenticate(d); err != nil {
+		c.deadLetter(d, key, err)
+		return
+	}
+
+	err = c.scheduler.Schedule(ctx, taskMessage(e, d))
+	switch {
+	case err == nil:
+		if err := d.Ack(false); err != nil {
+			slog.Error("taskqueue: failed to ack task", slog.String("id", key), slog.Any("err", err))
+		}
+		return
+	case permanent(err):
+		c.deadLetter(d, key, err)
+		return
+	case ctx.Err() != nil:
+		// The unacknowledged message is requeued when the channel closes.
+		return
+	}
+
+	attempts, _ := headerInt(d.Headers[HeaderAttempts])
+	attempts++
+	delay := c.opts.RetryDelay
+	var (
+		quotaErr *orchestrator.QuotaExceededError
+		usageErr *usage.QuotaError
+	)
+	switch {
+	case errors.As(err, &quotaErr):
+		if quotaErr.RetryAfter > 0 {
+			delay = quotaErr.RetryAfter
+		}
+	case errors.As(err, &usageErr):
+		// The task waits for the quota period to reset, rounded up to the
+		// minute so that tasks share retry queues.
+		if until := time.Until(usageErr.ResetsAt); until > 0 {
+			delay = until.Truncate(time.Minute) + time.Minute
+		}
+	case errors.Is(err, orchestrator.ErrMaintenance):
+	default:
+		if e.Retries+attempts > c.opts.MaxRetries {
+			c.deadLetter(d, key, err)
+			return
+		}
+	}
+	slog.Warn("taskqueue: failed to schedule task, retrying", slog.String("id", key), slog.String("task", e.Task), slog.Duration("delay", delay), slog.Any("err", err))
+
+	if err := c.retry(ctx, ch, d, attempts, delay); err != nil {
+		slog.Error("taskqueue: failed to republish task, requeueing", slog.String("id", key), slog.Any("err", err))
+		if err := d.Nack(false, true); err != nil {
+			slog.Error("taskqueue: failed to requeue task", slog.String("id", key), slog.Any("err", err))
+		}
+		return
+	}
+	if err := d.Ack(false); err != nil {
+		slog.Error("taskqueue: failed to ack retried task", slog.String("id", key), slog.Any("err", err))
+	}
+}
+
+// authenticate verifies the token of the message, which the app and
+// installation headers are trusted on.
+func (c *Consumer) authenticate(d *amqp.Delivery) error {
+	if c.opts.Authenticator == nil {
+		return errUnauthenticated
+	}
+	authorization, _ := d.Headers[HeaderAuthorization].(string)
+	if err := c.opts.Authenticator(authorization); err != nil {
+		return fmt.Errorf("%w: %v", errUnauthenticated, err)
+	}
+	return nil
+}
+
+// permanent reports whether the task failed in a way retries cannot fix.
+func permanent(err error) bool {
+	return errors.Is(err, orchestrator.ErrInvalidTask)
+}
+
+// retry republishes the message to the retry queue of the delay, with the
+// number of failed attempts.
+func (c *Consumer) retry(ctx context.Context, ch Channel, d *amqp.Delivery, attempts int, delay time.Duration) error {
+	queue := c.retryQueue(delay)
+	ttl := delay.Milliseconds()
+	_, err := ch.QueueDeclare(queue, true, false, false, false, amqp.Table{
+		"x-message-ttl":             ttl,
+		"x-expires":                 2*ttl + retryQueueExpiry.Milliseconds(),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": c.opts.Queue,
+	})
+	if err != nil {
+		return err
+	}
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[HeaderAttempts] = int32(attempts)
+	return ch.PublishWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	})
+}
+
+// retryQueue returns the name of the queue holding the retries of the delay.
+// Each delay has a queue of its own, since messages only expire at the head
+// of a queue.
+func (c *Consumer) retryQueue(delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%d", c.opts.Queue, delay.Milliseconds())
+}
+
+func (c *Consumer) deadLetter(d *amqp.Delivery, key string, cause error) {
+	slog.Error("taskqueue: dead-lettering task", slog.String("id", key), slog.Any("err", cause))
+	if err := d.Nack(false, false); err != nil {
+		slog.Error("taskqueue: failed to dead-letter task", slog.String("id", key), slog.Any("err", err))
+	}
+}
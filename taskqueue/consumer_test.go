@@ -0,0 +1,313 @@
+package taskqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepsourcecorp/runner/orchestrator"
+	"github.com/deepsourcecorp/runner/usage"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// broker is a local stand-in for RabbitMQ: published messages are delivered
+// to the consumer, requeued messages are redelivered, rejected messages are
+// routed to the dead-letter queue the queue was declared with, and messages
+// published to a retry queue are dead-lettered back to the queue at once.
+type broker struct {
+	mu         sync.Mutex
+	queues     map[string]amqp.Table
+	deliveries chan amqp.Delivery
+	delivered  map[uint64]amqp.Delivery
+	acked      []string
+	dead       map[string][]string
+	retried    map[string][]amqp.Publishing
+	tag        uint64
+	closed     bool
+}
+
+func newBroker() *broker {
+	return &broker{
+		queues:     make(map[string]amqp.Table),
+		deliveries: make(chan amqp.Delivery, 16),
+		delivered:  make(map[uint64]amqp.Delivery),
+		dead:       make(map[string][]string),
+		retried:    make(map[string][]amqp.Publishing),
+	}
+}
+
+const testAuthorization = "Bearer deepsource"
+
+func testAuthenticator(authorization string) error {
+	if authorization != testAuthorization {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// authorized returns the headers with the token of testAuthenticator.
+func authorized(headers amqp.Table) amqp.Table {
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+	headers[HeaderAuthorization] = testAuthorization
+	return headers
+}
+
+func (b *broker) publish(body string, headers amqp.Table) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deliver(amqp.Delivery{ContentType: "application/json", Headers: headers, Body: []byte(body)})
+}
+
+func (b *broker) deliver(d amqp.Delivery) {
+	if b.closed {
+		return
+	}
+	b.tag++
+	d.Acknowledger, d.DeliveryTag = b, b.tag
+	b.delivered[b.tag] = d
+	b.deliveries <- d
+}
+
+func (b *broker) Ack(tag uint64, _ bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.acked = append(b.acked, string(b.delivered[tag].Body))
+	return nil
+}
+
+func (b *broker) Nack(tag uint64, _ bool, requeue bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	d := b.delivered[tag]
+	if requeue {
+		d.Redelivered = true
+		b.deliver(d)
+		return nil
+	}
+	dlq, _ := b.queues["tasks"]["x-dead-letter-routing-key"].(string)
+	b.dead[dlq] = append(b.dead[dlq], string(d.Body))
+	return nil
+}
+
+func (b *broker) PublishWithContext(_ context.Context, _, key string, _, _ bool, msg amqp.Publishing) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.retried[key] = append(b.retried[key], msg)
+	b.deliver(amqp.Delivery{ContentType: msg.ContentType, Headers: msg.Headers, Body: msg.Body})
+	return nil
+}
+
+func (b *broker) Reject(tag uint64, requeue bool) error {
+	return b.Nack(tag, false, requeue)
+}
+
+func (b *broker) Qos(int, int, bool) error { return nil }
+
+func (b *broker) QueueDeclare(name string, _, _, _, _ bool, args amqp.Table) (amqp.Queue, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queues[name] = args
+	return amqp.Queue{Name: name}, nil
+}
+
+func (b *broker) Consume(string, string, bool, bool, bool, bool, amqp.Table) (<-chan amqp.Delivery, error) {
+	return b.deliveries, nil
+}
+
+func (b *broker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.deliveries)
+	}
+	return nil
+}
+
+type testScheduler struct {
+	mu    sync.Mutex
+	tasks []*orchestrator.TaskMessage
+	err   func(msg *orchestrator.TaskMessage) error
+}
+
+func (s *testScheduler) Schedule(_ context.Context, msg *orchestrator.TaskMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, msg)
+	if s.err != nil {
+		return s.err(msg)
+	}
+	return nil
+}
+
+func envelope(t *testing.T, id, task string) string {
+	t.Helper()
+	body, err := json.Marshal(&Envelope{ID: id, Task: task, KWArgs: json.RawMessage(`{"run_id":"` + id + `"}`)})
+	require.NoError(t, err)
+	return string(body)
+}
+
+func (b *broker) state() (acked []string, dead map[string][]string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dead = make(map[string][]string)
+	for q, bodies := range b.dead {
+		dead[q] = append([]string{}, bodies...)
+	}
+	return append([]string{}, b.acked...), dead
+}
+
+func TestConsumer(t *testing.T) {
+	b := newBroker()
+	flaky := errors.New("provider unavailable")
+	flakyFailures, quotaFailures := 0, 0
+	scheduler := &testScheduler{err: func(msg *orchestrator.TaskMessage) error {
+		switch string(msg.Body) {
+		case `{"run_id":"flaky"}`:
+			if flakyFailures < 2 {
+				flakyFailures++
+				return flaky
+			}
+		case `{"run_id":"broken"}`:
+			return flaky
+		case `{"run_id":"unknown"}`:
+			return orchestrator.ErrInvalidTask
+		case `{"run_id":"quota"}`:
+			if quotaFailures < 3 {
+				quotaFailures++
+				return &orchestrator.QuotaExceededError{RetryAfter: time.Millisecond}
+			}
+		}
+		return nil
+	}}
+	consumer := NewConsumer(func() (Channel, error) { return b, nil }, scheduler, &Opts{
+		Queue:         "tasks",
+		Prefetch:      1,
+		MaxRetries:    2,
+		RetryDelay:    time.Millisecond,
+		Authenticator: testAuthenticator,
+	})
+
+	ok := envelope(t, "ok", orchestrator.TaskNameAnalysis)
+	b.publish(ok, authorized(amqp.Table{HeaderAppID: "app-id", HeaderInstallationID: "42"}))
+	b.publish(envelope(t, "flaky", orchestrator.TaskNameAutofix), authorized(nil))
+	b.publish(envelope(t, "broken", orchestrator.TaskNameAutofix), authorized(nil))
+	b.publish(envelope(t, "unknown", "unknown"), authorized(nil))
+	b.publish(`{"id": "poison"`, authorized(nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- consumer.Consume(ctx) }()
+
+	require.Eventually(t, func() bool {
+		acked, dead := b.state()
+		return len(acked) == 1+3+2 && len(dead["tasks.dead"]) == 3
+	}, 5*time.Second, time.Millisecond)
+
+	// Quota rejections are retried past MaxRetries.
+	b.publish(envelope(t, "quota", orchestrator.TaskNameAnalysis), authorized(nil))
+	require.Eventually(t, func() bool {
+		acked, _ := b.state()
+		return len(acked) == 3+2+2+3
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	// Failed attempts are acked once republished to the retry queue.
+	acked, dead := b.state()
+	flakyTask, brokenTask, quotaTask := envelope(t, "flaky", orchestrator.TaskNameAutofix), envelope(t, "broken", orchestrator.TaskNameAutofix), envelope(t, "quota", orchestrator.TaskNameAnalysis)
+	assert.ElementsMatch(t, []string{ok, flakyTask, flakyTask, flakyTask, brokenTask, brokenTask, quotaTask, quotaTask, quotaTask, quotaTask}, acked)
+	assert.ElementsMatch(t, []string{brokenTask, envelope(t, "unknown", "unknown"), `{"id": "poison"`}, dead["tasks.dead"])
+	assert.Equal(t, "tasks.dead", b.queues["tasks"]["x-dead-letter-routing-key"])
+
+	retries := b.retried["tasks.retry.1"]
+	require.Len(t, retries, 7)
+	assert.Equal(t, int32(1), retries[0].Headers[HeaderAttempts])
+	assert.Equal(t, testAuthorization, retries[0].Headers[HeaderAuthorization])
+	assert.Equal(t, amqp.Table{
+		"x-message-ttl":             int64(1),
+		"x-expires":                 int64(2 + 60000),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": "tasks",
+	}, b.queues["tasks.retry.1"])
+
+	assert.Equal(t, &orchestrator.TaskMessage{
+		Name:           orchestrator.TaskNameAnalysis,
+		AppID:          "app-id",
+		InstallationID: "42",
+		Body:           json.RawMessage(`{"run_id":"ok"}`),
+	}, scheduler.tasks[0])
+}
+
+func TestConsumer_DeadLetter(t *testing.T) {
+	b := newBroker()
+	scheduler := &testScheduler{err: func(*orchestrator.TaskMessage) error { return nil }}
+	consumer := NewConsumer(func() (Channel, error) { return b, nil }, scheduler, &Opts{
+		Queue:         "tasks",
+		Prefetch:      1,
+		RetryDelay:    time.Hour,
+		Authenticator: testAuthenticator,
+	})
+
+	unauthenticated := envelope(t, "unauthenticated", orchestrator.TaskNameAnalysis)
+	b.publish(unauthenticated, amqp.Table{HeaderAppID: "app-id", HeaderAuthorization: "Bearer forged"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- consumer.Consume(ctx) }()
+	require.Eventually(t, func() bool {
+		_, dead := b.state()
+		return len(dead["tasks.dead"]) == 1
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	assert.Empty(t, scheduler.tasks, "unauthenticated tasks are not scheduled")
+	assert.Empty(t, b.retried)
+}
+
+func TestConsumer_UsageQuota(t *testing.T) {
+	b := newBroker()
+	exhausted := true
+	scheduler := &testScheduler{err: func(*orchestrator.TaskMessage) error {
+		if exhausted {
+			exhausted = false
+			return &usage.QuotaError{AppID: "app-id", Period: "daily", ResetsAt: time.Now().Add(90 * time.Minute)}
+		}
+		return nil
+	}}
+	consumer := NewConsumer(func() (Channel, error) { return b, nil }, scheduler, &Opts{
+		Queue:         "tasks",
+		Prefetch:      1,
+		MaxRetries:    1,
+		RetryDelay:    time.Millisecond,
+		Authenticator: testAuthenticator,
+	})
+
+	task := envelope(t, "usage", orchestrator.TaskNameAnalysis)
+	b.publish(task, authorized(amqp.Table{HeaderAppID: "app-id"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- consumer.Consume(ctx) }()
+	require.Eventually(t, func() bool {
+		acked, _ := b.state()
+		return len(acked) == 2
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	// The task waits in the retry queue until the quota period resets.
+	_, dead := b.state()
+	assert.Empty(t, dead["tasks.dead"], "tasks of apps over their usage quota are not dead-lettered")
+	require.Len(t, b.retried["tasks.retry.5400000"], 1)
+	assert.Len(t, scheduler.tasks, 2)
+}
@@ -0,0 +1,111 @@
+package taskqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/deepsourcecorp/runner/orchestrator"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Message headers carrying the app and installation of a task, which the
+// task endpoints read from the path and the X-Installation-ID header, and
+// the DeepSource token of the Authorization header.
+const (
+	HeaderAppID          = "app_id"
+	HeaderInstallationID = "installation_id"
+	HeaderAuthorization  = "authorization"
+
+	// HeaderAttempts is the number of times the runner failed to schedule
+	// the task, set on the messages it republishes for a retry.
+	HeaderAttempts = "x-runner-attempts"
+)
+
+var ErrInvalidEnvelope = errors.New("taskqueue: invalid envelope")
+
+// Envelope is a Celery task message, in the format of the *CeleryTask types
+// of the results the runner publishes.  KWArgs is the run of the task.
+type Envelope struct {
+	ID      string          `json:"id"`
+	Task    string          `json:"task"`
+	KWArgs  json.RawMessage `json:"kwargs"`
+	Retries int             `json:"retries"`
+}
+
+// decode returns the envelope of the delivery.  Both the version 1 Celery
+// protocol, with the envelope in the body, and version 2, with the task in
+// the headers and a body of [args, kwargs, embed], are accepted.
+func decode(d *amqp.Delivery) (*Envelope, error) {
+	if d.ContentType != "" && d.ContentType != "application/json" {
+		return nil, fmt.Errorf("%w: unsupported content type %q", ErrInvalidEnvelope, d.ContentType)
+	}
+
+	e := new(Envelope)
+	if task, ok := d.Headers["task"].(string); ok {
+		e.Task = task
+		e.ID, _ = d.Headers["id"].(string)
+		if retries, ok := headerInt(d.Headers["retries"]); ok {
+			e.Retries = retries
+		}
+		var body []json.RawMessage
+		if err := json.Unmarshal(d.Body, &body); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+		}
+		if len(body) < 2 {
+			return nil, fmt.Errorf("%w: missing kwargs", ErrInvalidEnvelope)
+		}
+		e.KWArgs = body[1]
+	} else if err := json.Unmarshal(d.Body, e); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+
+	if e.Task == "" {
+		return nil, fmt.Errorf("%w: missing task", ErrInvalidEnvelope)
+	}
+	if len(bytes.TrimSpace(e.KWArgs)) == 0 {
+		return nil, fmt.Errorf("%w: missing kwargs", ErrInvalidEnvelope)
+	}
+	if e.ID == "" {
+		e.ID = d.MessageId
+	}
+	return e, nil
+}
+
+// taskMessage returns the task of the envelope.
+func taskMessage(e *Envelope, d *amqp.Delivery) *orchestrator.TaskMessage {
+	appID, _ := d.Headers[HeaderAppID].(string)
+	installationID, _ := d.Headers[HeaderInstallationID].(string)
+	return &orchestrator.TaskMessage{
+		Name:           e.Task,
+		AppID:          appID,
+		InstallationID: installationID,
+		Body:           e.KWArgs,
+	}
+}
+
+// headerInt returns an integer header, which AMQP decodes to any of the Go
+// integer types.
+func headerInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,71 @@
+package taskqueue
+
+import (
+	"encoding/json"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery amqp.Delivery
+		want     *Envelope
+		wantErr  bool
+	}{
+		{
+			name: "protocol version 1",
+			delivery: amqp.Delivery{
+				ContentType: "application/json",
+				Body:        []byte(`{"id": "t1", "task": "analysis", "args": [], "kwargs": {"run_id": "r1"}, "retries": 1}`),
+			},
+			want: &Envelope{ID: "t1", Task: "analysis", KWArgs: json.RawMessage(`{"run_id": "r1"}`), Retries: 1},
+		},
+		{
+			name: "protocol version 2",
+			delivery: amqp.Delivery{
+				ContentType: "application/json",
+				Headers:     amqp.Table{"id": "t2", "task": "autofix", "retries": int32(2)},
+				Body:        []byte(`[[], {"run_id": "r2"}, {"callbacks": null}]`),
+			},
+			want: &Envelope{ID: "t2", Task: "autofix", KWArgs: json.RawMessage(`{"run_id": "r2"}`), Retries: 2},
+		},
+		{
+			name: "message ID",
+			delivery: amqp.Delivery{
+				MessageId: "m3",
+				Body:      []byte(`{"task": "commit", "kwargs": {}}`),
+			},
+			want: &Envelope{ID: "m3", Task: "commit", KWArgs: json.RawMessage(`{}`)},
+		},
+		{
+			name:     "pickle",
+			delivery: amqp.Delivery{ContentType: "application/x-python-serialize", Body: []byte("\x80\x04")},
+			wantErr:  true,
+		},
+		{
+			name:     "missing task",
+			delivery: amqp.Delivery{Body: []byte(`{"id": "t4", "kwargs": {}}`)},
+			wantErr:  true,
+		},
+		{
+			name:     "missing kwargs",
+			delivery: amqp.Delivery{Headers: amqp.Table{"task": "analysis"}, Body: []byte(`[[]]`)},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decode(&tt.delivery)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidEnvelope)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
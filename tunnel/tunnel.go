@@ -0,0 +1,309 @@
+// Package tunnel lets the runner serve DeepSource without accepting inbound
+// connections.  The runner long-polls DeepSource for requests, runs them
+// against its own HTTP handlers and streams the responses back.
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	DefaultConcurrency    = 4
+	DefaultPollTimeout    = 30 * time.Second
+	DefaultReconnectDelay = 5 * time.Second
+
+	// ScopeTunnel is the scope of the tokens the runner polls with.
+	ScopeTunnel = "tunnel"
+
+	tokenExpiry = 5 * time.Minute
+
+	// maxRequestSize is the largest polled request decoded, with its body
+	// encoded in base64.
+	maxRequestSize = 32 << 20
+)
+
+// Headers of the upload of a response, carrying the status and the headers
+// of the response.  The body of the upload is the body of the response.
+const (
+	HeaderStatus = "X-Tunnel-Status"
+	HeaderHeader = "X-Tunnel-Header"
+)
+
+var (
+	ErrMissingOpts = errors.New("tunnel: missing opts")
+
+	errUploadEnded = errors.New("tunnel: upload ended")
+)
+
+type Signer interface {
+	GenerateToken(issuer string, scope []string, claims map[string]interface{}, expiry time.Duration) (string, error)
+}
+
+// Request is a request to the runner, relayed by DeepSource.
+type Request struct {
+	ID     string      `json:"id"`
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+type Opts struct {
+	RunnerID       string
+	DeepSourceHost url.URL
+
+	// Concurrency is the number of requests polled for, and run, at a time.
+	Concurrency int
+
+	// PollTimeout is how long DeepSource holds a poll open when no request
+	// is pending.
+	PollTimeout time.Duration
+
+	// ReconnectDelay is the wait before polling again after a failed poll.
+	ReconnectDelay time.Duration
+}
+
+// Tunnel polls DeepSource for requests and serves them with the handler.
+type Tunnel struct {
+	handler http.Handler
+	signer  Signer
+	client  *http.Client
+	opts    *Opts
+}
+
+func New(handler http.Handler, signer Signer, client *http.Client, opts *Opts) (*Tunnel, error) {
+	if handler == nil || signer == nil || opts == nil || opts.RunnerID == "" {
+		return nil, ErrMissingOpts
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.PollTimeout <= 0 {
+		opts.PollTimeout = DefaultPollTimeout
+	}
+	if opts.ReconnectDelay <= 0 {
+		opts.ReconnectDelay = DefaultReconnectDelay
+	}
+	return &Tunnel{handler: handler, signer: signer, client: client, opts: opts}, nil
+}
+
+// Start polls for requests until the context is cancelled.
+func (t *Tunnel) Start(ctx context.Context) {
+	done := make(chan struct{})
+	for i := 0; i < t.opts.Concurrency; i++ {
+		go func() {
+			t.run(ctx)
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < t.opts.Concurrency; i++ {
+		<-done
+	}
+}
+
+func (t *Tunnel) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := t.Poll(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("tunnel: poll failed", slog.Any("err", err))
+			select {
+			case <-ctx.Done():
+			case <-time.After(t.opts.ReconnectDelay):
+			}
+		}
+	}
+}
+
+// Poll waits for one request and serves it.  It returns without error when
+// the poll timed out with no request pending.
+func (t *Tunnel) Poll(ctx context.Context) error {
+	req, err := t.next(ctx)
+	if err != nil || req == nil {
+		return err
+	}
+	return t.Serve(ctx, req)
+}
+
+// Serve runs the request against the handler, as if it had been received
+// over HTTP, and streams the response to DeepSource as the handler writes
+// it.  The handler's context is cancelled when the upload fails, as when the
+// client of a stream went away.
+func (t *Tunnel) Serve(ctx context.Context, req *Request) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := &responseWriter{tunnel: t, ctx: ctx, cancel: cancel, id: req.ID, header: http.Header{}}
+
+	r, err := http.NewRequestWithContext(ctx, req.Method, req.Path, bytes.NewReader(req.Body))
+	if err != nil || !strings.HasPrefix(req.Path, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		return w.finish()
+	}
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.RemoteAddr = "tunnel"
+	t.handler.ServeHTTP(w, r)
+	return w.finish()
+}
+
+func (t *Tunnel) next(ctx context.Context) (*Request, error) {
+	target := t.opts.DeepSourceHost.JoinPath("/api/runner/tunnel/poll")
+	target.RawQuery = url.Values{"timeout": {t.opts.PollTimeout.String()}}.Encode()
+	ctx, cancel := context.WithTimeout(ctx, t.opts.PollTimeout+10*time.Second)
+	defer cancel()
+	r, err := t.request(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.client.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("tunnel: failed to poll: %w", err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("tunnel: failed to poll: code=%d, body=%s", res.StatusCode, body)
+	}
+	req := new(Request)
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxRequestSize)).Decode(req); err != nil {
+		return nil, fmt.Errorf("tunnel: invalid request: %w", err)
+	}
+	return req, nil
+}
+
+// upload posts the response of a request, streaming the body as it is read.
+func (t *Tunnel) upload(ctx context.Context, id string, status int, header http.Header, body io.Reader) error {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	target := t.opts.DeepSourceHost.JoinPath("/api/runner/tunnel/responses", id)
+	r, err := t.request(ctx, http.MethodPost, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	// A body of unknown length is sent chunked, each write in a chunk of its
+	// own.
+	r.Body, r.ContentLength, r.GetBody = io.NopCloser(body), -1, nil
+	r.Header.Set("Content-Type", "application/octet-stream")
+	r.Header.Set(HeaderStatus, strconv.Itoa(status))
+	r.Header.Set(HeaderHeader, string(encoded))
+	reply, err := t.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("tunnel: failed to reply: %w", err)
+	}
+	defer reply.Body.Close()
+	if reply.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(reply.Body, 1024))
+		return fmt.Errorf("tunnel: failed to reply: code=%d, body=%s", reply.StatusCode, body)
+	}
+	return nil
+}
+
+// responseWriter streams the response of a handler through the tunnel.  The
+// upload starts when the status is written, and every write is sent as it
+// happens, so that server-sent events and proxied streams are not buffered.
+type responseWriter struct {
+	tunnel *Tunnel
+	ctx    context.Context
+	cancel context.CancelFunc
+	id     string
+	header http.Header
+
+	mu     sync.Mutex
+	status int
+	body   *io.PipeWriter
+	done   chan error
+	err    error
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.start(status)
+}
+
+// start starts the upload, once.
+func (w *responseWriter) start(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	header := w.header.Clone()
+	pr, pw := io.Pipe()
+	w.body, w.done = pw, make(chan error, 1)
+	go func() {
+		err := w.tunnel.upload(w.ctx, w.id, status, header, pr)
+		// Unblock the handler when the upload ends before the body does.
+		pr.CloseWithError(errUploadEnded)
+		if err != nil {
+			w.cancel()
+		}
+		w.done <- err
+	}()
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.start(http.StatusOK)
+	n, err := w.body.Write(b)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// Flush is a no-op: writes are not buffered.  It lets handlers that stream
+// find an http.Flusher.
+func (w *responseWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.start(http.StatusOK)
+}
+
+// finish ends the body and waits for the upload.
+func (w *responseWriter) finish() error {
+	w.mu.Lock()
+	w.start(http.StatusOK)
+	w.body.Close()
+	w.mu.Unlock()
+	return <-w.done
+}
+
+func (t *Tunnel) request(ctx context.Context, method, target string, body []byte) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	token, err := t.signer.GenerateToken(t.opts.RunnerID, []string{ScopeTunnel}, nil, tokenExpiry)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("X-Runner-ID", t.opts.RunnerID)
+	return r, nil
+}
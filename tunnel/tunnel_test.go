@@ -0,0 +1,245 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepsourcecorp/runner/auth/jwtutil"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deepsource is a local stand-in for the tunnel endpoints of DeepSource.
+type deepsource struct {
+	t        *testing.T
+	verifier *jwtutil.Verifier
+	requests chan *Request
+
+	// release lets the event stream of the test handler end.
+	release chan struct{}
+
+	mu        sync.Mutex
+	responses map[string]*response
+}
+
+// response is a response uploaded by the tunnel, with the body received so
+// far.
+type response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Done   bool
+}
+
+func (d *deepsource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := d.verifier.Verify(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil || r.Header.Get("X-Runner-ID") != "runner-id" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	assert.Equal(d.t, "runner-id", claims["iss"])
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/runner/tunnel/poll":
+		select {
+		case req := <-d.requests:
+			_ = json.NewEncoder(w).Encode(req)
+		case <-time.After(10 * time.Millisecond):
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/api/runner/tunnel/responses/"):
+		res := &response{Header: http.Header{}}
+		res.Status, _ = strconv.Atoi(r.Header.Get(HeaderStatus))
+		require.NoError(d.t, json.Unmarshal([]byte(r.Header.Get(HeaderHeader)), &res.Header))
+		id := strings.TrimPrefix(r.URL.Path, "/api/runner/tunnel/responses/")
+		d.mu.Lock()
+		d.responses[id] = res
+		d.mu.Unlock()
+		buf := make([]byte, 1024)
+		for {
+			n, err := r.Body.Read(buf)
+			d.mu.Lock()
+			res.Body = append(res.Body, buf[:n]...)
+			res.Done = err != nil
+			d.mu.Unlock()
+			if err != nil {
+				break
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// response returns a copy of the response of the request, once done.
+func (d *deepsource) response(id string) *response {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res, ok := d.responses[id]
+	if !ok || !res.Done {
+		return nil
+	}
+	cp := *res
+	return &cp
+}
+
+// body returns the body of the response of the request received so far.
+func (d *deepsource) body(id string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if res, ok := d.responses[id]; ok {
+		return string(res.Body)
+	}
+	return ""
+}
+
+func newTestTunnel(t *testing.T) (*Tunnel, *deepsource) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ds := &deepsource{
+		t:         t,
+		verifier:  jwtutil.NewVerifier(&key.PublicKey),
+		requests:  make(chan *Request, 4),
+		release:   make(chan struct{}),
+		responses: make(map[string]*response),
+	}
+	server := httptest.NewServer(ds)
+	t.Cleanup(server.Close)
+	host, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.POST("/apps/:app_id/tasks/analysis", func(c echo.Context) error {
+		if c.Request().Header.Get("Authorization") != "Bearer deepsource-token" {
+			return c.NoContent(http.StatusUnauthorized)
+		}
+		return c.JSON(http.StatusAccepted, map[string]string{"app_id": c.Param("app_id"), "installation_id": c.Request().Header.Get("X-Installation-ID")})
+	})
+	e.GET("/admin/events", func(c echo.Context) error {
+		res := c.Response()
+		res.Header().Set(echo.HeaderContentType, "text/event-stream")
+		res.WriteHeader(http.StatusOK)
+		fmt.Fprint(res, "data: one\n\n")
+		res.Flush()
+		select {
+		case <-ds.release:
+		case <-c.Request().Context().Done():
+			return nil
+		}
+		fmt.Fprint(res, "data: two\n\n")
+		return nil
+	})
+
+	tunnel, err := New(e, jwtutil.NewSigner(key), server.Client(), &Opts{
+		RunnerID:       "runner-id",
+		DeepSourceHost: *host,
+		Concurrency:    2,
+		PollTimeout:    time.Second,
+		ReconnectDelay: time.Millisecond,
+	})
+	require.NoError(t, err)
+	return tunnel, ds
+}
+
+func TestTunnel_Poll(t *testing.T) {
+	tunnel, ds := newTestTunnel(t)
+
+	t.Run("no request", func(t *testing.T) {
+		assert.NoError(t, tunnel.Poll(context.Background()))
+	})
+
+	t.Run("request", func(t *testing.T) {
+		ds.requests <- &Request{
+			ID:     "req-1",
+			Method: http.MethodPost,
+			Path:   "/apps/app-id/tasks/analysis",
+			Header: http.Header{"Authorization": {"Bearer deepsource-token"}, "X-Installation-Id": {"42"}},
+			Body:   []byte(`{}`),
+		}
+		require.NoError(t, tunnel.Poll(context.Background()))
+
+		res := ds.response("req-1")
+		require.NotNil(t, res)
+		assert.Equal(t, http.StatusAccepted, res.Status)
+		assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, res.Header.Get(echo.HeaderContentType))
+		assert.JSONEq(t, `{"app_id":"app-id","installation_id":"42"}`, string(res.Body))
+	})
+
+	t.Run("unauthorized request", func(t *testing.T) {
+		ds.requests <- &Request{ID: "req-2", Method: http.MethodPost, Path: "/apps/app-id/tasks/analysis"}
+		require.NoError(t, tunnel.Poll(context.Background()))
+		assert.Equal(t, http.StatusUnauthorized, ds.response("req-2").Status)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		ds.requests <- &Request{ID: "req-3", Method: http.MethodGet, Path: "http://example.com/"}
+		require.NoError(t, tunnel.Poll(context.Background()))
+		assert.Equal(t, http.StatusBadRequest, ds.response("req-3").Status)
+	})
+}
+
+func TestTunnel_Start(t *testing.T) {
+	tunnel, ds := newTestTunnel(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tunnel.Start(ctx)
+		close(done)
+	}()
+
+	for _, id := range []string{"a", "b", "c"} {
+		ds.requests <- &Request{ID: id, Method: http.MethodGet, Path: "/missing"}
+	}
+	require.Eventually(t, func() bool {
+		return ds.response("a") != nil && ds.response("b") != nil && ds.response("c") != nil
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, http.StatusNotFound, ds.response("b").Status)
+
+	cancel()
+	<-done
+}
+
+func TestTunnel_PollUnauthorized(t *testing.T) {
+	tunnel, _ := newTestTunnel(t)
+	tunnel.opts.RunnerID = "other-runner"
+	assert.ErrorContains(t, tunnel.Poll(context.Background()), "code=401")
+}
+
+func TestTunnel_Stream(t *testing.T) {
+	tunnel, ds := newTestTunnel(t)
+	ds.requests <- &Request{ID: "events", Method: http.MethodGet, Path: "/admin/events"}
+	done := make(chan error)
+	go func() { done <- tunnel.Poll(context.Background()) }()
+
+	// The first event is received while the handler is still streaming.
+	require.Eventually(t, func() bool { return ds.body("events") == "data: one\n\n" }, 5*time.Second, time.Millisecond)
+	assert.Nil(t, ds.response("events"))
+
+	close(ds.release)
+	require.NoError(t, <-done)
+	res := ds.response("events")
+	require.NotNil(t, res)
+	assert.Equal(t, http.StatusOK, res.Status)
+	assert.Equal(t, "text/event-stream", res.Header.Get(echo.HeaderContentType))
+	assert.Equal(t, "data: one\n\ndata: two\n\n", string(res.Body))
+}
+
+func TestTunnel_PollTooLarge(t *testing.T) {
+	tunnel, ds := newTestTunnel(t)
+	ds.requests <- &Request{ID: "large", Method: http.MethodPost, Path: "/apps/app-id/tasks/analysis", Body: make([]byte, maxRequestSize)}
+	assert.ErrorContains(t, tunnel.Poll(context.Background()), "invalid request")
+}
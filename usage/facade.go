@@ -0,0 +1,46 @@
+package usage
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+var ErrMissingOpts = errors.New("missing required options")
+
+type Router interface {
+	AddRoute(method string, path string, handlerFunc echo.HandlerFunc, middleware ...echo.MiddlewareFunc)
+}
+
+type Opts struct {
+	Store Store
+
+	// JobStatus reads the times of jobs.  Nil when jobs are not run, as
+	// with the printer driver.
+	JobStatus JobStatus
+
+	*MeterOpts
+}
+
+// Facade wires up usage accounting: the meter recording jobs and enforcing
+// quotas, and the usage reports.
+type Facade struct {
+	Handler *Handler
+	Meter   *Meter
+}
+
+func New(opts *Opts) (*Facade, error) {
+	if opts == nil || opts.Store == nil || opts.MeterOpts == nil {
+		return nil, ErrMissingOpts
+	}
+	return &Facade{
+		Handler: NewHandler(opts.Store),
+		Meter:   NewMeter(opts.Store, opts.JobStatus, opts.MeterOpts),
+	}, nil
+}
+
+func (f *Facade) AddRoutes(r Router, adminMiddleware []echo.MiddlewareFunc) Router {
+	r.AddRoute(http.MethodGet, "/admin/usage", f.Handler.HandleReport, adminMiddleware...)
+	return r
+}
@@ -0,0 +1,82 @@
+package usage
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/deepsourcecorp/runner/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+const monthLayout = "2006-01"
+
+var errInvalidFormat = errors.New("format must be json or csv")
+
+var csvHeader = []string{"app_id", "repository", "kind", "jobs", "lost_jobs", "wall_seconds", "cpu_seconds", "memory_gib_seconds"}
+
+type Handler struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store, now: time.Now}
+}
+
+// Report is the usage of a month.
+type Report struct {
+	Month string     `json:"month"`
+	Usage []*Summary `json:"usage"`
+}
+
+// HandleReport returns the usage of the month given as YYYY-MM, the current
+// one by default, as JSON or, with format=csv, as CSV.
+func (h *Handler) HandleReport(c echo.Context) error {
+	from := h.now().UTC()
+	from = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if v := c.QueryParam("month"); v != "" {
+		month, err := time.Parse(monthLayout, v)
+		if err != nil {
+			return httperror.ErrBadRequest(err)
+		}
+		from = month
+	}
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "csv" {
+		return httperror.ErrBadRequest(errInvalidFormat)
+	}
+
+	summaries, err := h.store.Summaries(from, from.AddDate(0, 1, 0))
+	if err != nil {
+		return httperror.ErrUnknown(err)
+	}
+	if summaries == nil {
+		summaries = []*Summary{}
+	}
+	if format != "csv" {
+		return c.JSON(http.StatusOK, &Report{Month: from.Format(monthLayout), Usage: summaries})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="usage-`+from.Format(monthLayout)+`.csv"`)
+	c.Response().WriteHeader(http.StatusOK)
+	w := csv.NewWriter(c.Response())
+	_ = w.Write(csvHeader)
+	for _, s := range summaries {
+		_ = w.Write([]string{
+			s.AppID,
+			s.Repository,
+			s.Kind,
+			strconv.FormatInt(s.Jobs, 10),
+			strconv.FormatInt(s.LostJobs, 10),
+			strconv.FormatInt(s.WallSeconds, 10),
+			strconv.FormatFloat(s.CPUSeconds, 'f', 3, 64),
+			strconv.FormatFloat(s.MemoryGiBSeconds, 'f', 3, 64),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,85 @@
+package usage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_HandleReport(t *testing.T) {
+	store := newMemStore()
+	for i, repo := range []string{"acme/web", "acme/web", "acme/api"} {
+		created := time.Date(2026, 9, 10+i, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, store.Save(&Job{
+			Name:       repo + string(rune('a'+i)),
+			AppID:      "app",
+			Repository: repo,
+			Kind:       KindAnalysis,
+			CPUMillis:  500,
+			Memory:     1 << 30,
+			CreatedAt:  created,
+			StartedAt:  created,
+			FinishedAt: created.Add(time.Minute),
+		}))
+	}
+	lost := time.Date(2026, 9, 20, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Save(&Job{Name: "lost", AppID: "app", Repository: "acme/api", Kind: KindAnalysis, CreatedAt: lost, StartedAt: lost, FinishedAt: lost, Lost: true}))
+	// Jobs of other months are not reported.
+	require.NoError(t, store.Save(&Job{Name: "october", AppID: "app", Repository: "acme/web", CreatedAt: testNow, StartedAt: testNow, FinishedAt: testNow.Add(time.Hour)}))
+
+	h := NewHandler(store)
+	h.now = func() time.Time { return testNow }
+	serve := func(query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/admin/usage?"+query, nil), rec)
+		require.NoError(t, h.HandleReport(c))
+		return rec
+	}
+
+	t.Run("json", func(t *testing.T) {
+		rec := serve("month=2026-09")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var report Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		assert.Equal(t, "2026-09", report.Month)
+		require.Len(t, report.Usage, 2)
+		assert.Equal(t, &Summary{
+			AppID:            "app",
+			Repository:       "acme/web",
+			Kind:             KindAnalysis,
+			Jobs:             2,
+			WallSeconds:      120,
+			CPUSeconds:       60,
+			MemoryGiBSeconds: 120,
+		}, report.Usage[1])
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		rec := serve("month=2026-09&format=csv")
+		assert.Equal(t, "text/csv", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "app_id,repository,kind,jobs,lost_jobs,wall_seconds,cpu_seconds,memory_gib_seconds\n"+
+			"app,acme/api,analysis,1,1,60,30.000,60.000\n"+
+			"app,acme/web,analysis,2,0,120,60.000,120.000\n", rec.Body.String())
+	})
+
+	t.Run("current month", func(t *testing.T) {
+		var report Report
+		require.NoError(t, json.Unmarshal(serve("").Body.Bytes(), &report))
+		assert.Equal(t, "2026-10", report.Month)
+		require.Len(t, report.Usage, 1)
+		assert.Equal(t, int64(3600), report.Usage[0].WallSeconds)
+	})
+
+	t.Run("invalid month", func(t *testing.T) {
+		e := echo.New()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/admin/usage?month=september", nil), httptest.NewRecorder())
+		assert.Error(t, h.HandleReport(c))
+	})
+}
@@ -0,0 +1,201 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	DefaultSyncInterval = time.Minute
+
+	// DefaultLostAfter is how long after its creation a job that no longer
+	// exists is recorded as lost, with no usage.
+	DefaultLostAfter = 2 * time.Hour
+
+	// DefaultMaxJobDuration is the usage recorded at most for a job.  Jobs
+	// are killed at their deadline well before.
+	DefaultMaxJobDuration = 10 * time.Minute
+)
+
+var ErrQuotaExceeded = errors.New("usage: job-minute quota exceeded")
+
+// QuotaError is returned for apps over one of their job-minute quotas.
+// ResetsAt is when the period of the quota ends.
+type QuotaError struct {
+	AppID        string
+	Period       string
+	UsedMinutes  int64
+	LimitMinutes int64
+	ResetsAt     time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("app %s exceeded its %s quota of %d job-minutes (%d used)", e.AppID, e.Period, e.LimitMinutes, e.UsedMinutes)
+}
+
+func (*QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Limits are the job-minute quotas of an app.  Zero is unlimited.
+type Limits struct {
+	DailyMinutes   int64
+	MonthlyMinutes int64
+}
+
+// JobStatus looks up the times of jobs.  Finished is zero while the job
+// runs, and found is false once the job is deleted.
+type JobStatus interface {
+	JobTimes(ctx context.Context, name string) (started, finished time.Time, found bool, err error)
+}
+
+type MeterOpts struct {
+	// Limits apply to apps without AppLimits.  Nil is unlimited.
+	Limits    *Limits
+	AppLimits map[string]*Limits
+
+	SyncInterval time.Duration
+	LostAfter    time.Duration
+
+	// MaxJobDuration caps the recorded usage of jobs, whose end is not
+	// known without JobStatus or when their status is stale.  Defaults to
+	// DefaultMaxJobDuration.
+	MaxJobDuration time.Duration
+}
+
+// Meter records the usage of jobs and enforces the job-minute quotas of
+// apps.  Jobs are recorded when they are triggered, and their start and
+// finish times are filled in from JobStatus once they finish.  Without
+// JobStatus, jobs are closed at MaxJobDuration.
+type Meter struct {
+	store  Store
+	status JobStatus
+	opts   *MeterOpts
+	now    func() time.Time
+}
+
+func NewMeter(store Store, status JobStatus, opts *MeterOpts) *Meter {
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = DefaultSyncInterval
+	}
+	if opts.LostAfter <= 0 {
+		opts.LostAfter = DefaultLostAfter
+	}
+	if opts.MaxJobDuration <= 0 {
+		opts.MaxJobDuration = DefaultMaxJobDuration
+	}
+	return &Meter{
+		store:  store,
+		status: status,
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// Check returns a QuotaError when the app used up its daily or monthly
+// job-minutes.  Days and months are in UTC.
+func (m *Meter) Check(_ context.Context, appID string) error {
+	limits := m.opts.Limits
+	if l, ok := m.opts.AppLimits[appID]; ok {
+		limits = l
+	}
+	if limits == nil {
+		return nil
+	}
+	now := m.now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	periods := []struct {
+		name  string
+		limit int64
+		since time.Time
+		until time.Time
+	}{
+		{"daily", limits.DailyMinutes, day, day.AddDate(0, 0, 1)},
+		{"monthly", limits.MonthlyMinutes, month, month.AddDate(0, 1, 0)},
+	}
+	for _, p := range periods {
+		if p.limit <= 0 {
+			continue
+		}
+		seconds, err := m.store.JobSeconds(appID, p.since, now, m.opts.MaxJobDuration)
+		if err != nil {
+			return err
+		}
+		if used := seconds / 60; used >= p.limit {
+			return &QuotaError{AppID: appID, Period: p.name, UsedMinutes: used, LimitMinutes: p.limit, ResetsAt: p.until}
+		}
+	}
+	return nil
+}
+
+// Track records a triggered job.
+func (m *Meter) Track(_ context.Context, j *Job) error {
+	if j.CreatedAt.IsZero() {
+		j.CreatedAt = m.now()
+	}
+	return m.store.Save(j)
+}
+
+// Start records the times of finished jobs until the context is cancelled.
+func (m *Meter) Start(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Sync(ctx); err != nil {
+				slog.Error("usage: failed to sync job times", slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// Sync records the times of the pending jobs that finished.  Jobs deleted
+// before their times could be read are recorded as lost after LostAfter.
+// Jobs still open after MaxJobDuration, as when there is no JobStatus, are
+// closed with MaxJobDuration of usage.
+func (m *Meter) Sync(ctx context.Context) error {
+	jobs, err := m.store.Pending()
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	for _, j := range jobs {
+		var (
+			started, finished time.Time
+			found             bool
+		)
+		if m.status != nil {
+			started, finished, found, err = m.status.JobTimes(ctx, j.Name)
+			if err != nil {
+				slog.Error("usage: failed to read job times", slog.String("job", j.Name), slog.Any("err", err))
+				continue
+			}
+		}
+		switch {
+		case m.status != nil && !found && now.Sub(j.CreatedAt) > m.opts.LostAfter:
+			slog.Warn("usage: job lost before it was accounted", slog.String("job", j.Name))
+			if err := m.store.Lose(j.Name, j.CreatedAt); err != nil {
+				return err
+			}
+			continue
+		case finished.IsZero() && now.Sub(j.CreatedAt) > m.opts.MaxJobDuration && (m.status == nil || found):
+			started, finished = j.CreatedAt, j.CreatedAt.Add(m.opts.MaxJobDuration)
+		case finished.IsZero():
+			continue
+		case started.IsZero():
+			started = finished
+		}
+		if err := m.store.Finish(j.Name, started, finished); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package usage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func newMemStore() *memStore {
+	return &memStore{jobs: make(map[string]*Job)}
+}
+
+func (s *memStore) Save(j *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *j
+	s.jobs[j.Name] = &cp
+	return nil
+}
+
+func (s *memStore) Finish(name string, startedAt, finishedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[name]; ok {
+		j.StartedAt, j.FinishedAt = startedAt, finishedAt
+	}
+	return nil
+}
+
+func (s *memStore) Lose(name string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[name]; ok {
+		j.StartedAt, j.FinishedAt, j.Lost = at, at, true
+	}
+	return nil
+}
+
+func (s *memStore) Pending() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []*Job
+	for _, j := range s.jobs {
+		if j.FinishedAt.IsZero() {
+			cp := *j
+			jobs = append(jobs, &cp)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *memStore) Summaries(from, to time.Time) ([]*Summary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byKey := make(map[[3]string]*Summary)
+	for _, j := range s.jobs {
+		if j.FinishedAt.IsZero() || j.CreatedAt.Before(from) || !j.CreatedAt.Before(to) {
+			continue
+		}
+		key := [3]string{j.AppID, j.Repository, j.Kind}
+		sum, ok := byKey[key]
+		if !ok {
+			sum = &Summary{AppID: j.AppID, Repository: j.Repository, Kind: j.Kind}
+			byKey[key] = sum
+		}
+		if j.Lost {
+			sum.LostJobs++
+			continue
+		}
+		seconds := int64(j.FinishedAt.Sub(j.StartedAt).Seconds())
+		sum.Jobs++
+		sum.WallSeconds += seconds
+		sum.CPUSeconds += float64(seconds*j.CPUMillis) / 1000
+		sum.MemoryGiBSeconds += float64(seconds*j.Memory) / (1 << 30)
+	}
+	var summaries []*Summary
+	for _, sum := range byKey {
+		summaries = append(summaries, sum)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Repository < summaries[j].Repository })
+	return summaries, nil
+}
+
+func (s *memStore) JobSeconds(appID string, since, now time.Time, maxOpen time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var seconds int64
+	for _, j := range s.jobs {
+		if j.AppID != appID || j.CreatedAt.Before(since) {
+			continue
+		}
+		if j.FinishedAt.IsZero() {
+			open := now.Sub(j.CreatedAt)
+			if open > maxOpen {
+				open = maxOpen
+			}
+			seconds += int64(open.Seconds())
+		} else {
+			seconds += int64(j.FinishedAt.Sub(j.StartedAt).Seconds())
+		}
+	}
+	return seconds, nil
+}
+
+type jobTimes struct {
+	started, finished time.Time
+}
+
+type fakeJobStatus map[string]*jobTimes
+
+func (s fakeJobStatus) JobTimes(_ context.Context, name string) (started, finished time.Time, found bool, err error) {
+	t, ok := s[name]
+	if !ok {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	return t.started, t.finished, true, nil
+}
+
+var testNow = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func TestMeter_Check(t *testing.T) {
+	store := newMemStore()
+	meter := NewMeter(store, nil, &MeterOpts{
+		Limits:    &Limits{DailyMinutes: 60},
+		AppLimits: map[string]*Limits{"big": {MonthlyMinutes: 600}, "free": nil},
+	})
+	meter.now = func() time.Time { return testNow }
+
+	save := func(name, appID string, created time.Time, minutes int) {
+		require.NoError(t, store.Save(&Job{
+			Name:       name,
+			AppID:      appID,
+			CreatedAt:  created,
+			StartedAt:  created,
+			FinishedAt: created.Add(time.Duration(minutes) * time.Minute),
+		}))
+	}
+	save("yesterday", "app", testNow.AddDate(0, 0, -1), 120)
+	save("today", "app", testNow.Add(-2*time.Hour), 50)
+	assert.NoError(t, meter.Check(context.Background(), "app"))
+
+	// Running jobs count until now.
+	require.NoError(t, store.Save(&Job{Name: "running", AppID: "app", CreatedAt: testNow.Add(-10 * time.Minute)}))
+	err := meter.Check(context.Background(), "app")
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.EqualError(t, err, "app app exceeded its daily quota of 60 job-minutes (60 used)")
+	var quotaErr *QuotaError
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), quotaErr.ResetsAt)
+
+	save("big-last-month", "big", testNow.AddDate(0, -1, 0), 1000)
+	save("big-this-month", "big", testNow.AddDate(0, 0, -10), 599)
+	assert.NoError(t, meter.Check(context.Background(), "big"))
+	save("big-today", "big", testNow.Add(-time.Hour), 1)
+	require.ErrorAs(t, meter.Check(context.Background(), "big"), &quotaErr)
+	assert.Equal(t, "monthly", quotaErr.Period)
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), quotaErr.ResetsAt)
+
+	save("free", "free", testNow.Add(-time.Hour), 1000)
+	assert.NoError(t, meter.Check(context.Background(), "free"))
+}
+
+func TestMeter_Sync(t *testing.T) {
+	store := newMemStore()
+	started := testNow.Add(-4 * time.Minute)
+	status := fakeJobStatus{
+		"finished": {started: started, finished: started.Add(3 * time.Minute)},
+		"running":  {started: started},
+		"stuck":    {started: testNow.Add(-time.Hour)},
+	}
+	meter := NewMeter(store, status, &MeterOpts{})
+	meter.now = func() time.Time { return testNow }
+
+	created := map[string]time.Time{
+		"finished": testNow.Add(-5 * time.Minute),
+		"running":  testNow.Add(-5 * time.Minute),
+		"stuck":    testNow.Add(-time.Hour),
+		"deleted":  testNow.Add(-15 * time.Minute),
+		"lost":     testNow.Add(-DefaultLostAfter - time.Minute),
+	}
+	for name, at := range created {
+		require.NoError(t, meter.Track(context.Background(), &Job{Name: name, AppID: "app", CreatedAt: at}))
+	}
+	require.NoError(t, meter.Sync(context.Background()))
+
+	assert.Equal(t, started.Add(3*time.Minute), store.jobs["finished"].FinishedAt)
+	assert.True(t, store.jobs["running"].FinishedAt.IsZero())
+	assert.Equal(t, DefaultMaxJobDuration, store.jobs["stuck"].FinishedAt.Sub(store.jobs["stuck"].StartedAt), "jobs are capped at MaxJobDuration")
+	assert.True(t, store.jobs["deleted"].FinishedAt.IsZero(), "deleted jobs are only lost after LostAfter")
+	assert.True(t, store.jobs["lost"].Lost)
+	assert.Equal(t, store.jobs["lost"].CreatedAt, store.jobs["lost"].FinishedAt)
+	assert.Equal(t, store.jobs["lost"].StartedAt, store.jobs["lost"].FinishedAt)
+	assert.False(t, store.jobs["finished"].Lost)
+
+	// Open jobs count for MaxJobDuration at most.
+	seconds, err := store.JobSeconds("app", testNow.AddDate(0, 0, -1), testNow, DefaultMaxJobDuration)
+	require.NoError(t, err)
+	assert.Equal(t, int64((3+5+10+10)*60), seconds)
+}
+
+func TestMeter_Sync_WithoutStatus(t *testing.T) {
+	store := newMemStore()
+	meter := NewMeter(store, nil, &MeterOpts{MaxJobDuration: 5 * time.Minute})
+	meter.now = func() time.Time { return testNow }
+
+	require.NoError(t, meter.Track(context.Background(), &Job{Name: "recent", AppID: "app", CreatedAt: testNow.Add(-time.Minute)}))
+	require.NoError(t, meter.Track(context.Background(), &Job{Name: "old", AppID: "app", CreatedAt: testNow.Add(-time.Hour)}))
+	require.NoError(t, meter.Sync(context.Background()))
+
+	assert.True(t, store.jobs["recent"].FinishedAt.IsZero())
+	assert.Equal(t, testNow.Add(-55*time.Minute), store.jobs["old"].FinishedAt)
+	assert.False(t, store.jobs["old"].Lost)
+}
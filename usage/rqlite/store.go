@@ -0,0 +1,193 @@
+package rqlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/deepsourcecorp/runner/usage"
+	"github.com/rqlite/gorqlite"
+)
+
+var tableName = "usage_jobs"
+
+var columns = []string{"name", "app_id", "repository", "kind", "cpu_millis", "memory", "created_at", "started_at", "finished_at", "lost"}
+
+type Store struct {
+	db *gorqlite.Connection
+}
+
+func New(db *gorqlite.Connection) usage.Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Save(j *usage.Job) error {
+	builder := squirrel.Insert(tableName).
+		Columns(columns...).
+		Values(
+			j.Name,
+			j.AppID,
+			j.Repository,
+			j.Kind,
+			j.CPUMillis,
+			j.Memory,
+			j.CreatedAt.Unix(),
+			unix(j.StartedAt),
+			unix(j.FinishedAt),
+			flag(j.Lost),
+		)
+	return s.write(builder)
+}
+
+func (s *Store) Finish(name string, startedAt, finishedAt time.Time) error {
+	builder := squirrel.Update(tableName).
+		Set("started_at", startedAt.Unix()).
+		Set("finished_at", finishedAt.Unix()).
+		Where(squirrel.Eq{"name": name})
+	return s.write(builder)
+}
+
+func (s *Store) Lose(name string, at time.Time) error {
+	builder := squirrel.Update(tableName).
+		Set("started_at", at.Unix()).
+		Set("finished_at", at.Unix()).
+		Set("lost", 1).
+		Where(squirrel.Eq{"name": name})
+	return s.write(builder)
+}
+
+func (s *Store) Pending() ([]*usage.Job, error) {
+	builder := squirrel.Select(columns...).
+		From(tableName).
+		Where(squirrel.Eq{"finished_at": 0}).
+		OrderBy("created_at")
+	rows, err := s.query(builder)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*usage.Job
+	for rows.Next() {
+		var (
+			j                                usage.Job
+			createdAt, startedAt, finishedAt int64
+		)
+		err := rows.Scan(&j.Name, &j.AppID, &j.Repository, &j.Kind, &j.CPUMillis, &j.Memory, &createdAt, &startedAt, &finishedAt, &j.Lost)
+		if err != nil {
+			return nil, fmt.Errorf("usage/rqlite: failed to scan row: %w", err)
+		}
+		j.CreatedAt = time.Unix(createdAt, 0)
+		jobs = append(jobs, &j)
+	}
+	return jobs, nil
+}
+
+func (s *Store) Summaries(from, to time.Time) ([]*usage.Summary, error) {
+	builder := squirrel.Select(
+		"app_id",
+		"repository",
+		"kind",
+		"COUNT(*) - SUM(lost)",
+		"SUM(lost)",
+		"SUM(finished_at - started_at)",
+		"SUM((finished_at - started_at) * cpu_millis)",
+		"SUM((finished_at - started_at) * memory)",
+	).
+		From(tableName).
+		Where(squirrel.Gt{"finished_at": 0}).
+		Where(squirrel.GtOrEq{"created_at": from.Unix()}).
+		Where(squirrel.Lt{"created_at": to.Unix()}).
+		GroupBy("app_id", "repository", "kind").
+		OrderBy("app_id", "repository", "kind")
+	rows, err := s.query(builder)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []*usage.Summary
+	for rows.Next() {
+		var (
+			sum                 usage.Summary
+			cpuMillis, memBytes int64
+		)
+		err := rows.Scan(&sum.AppID, &sum.Repository, &sum.Kind, &sum.Jobs, &sum.LostJobs, &sum.WallSeconds, &cpuMillis, &memBytes)
+		if err != nil {
+			return nil, fmt.Errorf("usage/rqlite: failed to scan row: %w", err)
+		}
+		sum.CPUSeconds = float64(cpuMillis) / 1000
+		sum.MemoryGiBSeconds = float64(memBytes) / (1 << 30)
+		summaries = append(summaries, &sum)
+	}
+	return summaries, nil
+}
+
+func (s *Store) JobSeconds(appID string, since, now time.Time, maxOpen time.Duration) (int64, error) {
+	builder := squirrel.Select().
+		Column(squirrel.Expr("COALESCE(SUM(CASE WHEN finished_at > 0 THEN finished_at - started_at ELSE MIN(? - created_at, ?) END), 0)", now.Unix(), int64(maxOpen.Seconds()))).
+		From(tableName).
+		Where(squirrel.Eq{"app_id": appID}).
+		Where(squirrel.GtOrEq{"created_at": since.Unix()})
+	rows, err := s.query(builder)
+	if err != nil {
+		return 0, err
+	}
+	var seconds int64
+	if rows.Next() {
+		if err := rows.Scan(&seconds); err != nil {
+			return 0, fmt.Errorf("usage/rqlite: failed to scan row: %w", err)
+		}
+	}
+	return seconds, nil
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func flag(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type sqlizer interface {
+	ToSql() (string, []interface{}, error)
+}
+
+func (s *Store) write(builder sqlizer) error {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return fmt.Errorf("usage/rqlite: failed to build query: %w", err)
+	}
+	_, err = s.db.WriteOneParameterized(
+		gorqlite.ParameterizedStatement{
+			Query:     query,
+			Arguments: args,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("usage/rqlite: failed to write to rqlite: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) query(builder squirrel.SelectBuilder) (gorqlite.QueryResult, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return gorqlite.QueryResult{}, fmt.Errorf("usage/rqlite: failed to build query: %w", err)
+	}
+	rows, err := s.db.QueryOneParameterized(
+		gorqlite.ParameterizedStatement{
+			Query:     query,
+			Arguments: args,
+		},
+	)
+	if err != nil {
+		return gorqlite.QueryResult{}, fmt.Errorf("usage/rqlite: failed to query rqlite: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,63 @@
+package usage
+
+import (
+	"time"
+)
+
+const (
+	KindAnalysis    = "analysis"
+	KindAutofix     = "autofix"
+	KindTransformer = "transformer"
+	KindPatcher     = "patcher"
+)
+
+// Job is the usage of a job the runner triggered.  StartedAt and FinishedAt
+// are zero until the job finished.  Lost jobs were deleted before their times
+// could be read, and have no usage.
+type Job struct {
+	Name       string    `json:"name"`
+	AppID      string    `json:"app_id"`
+	Repository string    `json:"repository"`
+	Kind       string    `json:"kind"`
+	CPUMillis  int64     `json:"cpu_millis"`
+	Memory     int64     `json:"memory"`
+	CreatedAt  time.Time `json:"created_at"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Lost       bool      `json:"lost"`
+}
+
+// Summary is the usage of the finished jobs of a kind for an app and
+// repository.  CPU and memory are the requested resources multiplied by the
+// wall-clock time of the jobs.  Lost jobs are counted apart, since their
+// usage is unknown.
+type Summary struct {
+	AppID            string  `json:"app_id"`
+	Repository       string  `json:"repository"`
+	Kind             string  `json:"kind"`
+	Jobs             int64   `json:"jobs"`
+	LostJobs         int64   `json:"lost_jobs"`
+	WallSeconds      int64   `json:"wall_seconds"`
+	CPUSeconds       float64 `json:"cpu_seconds"`
+	MemoryGiBSeconds float64 `json:"memory_gib_seconds"`
+}
+
+// Store persists job usage.
+type Store interface {
+	Save(j *Job) error
+	Finish(name string, startedAt, finishedAt time.Time) error
+
+	// Lose closes the record of a job whose times are unknown.
+	Lose(name string, at time.Time) error
+
+	// Pending returns the jobs that did not finish yet.
+	Pending() ([]*Job, error)
+
+	// Summaries returns the usage of the jobs created in [from, to).
+	Summaries(from, to time.Time) ([]*Summary, error)
+
+	// JobSeconds returns the wall-clock seconds of the jobs of the app
+	// created since the given time.  Jobs that did not finish count until
+	// now, for at most maxOpen.
+	JobSeconds(appID string, since, now time.Time, maxOpen time.Duration) (int64, error)
+}